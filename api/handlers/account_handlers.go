@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+)
+
+// AccountHandler handles requests for a user's personal data export and account deletion
+type AccountHandler struct {
+	accountService *services.AccountService
+}
+
+// NewAccountHandler creates a new AccountHandler
+func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// HandleDataExport handles requests to download all of the authenticated user's data
+// @Summary Export personal data
+// @Description Download a zip archive of the authenticated user's profile, devices and entity tree as JSON, with a CSV of each device's recent sensor readings. Sensor data covers a limited window and is capped per device; manifest.json in the archive records the window and which devices were truncated.
+// @Tags User Management
+// @Produce application/zip
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {file} binary "Data export archive"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/data-export [get]
+func (h *AccountHandler) HandleDataExport(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	data, err := h.accountService.PrepareDataExport(c.Request.Context(), userID)
+	if err != nil {
+		handleAccountError(c, err, "Failed to export personal data")
+		return
+	}
+
+	filename := fmt.Sprintf("zolaris-data-export_%s_%s.zip", userID, data.GeneratedAt.Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent once streaming starts, so failures can only be logged
+	if err := h.accountService.StreamDataExport(c.Request.Context(), data, c.Writer); err != nil {
+		log.Printf("Error streaming data export for user %s: %v", userID, err)
+		c.Abort()
+		return
+	}
+
+	log.Printf("Exported personal data for user %s", userID)
+}
+
+// HandleDeleteAccount handles requests to delete the authenticated user's account
+// @Summary Delete account
+// @Description Schedule the authenticated user's account to be erased once the grace period has passed. The account keeps working until then and the deletion can be cancelled. Erasure removes the profile, devices, entity tree and export files; entities of other users are moved out of the tree and child accounts become standalone. Only a tombstone with a hash of the email is kept. Asking again keeps the original schedule. Admins must give up their role first.
+// @Tags User Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 202 {object} dto.Response{data=dto.AccountDeletionResponse} "Account deletion scheduled"
+// @Failure 400 {object} dto.ErrorResponse "Admins cannot delete their own account"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user [delete]
+func (h *AccountHandler) HandleDeleteAccount(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	deletion, err := h.accountService.ScheduleDeletion(c.Request.Context(), userID)
+	if err != nil {
+		handleAccountError(c, err, "Failed to schedule account deletion")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, deletion, "Account deletion scheduled")
+}
+
+// HandleGetDeletion handles requests for the authenticated user's pending account deletion
+// @Summary Get account deletion
+// @Description Get when the authenticated user asked for their account to be deleted and when it will be erased.
+// @Tags User Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=dto.AccountDeletionResponse} "Account deletion retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Account deletion is not scheduled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/deletion [get]
+func (h *AccountHandler) HandleGetDeletion(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	deletion, err := h.accountService.GetDeletion(c.Request.Context(), userID)
+	if err != nil {
+		handleAccountError(c, err, "Failed to retrieve account deletion")
+		return
+	}
+
+	response.OK(c, deletion, "Account deletion retrieved successfully")
+}
+
+// HandleCancelDeletion handles requests to cancel the authenticated user's pending account deletion
+// @Summary Cancel account deletion
+// @Description Cancel a pending account deletion before its grace period ends.
+// @Tags User Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response "Account deletion cancelled"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Account deletion is not scheduled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/deletion/cancel [post]
+func (h *AccountHandler) HandleCancelDeletion(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.accountService.CancelDeletion(c.Request.Context(), userID); err != nil {
+		handleAccountError(c, err, "Failed to cancel account deletion")
+		return
+	}
+
+	response.OK(c, nil, "Account deletion cancelled")
+}
+
+// handleAccountError maps account service errors to HTTP responses
+func handleAccountError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		response.NotFound(c, "User not found")
+	case errors.Is(err, services.ErrDeletionNotScheduled):
+		response.NotFound(c, "Account deletion is not scheduled")
+	case errors.Is(err, services.ErrSelfDeletion):
+		response.BadRequest(c, err.Error())
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
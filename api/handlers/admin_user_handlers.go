@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// AdminUserHandler handles requests from admins managing user accounts
+type AdminUserHandler struct {
+	adminUserService *services.AdminUserService
+}
+
+// NewAdminUserHandler creates a new AdminUserHandler
+func NewAdminUserHandler(adminUserService *services.AdminUserService) *AdminUserHandler {
+	return &AdminUserHandler{adminUserService: adminUserService}
+}
+
+// HandleSearchUsers handles requests to list and search user accounts
+// @Summary Search users
+// @Description List user accounts, newest first, optionally matching an email, name or user ID and filtered by role and status. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param q query string false "Text to match against email, name or user ID"
+// @Param role query string false "Role filter" Enums(admin, user)
+// @Param status query string false "Status filter" Enums(active, disabled)
+// @Param page query int false "Zero-based page number"
+// @Param pageSize query int false "Users per page (default 20, max 100)"
+// @Success 200 {object} dto.Response{data=dto.PaginatedResponse{items=[]dto.AdminUserResponse}} "Users retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid query parameters"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/users [get]
+func (h *AdminUserHandler) HandleSearchUsers(c *gin.Context) {
+	var request dto.AdminUserSearchRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	result, err := h.adminUserService.SearchUsers(c.Request.Context(), request)
+	if err != nil {
+		handleAdminUserError(c, err, "Failed to search users")
+		return
+	}
+
+	response.Paginated(c, result.Items, result.TotalItems, result.Page, result.PageSize)
+}
+
+// HandleGetUser handles requests for a user account with everything it owns
+// @Summary Get user details
+// @Description Get a user account with its role, devices, entity tree and direct child accounts. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} dto.Response{data=dto.AdminUserDetailResponse} "User retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/users/{user_id} [get]
+func (h *AdminUserHandler) HandleGetUser(c *gin.Context) {
+	user, err := h.adminUserService.GetUserDetail(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		handleAdminUserError(c, err, "Failed to retrieve user")
+		return
+	}
+
+	response.OK(c, user, "User retrieved successfully")
+}
+
+// HandleDisableUser handles requests to disable a user account
+// @Summary Disable a user
+// @Description Disable a user account. The user keeps their data but every request they make is refused until the account is enabled again. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} dto.Response "User disabled successfully"
+// @Failure 400 {object} dto.ErrorResponse "Admins cannot disable themselves"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/users/{user_id}/disable [post]
+func (h *AdminUserHandler) HandleDisableUser(c *gin.Context) {
+	h.handleSetDisabled(c, true, "User disabled successfully")
+}
+
+// HandleEnableUser handles requests to re-enable a disabled user account
+// @Summary Enable a user
+// @Description Re-enable a disabled user account. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} dto.Response "User enabled successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/users/{user_id}/enable [post]
+func (h *AdminUserHandler) HandleEnableUser(c *gin.Context) {
+	h.handleSetDisabled(c, false, "User enabled successfully")
+}
+
+// handleSetDisabled disables or re-enables the user named in the path
+func (h *AdminUserHandler) handleSetDisabled(c *gin.Context, disabled bool, message string) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.adminUserService.SetUserDisabled(c.Request.Context(), userID, c.Param("user_id"), disabled); err != nil {
+		handleAdminUserError(c, err, "Failed to update user")
+		return
+	}
+
+	response.OK(c, nil, message)
+}
+
+// HandleUpdateUserRole handles requests to change a user's role
+// @Summary Change a user's role
+// @Description Grant or revoke the admin role. Admins cannot revoke their own. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "User ID"
+// @Param request body dto.UpdateUserRoleRequest true "New role"
+// @Success 200 {object} dto.Response "User role updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error or admins cannot demote themselves"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/users/{user_id}/role [put]
+func (h *AdminUserHandler) HandleUpdateUserRole(c *gin.Context) {
+	// Parse request body
+	var request dto.UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.adminUserService.SetUserRole(c.Request.Context(), userID, c.Param("user_id"), domain.UserRole(request.Role)); err != nil {
+		handleAdminUserError(c, err, "Failed to update user role")
+		return
+	}
+
+	response.OK(c, nil, "User role updated successfully")
+}
+
+// HandleListImpersonations handles requests for the impersonation log
+// @Summary List impersonated requests
+// @Description List the requests admins made while impersonating users with the X-Impersonate-User header, newest first. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param adminId query string false "Only requests by this admin"
+// @Param userId query string false "Only requests impersonating this user"
+// @Param page query int false "Zero-based page number"
+// @Param pageSize query int false "Entries per page (default 20, max 100)"
+// @Success 200 {object} dto.Response{data=dto.PaginatedResponse{items=[]dto.ImpersonationLogResponse}} "Impersonation log retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid query parameters"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/impersonations [get]
+func (h *AdminUserHandler) HandleListImpersonations(c *gin.Context) {
+	var request dto.ImpersonationLogRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	result, err := h.adminUserService.ListImpersonations(c.Request.Context(), request)
+	if err != nil {
+		handleAdminUserError(c, err, "Failed to retrieve impersonation log")
+		return
+	}
+
+	response.Paginated(c, result.Items, result.TotalItems, result.Page, result.PageSize)
+}
+
+// HandleGetIdentityCacheStats handles requests for the identity cache's hit and miss counters
+// @Summary Get identity cache statistics
+// @Description Get how often this instance resolved the signed-in user from its identity cache rather than the database since it started. Counters are per instance. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=dto.IdentityCacheStatsResponse} "Identity cache statistics retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Security ApiKeyAuth
+// @Router /admin/identity-cache/stats [get]
+func (h *AdminUserHandler) HandleGetIdentityCacheStats(c *gin.Context) {
+	response.OK(c, h.adminUserService.GetIdentityCacheStats(), "Identity cache statistics retrieved successfully")
+}
+
+// handleAdminUserError maps admin user management errors to HTTP responses
+func handleAdminUserError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		response.NotFound(c, "User not found")
+	case errors.Is(err, services.ErrSelfLockout):
+		response.BadRequest(c, err.Error())
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
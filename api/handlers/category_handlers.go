@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"log"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 
@@ -31,7 +30,7 @@ func NewAddCategoryHandler(categoryService *services.CategoryService) *AddCatego
 // @Param category body dto.CategoryRequest true "Category information"
 // @Success 201 {object} dto.Response "Category added successfully"
 // @Failure 400 {object} dto.ErrorResponse "Validation error"
-// @Failure 409 {object} dto.ErrorResponse "Category already exists"
+// @Failure 409 {object} dto.ErrorResponse "Category with this name and type already exists"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /category/add [post]
 func (h *AddCategoryHandler) HandleGin(c *gin.Context) {
@@ -53,12 +52,7 @@ func (h *AddCategoryHandler) HandleGin(c *gin.Context) {
 
 	// Call service to add category
 	if err := h.categoryService.AddCategory(c.Request.Context(), request.Name, request.Type); err != nil {
-		if err.Error() == "category with this name already exists" {
-			response.Error(c, http.StatusConflict, "Category with this name already exists", "CONFLICT")
-			return
-		}
-		log.Printf("Error adding category: %v", err)
-		response.InternalError(c, "Failed to add category")
+		handleCategoryError(c, err, "Failed to add category")
 		return
 	}
 
@@ -121,7 +115,7 @@ func NewListAllCategoriesHandler(categoryService *services.CategoryService) *Lis
 
 // HandleGin handles requests using Gin framework
 // @Summary Get all categories
-// @Description Retrieve all categories
+// @Description Retrieve all categories with the number of entities using each
 // @Tags Category Management
 // @Produce json
 // @Success 200 {array} dto.CategoryResponse "List of categories"
@@ -144,3 +138,193 @@ func (h *ListAllCategoriesHandler) HandleGin(c *gin.Context) {
 	response.OK(c, categories, "Categories retrieved successfully")
 }
 
+// UpdateCategoryHandler handles requests to update a category
+type UpdateCategoryHandler struct {
+	categoryService *services.CategoryService
+}
+
+// NewUpdateCategoryHandler creates a new UpdateCategoryHandler
+func NewUpdateCategoryHandler(categoryService *services.CategoryService) *UpdateCategoryHandler {
+	return &UpdateCategoryHandler{categoryService: categoryService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary Update a category
+// @Description Rename a category and/or change its type. The type cannot change while entities use the category. Admin only.
+// @Tags Category Management
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param category_id path string true "Category ID"
+// @Param category body dto.UpdateCategoryRequest true "Category changes"
+// @Success 200 {object} dto.Response{data=dto.CategoryResponse} "Category updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Category not found"
+// @Failure 409 {object} dto.ErrorResponse "Duplicate category or category in use"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /category/{category_id} [put]
+func (h *UpdateCategoryHandler) HandleGin(c *gin.Context) {
+	// Parse request body
+	var request dto.UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	category, err := h.categoryService.UpdateCategory(c.Request.Context(), c.Param("category_id"), request.Name, request.Type)
+	if err != nil {
+		handleCategoryError(c, err, "Failed to update category")
+		return
+	}
+
+	response.OK(c, category, "Category updated successfully")
+}
+
+// DeleteCategoryHandler handles requests to delete a category
+type DeleteCategoryHandler struct {
+	categoryService *services.CategoryService
+}
+
+// NewDeleteCategoryHandler creates a new DeleteCategoryHandler
+func NewDeleteCategoryHandler(categoryService *services.CategoryService) *DeleteCategoryHandler {
+	return &DeleteCategoryHandler{categoryService: categoryService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary Delete a category
+// @Description Delete a category. If entities use it, a replacement category of the same type must be given and the entities are moved to it first. Admin only.
+// @Tags Category Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param category_id path string true "Category ID"
+// @Param replacementCategoryId query string false "Category to move the deleted category's entities to"
+// @Success 200 {object} dto.Response{data=dto.CategoryMergeResponse} "Category deleted successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Category not found"
+// @Failure 409 {object} dto.ErrorResponse "Category is used by entities"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /category/{category_id} [delete]
+func (h *DeleteCategoryHandler) HandleGin(c *gin.Context) {
+	var request dto.DeleteCategoryRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	result, err := h.categoryService.DeleteCategory(c.Request.Context(), c.Param("category_id"), request.ReplacementCategoryID)
+	if err != nil {
+		handleCategoryError(c, err, "Failed to delete category")
+		return
+	}
+
+	response.OK(c, result, "Category deleted successfully")
+}
+
+// MergeCategoryHandler handles requests to merge a category into another
+type MergeCategoryHandler struct {
+	categoryService *services.CategoryService
+}
+
+// NewMergeCategoryHandler creates a new MergeCategoryHandler
+func NewMergeCategoryHandler(categoryService *services.CategoryService) *MergeCategoryHandler {
+	return &MergeCategoryHandler{categoryService: categoryService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary Merge categories
+// @Description Move every entity of a category to another category of the same type, then delete it. Moved entities are re-checked against the target's details schema. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param category_id path string true "Category to merge away"
+// @Param request body dto.MergeCategoryRequest true "Category to merge into"
+// @Success 200 {object} dto.Response{data=dto.CategoryMergeResponse} "Categories merged successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Category not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category/{category_id}/merge [post]
+func (h *MergeCategoryHandler) HandleGin(c *gin.Context) {
+	// Parse request body
+	var request dto.MergeCategoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	result, err := h.categoryService.MergeCategories(c.Request.Context(), c.Param("category_id"), request.TargetCategoryID)
+	if err != nil {
+		handleCategoryError(c, err, "Failed to merge categories")
+		return
+	}
+
+	response.OK(c, result, "Categories merged successfully")
+}
+
+// ListDuplicateCategoriesHandler handles requests to find duplicate categories
+type ListDuplicateCategoriesHandler struct {
+	categoryService *services.CategoryService
+}
+
+// NewListDuplicateCategoriesHandler creates a new ListDuplicateCategoriesHandler
+func NewListDuplicateCategoriesHandler(categoryService *services.CategoryService) *ListDuplicateCategoriesHandler {
+	return &ListDuplicateCategoriesHandler{categoryService: categoryService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary List duplicate categories
+// @Description Group categories of the same type whose names differ only by case or surrounding whitespace, oldest first, as candidates for merging. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=[]dto.CategoryDuplicateGroupResponse} "Duplicate categories retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category/duplicates [get]
+func (h *ListDuplicateCategoriesHandler) HandleGin(c *gin.Context) {
+	groups, err := h.categoryService.ListDuplicateCategories(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing duplicate categories: %v", err)
+		response.InternalError(c, "Failed to retrieve duplicate categories")
+		return
+	}
+
+	response.OK(c, groups, "Duplicate categories retrieved successfully")
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// CategoryRuleHandler handles requests to manage category-type parenting rules
+type CategoryRuleHandler struct {
+	categoryService *services.CategoryService
+}
+
+// NewCategoryRuleHandler creates a new CategoryRuleHandler
+func NewCategoryRuleHandler(categoryService *services.CategoryService) *CategoryRuleHandler {
+	return &CategoryRuleHandler{categoryService: categoryService}
+}
+
+// HandleListTypeRules handles requests to list the parenting rules
+// @Summary List category type rules
+// @Description List, per category type, whether entities may be roots, which category types may be their children and how deep they may sit. Types without a rule are unrestricted.
+// @Tags Category Management
+// @Produce json
+// @Success 200 {object} dto.Response{data=[]dto.CategoryTypeRuleResponse} "Category type rules retrieved successfully"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /category/type-rules [get]
+func (h *CategoryRuleHandler) HandleListTypeRules(c *gin.Context) {
+	rules, err := h.categoryService.ListTypeRules(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing category type rules: %v", err)
+		response.InternalError(c, "Failed to retrieve category type rules")
+		return
+	}
+
+	response.OK(c, rules, "Category type rules retrieved successfully")
+}
+
+// HandleUpdateTypeRule handles requests to set the parenting rule of a category type
+// @Summary Set category type rule
+// @Description Replace the parenting rule of a category type. Existing entities are not changed; use the entity rule audit to find placements the new rule forbids. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param type path string true "Category type"
+// @Param request body dto.CategoryTypeRuleRequest true "Parenting rule"
+// @Success 200 {object} dto.Response{data=dto.CategoryTypeRuleResponse} "Category type rule updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category-type-rules/{type} [put]
+func (h *CategoryRuleHandler) HandleUpdateTypeRule(c *gin.Context) {
+	// Parse request body
+	var request dto.CategoryTypeRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	rule, err := h.categoryService.UpdateTypeRule(c.Request.Context(), c.Param("type"), request)
+	if err != nil {
+		handleCategoryError(c, err, "Failed to update category type rule")
+		return
+	}
+
+	response.OK(c, rule, "Category type rule updated successfully")
+}
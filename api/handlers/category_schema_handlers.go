@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+)
+
+// CategorySchemaHandler handles requests to manage category details schemas
+type CategorySchemaHandler struct {
+	categoryService *services.CategoryService
+}
+
+// NewCategorySchemaHandler creates a new CategorySchemaHandler
+func NewCategorySchemaHandler(categoryService *services.CategoryService) *CategorySchemaHandler {
+	return &CategorySchemaHandler{categoryService: categoryService}
+}
+
+// HandleUpdateSchema handles requests to set a category's details schema
+// @Summary Set category details schema
+// @Description Set the JSON Schema that entity details of this category must match, or remove it with an empty schema. Existing entities are re-checked and flagged as non-conforming instead of being rejected. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param category_id path string true "Category ID"
+// @Param request body dto.CategorySchemaRequest true "Details schema"
+// @Success 200 {object} dto.Response{data=dto.CategorySchemaUpdateResponse} "Category schema updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid schema"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Category not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category/{category_id}/schema [put]
+func (h *CategorySchemaHandler) HandleUpdateSchema(c *gin.Context) {
+	// Parse request body
+	var request dto.CategorySchemaRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	result, err := h.categoryService.UpdateDetailsSchema(c.Request.Context(), c.Param("category_id"), request.Schema)
+	if err != nil {
+		handleCategoryError(c, err, "Failed to update category schema")
+		return
+	}
+
+	response.OK(c, result, "Category schema updated successfully")
+}
+
+// HandleListNonConformingEntities handles requests to list entities that do not match their category schema
+// @Summary List non-conforming entities
+// @Description List the entities of a category whose details do not match its current schema, with the field-level errors found. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param category_id path string true "Category ID"
+// @Success 200 {object} dto.Response{data=[]dto.NonConformingEntityResponse} "Non-conforming entities retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Category not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category/{category_id}/nonconforming-entities [get]
+func (h *CategorySchemaHandler) HandleListNonConformingEntities(c *gin.Context) {
+	entities, err := h.categoryService.ListNonConformingEntities(c.Request.Context(), c.Param("category_id"))
+	if err != nil {
+		handleCategoryError(c, err, "Failed to retrieve non-conforming entities")
+		return
+	}
+
+	response.OK(c, entities, "Non-conforming entities retrieved successfully")
+}
+
+// handleCategoryError maps category service errors to HTTP responses
+func handleCategoryError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrCategoryNotFound):
+		response.NotFound(c, "Category not found")
+	case errors.Is(err, services.ErrInvalidSchema),
+		errors.Is(err, services.ErrUnknownCategoryType),
+		errors.Is(err, services.ErrInvalidMerge):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrCategoryExists),
+		errors.Is(err, services.ErrCategoryInUse),
+		errors.Is(err, services.ErrCategoryTypeExists),
+		errors.Is(err, services.ErrCategoryTypeInUse),
+		errors.Is(err, services.ErrCategoryTypeProtected):
+		response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// CategoryTypeHandler handles requests to manage category types
+type CategoryTypeHandler struct {
+	categoryService *services.CategoryService
+}
+
+// NewCategoryTypeHandler creates a new CategoryTypeHandler
+func NewCategoryTypeHandler(categoryService *services.CategoryService) *CategoryTypeHandler {
+	return &CategoryTypeHandler{categoryService: categoryService}
+}
+
+// HandleListCategoryTypes handles requests to list category types
+// @Summary List category types
+// @Description List every category type categories can be created with
+// @Tags Category Management
+// @Produce json
+// @Success 200 {object} dto.Response{data=[]dto.CategoryTypeResponse} "Category types retrieved successfully"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /category/types [get]
+func (h *CategoryTypeHandler) HandleListCategoryTypes(c *gin.Context) {
+	categoryTypes, err := h.categoryService.ListCategoryTypes(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing category types: %v", err)
+		response.InternalError(c, "Failed to retrieve category types")
+		return
+	}
+
+	response.OK(c, categoryTypes, "Category types retrieved successfully")
+}
+
+// HandleAddCategoryType handles requests to add a category type
+// @Summary Add a category type
+// @Description Register a new category type such as "warehouse". Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param request body dto.CategoryTypeRequest true "Category type"
+// @Success 201 {object} dto.Response{data=dto.CategoryTypeResponse} "Category type added successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 409 {object} dto.ErrorResponse "Category type already exists"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category-types [post]
+func (h *CategoryTypeHandler) HandleAddCategoryType(c *gin.Context) {
+	// Parse request body
+	var request dto.CategoryTypeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	categoryType, err := h.categoryService.AddCategoryType(c.Request.Context(), request.Name, request.Description)
+	if err != nil {
+		handleCategoryError(c, err, "Failed to add category type")
+		return
+	}
+
+	response.Created(c, categoryType, "Category type added successfully")
+}
+
+// HandleUpdateCategoryType handles requests to update a category type
+// @Summary Update a category type
+// @Description Replace the description of a category type. Admin only.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param name path string true "Category type name"
+// @Param request body dto.UpdateCategoryTypeRequest true "Category type changes"
+// @Success 200 {object} dto.Response{data=dto.CategoryTypeResponse} "Category type updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Category type not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category-types/{name} [put]
+func (h *CategoryTypeHandler) HandleUpdateCategoryType(c *gin.Context) {
+	// Parse request body
+	var request dto.UpdateCategoryTypeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	categoryType, err := h.categoryService.UpdateCategoryType(c.Request.Context(), c.Param("name"), request.Description)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownCategoryType) {
+			response.NotFound(c, "Category type not found")
+			return
+		}
+		handleCategoryError(c, err, "Failed to update category type")
+		return
+	}
+
+	response.OK(c, categoryType, "Category type updated successfully")
+}
+
+// HandleDeleteCategoryType handles requests to delete a category type
+// @Summary Delete a category type
+// @Description Delete a category type that no category uses. Its parenting rule is removed and it is dropped from other types' allowed child types. System types cannot be deleted. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param name path string true "Category type name"
+// @Success 200 {object} dto.Response "Category type deleted successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Category type not found"
+// @Failure 409 {object} dto.ErrorResponse "Category type is in use or is a system type"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/category-types/{name} [delete]
+func (h *CategoryTypeHandler) HandleDeleteCategoryType(c *gin.Context) {
+	if err := h.categoryService.DeleteCategoryType(c.Request.Context(), c.Param("name")); err != nil {
+		if errors.Is(err, services.ErrUnknownCategoryType) {
+			response.NotFound(c, "Category type not found")
+			return
+		}
+		handleCategoryError(c, err, "Failed to delete category type")
+		return
+	}
+
+	response.OK(c, nil, "Category type deleted successfully")
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// ChildAccountHandler handles requests from parent users managing their sub-accounts
+type ChildAccountHandler struct {
+	childService *services.ChildAccountService
+}
+
+// NewChildAccountHandler creates a new ChildAccountHandler
+func NewChildAccountHandler(childService *services.ChildAccountService) *ChildAccountHandler {
+	return &ChildAccountHandler{childService: childService}
+}
+
+// HandleCreateChild handles requests to invite a sub-account
+// @Summary Invite child account
+// @Description Invite the user with an email address to become a sub-account of the authenticated user. The parent chain only reaches their account once they accept the invitation, after signing up if they have no account yet.
+// @Tags Child Accounts
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param request body dto.CreateChildUserRequest true "Child account details"
+// @Success 201 {object} dto.Response{data=dto.ChildInvitationResponse} "Child account invitation sent successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error or own email"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 409 {object} dto.ErrorResponse "Email has already been invited"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/children [post]
+func (h *ChildAccountHandler) HandleCreateChild(c *gin.Context) {
+	// Parse request body
+	var request dto.CreateChildUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	child, err := h.childService.CreateChild(c.Request.Context(), userID, request)
+	if err != nil {
+		handleChildAccountError(c, err, "Failed to invite child account")
+		return
+	}
+
+	response.Created(c, child, "Child account invitation sent successfully")
+}
+
+// HandleListChildInvitations handles requests to list the sub-account invitations the authenticated user sent
+// @Summary List sent child invitations
+// @Description List the invitations to become a sub-account that the authenticated user sent and that were not accepted yet.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=[]dto.ChildInvitationResponse} "Child invitations retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/child-invitations [get]
+func (h *ChildAccountHandler) HandleListChildInvitations(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	invitations, err := h.childService.ListChildInvitations(c.Request.Context(), userID)
+	if err != nil {
+		handleChildAccountError(c, err, "Failed to list child invitations")
+		return
+	}
+
+	response.OK(c, invitations, "Child invitations retrieved successfully")
+}
+
+// HandleCancelChildInvitation handles requests to withdraw a sub-account invitation
+// @Summary Cancel child invitation
+// @Description Withdraw an invitation to become a sub-account that the authenticated user sent.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param invitation_id path string true "Invitation ID"
+// @Success 200 {object} dto.Response "Child invitation cancelled successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Invitation not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/child-invitations/{invitation_id} [delete]
+func (h *ChildAccountHandler) HandleCancelChildInvitation(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.childService.CancelChildInvitation(c.Request.Context(), userID, c.Param("invitation_id")); err != nil {
+		handleChildAccountError(c, err, "Failed to cancel child invitation")
+		return
+	}
+
+	response.OK(c, nil, "Child invitation cancelled successfully")
+}
+
+// HandleListParentInvitations handles requests to list the invitations for the authenticated user to become a sub-account
+// @Summary List received parent invitations
+// @Description List the invitations for the authenticated user to become a sub-account, sent to their email address.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=[]dto.ChildInvitationResponse} "Parent invitations retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/parent-invitations [get]
+func (h *ChildAccountHandler) HandleListParentInvitations(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	invitations, err := h.childService.ListParentInvitations(c.Request.Context(), userID)
+	if err != nil {
+		handleChildAccountError(c, err, "Failed to list parent invitations")
+		return
+	}
+
+	response.OK(c, invitations, "Parent invitations retrieved successfully")
+}
+
+// HandleAcceptParentInvitation handles requests to accept an invitation to become a sub-account
+// @Summary Accept parent invitation
+// @Description Become a sub-account of the user who sent the invitation. They, and the accounts above them, can then view the authenticated user's devices and entities and disable their account. Other invitations are withdrawn.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param invitation_id path string true "Invitation ID"
+// @Success 200 {object} dto.Response "Parent invitation accepted successfully"
+// @Failure 400 {object} dto.ErrorResponse "Parent is an account below the user"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Invitation not found"
+// @Failure 409 {object} dto.ErrorResponse "User already has a parent account"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/parent-invitations/{invitation_id}/accept [post]
+func (h *ChildAccountHandler) HandleAcceptParentInvitation(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.childService.AcceptParentInvitation(c.Request.Context(), userID, c.Param("invitation_id")); err != nil {
+		handleChildAccountError(c, err, "Failed to accept parent invitation")
+		return
+	}
+
+	response.OK(c, nil, "Parent invitation accepted successfully")
+}
+
+// HandleDeclineParentInvitation handles requests to decline an invitation to become a sub-account
+// @Summary Decline parent invitation
+// @Description Decline an invitation for the authenticated user to become a sub-account.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param invitation_id path string true "Invitation ID"
+// @Success 200 {object} dto.Response "Parent invitation declined successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Invitation not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/parent-invitations/{invitation_id} [delete]
+func (h *ChildAccountHandler) HandleDeclineParentInvitation(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.childService.DeclineParentInvitation(c.Request.Context(), userID, c.Param("invitation_id")); err != nil {
+		handleChildAccountError(c, err, "Failed to decline parent invitation")
+		return
+	}
+
+	response.OK(c, nil, "Parent invitation declined successfully")
+}
+
+// HandleListChildren handles requests to list the authenticated user's sub-accounts
+// @Summary List child accounts
+// @Description List the accounts directly below the authenticated user, disabled ones included.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=[]dto.UserResponse} "Child accounts retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/children [get]
+func (h *ChildAccountHandler) HandleListChildren(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	children, err := h.childService.ListChildren(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing child accounts: %v", err)
+		response.InternalError(c, "Failed to list child accounts")
+		return
+	}
+
+	response.OK(c, children, "Child accounts retrieved successfully")
+}
+
+// HandleDisableChild handles requests to disable a sub-account
+// @Summary Disable child account
+// @Description Disable an account anywhere below the authenticated user in the parent chain. Disabled accounts keep their data but are refused on sign-in.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "Child user ID"
+// @Success 200 {object} dto.Response "Child account disabled successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "User is not managed by requester"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/children/{user_id}/disable [post]
+func (h *ChildAccountHandler) HandleDisableChild(c *gin.Context) {
+	h.handleSetDisabled(c, true, "Child account disabled successfully")
+}
+
+// HandleEnableChild handles requests to re-enable a disabled sub-account
+// @Summary Enable child account
+// @Description Re-enable a disabled account anywhere below the authenticated user in the parent chain.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "Child user ID"
+// @Success 200 {object} dto.Response "Child account enabled successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "User is not managed by requester"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/children/{user_id}/enable [post]
+func (h *ChildAccountHandler) HandleEnableChild(c *gin.Context) {
+	h.handleSetDisabled(c, false, "Child account enabled successfully")
+}
+
+// handleSetDisabled disables or re-enables the sub-account named in the path
+func (h *ChildAccountHandler) handleSetDisabled(c *gin.Context, disabled bool, message string) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.childService.SetChildDisabled(c.Request.Context(), userID, c.Param("user_id"), disabled); err != nil {
+		handleChildAccountError(c, err, "Failed to update child account")
+		return
+	}
+
+	response.OK(c, nil, message)
+}
+
+// HandleListChildDevices handles requests for the devices of a sub-account
+// @Summary List child account devices
+// @Description List the devices of an account anywhere below the authenticated user in the parent chain.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "Child user ID"
+// @Success 200 {object} dto.Response{data=[]dto.DeviceResponse} "Child account devices retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "User is not managed by requester"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/children/{user_id}/devices [get]
+func (h *ChildAccountHandler) HandleListChildDevices(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	devices, err := h.childService.GetChildDevices(c.Request.Context(), userID, c.Param("user_id"))
+	if err != nil {
+		handleChildAccountError(c, err, "Failed to retrieve child account devices")
+		return
+	}
+
+	response.OK(c, devices, "Child account devices retrieved successfully")
+}
+
+// HandleListChildEntities handles requests for the entities of a sub-account
+// @Summary List child account entities
+// @Description List the user entity of an account anywhere below the authenticated user in the parent chain, with all its descendants. Parents can also open these entities through the entity endpoints with the viewer role.
+// @Tags Child Accounts
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "Child user ID"
+// @Success 200 {object} dto.Response{data=[]dto.EntityResponse} "Child account entities retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "User is not managed by requester"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/children/{user_id}/entities [get]
+func (h *ChildAccountHandler) HandleListChildEntities(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	entities, err := h.childService.GetChildEntities(c.Request.Context(), userID, c.Param("user_id"))
+	if err != nil {
+		handleChildAccountError(c, err, "Failed to retrieve child account entities")
+		return
+	}
+
+	response.OK(c, entities, "Child account entities retrieved successfully")
+}
+
+// handleChildAccountError maps child account errors to HTTP responses
+func handleChildAccountError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		response.NotFound(c, "User not found")
+	case errors.Is(err, services.ErrUserAccessDenied):
+		response.Forbidden(c, "User is not managed by requester")
+	case errors.Is(err, services.ErrInvitationNotFound):
+		response.NotFound(c, "Invitation not found")
+	case errors.Is(err, services.ErrSelfInvitation),
+		errors.Is(err, services.ErrParentCycle):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrChildInvitationExists),
+		errors.Is(err, services.ErrParentAlreadySet):
+		response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/cognito"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// maxWebhookBodySize bounds the size of trigger events the webhook reads
+const maxWebhookBodySize = 64 << 10
+
+// CognitoWebhookHandler handles Cognito trigger events forwarded by a Lambda function
+type CognitoWebhookHandler struct {
+	userService *services.UserService
+	secret      []byte
+	userPoolID  string
+}
+
+// NewCognitoWebhookHandler creates a new CognitoWebhookHandler. Requests must be signed with
+// secret; if userPoolID is set, events from other pools are refused.
+func NewCognitoWebhookHandler(userService *services.UserService, secret, userPoolID string) *CognitoWebhookHandler {
+	return &CognitoWebhookHandler{
+		userService: userService,
+		secret:      []byte(secret),
+		userPoolID:  userPoolID,
+	}
+}
+
+// HandleEvent handles a forwarded Cognito trigger event
+// @Summary Receive Cognito trigger event
+// @Description Create or update the user a Cognito Lambda trigger event is about, keyed by their Cognito ID (sub). The event is sent as-is, signed with the shared webhook secret: X-Zolaris-Timestamp holds the Unix time in seconds and X-Zolaris-Signature holds "v1=" followed by the hex HMAC-SHA256 of the timestamp, a dot and the body. Sign-up confirmations create the user, or link an existing account without a Cognito identity that has the same verified email, and only fill in missing details. Attribute updates replace the stored details, and change the email once Cognito has verified it. Events of other triggers are acknowledged and ignored.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param X-Zolaris-Timestamp header string true "Unix time the request was signed at"
+// @Param X-Zolaris-Signature header string true "Request signature"
+// @Param request body dto.CognitoTriggerEvent true "Cognito trigger event"
+// @Success 200 {object} dto.Response{data=dto.ProvisionedUserResponse} "User provisioned successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid event"
+// @Failure 401 {object} dto.ErrorResponse "Invalid or missing signature"
+// @Failure 403 {object} dto.ErrorResponse "Event is from another user pool"
+// @Failure 409 {object} dto.ErrorResponse "Email is already in use or user was deleted"
+// @Failure 413 {object} dto.ErrorResponse "Event too large"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 503 {object} dto.ErrorResponse "Webhook not configured"
+// @Router /webhooks/cognito [post]
+func (h *CognitoWebhookHandler) HandleEvent(c *gin.Context) {
+	if len(h.secret) == 0 {
+		response.Error(c, http.StatusServiceUnavailable, "Cognito webhook is not configured", "SERVICE_UNAVAILABLE")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize+1))
+	if err != nil {
+		response.BadRequest(c, "Failed to read request body")
+		return
+	}
+	if len(body) > maxWebhookBodySize {
+		response.Error(c, http.StatusRequestEntityTooLarge, "Event too large", "PAYLOAD_TOO_LARGE")
+		return
+	}
+
+	// The signature covers the raw body, so it is checked before the body is parsed
+	if err := cognito.VerifySignature(h.secret, c.GetHeader(cognito.TimestampHeader), c.GetHeader(cognito.SignatureHeader), body, time.Now()); err != nil {
+		log.Printf("Rejected Cognito webhook request: %v", err)
+		response.Unauthorized(c, err.Error())
+		return
+	}
+
+	var event dto.CognitoTriggerEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(event)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	if h.userPoolID != "" && event.UserPoolID != h.userPoolID {
+		response.Forbidden(c, "Event is from another user pool")
+		return
+	}
+
+	result, err := h.userService.HandleCognitoEvent(c.Request.Context(), &event)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCognitoProfile):
+			response.BadRequest(c, err.Error())
+		case errors.Is(err, services.ErrEmailInUse), errors.Is(err, services.ErrUserDeleted):
+			response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+		default:
+			log.Printf("Error handling Cognito %s event: %v", event.TriggerSource, err)
+			response.InternalError(c, "Failed to provision user")
+		}
+		return
+	}
+
+	if result == nil {
+		response.OK(c, nil, "Event ignored")
+		return
+	}
+
+	response.OK(c, result, "User provisioned successfully")
+}
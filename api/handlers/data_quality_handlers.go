@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// DataQualityHandler handles sensor data quality requests
+type DataQualityHandler struct {
+	qualityService *services.DataQualityService
+}
+
+// NewDataQualityHandler creates a new DataQualityHandler
+func NewDataQualityHandler(qualityService *services.DataQualityService) *DataQualityHandler {
+	return &DataQualityHandler{qualityService: qualityService}
+}
+
+// HandleGetDeviceDataQuality handles requests to analyze a device's data quality
+// @Summary Get device data quality
+// @Description Report expected vs actual sample counts, gaps longer than gapFactor × samplePeriod, duplicate readings and values outside the configured physical bounds
+// @Tags Device Data
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Param startTime query int true "Start of the window in milliseconds"
+// @Param endTime query int true "End of the window in milliseconds"
+// @Param samplePeriod query string false "Expected interval between readings, at least 1s (e.g. 30s, 1m)"
+// @Param gapFactor query number false "Multiple of the sample period reported as a gap"
+// @Success 200 {object} dto.Response{data=dto.DataQualityReportResponse} "Data quality analyzed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac}/data-quality [get]
+func (h *DataQualityHandler) HandleGetDeviceDataQuality(c *gin.Context) {
+	request, ok := bindDataQualityRequest(c)
+	if !ok {
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	report, err := h.qualityService.GetDeviceReport(c.Request.Context(), userID, c.Param("mac"), request)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQualityParams) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		handleDeviceError(c, err, "Failed to analyze data quality")
+		return
+	}
+
+	response.OK(c, report, "Data quality analyzed successfully")
+}
+
+// HandleGetFleetDataQuality handles requests to summarize data quality across all devices
+// @Summary Get fleet data quality
+// @Description Summarize data quality for every device over a window, least complete devices first. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param startTime query int true "Start of the window in milliseconds"
+// @Param endTime query int true "End of the window in milliseconds"
+// @Param samplePeriod query string false "Expected interval between readings, at least 1s (e.g. 30s, 1m)"
+// @Param gapFactor query number false "Multiple of the sample period reported as a gap"
+// @Success 200 {object} dto.Response{data=dto.FleetDataQualityResponse} "Fleet data quality analyzed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin access required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/data-quality [get]
+func (h *DataQualityHandler) HandleGetFleetDataQuality(c *gin.Context) {
+	request, ok := bindDataQualityRequest(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.qualityService.GetFleetSummary(c.Request.Context(), request)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQualityParams) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		log.Printf("Error analyzing fleet data quality: %v", err)
+		response.InternalError(c, "Failed to analyze fleet data quality")
+		return
+	}
+
+	response.OK(c, summary, "Fleet data quality analyzed successfully")
+}
+
+// bindDataQualityRequest parses and validates the query parameters, writing the error response on failure
+func bindDataQualityRequest(c *gin.Context) (*dto.DataQualityRequest, bool) {
+	var request dto.DataQualityRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return nil, false
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return nil, false
+	}
+
+	return &request, true
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"n1h41/zolaris-backend-app/internal/energy"
 	"n1h41/zolaris-backend-app/internal/middleware"
 	"n1h41/zolaris-backend-app/internal/services"
 	"n1h41/zolaris-backend-app/internal/transport/dto"
@@ -33,6 +37,7 @@ func NewAddDeviceHandler(deviceService *services.DeviceService) *AddDeviceHandle
 // @Success 201 {object} dto.Response "Device added successfully"
 // @Failure 400 {object} dto.ErrorResponse "Validation error"
 // @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 409 {object} dto.ErrorResponse "Device was deleted by another user and has not been purged yet"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
 // @Router /device/add [post]
@@ -62,6 +67,10 @@ func (h *AddDeviceHandler) HandleGin(c *gin.Context) {
 
 	// Call service to add device
 	if err := h.deviceService.AddDevice(c.Request.Context(), request.DeviceID, request.DeviceName, userID); err != nil {
+		if errors.Is(err, services.ErrDeviceDeletedByOtherUser) {
+			response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+			return
+		}
 		log.Printf("Error adding device: %v", err)
 		response.InternalError(c, "Failed to add device")
 		return
@@ -82,17 +91,33 @@ func NewListUserDevicesHandler(deviceService *services.DeviceService) *ListUserD
 
 // HandleGin handles requests using Gin framework
 // @Summary List user devices
-// @Description Get all devices registered to the authenticated user
+// @Description Get all devices registered to the authenticated user, with their tags, optionally keeping only devices that carry every given tag
 // @Tags Device Management
 // @Accept json
 // @Produce json
 // @Param X-User-ID header string true "User ID"
+// @Param tags query []string false "Tag filters as key or key=value" collectionFormat(multi)
 // @Success 200 {array} dto.DeviceResponse "List of user devices"
+// @Failure 400 {object} dto.ErrorResponse "Invalid tag filter"
 // @Failure 401 {object} dto.ErrorResponse "User not authenticated"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
 // @Router /user/devices [get]
 func (h *ListUserDevicesHandler) HandleGin(c *gin.Context) {
+	var request dto.ListUserDevicesRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
 	// Get user ID from context (set by auth middleware)
 	userID := middleware.GetUserIDFromGin(c)
 	if userID == "" {
@@ -101,8 +126,12 @@ func (h *ListUserDevicesHandler) HandleGin(c *gin.Context) {
 	}
 
 	// Call service to get user devices
-	devices, err := h.deviceService.GetUserDevices(c.Request.Context(), userID)
+	devices, err := h.deviceService.GetUserDevices(c.Request.Context(), userID, request.Tags)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidTag) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		log.Printf("Error getting user devices: %v", err)
 		response.InternalError(c, "Failed to retrieve user devices")
 		return
@@ -163,3 +192,250 @@ func (h *GetDeviceSensorDataHandler) HandleGin(c *gin.Context) {
 	// Use the data directly in the response
 	response.OK(c, data, "Data retrieved successfully")
 }
+
+// UpdateDeviceElectricalParamsHandler handles requests to update a device's electrical parameters
+type UpdateDeviceElectricalParamsHandler struct {
+	deviceService *services.DeviceService
+}
+
+// NewUpdateDeviceElectricalParamsHandler creates a new UpdateDeviceElectricalParamsHandler
+func NewUpdateDeviceElectricalParamsHandler(deviceService *services.DeviceService) *UpdateDeviceElectricalParamsHandler {
+	return &UpdateDeviceElectricalParamsHandler{deviceService: deviceService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary Update device electrical parameters
+// @Description Set the voltage, phase count and power factor used to compute energy from amperage
+// @Tags Device Management
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Param params body dto.DeviceElectricalParamsRequest true "Electrical parameters"
+// @Success 200 {object} dto.Response{data=dto.DeviceResponse} "Electrical parameters updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac}/electrical-params [put]
+func (h *UpdateDeviceElectricalParamsHandler) HandleGin(c *gin.Context) {
+	// Parse request body
+	var request dto.DeviceElectricalParamsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	params := energy.ElectricalParams{
+		Voltage:     request.Voltage,
+		PhaseCount:  request.PhaseCount,
+		PowerFactor: request.PowerFactor,
+	}
+
+	device, err := h.deviceService.UpdateElectricalParams(c.Request.Context(), userID, c.Param("mac"), params)
+	if err != nil {
+		handleDeviceError(c, err, "Failed to update electrical parameters")
+		return
+	}
+
+	response.OK(c, device, "Electrical parameters updated successfully")
+}
+
+// DeleteDeviceHandler handles requests to delete a device
+type DeleteDeviceHandler struct {
+	deviceService *services.DeviceService
+}
+
+// NewDeleteDeviceHandler creates a new DeleteDeviceHandler
+func NewDeleteDeviceHandler(deviceService *services.DeviceService) *DeleteDeviceHandler {
+	return &DeleteDeviceHandler{deviceService: deviceService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary Delete a device
+// @Description Delete a device owned by the authenticated user. The device is hidden at once and can be restored by an admin until the retention period ends, after which it is purged.
+// @Tags Device Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Success 200 {object} dto.Response "Device deleted successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac} [delete]
+func (h *DeleteDeviceHandler) HandleGin(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.deviceService.DeleteDevice(c.Request.Context(), userID, c.Param("mac")); err != nil {
+		handleDeviceError(c, err, "Failed to delete device")
+		return
+	}
+
+	response.OK(c, nil, "Device deleted successfully")
+}
+
+// IngestSensorDataHandler handles requests to store sensor readings for a device
+type IngestSensorDataHandler struct {
+	deviceService *services.DeviceService
+}
+
+// NewIngestSensorDataHandler creates a new IngestSensorDataHandler
+func NewIngestSensorDataHandler(deviceService *services.DeviceService) *IngestSensorDataHandler {
+	return &IngestSensorDataHandler{deviceService: deviceService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary Ingest device sensor data
+// @Description Store a batch of sensor readings for a device in the configured telemetry backend. Readings with an existing timestamp are replaced.
+// @Tags Device Data
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Param readings body dto.SensorDataIngestRequest true "Sensor readings"
+// @Success 201 {object} dto.Response "Sensor data stored successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac}/sensor-data [post]
+func (h *IngestSensorDataHandler) HandleGin(c *gin.Context) {
+	// Parse request body
+	var request dto.SensorDataIngestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.deviceService.IngestSensorData(c.Request.Context(), userID, c.Param("mac"), request.Readings); err != nil {
+		handleDeviceError(c, err, "Failed to store sensor data")
+		return
+	}
+
+	response.Created(c, nil, "Sensor data stored successfully")
+}
+
+// AggregateSensorDataHandler handles requests to summarize sensor data for a device
+type AggregateSensorDataHandler struct {
+	deviceService *services.DeviceService
+}
+
+// NewAggregateSensorDataHandler creates a new AggregateSensorDataHandler
+func NewAggregateSensorDataHandler(deviceService *services.DeviceService) *AggregateSensorDataHandler {
+	return &AggregateSensorDataHandler{deviceService: deviceService}
+}
+
+// HandleGin handles requests using Gin framework
+// @Summary Aggregate device sensor data
+// @Description Summarize sensor readings in fixed-width buckets (e.g. interval "15m" or "1h") aligned to the Unix epoch
+// @Tags Device Data
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Param request body dto.SensorDataAggregateRequest true "Aggregation range and interval"
+// @Success 200 {object} dto.Response{data=[]dto.SensorAggregateResponse} "Sensor data aggregated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac}/sensor-data/aggregate [post]
+func (h *AggregateSensorDataHandler) HandleGin(c *gin.Context) {
+	// Parse request body
+	var request dto.SensorDataAggregateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	interval, err := time.ParseDuration(request.Interval)
+	if err != nil || interval < time.Minute {
+		response.BadRequest(c, "Interval must be a duration of at least 1m")
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	start := time.UnixMilli(request.StartTime)
+	end := time.UnixMilli(request.EndTime)
+
+	aggregates, err := h.deviceService.AggregateSensorData(c.Request.Context(), userID, c.Param("mac"), start, end, interval)
+	if err != nil {
+		handleDeviceError(c, err, "Failed to aggregate sensor data")
+		return
+	}
+
+	response.OK(c, aggregates, "Sensor data aggregated successfully")
+}
+
+// handleDeviceError maps device service errors to HTTP responses
+func handleDeviceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrDeviceNotFound):
+		response.NotFound(c, "Device not found")
+	case errors.Is(err, services.ErrDeviceAccessDenied):
+		response.Forbidden(c, "Device does not belong to user")
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
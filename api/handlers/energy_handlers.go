@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/energy"
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// EnergyHandler handles energy consumption and cost requests
+type EnergyHandler struct {
+	deviceService *services.DeviceService
+}
+
+// NewEnergyHandler creates a new EnergyHandler
+func NewEnergyHandler(deviceService *services.DeviceService) *EnergyHandler {
+	return &EnergyHandler{deviceService: deviceService}
+}
+
+// HandleGetDeviceEnergy handles requests to compute a device's energy consumption
+// @Summary Get device energy consumption
+// @Description Integrate amperage readings into kWh per hour, day or month. Gaps between readings longer than the configured maximum are not interpolated and lower the bucket coverage.
+// @Tags Device Data
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Param request body dto.EnergyRequest true "Time range and bucket size"
+// @Success 200 {object} dto.Response{data=dto.EnergyResponse} "Energy computed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac}/energy [post]
+func (h *EnergyHandler) HandleGetDeviceEnergy(c *gin.Context) {
+	// Parse request body
+	var request dto.EnergyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	result, err := h.deviceService.GetDeviceEnergy(
+		c.Request.Context(),
+		userID,
+		c.Param("mac"),
+		time.UnixMilli(request.StartTime),
+		time.UnixMilli(request.EndTime),
+		energy.BucketSize(request.Bucket),
+	)
+	if err != nil {
+		handleDeviceError(c, err, "Failed to compute energy consumption")
+		return
+	}
+
+	response.OK(c, result, "Energy computed successfully")
+}
+
+// HandleGetDeviceEnergyCost handles requests to compute a device's energy cost
+// @Summary Get device energy cost
+// @Description Price a device's energy consumption per day or month using a flat or time-of-use tariff. The configured flat rate is used when no tariff is given.
+// @Tags Device Data
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Param request body dto.EnergyCostRequest true "Time range, billing period and tariff"
+// @Success 200 {object} dto.Response{data=dto.EnergyCostResponse} "Energy cost computed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac}/energy/cost [post]
+func (h *EnergyHandler) HandleGetDeviceEnergyCost(c *gin.Context) {
+	// Parse request body
+	var request dto.EnergyCostRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	result, err := h.deviceService.GetDeviceEnergyCost(
+		c.Request.Context(),
+		userID,
+		c.Param("mac"),
+		time.UnixMilli(request.StartTime),
+		time.UnixMilli(request.EndTime),
+		energy.BucketSize(request.Period),
+		request.Tariff,
+	)
+	if err != nil {
+		handleDeviceError(c, err, "Failed to compute energy cost")
+		return
+	}
+
+	response.OK(c, result, "Energy cost computed successfully")
+}
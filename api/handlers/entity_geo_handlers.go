@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// EntityGeoHandler handles requests to place entities on the map and find them by location
+type EntityGeoHandler struct {
+	geoService *services.EntityGeoService
+}
+
+// NewEntityGeoHandler creates a new EntityGeoHandler
+func NewEntityGeoHandler(geoService *services.EntityGeoService) *EntityGeoHandler {
+	return &EntityGeoHandler{geoService: geoService}
+}
+
+// HandleSetLocation handles requests to set an entity's location
+// @Summary Set entity location
+// @Description Place an entity on the map at the given latitude and longitude (WGS 84). Requires the admin role on the entity.
+// @Tags Entity Geolocation
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param request body dto.SetEntityLocationRequest true "Coordinates"
+// @Success 200 {object} dto.Response "Entity location updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/location [put]
+func (h *EntityGeoHandler) HandleSetLocation(c *gin.Context) {
+	// Parse request body
+	var request dto.SetEntityLocationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.geoService.SetLocation(c.Request.Context(), userID, c.Param("entity_id"), request); err != nil {
+		handleEntityError(c, err, "Failed to update entity location")
+		return
+	}
+
+	response.OK(c, nil, "Entity location updated successfully")
+}
+
+// HandleClearLocation handles requests to remove an entity's location
+// @Summary Clear entity location
+// @Description Remove an entity from the map. Requires the admin role on the entity.
+// @Tags Entity Geolocation
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Success 200 {object} dto.Response "Entity location cleared successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/location [delete]
+func (h *EntityGeoHandler) HandleClearLocation(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.geoService.ClearLocation(c.Request.Context(), userID, c.Param("entity_id")); err != nil {
+		handleEntityError(c, err, "Failed to clear entity location")
+		return
+	}
+
+	response.OK(c, nil, "Entity location cleared successfully")
+}
+
+// HandleFindNearby handles requests to find entities around a point
+// @Summary Find nearby entities
+// @Description List the located entities the user can reach within a radius of a point, nearest first, with their distance in meters. Optionally restricted to an entity and its descendants.
+// @Tags Entity Geolocation
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param lat query number true "Latitude of the center"
+// @Param lon query number true "Longitude of the center"
+// @Param radius query number true "Radius in meters"
+// @Param under query string false "Only return this entity and its descendants"
+// @Param limit query int false "Maximum number of results (default 100, max 1000)"
+// @Success 200 {object} dto.Response{data=[]dto.GeoEntityResponse} "Nearby entities retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/nearby [get]
+func (h *EntityGeoHandler) HandleFindNearby(c *gin.Context) {
+	var request dto.EntityNearbyRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	entities, err := h.geoService.FindNearby(c.Request.Context(), userID, request)
+	if err != nil {
+		log.Printf("Error finding nearby entities: %v", err)
+		response.InternalError(c, "Failed to find nearby entities")
+		return
+	}
+
+	response.OK(c, entities, "Nearby entities retrieved successfully")
+}
+
+// HandleFindInBounds handles requests to find entities inside a bounding box
+// @Summary Find entities in bounds
+// @Description List the located entities the user can reach inside a bounding box, by name. A box whose minLon is greater than its maxLon crosses the antimeridian. Optionally restricted to an entity and its descendants.
+// @Tags Entity Geolocation
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param minLat query number true "Southern edge"
+// @Param minLon query number true "Western edge"
+// @Param maxLat query number true "Northern edge"
+// @Param maxLon query number true "Eastern edge"
+// @Param under query string false "Only return this entity and its descendants"
+// @Param limit query int false "Maximum number of results (default 100, max 1000)"
+// @Success 200 {object} dto.Response{data=[]dto.GeoEntityResponse} "Entities retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/within [get]
+func (h *EntityGeoHandler) HandleFindInBounds(c *gin.Context) {
+	var request dto.EntityBoundsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	entities, err := h.geoService.FindInBounds(c.Request.Context(), userID, request)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidGeoQuery) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		log.Printf("Error finding entities in bounds: %v", err)
+		response.InternalError(c, "Failed to find entities")
+		return
+	}
+
+	response.OK(c, entities, "Entities retrieved successfully")
+}
+
+// HandleGetGeoJSON handles requests for the located entities of a hierarchy as GeoJSON
+// @Summary Get entity hierarchy as GeoJSON
+// @Description Return the located entities among an entity and its descendants as a GeoJSON FeatureCollection of points, for direct use by map widgets. Entities without a location are left out. Each feature's properties hold the entity's id, name, parentId, categoryName, categoryType and depth. Requires any role on the entity.
+// @Tags Entity Geolocation
+// @Produce application/geo+json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param maxDepth query int false "Maximum depth below the entity (default 10, max 50)"
+// @Success 200 {object} dto.GeoJSONFeatureCollection "GeoJSON feature collection"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/geojson [get]
+func (h *EntityGeoHandler) HandleGetGeoJSON(c *gin.Context) {
+	var request dto.EntityGeoJSONRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	collection, err := h.geoService.GetHierarchyGeoJSON(c.Request.Context(), userID, c.Param("entity_id"), request.MaxDepth)
+	if err != nil {
+		handleEntityError(c, err, "Failed to retrieve entity locations")
+		return
+	}
+
+	// GeoJSON is returned bare rather than wrapped so map libraries can load it directly
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, collection)
+}
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/repositories"
 	"n1h41/zolaris-backend-app/internal/services"
 	"n1h41/zolaris-backend-app/internal/transport/dto"
 	"n1h41/zolaris-backend-app/internal/transport/mappers"
@@ -13,6 +17,9 @@ import (
 	"n1h41/zolaris-backend-app/internal/utils"
 )
 
+// defaultHierarchyDepth is the number of levels returned when maxDepth is not given
+const defaultHierarchyDepth = 10
+
 // EntityHandler handles all entity-related HTTP requests
 type EntityHandler struct {
 	entityService *services.EntityService
@@ -34,6 +41,7 @@ func NewEntityHandler(entityService *services.EntityService) *EntityHandler {
 // @Success 201 {object} dto.Response "Entity created successfully"
 // @Failure 400 {object} dto.ErrorResponse "Validation error"
 // @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 422 {object} dto.ErrorResponse "Placement breaks the category-type parenting rules"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
 // @Router /entity/root [post]
@@ -70,8 +78,7 @@ func (h *EntityHandler) HandleCreateRootEntity(c *gin.Context) {
 		request.Details,
 	)
 	if err != nil {
-		log.Printf("Error creating root entity: %v", err)
-		response.InternalError(c, "Failed to create root entity")
+		handleEntityError(c, err, "Failed to create root entity")
 		return
 	}
 
@@ -80,7 +87,7 @@ func (h *EntityHandler) HandleCreateRootEntity(c *gin.Context) {
 
 // HandleCreateSubEntity handles requests to create a sub-entity
 // @Summary Create a sub-entity
-// @Description Create a new entity as a child of an existing entity
+// @Description Create a new entity as a child of an existing entity, or of the user's own entity when no parent is given. Requires the admin role on the parent.
 // @Tags Entity Management
 // @Accept json
 // @Produce json
@@ -89,7 +96,9 @@ func (h *EntityHandler) HandleCreateRootEntity(c *gin.Context) {
 // @Success 201 {object} dto.Response "Sub-entity created successfully"
 // @Failure 400 {object} dto.ErrorResponse "Validation error"
 // @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role on the parent entity required"
 // @Failure 404 {object} dto.ErrorResponse "Parent entity not found"
+// @Failure 422 {object} dto.ErrorResponse "Placement breaks the category-type parenting rules"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
 // @Router /entity/sub [post]
@@ -131,8 +140,7 @@ func (h *EntityHandler) HandleCreateSubEntity(c *gin.Context) {
 			response.BadRequest(c, "User does not have any existing entities")
 			return
 		}
-		log.Printf("Error creating sub-entity: %v", err)
-		response.InternalError(c, "Failed to create sub-entity")
+		handleEntityError(c, err, "Failed to create sub-entity")
 		return
 	}
 
@@ -191,6 +199,10 @@ func (h *EntityHandler) HandleGetEntityChildren(c *gin.Context) {
 			response.NotFound(c, "Entity not found")
 			return
 		}
+		if errors.Is(err, services.ErrUnknownCategoryType) {
+			response.BadRequest(c, "Unknown category type")
+			return
+		}
 		log.Printf("Error getting entity children: %v", err)
 		response.InternalError(c, "Failed to retrieve entity children")
 		return
@@ -211,14 +223,15 @@ func (h *EntityHandler) HandleGetEntityChildren(c *gin.Context) {
 
 // HandleGetEntityHierarchy handles requests to get an entity hierarchy
 // @Summary Get entity hierarchy
-// @Description Get an entity and all its descendants as a hierarchical structure
+// @Description Get an entity and its descendants up to maxDepth levels below it. Nodes whose children were cut off have hasMore set and can be expanded by requesting their own hierarchy. This route is public and only serves the current hierarchy: past hierarchies include deleted entities, so asOf is rejected here and served to authenticated viewers by /entity/{entity_id}/hierarchy/as-of instead.
 // @Tags Entity Management
 // @Accept json
 // @Produce json
 // @Param entity_id path string true "Entity ID"
-// @Param max_depth query int false "Maximum depth to include (default: 10)"
+// @Param maxDepth query int false "Number of levels below the entity to include (default: 10, max: 50)"
+// @Param asOf query int false "Not supported here; use /entity/{entity_id}/hierarchy/as-of"
 // @Success 200 {object} dto.Response{data=dto.EntityHierarchyResponse} "Entity hierarchy retrieved successfully"
-// @Failure 400 {object} dto.ErrorResponse "Invalid request"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request, or asOf was given"
 // @Failure 404 {object} dto.ErrorResponse "Entity not found"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /entity/{entity_id}/hierarchy [get]
@@ -230,29 +243,139 @@ func (h *EntityHandler) HandleGetEntityHierarchy(c *gin.Context) {
 		return
 	}
 
+	// Past hierarchies include deleted entities, so they are only served to authenticated viewers
+	if _, ok := c.GetQuery("asOf"); ok {
+		response.BadRequest(c, "asOf is only supported by /entity/{entity_id}/hierarchy/as-of")
+		return
+	}
+
+	// Parse query parameters
+	var request dto.GetEntityHierarchyRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	maxDepth := request.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = defaultHierarchyDepth
+	}
+
 	// Call service to get entity hierarchy
-	hierarchy, err := h.entityService.GetEntityHierarchy(
+	hierarchy, err := h.entityService.GetEntityHierarchy(c.Request.Context(), entityID, maxDepth)
+	if err != nil {
+		handleEntityError(c, err, "Failed to retrieve entity hierarchy")
+		return
+	}
+
+	response.OK(c, hierarchy, "Entity hierarchy retrieved successfully")
+}
+
+// HandleGetEntityHierarchyAsOf handles requests to reconstruct a past entity hierarchy
+// @Summary Get entity hierarchy at a past time
+// @Description Reconstruct an entity and its descendants up to maxDepth levels below it as they were at asOf, including entities deleted since. Requires the viewer role on the entity. This takes the place of asOf on the public /entity/{entity_id}/hierarchy route, which rejects it.
+// @Tags Entity Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param asOf query int true "Reconstruct the hierarchy as it was at this time, in Unix milliseconds"
+// @Param maxDepth query int false "Number of levels below the entity to include (default: 10, max: 50)"
+// @Success 200 {object} dto.Response{data=dto.EntityHierarchyResponse} "Entity hierarchy retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/hierarchy/as-of [get]
+func (h *EntityHandler) HandleGetEntityHierarchyAsOf(c *gin.Context) {
+	var request dto.GetEntityHierarchyAsOfRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	maxDepth := request.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = defaultHierarchyDepth
+	}
+
+	hierarchy, err := h.entityService.GetEntityHierarchyAsOf(
 		c.Request.Context(),
-		entityID,
+		userID,
+		c.Param("entity_id"),
+		maxDepth,
+		time.UnixMilli(request.AsOf),
 	)
 	if err != nil {
-		if err.Error() == "entity with ID "+entityID+" not found" {
-			response.NotFound(c, "Entity not found")
-			return
-		}
-		log.Printf("Error getting entity hierarchy: %v", err)
-		response.InternalError(c, "Failed to retrieve entity hierarchy")
+		handleEntityError(c, err, "Failed to retrieve entity hierarchy")
+		return
+	}
+
+	response.OK(c, hierarchy, "Entity hierarchy retrieved successfully")
+}
+
+// HandleGetEntityHistory handles requests to list an entity's recorded changes
+// @Summary Get entity history
+// @Description List the recorded creates, updates, moves, deletes and restores of an entity, newest first, with before/after snapshots and the user who made each change
+// @Tags Entity Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param page query int false "Zero-based page number"
+// @Param pageSize query int false "Entries per page (default 50, max 200)"
+// @Success 200 {object} dto.Response{data=dto.PaginatedResponse{items=[]dto.EntityHistoryResponse}} "Entity history retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid query parameters"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/history [get]
+func (h *EntityHandler) HandleGetEntityHistory(c *gin.Context) {
+	var request dto.EntityHistoryRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
 		return
 	}
 
-	// Convert hierarchy map to response structure
-	hierarchyResponse := mappers.HierarchyMapToResponse(hierarchy)
-	if hierarchyResponse == nil {
-		response.InternalError(c, "Failed to process entity hierarchy")
+	result, err := h.entityService.GetEntityHistory(c.Request.Context(), userID, c.Param("entity_id"), request.Page, request.PageSize)
+	if err != nil {
+		handleEntityError(c, err, "Failed to retrieve entity history")
 		return
 	}
 
-	response.OK(c, hierarchyResponse, "Entity hierarchy retrieved successfully")
+	response.Paginated(c, result.Items, result.TotalItems, result.Page, result.PageSize)
 }
 
 // HandleCheckEntityPresence handles requests to check if a user has any entities
@@ -289,3 +412,275 @@ func (h *EntityHandler) HandleCheckEntityPresence(c *gin.Context) {
 	result := map[string]bool{"hasEntity": hasEntity}
 	response.OK(c, result, "Entity presence check successful")
 }
+
+// HandleUpdateEntity handles requests to rename an entity or replace its details
+// @Summary Update an entity
+// @Description Rename an entity and/or replace its details. Omitted fields are left unchanged.
+// @Tags Entity Management
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param entity body dto.UpdateEntityRequest true "Fields to update"
+// @Success 200 {object} dto.Response{data=dto.EntityResponse} "Entity updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id} [put]
+func (h *EntityHandler) HandleUpdateEntity(c *gin.Context) {
+	// Parse request body
+	var request dto.UpdateEntityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	if request.Name == nil && request.Details == nil {
+		response.BadRequest(c, "Nothing to update")
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	entity, err := h.entityService.UpdateEntity(c.Request.Context(), userID, c.Param("entity_id"), request.Name, request.Details)
+	if err != nil {
+		handleEntityError(c, err, "Failed to update entity")
+		return
+	}
+
+	response.OK(c, mappers.EntityToResponse(entity), "Entity updated successfully")
+}
+
+// HandleMoveEntity handles requests to move an entity under a new parent
+// @Summary Move an entity
+// @Description Re-parent an entity. The paths and depths of its whole subtree are rewritten in one transaction.
+// @Tags Entity Management
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param request body dto.MoveEntityRequest true "New parent"
+// @Success 200 {object} dto.Response{data=dto.EntityResponse} "Entity moved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 409 {object} dto.ErrorResponse "Move would create a cycle or the subtree holds another user's entity"
+// @Failure 422 {object} dto.ErrorResponse "Placement breaks the category-type parenting rules"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/move [post]
+func (h *EntityHandler) HandleMoveEntity(c *gin.Context) {
+	// Parse request body
+	var request dto.MoveEntityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	entity, err := h.entityService.MoveEntity(c.Request.Context(), userID, c.Param("entity_id"), request.ParentEntityID)
+	if err != nil {
+		handleEntityError(c, err, "Failed to move entity")
+		return
+	}
+
+	response.OK(c, mappers.EntityToResponse(entity), "Entity moved successfully")
+}
+
+// HandleDeleteEntity handles requests to delete an entity
+// @Summary Delete an entity
+// @Description Delete an entity. mode=restrict (default) refuses if it has children, mode=cascade deletes the whole subtree, and mode=reparent moves its children to its parent first. Deleted entities can be restored by an admin until the retention period ends.
+// @Tags Entity Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param mode query string false "Handling of child entities (restrict, cascade, reparent)"
+// @Success 200 {object} dto.Response "Entity deleted successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 409 {object} dto.ErrorResponse "Entity has children or its subtree holds another user's entity"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id} [delete]
+func (h *EntityHandler) HandleDeleteEntity(c *gin.Context) {
+	// Parse query parameters
+	var request dto.DeleteEntityRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	mode := repositories.DeleteModeRestrict
+	if request.Mode != "" {
+		mode = repositories.EntityDeleteMode(request.Mode)
+	}
+
+	if err := h.entityService.DeleteEntity(c.Request.Context(), userID, c.Param("entity_id"), mode); err != nil {
+		handleEntityError(c, err, "Failed to delete entity")
+		return
+	}
+
+	response.OK(c, nil, "Entity deleted successfully")
+}
+
+// HandleSearchEntities handles requests to search the user's entities
+// @Summary Search entities
+// @Description Search the entities the user can reach, through their own entity or memberships, by name (prefix, substring or fuzzy), category type or ID, ancestor entity, details values and tags, with sorting and page or cursor pagination. Results include each entity's tags.
+// @Tags Entity Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param name query string false "Name to search for"
+// @Param match query string false "Name matching: prefix (default), contains or fuzzy"
+// @Param categoryType query string false "Filter by category type"
+// @Param categoryId query string false "Filter by category ID"
+// @Param under query string false "Only return descendants of this entity"
+// @Param details query []string false "Details filters as key=value, nested keys dotted (e.g. address.city=Kochi)" collectionFormat(multi)
+// @Param tags query []string false "Tag filters as key or key=value; entities must carry every tag" collectionFormat(multi)
+// @Param sort query string false "Sort by name (default), createdAt, updatedAt or depth"
+// @Param order query string false "asc (default) or desc"
+// @Param page query int false "Zero-based page number, ignored when a cursor is given"
+// @Param pageSize query int false "Results per page (default 20, max 100)"
+// @Param cursor query string false "Cursor of the next page from a previous response"
+// @Success 200 {object} dto.Response{data=dto.PaginatedResponse{items=[]dto.EntityResponse}} "Matching entities"
+// @Failure 400 {object} dto.ErrorResponse "Validation error or invalid cursor"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/search [get]
+func (h *EntityHandler) HandleSearchEntities(c *gin.Context) {
+	var request dto.EntitySearchRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	result, err := h.entityService.SearchEntities(c.Request.Context(), userID, request)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			response.BadRequest(c, "Invalid cursor")
+			return
+		}
+		if errors.Is(err, services.ErrInvalidSearch) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		log.Printf("Error searching entities: %v", err)
+		response.InternalError(c, "Failed to search entities")
+		return
+	}
+
+	response.PaginatedWithCursor(c, result.Items, result.TotalItems, result.Page, result.PageSize, result.NextCursor)
+}
+
+// HandleAuditTreeRules handles requests to check every entity against the parenting rules
+// @Summary Audit entity tree rules
+// @Description Check every existing entity against the current category-type parenting rules (allowed roots, allowed child types and maximum depths) and list the entities that break them. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=dto.EntityRuleAuditResponse} "Entity tree audited successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/entity-rules/audit [get]
+func (h *EntityHandler) HandleAuditTreeRules(c *gin.Context) {
+	audit, err := h.entityService.AuditTreeRules(c.Request.Context())
+	if err != nil {
+		log.Printf("Error auditing entity tree: %v", err)
+		response.InternalError(c, "Failed to audit entity tree")
+		return
+	}
+
+	response.OK(c, audit, "Entity tree audited successfully")
+}
+
+// handleEntityError maps entity service errors to HTTP responses
+func handleEntityError(c *gin.Context, err error, message string) {
+	var (
+		detailsErr *services.DetailsValidationError
+		ruleErr    *services.RuleViolationError
+	)
+	switch {
+	case errors.As(err, &detailsErr):
+		response.ValidationErrors(c, mappers.SchemaFieldErrorsToValidationErrors(detailsErr.Errors))
+	case errors.As(err, &ruleErr):
+		response.Error(c, http.StatusUnprocessableEntity, ruleErr.Error(), "RULE_VIOLATION")
+	case errors.Is(err, services.ErrEntityNotFound):
+		response.NotFound(c, "Entity not found")
+	case errors.Is(err, services.ErrEntityAccessDenied):
+		response.Forbidden(c, "Entity is not accessible to user")
+	case errors.Is(err, services.ErrEntityCycle),
+		errors.Is(err, services.ErrEntityHasChildren),
+		errors.Is(err, services.ErrEntityProtected):
+		response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
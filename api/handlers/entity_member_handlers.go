@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// EntityMemberHandler handles requests to manage entity memberships
+type EntityMemberHandler struct {
+	memberService *services.EntityMemberService
+}
+
+// NewEntityMemberHandler creates a new EntityMemberHandler
+func NewEntityMemberHandler(memberService *services.EntityMemberService) *EntityMemberHandler {
+	return &EntityMemberHandler{memberService: memberService}
+}
+
+// HandleListUserEntities handles requests to list the entities a user can reach
+// @Summary List user entities
+// @Description List every entity the authenticated user can reach, through their own entity or accepted memberships, with their effective role. Roles are inherited down the tree; the strongest applies.
+// @Tags Entity Membership
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=[]dto.UserEntityResponse} "User entities retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/entities [get]
+func (h *EntityMemberHandler) HandleListUserEntities(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	entities, err := h.memberService.ListUserEntities(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing user entities: %v", err)
+		response.InternalError(c, "Failed to retrieve user entities")
+		return
+	}
+
+	response.OK(c, entities, "User entities retrieved successfully")
+}
+
+// HandleListInvitations handles requests to list a user's pending invitations
+// @Summary List entity invitations
+// @Description List the entity invitations the authenticated user has not accepted yet
+// @Tags Entity Membership
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=[]dto.EntityMemberResponse} "Invitations retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/entity-invitations [get]
+func (h *EntityMemberHandler) HandleListInvitations(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	invitations, err := h.memberService.ListInvitations(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error listing entity invitations: %v", err)
+		response.InternalError(c, "Failed to retrieve invitations")
+		return
+	}
+
+	response.OK(c, invitations, "Invitations retrieved successfully")
+}
+
+// HandleListMembers handles requests to list the members of an entity
+// @Summary List entity members
+// @Description List the memberships and pending invitations granted directly on an entity. Requires any role on the entity.
+// @Tags Entity Membership
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Success 200 {object} dto.Response{data=[]dto.EntityMemberResponse} "Entity members retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/members [get]
+func (h *EntityMemberHandler) HandleListMembers(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	members, err := h.memberService.ListMembers(c.Request.Context(), userID, c.Param("entity_id"))
+	if err != nil {
+		handleEntityMemberError(c, err, "Failed to retrieve entity members")
+		return
+	}
+
+	response.OK(c, members, "Entity members retrieved successfully")
+}
+
+// HandleInviteMember handles requests to invite a user to an entity
+// @Summary Invite an entity member
+// @Description Invite a registered user, by email, to hold a role on an entity and its subtree. The invitation takes effect once accepted. Admins may invite admins and viewers; only owners may invite owners.
+// @Tags Entity Membership
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param request body dto.InviteEntityMemberRequest true "Invitation"
+// @Success 201 {object} dto.Response{data=dto.EntityMemberResponse} "Invitation sent successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity or user not found"
+// @Failure 409 {object} dto.ErrorResponse "User is already a member"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/members [post]
+func (h *EntityMemberHandler) HandleInviteMember(c *gin.Context) {
+	// Parse request body
+	var request dto.InviteEntityMemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	member, err := h.memberService.InviteMember(c.Request.Context(), userID, c.Param("entity_id"), request)
+	if err != nil {
+		handleEntityMemberError(c, err, "Failed to invite entity member")
+		return
+	}
+
+	response.Created(c, member, "Invitation sent successfully")
+}
+
+// HandleAcceptInvitation handles requests to accept an invitation to an entity
+// @Summary Accept an entity invitation
+// @Description Accept the authenticated user's pending invitation to an entity
+// @Tags Entity Membership
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Success 200 {object} dto.Response{data=dto.EntityMemberResponse} "Invitation accepted successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Invitation not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/members/accept [post]
+func (h *EntityMemberHandler) HandleAcceptInvitation(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	member, err := h.memberService.AcceptInvitation(c.Request.Context(), userID, c.Param("entity_id"))
+	if err != nil {
+		handleEntityMemberError(c, err, "Failed to accept invitation")
+		return
+	}
+
+	response.OK(c, member, "Invitation accepted successfully")
+}
+
+// HandleRemoveMember handles requests to remove a member from an entity
+// @Summary Remove an entity member
+// @Description Remove a membership or withdraw an invitation. Users may remove themselves, which also declines an invitation; removing others takes an admin, or an owner for owners. Ownership of a user's own entity cannot be removed.
+// @Tags Entity Membership
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param user_id path string true "User ID of the member"
+// @Success 200 {object} dto.Response "Member removed successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity or member not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/members/{user_id} [delete]
+func (h *EntityMemberHandler) HandleRemoveMember(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.memberService.RemoveMember(c.Request.Context(), userID, c.Param("entity_id"), c.Param("user_id")); err != nil {
+		handleEntityMemberError(c, err, "Failed to remove entity member")
+		return
+	}
+
+	response.OK(c, nil, "Member removed successfully")
+}
+
+// handleEntityMemberError maps membership errors to HTTP responses, deferring to handleEntityError for the rest
+func handleEntityMemberError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrEntityMemberNotFound),
+		errors.Is(err, services.ErrInvitationNotFound),
+		errors.Is(err, services.ErrInviteeNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrInvalidInvitation):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrEntityMemberExists):
+		response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+	default:
+		handleEntityError(c, err, message)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// EntityMetricsHandler handles requests for telemetry rolled up across entity subtrees
+type EntityMetricsHandler struct {
+	metricsService *services.EntityMetricsService
+}
+
+// NewEntityMetricsHandler creates a new EntityMetricsHandler
+func NewEntityMetricsHandler(metricsService *services.EntityMetricsService) *EntityMetricsHandler {
+	return &EntityMetricsHandler{metricsService: metricsService}
+}
+
+// HandleGetEntityMetrics handles requests to roll up sensor metrics across an entity subtree
+// @Summary Get entity subtree metrics
+// @Description Aggregate the telemetry of every device beneath an entity into bucketed series (total current draw, average temperature and humidity) for the whole subtree and for each direct child entity
+// @Tags Entity Management
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param request body dto.SensorDataAggregateRequest true "Time range and bucket interval"
+// @Success 200 {object} dto.Response{data=dto.EntityMetricsResponse} "Entity metrics retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/metrics [post]
+func (h *EntityMetricsHandler) HandleGetEntityMetrics(c *gin.Context) {
+	// Parse request body
+	var request dto.SensorDataAggregateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	interval, err := time.ParseDuration(request.Interval)
+	if err != nil {
+		response.BadRequest(c, "Interval must be a duration such as 15m or 1h")
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	metrics, err := h.metricsService.GetSubtreeMetrics(
+		c.Request.Context(),
+		userID,
+		c.Param("entity_id"),
+		time.UnixMilli(request.StartTime),
+		time.UnixMilli(request.EndTime),
+		interval,
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMetricsRange) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		handleEntityError(c, err, "Failed to retrieve entity metrics")
+		return
+	}
+
+	response.OK(c, metrics, "Entity metrics retrieved successfully")
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// maxTreeImportBytes caps the size of an imported tree document
+const maxTreeImportBytes = 10 << 20
+
+// HandleImportEntityTree handles requests to create a tree of entities in one go
+// @Summary Import an entity tree
+// @Description Create a nested tree of entities beneath an entity in one transaction. The body is JSON, or YAML when sent with a YAML content type, in the format produced by the export endpoint. Each node names a category by name or ID, with categoryType to pick between categories sharing a name. The whole tree is validated first; if any node is invalid nothing is created and every error is reported with its position in the tree. User entities cannot be imported. Requires the admin role on the parent entity.
+// @Tags Entity Management
+// @Accept json,application/yaml
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Parent entity ID"
+// @Param tree body dto.EntityTreeNode true "Entity tree"
+// @Success 201 {object} dto.Response{data=dto.EntityTreeImportResponse} "Entity tree imported successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid tree"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/import [post]
+func (h *EntityHandler) HandleImportEntityTree(c *gin.Context) {
+	var tree dto.EntityTreeNode
+	if err := decodeEntityTree(c, &tree); err != nil {
+		log.Printf("Error decoding entity tree: %v", err)
+		response.BadRequest(c, fmt.Sprintf("Invalid request format: %v", err))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	result, err := h.entityService.ImportEntityTree(c.Request.Context(), userID, c.Param("entity_id"), &tree)
+	if err != nil {
+		var importErr *services.TreeImportError
+		if errors.As(err, &importErr) {
+			response.ValidationErrors(c, importErr.Errors)
+			return
+		}
+		handleEntityError(c, err, "Failed to import entity tree")
+		return
+	}
+
+	response.Created(c, result, "Entity tree imported successfully")
+}
+
+// HandleExportEntityTree handles requests to export an entity and its subtree
+// @Summary Export an entity tree
+// @Description Export an entity and its whole subtree as JSON or YAML, in the format accepted by the import endpoint. Categories are referenced by name and type so the tree can be imported into another environment. User entities cannot be imported, so those below the entity are left out with their subtrees, and a user entity cannot be exported itself. Requires any role on the entity.
+// @Tags Entity Management
+// @Produce json,application/yaml
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Param format query string false "Output format (json or yaml, default json)"
+// @Success 200 {object} dto.EntityTreeNode "Entity tree"
+// @Failure 400 {object} dto.ErrorResponse "Validation error or the entity is a user entity"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /entity/{entity_id}/export [get]
+func (h *EntityHandler) HandleExportEntityTree(c *gin.Context) {
+	var request dto.ExportEntityTreeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	entityID := c.Param("entity_id")
+	tree, err := h.entityService.ExportEntityTree(c.Request.Context(), userID, entityID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserEntityExport) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		handleEntityError(c, err, "Failed to export entity tree")
+		return
+	}
+
+	format := request.Format
+	if format == "" {
+		format = "json"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("entity-%s.%s", sanitizeFilename(entityID), format)))
+
+	if format == "yaml" {
+		out, err := yaml.Marshal(tree)
+		if err != nil {
+			log.Printf("Error encoding entity tree: %v", err)
+			response.InternalError(c, "Failed to export entity tree")
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml", out)
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// decodeEntityTree reads an entity tree from the request body as YAML when the content
+// type says so and as JSON otherwise. Unknown fields are rejected to catch typos.
+func decodeEntityTree(c *gin.Context, tree *dto.EntityTreeNode) error {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxTreeImportBytes)
+
+	if strings.Contains(c.ContentType(), "yaml") {
+		decoder := yaml.NewDecoder(body)
+		decoder.KnownFields(true)
+		return decoder.Decode(tree)
+	}
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(tree)
+}
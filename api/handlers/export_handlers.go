@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/export"
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// ExportHandler handles sensor data export requests
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// HandleExportSensorData handles requests to export device sensor data
+// @Summary Export device sensor data
+// @Description Stream sensor data as CSV, Parquet or NDJSON using the same range semantics as /device/sensor-data. Ranges longer than the configured limit, or requests with async=true, start a background job instead and return 202 with the job status.
+// @Tags Device Data
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.apache.parquet
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Param format query string true "Export format (csv, parquet, ndjson)"
+// @Param timestamp query string true "End of the range in milliseconds"
+// @Param dateMode query string true "Range length (hourly, daily, weekly, monthly, yearly)"
+// @Param async query bool false "Force an asynchronous export job"
+// @Success 200 {file} file "Exported sensor data"
+// @Success 202 {object} dto.Response{data=dto.ExportJobResponse} "Export job started"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Device does not belong to user"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /device/{mac}/sensor-data/export [get]
+func (h *ExportHandler) HandleExportSensorData(c *gin.Context) {
+	// Parse query parameters
+	var request dto.SensorDataExportRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	macID := c.Param("mac")
+	format := export.Format(request.Format)
+
+	start, end, err := h.exportService.ResolveExportRange(c.Request.Context(), userID, macID, request.Timestamp, request.DateMode)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExportTimestamp) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		handleDeviceError(c, err, "Failed to export sensor data")
+		return
+	}
+
+	if request.Async || h.exportService.RequiresAsync(start, end) {
+		job, err := h.exportService.StartExportJob(c.Request.Context(), userID, macID, start, end, format)
+		if err != nil {
+			log.Printf("Error starting export job: %v", err)
+			response.InternalError(c, "Failed to start export job")
+			return
+		}
+
+		response.Success(c, http.StatusAccepted, job, "Export job started")
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%d_%d.%s", sanitizeFilename(macID), start.UnixMilli(), end.UnixMilli(), format.Extension())
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent once streaming starts, so failures can only be logged
+	rowCount, err := h.exportService.StreamExport(c.Request.Context(), macID, start, end, format, c.Writer)
+	if err != nil {
+		log.Printf("Error streaming export after %d rows: %v", rowCount, err)
+		c.Abort()
+		return
+	}
+
+	log.Printf("Exported %d rows for device %s", rowCount, macID)
+}
+
+// HandleGetExportJob handles requests to get the status of an export job
+// @Summary Get export job status
+// @Description Get the status of an asynchronous export job, including its download link once completed
+// @Tags Device Data
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param job_id path string true "Export job ID"
+// @Success 200 {object} dto.Response{data=dto.ExportJobResponse} "Export job retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Export job not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /export-jobs/{job_id} [get]
+func (h *ExportHandler) HandleGetExportJob(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	job, err := h.exportService.GetExportJob(c.Request.Context(), userID, c.Param("job_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrExportJobNotFound) {
+			response.NotFound(c, "Export job not found")
+			return
+		}
+		log.Printf("Error getting export job: %v", err)
+		response.InternalError(c, "Failed to retrieve export job")
+		return
+	}
+
+	response.OK(c, job, "Export job retrieved successfully")
+}
+
+// HandleDownloadExport handles requests to download the output of an export job
+// @Summary Download export
+// @Description Download the file produced by a completed export job
+// @Tags Device Data
+// @Produce octet-stream
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param job_id path string true "Export job ID"
+// @Success 200 {file} file "Exported sensor data"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "Export job not found"
+// @Failure 409 {object} dto.ErrorResponse "Export job has not completed"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /export-jobs/{job_id}/download [get]
+func (h *ExportHandler) HandleDownloadExport(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	reader, job, err := h.exportService.OpenExportDownload(c.Request.Context(), userID, c.Param("job_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrExportJobNotFound):
+			response.NotFound(c, "Export job not found")
+		case errors.Is(err, services.ErrExportJobNotReady):
+			response.Error(c, http.StatusConflict, "Export job has not completed", "CONFLICT")
+		default:
+			log.Printf("Error opening export download: %v", err)
+			response.InternalError(c, "Failed to download export")
+		}
+		return
+	}
+	defer reader.Close()
+
+	format := export.Format(job.Format)
+	filename := fmt.Sprintf("%s_%d_%d.%s", sanitizeFilename(job.MacAddress), job.StartTime.UnixMilli(), job.EndTime.UnixMilli(), format.Extension())
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("Error sending export %s: %v", job.ID, err)
+	}
+}
+
+// sanitizeFilename replaces characters that are awkward in file names, such as the colons in MAC addresses
+func sanitizeFilename(name string) string {
+	out := []rune(name)
+	for i, r := range out {
+		if r == ':' || r == '/' || r == '\\' || r == '"' {
+			out[i] = '-'
+		}
+	}
+	return string(out)
+}
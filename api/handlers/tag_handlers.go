@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+// TagHandler handles requests to tag entities and devices
+type TagHandler struct {
+	tagService *services.TagService
+}
+
+// NewTagHandler creates a new TagHandler
+func NewTagHandler(tagService *services.TagService) *TagHandler {
+	return &TagHandler{tagService: tagService}
+}
+
+// HandleApplyTags handles requests to tag several entities and devices at once
+// @Summary Tag entities and devices
+// @Description Set key/value tags on several entities and devices at once, replacing the value of keys they already carry. Keys are case-insensitive; the value may be left empty to use a tag as a plain label. Requires the admin role on every entity and ownership of every device; if any is missing nothing is tagged.
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param request body dto.BulkTagRequest true "Targets and tags"
+// @Success 200 {object} dto.Response "Tags applied successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity or device is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity or device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /tags [post]
+func (h *TagHandler) HandleApplyTags(c *gin.Context) {
+	// Parse request body
+	var request dto.BulkTagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.tagService.ApplyTags(c.Request.Context(), userID, request); err != nil {
+		handleTagError(c, err, "Failed to apply tags")
+		return
+	}
+
+	response.OK(c, nil, "Tags applied successfully")
+}
+
+// HandleRemoveTags handles requests to untag several entities and devices at once
+// @Summary Untag entities and devices
+// @Description Remove tag keys from several entities and devices at once. Requires the admin role on every entity and ownership of every device; if any is missing nothing is removed.
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param request body dto.BulkUntagRequest true "Targets and tag keys"
+// @Success 200 {object} dto.Response{data=dto.BulkUntagResponse} "Tags removed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Entity or device is not accessible to user"
+// @Failure 404 {object} dto.ErrorResponse "Entity or device not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /tags/remove [post]
+func (h *TagHandler) HandleRemoveTags(c *gin.Context) {
+	// Parse request body
+	var request dto.BulkUntagRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	result, err := h.tagService.RemoveTags(c.Request.Context(), userID, request)
+	if err != nil {
+		handleTagError(c, err, "Failed to remove tags")
+		return
+	}
+
+	response.OK(c, result, "Tags removed successfully")
+}
+
+// HandleSuggestTags handles tag autocomplete requests
+// @Summary Suggest tags
+// @Description Autocomplete tag keys, or the values of a key, starting with a prefix, most used first. Only tags on entities and devices the user can reach are suggested.
+// @Tags Tags
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param key query string false "Suggest values of this key instead of keys"
+// @Param prefix query string false "Prefix to complete"
+// @Param limit query int false "Maximum number of suggestions (default 10, max 50)"
+// @Success 200 {object} dto.Response{data=[]dto.TagSuggestionResponse} "Tag suggestions retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /tags/suggest [get]
+func (h *TagHandler) HandleSuggestTags(c *gin.Context) {
+	var request dto.TagSuggestRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	suggestions, err := h.tagService.SuggestTags(c.Request.Context(), userID, request)
+	if err != nil {
+		log.Printf("Error suggesting tags: %v", err)
+		response.InternalError(c, "Failed to retrieve tag suggestions")
+		return
+	}
+
+	response.OK(c, suggestions, "Tag suggestions retrieved successfully")
+}
+
+// handleTagError maps tagging errors to HTTP responses, deferring to the entity and device
+// error mappers for errors about the tagged resources
+func handleTagError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTag),
+		errors.Is(err, services.ErrNoTagTargets):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrDeviceNotFound),
+		errors.Is(err, services.ErrDeviceAccessDenied):
+		handleDeviceError(c, err, message)
+	default:
+		handleEntityError(c, err, message)
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"n1h41/zolaris-backend-app/internal/middleware"
+	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/transport/response"
+)
+
+// TrashHandler handles admin requests to delete users and to restore or purge deleted records
+type TrashHandler struct {
+	trashService *services.TrashService
+}
+
+// NewTrashHandler creates a new TrashHandler
+func NewTrashHandler(trashService *services.TrashService) *TrashHandler {
+	return &TrashHandler{trashService: trashService}
+}
+
+// HandleListTrash handles requests to list deleted records
+// @Summary List deleted records
+// @Description List the deleted users, entities and devices that can still be restored, with the time each will be purged. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=dto.TrashResponse} "Deleted records retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/trash [get]
+func (h *TrashHandler) HandleListTrash(c *gin.Context) {
+	trash, err := h.trashService.ListTrash(c.Request.Context())
+	if err != nil {
+		log.Printf("Error listing deleted records: %v", err)
+		response.InternalError(c, "Failed to retrieve deleted records")
+		return
+	}
+
+	response.OK(c, trash, "Deleted records retrieved successfully")
+}
+
+// HandleDeleteUser handles requests to delete a user
+// @Summary Delete a user
+// @Description Delete a user along with their devices and entity subtree. Everything can be restored together until the retention period ends. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} dto.Response "User deleted successfully"
+// @Failure 400 {object} dto.ErrorResponse "Admins cannot delete their own account"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/users/{user_id} [delete]
+func (h *TrashHandler) HandleDeleteUser(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.trashService.DeleteUser(c.Request.Context(), userID, c.Param("user_id")); err != nil {
+		handleTrashError(c, err, "Failed to delete user")
+		return
+	}
+
+	response.OK(c, nil, "User deleted successfully")
+}
+
+// HandleRestoreUser handles requests to restore a deleted user
+// @Summary Restore a user
+// @Description Restore a deleted user along with the devices and entities deleted with them. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} dto.Response "User restored successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 409 {object} dto.ErrorResponse "User is not deleted, or their entity's parent is still deleted"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/users/{user_id}/restore [post]
+func (h *TrashHandler) HandleRestoreUser(c *gin.Context) {
+	if err := h.trashService.RestoreUser(c.Request.Context(), c.Param("user_id")); err != nil {
+		handleTrashError(c, err, "Failed to restore user")
+		return
+	}
+
+	response.OK(c, nil, "User restored successfully")
+}
+
+// HandleRestoreEntity handles requests to restore a deleted entity
+// @Summary Restore an entity
+// @Description Restore a deleted entity along with the descendants deleted with it. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param entity_id path string true "Entity ID"
+// @Success 200 {object} dto.Response "Entity restored successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Entity not found"
+// @Failure 409 {object} dto.ErrorResponse "Entity is not deleted, or its parent or owner is still deleted"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/entities/{entity_id}/restore [post]
+func (h *TrashHandler) HandleRestoreEntity(c *gin.Context) {
+	if err := h.trashService.RestoreEntity(c.Request.Context(), c.Param("entity_id")); err != nil {
+		handleTrashError(c, err, "Failed to restore entity")
+		return
+	}
+
+	response.OK(c, nil, "Entity restored successfully")
+}
+
+// HandleRestoreDevice handles requests to restore a deleted device
+// @Summary Restore a device
+// @Description Restore a deleted device to its owner. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param mac path string true "Device MAC address"
+// @Success 200 {object} dto.Response "Device restored successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 404 {object} dto.ErrorResponse "Device not found"
+// @Failure 409 {object} dto.ErrorResponse "Device is not deleted, or its owner is still deleted"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/devices/{mac}/restore [post]
+func (h *TrashHandler) HandleRestoreDevice(c *gin.Context) {
+	if err := h.trashService.RestoreDevice(c.Request.Context(), c.Param("mac")); err != nil {
+		handleTrashError(c, err, "Failed to restore device")
+		return
+	}
+
+	response.OK(c, nil, "Device restored successfully")
+}
+
+// HandlePurgeTrash handles requests to purge expired records immediately
+// @Summary Purge expired records
+// @Description Permanently remove the users, entities and devices deleted longer ago than the retention period, without waiting for the scheduled purge. Admin only.
+// @Tags Admin
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=dto.PurgeResponse} "Expired records purged successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 403 {object} dto.ErrorResponse "Admin role required"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/trash/purge [post]
+func (h *TrashHandler) HandlePurgeTrash(c *gin.Context) {
+	result, err := h.trashService.PurgeExpired(c.Request.Context())
+	if err != nil {
+		log.Printf("Error purging deleted records: %v", err)
+		response.InternalError(c, "Failed to purge expired records")
+		return
+	}
+
+	response.OK(c, result, "Expired records purged successfully")
+}
+
+// handleTrashError maps delete and restore errors to HTTP responses
+func handleTrashError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrEntityNotFound),
+		errors.Is(err, services.ErrDeviceNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrSelfDeletion):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrNotDeleted),
+		errors.Is(err, services.ErrRestoreBlocked):
+		response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 
@@ -62,15 +65,16 @@ func (h *UserHandler) HandleGetUserDetails(c *gin.Context) {
 
 // HandleGin handles POST /user/details requests
 // @Summary Update user details
-// @Description Update the authenticated user's profile information
+// @Description Fill in the authenticated user's whole profile, replacing what was there. Every field is required; use PATCH /user/details to change only some of them. Phone numbers must be in E.164 form and countries ISO 3166-1 alpha-2 codes. The email must be the user's current one; it changes only when the sign-in account verifies a new address.
 // @Tags User Management
 // @Accept json
 // @Produce json
 // @Param X-User-ID header string true "User ID"
 // @Param user body dto.UserDetailsRequest true "User details"
 // @Success 200 {object} dto.Response{data=dto.UserResponse} "User details updated successfully"
-// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 400 {object} dto.ErrorResponse "Validation error, unknown referrer or changed email"
 // @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 409 {object} dto.ErrorResponse "User already has another referrer or the referral would form a cycle"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
 // @Router /user/details [post]
@@ -111,8 +115,18 @@ func (h *UserHandler) HandleUpdateUserDetails(c *gin.Context) {
 	// Update user details
 	updatedUser, err := h.userService.UpdateUserDetails(c.Request.Context(), userID, &request)
 	if err != nil {
-		log.Printf("Error updating user details: %v", err)
-		response.InternalError(c, "Failed to update user details")
+		switch {
+		case errors.Is(err, services.ErrReferrerNotFound),
+			errors.Is(err, services.ErrSelfReferral),
+			errors.Is(err, services.ErrEmailChangeUnverified):
+			response.BadRequest(c, err.Error())
+		case errors.Is(err, services.ErrReferralExists),
+			errors.Is(err, services.ErrReferralCycle):
+			response.Error(c, http.StatusConflict, err.Error(), "CONFLICT")
+		default:
+			log.Printf("Error updating user details: %v", err)
+			response.InternalError(c, "Failed to update user details")
+		}
 		return
 	}
 
@@ -121,15 +135,263 @@ func (h *UserHandler) HandleUpdateUserDetails(c *gin.Context) {
 	response.OK(c, userResponse, "User details updated successfully")
 }
 
+// HandlePatchUserDetails handles PATCH /user/details requests
+// @Summary Patch user details
+// @Description Change part of the authenticated user's profile with a JSON Merge Patch (RFC 7386) against the document {email, firstName, lastName, phone, address}. Members left out are unchanged and null members are cleared. The email cannot be changed; it follows the address verified by the sign-in account. The patched profile must be valid as a whole: phone numbers in E.164 form and countries as ISO 3166-1 alpha-2 codes.
+// @Tags User Management
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param patch body dto.UserProfileDocument true "Merge patch"
+// @Success 200 {object} dto.Response{data=dto.UserResponse} "User details updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid patch, validation error or changed email"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 415 {object} dto.ErrorResponse "Unsupported content type"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/details [patch]
+func (h *UserHandler) HandlePatchUserDetails(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	updatedUser, err := h.userService.PatchUserDetails(c.Request.Context(), userID, patch)
+	if err != nil {
+		handleProfileError(c, err, "Failed to update user details")
+		return
+	}
+
+	response.OK(c, mappers.UserToResponse(updatedUser), "User details updated successfully")
+}
+
+// HandleGetAddress handles GET /user/address requests
+// @Summary Get user address
+// @Description Retrieve the authenticated user's postal address
+// @Tags User Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=dto.AddressOutput} "Address retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 404 {object} dto.ErrorResponse "User has no address"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/address [get]
+func (h *UserHandler) HandleGetAddress(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	address, err := h.userService.GetUserAddress(c.Request.Context(), userID)
+	if err != nil {
+		handleProfileError(c, err, "Failed to retrieve address")
+		return
+	}
+
+	response.OK(c, address, "Address retrieved successfully")
+}
+
+// HandleReplaceAddress handles PUT /user/address requests
+// @Summary Set user address
+// @Description Set the authenticated user's postal address, replacing any they had. The country is an ISO 3166-1 alpha-2 code.
+// @Tags User Management
+// @Accept json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param address body dto.AddressRequest true "Address"
+// @Success 200 {object} dto.Response{data=dto.AddressOutput} "Address updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/address [put]
+func (h *UserHandler) HandleReplaceAddress(c *gin.Context) {
+	// Parse request body
+	var request dto.AddressRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Error decoding request: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	address, err := h.userService.ReplaceUserAddress(c.Request.Context(), userID, request)
+	if err != nil {
+		handleProfileError(c, err, "Failed to update address")
+		return
+	}
+
+	response.OK(c, address, "Address updated successfully")
+}
+
+// HandlePatchAddress handles PATCH /user/address requests
+// @Summary Patch user address
+// @Description Change part of the authenticated user's postal address with a JSON Merge Patch (RFC 7386). The patched address must be complete and valid.
+// @Tags User Management
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Param patch body dto.AddressRequest true "Merge patch"
+// @Success 200 {object} dto.Response{data=dto.AddressOutput} "Address updated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid patch or validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 415 {object} dto.ErrorResponse "Unsupported content type"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/address [patch]
+func (h *UserHandler) HandlePatchAddress(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	address, err := h.userService.PatchUserAddress(c.Request.Context(), userID, patch)
+	if err != nil {
+		handleProfileError(c, err, "Failed to update address")
+		return
+	}
+
+	response.OK(c, address, "Address updated successfully")
+}
+
+// HandleDeleteAddress handles DELETE /user/address requests
+// @Summary Delete user address
+// @Description Remove the authenticated user's postal address
+// @Tags User Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response "Address removed successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/address [delete]
+func (h *UserHandler) HandleDeleteAddress(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.userService.DeleteUserAddress(c.Request.Context(), userID); err != nil {
+		handleProfileError(c, err, "Failed to remove address")
+		return
+	}
+
+	response.OK(c, nil, "Address removed successfully")
+}
+
+// readMergePatch reads a JSON Merge Patch request body, responding with an error and
+// returning false if the body has the wrong content type or cannot be read
+func readMergePatch(c *gin.Context) ([]byte, bool) {
+	if contentType := c.ContentType(); contentType != "application/merge-patch+json" && contentType != "application/json" {
+		response.Error(c, http.StatusUnsupportedMediaType, "Content type must be application/merge-patch+json", "UNSUPPORTED_MEDIA_TYPE")
+		return nil, false
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		response.BadRequest(c, "Invalid request format")
+		return nil, false
+	}
+	if len(bytes.TrimSpace(patch)) == 0 {
+		response.BadRequest(c, "Request body must be a merge patch")
+		return nil, false
+	}
+
+	return patch, true
+}
+
+// handleProfileError maps profile update errors to HTTP responses
+func handleProfileError(c *gin.Context, err error, message string) {
+	var validationErr *services.ProfileValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		response.ValidationErrors(c, validationErr.Errors)
+	case errors.Is(err, services.ErrInvalidProfilePatch),
+		errors.Is(err, services.ErrEmailChangeUnverified):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrUserNotFound):
+		response.NotFound(c, "User not found")
+	case errors.Is(err, services.ErrAddressNotSet):
+		response.NotFound(c, err.Error())
+	default:
+		log.Printf("%s: %v", message, err)
+		response.InternalError(c, message)
+	}
+}
+
+// HandleGetOnboardingStatus handles GET /user/onboarding-status requests
+// @Summary Get onboarding status
+// @Description Report which account setup steps the authenticated user has completed: filling in their profile, creating their entity and adding a device. pendingSteps lists what is left, in order.
+// @Tags User Management
+// @Produce json
+// @Param X-Cognito-ID header string true "Cognito ID"
+// @Success 200 {object} dto.Response{data=dto.OnboardingStatusResponse} "Onboarding status retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /user/onboarding-status [get]
+func (h *UserHandler) HandleGetOnboardingStatus(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	status, err := h.userService.GetOnboardingStatus(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error retrieving onboarding status: %v", err)
+		response.InternalError(c, "Failed to retrieve onboarding status")
+		return
+	}
+
+	response.OK(c, status, "Onboarding status retrieved successfully")
+}
+
 // HandleGin handles GET /user/check-parent-id requests
 // @Summary Check if user has parent ID
-// @Description Checks if the authenticated user has a parent ID set in their profile
+// @Description Checks if the authenticated user has a parent ID set in their profile. Superseded by /user/onboarding-status.
 // @Tags User Management
 // @Produce json
 // @Param X-User-ID header string true "User ID"
 // @Success 200 {object} map[string]bool "Returns has_parent_id flag"
 // @Failure 400 {object} map[string]string "Error when user ID is not found in context"
 // @Failure 500 {object} map[string]string "Error when checking parent ID fails"
+// @Deprecated
 // @Router /user/check-parent-id [get]
 func (h *UserHandler) HandleCheckHasParentID(c *gin.Context) {
 	// Extract user ID from the request context
@@ -140,40 +402,109 @@ func (h *UserHandler) HandleCheckHasParentID(c *gin.Context) {
 	}
 
 	// Check if the user has a parent ID
-	hasParentID, err := h.userService.CheckHasParentID(c.Request.Context(), userID.(string))
+	status, err := h.userService.GetOnboardingStatus(c.Request.Context(), userID.(string))
 	if err != nil {
 		log.Printf("Error checking parent ID: %v", err)
 		response.InternalError(c, "Failed to check parent ID")
 		return
 	}
 
-	response.OK(c, gin.H{"has_parent_id": hasParentID}, "Success")
+	response.OK(c, gin.H{"has_parent_id": status.HasParentID}, "Success")
 }
 
-// HandleListReferredUsers handles GET /user/referred-users requests
-// @Summary List referred users
-// @Description Retrieve a list of users referred by the authenticated user
+// HandleListReferredUsers handles GET /users/referrals requests
+// @Summary List referral tree
+// @Description Retrieve the users referred by the authenticated user, each with the users they referred in turn, down to the requested depth. Nodes at the last level report hasMore when they referred further users.
 // @Tags User Management
 // @Produce json
 // @Param X-User-ID header string true "User ID"
-// @Success 200 {object} dto.Response{data=[]dto.UserResponse} "Referred users retrieved successfully"
-// @Failure 400 {object} dto.ErrorResponse "User ID not found in context"
+// @Param depth query int false "Levels of referrals to include (default 1, max 10)"
+// @Success 200 {object} dto.Response{data=[]dto.ReferralNodeResponse} "Referral tree retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
-// @Router /user/referred-users [get]
+// @Router /users/referrals [get]
 func (h *UserHandler) HandleListReferredUsers(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		response.BadRequest(c, "User ID not found in context")
+	var request dto.ReferralTreeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
+		return
+	}
+
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	referrals, err := h.userService.ListReferralTree(c.Request.Context(), userID, request.Depth)
+	if err != nil {
+		log.Printf("Error listing referral tree: %v", err)
+		response.InternalError(c, "Failed to list referrals")
+		return
+	}
+
+	response.OK(c, referrals, "Referral tree retrieved successfully")
+}
+
+// HandleGetReferralStats handles GET /users/referrals/stats requests
+// @Summary Get referral statistics
+// @Description Count the users referred by the authenticated user and how many of them converted by registering a device, with signups and conversions bucketed by day, week or month over a time window.
+// @Tags User Management
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param startTime query int true "Start of the window (Unix milliseconds)"
+// @Param endTime query int true "End of the window (Unix milliseconds)"
+// @Param interval query string false "Bucket size: day, week or month (default day)"
+// @Param depth query int false "Levels of referrals to count (default all, max 10)"
+// @Success 200 {object} dto.Response{data=dto.ReferralStatsResponse} "Referral statistics retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Validation error"
+// @Failure 401 {object} dto.ErrorResponse "User not authenticated"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/referrals/stats [get]
+func (h *UserHandler) HandleGetReferralStats(c *gin.Context) {
+	var request dto.ReferralStatsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		response.BadRequest(c, "Invalid query parameters")
 		return
 	}
 
-	referredUsers, err := h.userService.ListReferredUsers(c.Request.Context(), userID.(string))
+	// Validate request
+	validationErrs := utils.Validate(request)
+	if validationErrs != nil {
+		log.Printf("Validation errors: %s", utils.ValidationErrorsToString(validationErrs))
+		response.ValidationErrors(c, utils.CreateDtoValidationErrors(validationErrs))
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID := middleware.GetUserIDFromGin(c)
+	if userID == "" {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	stats, err := h.userService.GetReferralStats(c.Request.Context(), userID, request)
 	if err != nil {
-		log.Printf("Error listing referred users: %v", err)
-		response.InternalError(c, "Failed to list referred users")
+		if errors.Is(err, services.ErrInvalidReferralStats) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		log.Printf("Error retrieving referral statistics: %v", err)
+		response.InternalError(c, "Failed to retrieve referral statistics")
 		return
 	}
 
-	response.OK(c, mappers.UsersToResponses(referredUsers), "Referred users retrieved successfully")
+	response.OK(c, stats, "Referral statistics retrieved successfully")
 }
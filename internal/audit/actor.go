@@ -0,0 +1,18 @@
+// Package audit carries the user behind a request down to the repositories so that
+// the changes they make can be attributed to them
+package audit
+
+import "context"
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that attributes changes to userID
+func WithActor(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorFrom returns the user changes made with ctx are attributed to, or an empty string
+func ActorFrom(ctx context.Context) string {
+	userID, _ := ctx.Value(actorKey{}).(string)
+	return userID
+}
@@ -0,0 +1,15 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActorRoundTrip(t *testing.T) {
+	assert.Empty(t, ActorFrom(context.Background()))
+
+	ctx := WithActor(context.Background(), "3f9c2b1e-4d5a-4f6b-8c7d-9e0f1a2b3c4d")
+	assert.Equal(t, "3f9c2b1e-4d5a-4f6b-8c7d-9e0f1a2b3c4d", ActorFrom(ctx))
+}
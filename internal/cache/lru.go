@@ -0,0 +1,115 @@
+// Package cache provides in-process caching with size and age limits, optionally backed by
+// a cache shared between instances of the application.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a fixed-size cache whose entries expire after a time to live. When full, adding an
+// entry evicts the least recently used one. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	order     *list.List
+	entries   map[K]*list.Element
+	evictions int64
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// NewLRU creates a cache holding up to capacity entries for ttl each
+func NewLRU[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[K]*list.Element, capacity),
+	}
+}
+
+// Get returns the value for key if it is cached and has not expired
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	entry := element.Value.(*lruEntry[K, V])
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(element)
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set caches value for key, replacing any cached value and restarting its time to live
+func (c *LRU[K, V]) Set(key K, value V) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	if c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expiresAt: expiresAt})
+}
+
+// Delete removes key from the cache
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of cached entries, including expired ones not yet removed
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Evictions returns how many entries were dropped to make room for new ones
+func (c *LRU[K, V]) Evictions() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictions
+}
+
+func (c *LRU[K, V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry[K, V]).key)
+}
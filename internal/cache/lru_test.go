@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	newCache := func(capacity int) *LRU[string, int] {
+		c := NewLRU[string, int](capacity, time.Minute)
+		c.now = func() time.Time { return now }
+		return c
+	}
+
+	t.Run("EvictsLeastRecentlyUsed", func(t *testing.T) {
+		c := newCache(2)
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Get("a")
+		c.Set("c", 3)
+
+		_, ok := c.Get("b")
+		assert.False(t, ok)
+		value, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+		assert.Equal(t, int64(1), c.Evictions())
+		assert.Equal(t, 2, c.Len())
+	})
+
+	t.Run("ExpiresEntries", func(t *testing.T) {
+		c := newCache(2)
+		c.Set("a", 1)
+
+		now = now.Add(time.Minute - time.Second)
+		_, ok := c.Get("a")
+		assert.True(t, ok)
+
+		now = now.Add(time.Second)
+		_, ok = c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("SetRestartsTimeToLive", func(t *testing.T) {
+		c := newCache(2)
+		c.Set("a", 1)
+		now = now.Add(30 * time.Second)
+		c.Set("a", 2)
+		now = now.Add(45 * time.Second)
+
+		value, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("DeleteRemovesEntry", func(t *testing.T) {
+		c := newCache(2)
+		c.Set("a", 1)
+		c.Delete("a")
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("ZeroCapacityCachesNothing", func(t *testing.T) {
+		c := newCache(0)
+		c.Set("a", 1)
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+	})
+}
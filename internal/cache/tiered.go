@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Store is a cache shared between instances of the application, such as Redis or Memcached.
+// Values are opaque bytes; a missing or expired key is reported by found being false.
+type Store interface {
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Stats counts a cache's lookups. SharedHits are the hits served by the shared store after
+// missing the local tier; they are included in Hits.
+type Stats struct {
+	Hits         int64
+	Misses       int64
+	SharedHits   int64
+	SharedErrors int64
+	Evictions    int64
+	Entries      int
+}
+
+// Tiered caches values by string key in a local LRU and, if a shared store is given, in the
+// store as JSON under a key prefix. Store failures are logged and treated as misses, so the
+// caller falls back to its source. A Delete reaches the store and this instance's local tier;
+// other instances keep their local copy until it expires, so the TTL bounds how long they can
+// serve a stale value.
+type Tiered[V any] struct {
+	local  *LRU[string, V]
+	shared Store
+	prefix string
+	ttl    time.Duration
+
+	hits, misses, sharedHits, sharedErrors atomic.Int64
+}
+
+// NewTiered creates a cache holding up to capacity values locally for ttl each. shared may be nil.
+func NewTiered[V any](capacity int, ttl time.Duration, shared Store, prefix string) *Tiered[V] {
+	return &Tiered[V]{
+		local:  NewLRU[string, V](capacity, ttl),
+		shared: shared,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// Get returns the cached value for key, filling the local tier from the shared store on a local miss
+func (t *Tiered[V]) Get(ctx context.Context, key string) (V, bool) {
+	if value, ok := t.local.Get(key); ok {
+		t.hits.Add(1)
+		return value, true
+	}
+
+	var zero V
+	if t.shared == nil {
+		t.misses.Add(1)
+		return zero, false
+	}
+
+	data, found, err := t.shared.Get(ctx, t.prefix+key)
+	if err != nil {
+		t.sharedErrors.Add(1)
+		log.Printf("Error reading %s%s from shared cache: %v", t.prefix, key, err)
+	}
+	if err != nil || !found {
+		t.misses.Add(1)
+		return zero, false
+	}
+
+	var value V
+	if err := json.Unmarshal(data, &value); err != nil {
+		t.sharedErrors.Add(1)
+		log.Printf("Error decoding %s%s from shared cache: %v", t.prefix, key, err)
+		t.misses.Add(1)
+		return zero, false
+	}
+
+	t.hits.Add(1)
+	t.sharedHits.Add(1)
+	t.local.Set(key, value)
+	return value, true
+}
+
+// Set caches value for key in both tiers
+func (t *Tiered[V]) Set(ctx context.Context, key string, value V) {
+	t.local.Set(key, value)
+	if t.shared == nil {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = t.shared.Set(ctx, t.prefix+key, data, t.ttl)
+	}
+	if err != nil {
+		t.sharedErrors.Add(1)
+		log.Printf("Error writing %s%s to shared cache: %v", t.prefix, key, err)
+	}
+}
+
+// Delete removes key from both tiers
+func (t *Tiered[V]) Delete(ctx context.Context, key string) {
+	t.local.Delete(key)
+	if t.shared == nil {
+		return
+	}
+
+	if err := t.shared.Delete(ctx, t.prefix+key); err != nil {
+		t.sharedErrors.Add(1)
+		log.Printf("Error deleting %s%s from shared cache: %v", t.prefix, key, err)
+	}
+}
+
+// Stats returns the cache's counters since it was created
+func (t *Tiered[V]) Stats() Stats {
+	return Stats{
+		Hits:         t.hits.Load(),
+		Misses:       t.misses.Load(),
+		SharedHits:   t.sharedHits.Load(),
+		SharedErrors: t.sharedErrors.Load(),
+		Evictions:    t.local.Evictions(),
+		Entries:      t.local.Len(),
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore is a Store kept in a map, standing in for a shared cache
+type memoryStore struct {
+	values map[string][]byte
+	err    error
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	value, ok := s.values[key]
+	return value, ok, s.err
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	s.values[key] = value
+	return s.err
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) error {
+	delete(s.values, key)
+	return s.err
+}
+
+type cachedValue struct {
+	ID   string `json:"id"`
+	Flag bool   `json:"flag"`
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("CountsLocalHitsAndMisses", func(t *testing.T) {
+		c := NewTiered[cachedValue](10, time.Minute, nil, "test:")
+		_, ok := c.Get(ctx, "a")
+		assert.False(t, ok)
+
+		c.Set(ctx, "a", cachedValue{ID: "1"})
+		value, ok := c.Get(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, "1", value.ID)
+
+		stats := c.Stats()
+		assert.Equal(t, int64(1), stats.Hits)
+		assert.Equal(t, int64(1), stats.Misses)
+		assert.Equal(t, 1, stats.Entries)
+	})
+
+	t.Run("SharesValuesThroughStore", func(t *testing.T) {
+		store := &memoryStore{values: map[string][]byte{}}
+		writer := NewTiered[cachedValue](10, time.Minute, store, "test:")
+		reader := NewTiered[cachedValue](10, time.Minute, store, "test:")
+
+		writer.Set(ctx, "a", cachedValue{ID: "1", Flag: true})
+		assert.Contains(t, store.values, "test:a")
+
+		value, ok := reader.Get(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, cachedValue{ID: "1", Flag: true}, value)
+		assert.Equal(t, int64(1), reader.Stats().SharedHits)
+
+		writer.Delete(ctx, "a")
+		assert.NotContains(t, store.values, "test:a")
+	})
+
+	t.Run("TreatsStoreErrorsAsMisses", func(t *testing.T) {
+		store := &memoryStore{values: map[string][]byte{"test:a": []byte(`{"id":"1"}`)}, err: errors.New("unavailable")}
+		c := NewTiered[cachedValue](10, time.Minute, store, "test:")
+
+		_, ok := c.Get(ctx, "a")
+		assert.False(t, ok)
+		assert.Equal(t, int64(1), c.Stats().Misses)
+		assert.Equal(t, int64(1), c.Stats().SharedErrors)
+	})
+}
@@ -0,0 +1,70 @@
+// Package cognito verifies what the application receives from Amazon Cognito: ID tokens
+// signed by a user pool, and trigger events forwarded to the webhook with a shared secret.
+package cognito
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers carrying a webhook request's signature and the Unix time in seconds it was signed at
+const (
+	SignatureHeader = "X-Zolaris-Signature"
+	TimestampHeader = "X-Zolaris-Timestamp"
+)
+
+// signatureVersion prefixes signatures so the scheme can change without ambiguity
+const signatureVersion = "v1="
+
+// MaxSignatureAge is how far a webhook request's timestamp may be from the current time,
+// which bounds how long a captured request can be replayed
+const MaxSignatureAge = 5 * time.Minute
+
+var (
+	// ErrMissingSignature is returned when a webhook request has no signature or timestamp
+	ErrMissingSignature = errors.New("missing webhook signature")
+	// ErrInvalidSignature is returned when a webhook signature does not match the request
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleSignature is returned when a webhook request was signed too long ago, or in the future
+	ErrStaleSignature = errors.New("webhook signature timestamp is outside the allowed window")
+)
+
+// Sign computes the signature for a webhook body sent at timestamp: "v1=" followed by the hex
+// HMAC-SHA256, keyed with the shared secret, of the timestamp, a dot and the body
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks that signature was made with secret for body at timestamp, and that
+// timestamp is within MaxSignatureAge of now
+func VerifySignature(secret []byte, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return ErrStaleSignature
+	}
+
+	if !strings.HasPrefix(signature, signatureVersion) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
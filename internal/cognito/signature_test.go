@@ -0,0 +1,45 @@
+package cognito
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("webhook-secret")
+	body := []byte(`{"triggerSource":"PostConfirmation_ConfirmSignUp"}`)
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign(secret, timestamp, body)
+
+	t.Run("AcceptsValidSignature", func(t *testing.T) {
+		assert.NoError(t, VerifySignature(secret, timestamp, signature, body, now.Add(time.Minute)))
+	})
+
+	t.Run("RejectsMissingHeaders", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature(secret, "", signature, body, now), ErrMissingSignature)
+		assert.ErrorIs(t, VerifySignature(secret, timestamp, "", body, now), ErrMissingSignature)
+	})
+
+	t.Run("RejectsTamperedBody", func(t *testing.T) {
+		tampered := []byte(`{"triggerSource":"PostConfirmation_ConfirmForgotPassword"}`)
+		assert.ErrorIs(t, VerifySignature(secret, timestamp, signature, tampered, now), ErrInvalidSignature)
+	})
+
+	t.Run("RejectsWrongSecret", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature([]byte("other"), timestamp, signature, body, now), ErrInvalidSignature)
+	})
+
+	t.Run("RejectsReplayedTimestamp", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature(secret, timestamp, signature, body, now.Add(MaxSignatureAge+time.Second)), ErrStaleSignature)
+		assert.ErrorIs(t, VerifySignature(secret, timestamp, signature, body, now.Add(-MaxSignatureAge-time.Second)), ErrStaleSignature)
+	})
+
+	t.Run("RejectsTimestampNotSigned", func(t *testing.T) {
+		later := strconv.FormatInt(now.Unix()+1, 10)
+		assert.ErrorIs(t, VerifySignature(secret, later, signature, body, now), ErrInvalidSignature)
+	})
+}
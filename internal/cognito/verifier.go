@@ -0,0 +1,182 @@
+package cognito
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrInvalidToken is returned when a token is not a valid, unexpired ID token of the user pool
+var ErrInvalidToken = errors.New("invalid Cognito token")
+
+// keyRefreshInterval is the least time between JWKS fetches, so tokens with unknown key IDs
+// cannot make the verifier hammer Cognito
+const keyRefreshInterval = time.Minute
+
+// Attributes are a Cognito user's attributes by their standard names, such as sub, email,
+// email_verified, given_name, family_name and phone_number. Boolean values are "true" or "false".
+type Attributes map[string]string
+
+// TokenVerifier verifies ID tokens issued by a Cognito user pool against the pool's public keys,
+// which it fetches on first use and again when a token names a key it has not seen
+type TokenVerifier struct {
+	issuer    string
+	jwksURL   string
+	clientIDs map[string]bool
+	client    *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewTokenVerifier creates a verifier for ID tokens of the user pool. If clientIDs is not
+// empty, tokens must also have been issued to one of those app clients.
+func NewTokenVerifier(region, userPoolID string, clientIDs []string) *TokenVerifier {
+	issuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+	return newTokenVerifier(issuer, issuer+"/.well-known/jwks.json", clientIDs)
+}
+
+func newTokenVerifier(issuer, jwksURL string, clientIDs []string) *TokenVerifier {
+	allowed := make(map[string]bool, len(clientIDs))
+	for _, clientID := range clientIDs {
+		allowed[clientID] = true
+	}
+	return &TokenVerifier{
+		issuer:    issuer,
+		jwksURL:   jwksURL,
+		clientIDs: allowed,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		keys:      make(map[string]*rsa.PublicKey),
+	}
+}
+
+// Verify checks the token's signature, expiry, issuer, audience and use, and returns the
+// user attributes it carries. Access tokens are rejected because they carry no email.
+func (v *TokenVerifier) Verify(ctx context.Context, tokenString string) (Attributes, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if !claims.VerifyIssuer(v.issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if use, _ := claims["token_use"].(string); use != "id" {
+		return nil, fmt.Errorf("%w: not an ID token", ErrInvalidToken)
+	}
+	if len(v.clientIDs) > 0 {
+		audience, _ := claims["aud"].(string)
+		if !v.clientIDs[audience] {
+			return nil, fmt.Errorf("%w: issued to an unknown client", ErrInvalidToken)
+		}
+	}
+
+	attributes := make(Attributes)
+	for name, value := range claims {
+		switch value := value.(type) {
+		case string:
+			attributes[name] = value
+		case bool:
+			attributes[name] = strconv.FormatBool(value)
+		}
+	}
+	if attributes["sub"] == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return attributes, nil
+}
+
+// key returns the public key with the ID, fetching the pool's keys again if it is unknown
+func (v *TokenVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(v.fetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	v.fetchedAt = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// jsonWebKey is an RSA public key as published in a JWKS document
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// fetchKeys downloads the pool's JWKS document and decodes its RSA keys by key ID
+func (v *TokenVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	response, err := v.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", response.StatusCode)
+	}
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %q: %w", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,87 @@
+package cognito
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIssuer = "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_test"
+
+func TestTokenVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer server.Close()
+
+	verifier := newTokenVerifier(testIssuer, server.URL, []string{"client-1"})
+
+	sign := func(claims jwt.MapClaims, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	idClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":            "cognito-sub",
+			"iss":            testIssuer,
+			"aud":            "client-1",
+			"token_use":      "id",
+			"email":          "user@example.com",
+			"email_verified": true,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	t.Run("ReturnsAttributesOfValidIDToken", func(t *testing.T) {
+		attributes, err := verifier.Verify(context.Background(), sign(idClaims(), "key-1"))
+		require.NoError(t, err)
+		assert.Equal(t, "cognito-sub", attributes["sub"])
+		assert.Equal(t, "user@example.com", attributes["email"])
+		assert.Equal(t, "true", attributes["email_verified"])
+	})
+
+	t.Run("RejectsInvalidTokens", func(t *testing.T) {
+		expired := idClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		access := idClaims()
+		access["token_use"] = "access"
+		otherClient := idClaims()
+		otherClient["aud"] = "client-2"
+		otherIssuer := idClaims()
+		otherIssuer["iss"] = "https://example.com"
+
+		for name, token := range map[string]string{
+			"expired":      sign(expired, "key-1"),
+			"access token": sign(access, "key-1"),
+			"other client": sign(otherClient, "key-1"),
+			"other issuer": sign(otherIssuer, "key-1"),
+			"unknown key":  sign(idClaims(), "key-2"),
+			"malformed":    "not-a-token",
+		} {
+			_, err := verifier.Verify(context.Background(), token)
+			assert.ErrorIs(t, err, ErrInvalidToken, name)
+		}
+	})
+}
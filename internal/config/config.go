@@ -4,15 +4,24 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	AWS      AWSConfig
+	Server        ServerConfig
+	Database      DatabaseConfig
+	AWS           AWSConfig
+	Energy        EnergyConfig
+	Export        ExportConfig
+	Quality       DataQualityConfig
+	Retention     RetentionConfig
+	Account       AccountConfig
+	Cognito       CognitoConfig
+	IdentityCache IdentityCacheConfig
 }
 
 // ServerConfig holds server-related configuration
@@ -32,6 +41,9 @@ type DatabaseConfig struct {
 	PostgresPassword string
 	PostgresDBName   string
 	PostgresSSLMode  string
+	// TelemetryBackend selects where sensor readings are stored (dynamodb or postgres)
+	TelemetryBackend   string
+	TelemetryTimescale bool
 }
 
 // AWSConfig holds AWS-related configuration
@@ -40,6 +52,75 @@ type AWSConfig struct {
 	IoTPolicy string
 }
 
+// EnergyConfig holds settings for energy and cost computation
+type EnergyConfig struct {
+	MaxSampleGap time.Duration
+	TariffRate   float64
+	Currency     string
+	Timezone     string
+}
+
+// ExportConfig holds settings for sensor data exports
+type ExportConfig struct {
+	StorageDir   string
+	SyncMaxRange time.Duration
+	JobTimeout   time.Duration
+}
+
+// DataQualityConfig holds defaults for sensor data quality analysis
+type DataQualityConfig struct {
+	SamplePeriod    time.Duration
+	GapFactor       float64
+	DuplicateWindow time.Duration
+	AmperageMin     float64
+	AmperageMax     float64
+	TemperatureMin  float64
+	TemperatureMax  float64
+	HumidityMin     float64
+	HumidityMax     float64
+}
+
+// RetentionConfig holds settings for purging soft-deleted records
+type RetentionConfig struct {
+	// SoftDeletePeriod is how long deleted users, entities and devices can be restored
+	SoftDeletePeriod time.Duration
+	// PurgeInterval is how often expired records are purged; zero disables the purge job
+	PurgeInterval time.Duration
+}
+
+// AccountConfig holds settings for self-service account deletion and personal data exports
+type AccountConfig struct {
+	// DeletionGracePeriod is how long a requested account deletion can still be cancelled
+	DeletionGracePeriod time.Duration
+	// DeletionInterval is how often due account deletions are carried out; zero disables the job
+	DeletionInterval time.Duration
+	// ExportSensorWindow is how far back the sensor readings in a personal data export go
+	ExportSensorWindow time.Duration
+	// ExportMaxRows caps the sensor readings exported per device
+	ExportMaxRows int
+}
+
+// CognitoConfig holds settings for provisioning users from Amazon Cognito
+type CognitoConfig struct {
+	// UserPoolID identifies the pool whose ID tokens are accepted; empty disables token verification
+	UserPoolID string
+	// ClientIDs restricts accepted ID tokens to these app clients; empty accepts any client of the pool
+	ClientIDs []string
+	// WebhookSecret signs trigger events forwarded to the webhook; empty disables the webhook
+	WebhookSecret string
+	// JITProvisioning creates users on their first request carrying a verified ID token
+	JITProvisioning bool
+}
+
+// IdentityCacheConfig holds settings for caching the Cognito ID to user lookup made on every request
+type IdentityCacheConfig struct {
+	// Size is how many identities each instance keeps in memory; 0 disables the cache
+	Size int
+	// TTL bounds how long an identity is served from the cache, and so how long another
+	// instance can see a role or disabled status that has since changed
+	TTL time.Duration
+}
+
 // LoadEnv loads environment variables from .env files
 func LoadEnv() error {
 	// Try to load environment-specific .env file first
@@ -84,11 +165,52 @@ func LoadConfig() (*Config, error) {
 	config.Database.PostgresPassword = getEnv("POSTGRES_PASSWORD", "postgres")
 	config.Database.PostgresDBName = getEnv("POSTGRES_DB_NAME", "postgres")
 	config.Database.PostgresSSLMode = getEnv("POSTGRES_SSL_MODE", "disable")
+	config.Database.TelemetryBackend = getEnv("TELEMETRY_BACKEND", "dynamodb")
+	timescale, err := strconv.ParseBool(getEnv("TELEMETRY_TIMESCALE", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TELEMETRY_TIMESCALE value: %v", err)
+	}
+	config.Database.TelemetryTimescale = timescale
 
 	// AWS config
 	config.AWS.Region = getEnv("AWS_REGION", "us-east-1")
 	config.AWS.IoTPolicy = getEnv("IOT_POLICY_NAME", "IOT_POLICY_NAME")
 
+	// Energy config
+	if err := loadEnergyConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Export config
+	if err := loadExportConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Data quality config
+	if err := loadDataQualityConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Retention config
+	if err := loadRetentionConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Account config
+	if err := loadAccountConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Cognito config
+	if err := loadCognitoConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Identity cache config
+	if err := loadIdentityCacheConfig(config); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
@@ -123,14 +245,94 @@ func LoadConfigWithPath(envPaths ...string) (*Config, error) {
 	config.Database.PostgresPassword = getEnv("POSTGRES_PASSWORD", "postgres")
 	config.Database.PostgresDBName = getEnv("POSTGRES_DB_NAME", "postgres")
 	config.Database.PostgresSSLMode = getEnv("POSTGRES_SSL_MODE", "disable")
+	config.Database.TelemetryBackend = getEnv("TELEMETRY_BACKEND", "dynamodb")
+	timescale, err := strconv.ParseBool(getEnv("TELEMETRY_TIMESCALE", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TELEMETRY_TIMESCALE value: %v", err)
+	}
+	config.Database.TelemetryTimescale = timescale
 
 	// AWS config
 	config.AWS.Region = getEnv("AWS_REGION", "us-east-1")
 	config.AWS.IoTPolicy = getEnv("IOT_POLICY_NAME", "iot_p")
 
+	// Energy config
+	if err := loadEnergyConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Export config
+	if err := loadExportConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Data quality config
+	if err := loadDataQualityConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Retention config
+	if err := loadRetentionConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Account config
+	if err := loadAccountConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Cognito config
+	if err := loadCognitoConfig(config); err != nil {
+		return nil, err
+	}
+
+	// Identity cache config
+	if err := loadIdentityCacheConfig(config); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// loadEnergyConfig populates the energy section from environment variables
+func loadEnergyConfig(config *Config) error {
+	maxGap, err := time.ParseDuration(getEnv("ENERGY_MAX_SAMPLE_GAP", "15m"))
+	if err != nil {
+		return fmt.Errorf("invalid ENERGY_MAX_SAMPLE_GAP value: %v", err)
+	}
+	rate, err := strconv.ParseFloat(getEnv("ENERGY_TARIFF_RATE", "8"), 64)
+	if err != nil {
+		return fmt.Errorf("invalid ENERGY_TARIFF_RATE value: %v", err)
+	}
+	timezone := getEnv("ENERGY_TIMEZONE", "UTC")
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid ENERGY_TIMEZONE value: %v", err)
+	}
+
+	config.Energy.MaxSampleGap = maxGap
+	config.Energy.TariffRate = rate
+	config.Energy.Currency = getEnv("ENERGY_CURRENCY", "INR")
+	config.Energy.Timezone = timezone
+	return nil
+}
+
+// loadExportConfig populates the export section from environment variables
+func loadExportConfig(config *Config) error {
+	syncMaxRange, err := time.ParseDuration(getEnv("EXPORT_SYNC_MAX_RANGE", "744h"))
+	if err != nil {
+		return fmt.Errorf("invalid EXPORT_SYNC_MAX_RANGE value: %v", err)
+	}
+	jobTimeout, err := time.ParseDuration(getEnv("EXPORT_JOB_TIMEOUT", "30m"))
+	if err != nil {
+		return fmt.Errorf("invalid EXPORT_JOB_TIMEOUT value: %v", err)
+	}
+
+	config.Export.StorageDir = getEnv("EXPORT_STORAGE_DIR", "./exports")
+	config.Export.SyncMaxRange = syncMaxRange
+	config.Export.JobTimeout = jobTimeout
+	return nil
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -139,3 +341,139 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// loadDataQualityConfig populates the data quality section from environment variables
+func loadDataQualityConfig(config *Config) error {
+	samplePeriod, err := time.ParseDuration(getEnv("DATA_QUALITY_SAMPLE_PERIOD", "1m"))
+	if err != nil {
+		return fmt.Errorf("invalid DATA_QUALITY_SAMPLE_PERIOD value: %v", err)
+	}
+	gapFactor, err := strconv.ParseFloat(getEnv("DATA_QUALITY_GAP_FACTOR", "3"), 64)
+	if err != nil {
+		return fmt.Errorf("invalid DATA_QUALITY_GAP_FACTOR value: %v", err)
+	}
+	duplicateWindow, err := time.ParseDuration(getEnv("DATA_QUALITY_DUPLICATE_WINDOW", "1s"))
+	if err != nil {
+		return fmt.Errorf("invalid DATA_QUALITY_DUPLICATE_WINDOW value: %v", err)
+	}
+
+	bounds := []struct {
+		key      string
+		fallback string
+		target   *float64
+	}{
+		{"DATA_QUALITY_AMPERAGE_MIN", "0", &config.Quality.AmperageMin},
+		{"DATA_QUALITY_AMPERAGE_MAX", "100", &config.Quality.AmperageMax},
+		{"DATA_QUALITY_TEMPERATURE_MIN", "-40", &config.Quality.TemperatureMin},
+		{"DATA_QUALITY_TEMPERATURE_MAX", "125", &config.Quality.TemperatureMax},
+		{"DATA_QUALITY_HUMIDITY_MIN", "0", &config.Quality.HumidityMin},
+		{"DATA_QUALITY_HUMIDITY_MAX", "100", &config.Quality.HumidityMax},
+	}
+	for _, bound := range bounds {
+		value, err := strconv.ParseFloat(getEnv(bound.key, bound.fallback), 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %v", bound.key, err)
+		}
+		*bound.target = value
+	}
+
+	config.Quality.SamplePeriod = samplePeriod
+	config.Quality.GapFactor = gapFactor
+	config.Quality.DuplicateWindow = duplicateWindow
+	return nil
+}
+
+// loadRetentionConfig populates the retention section from environment variables
+func loadRetentionConfig(config *Config) error {
+	softDeletePeriod, err := time.ParseDuration(getEnv("SOFT_DELETE_RETENTION", "720h"))
+	if err != nil {
+		return fmt.Errorf("invalid SOFT_DELETE_RETENTION value: %v", err)
+	}
+	if softDeletePeriod <= 0 {
+		return fmt.Errorf("invalid SOFT_DELETE_RETENTION value: must be positive")
+	}
+	purgeInterval, err := time.ParseDuration(getEnv("SOFT_DELETE_PURGE_INTERVAL", "24h"))
+	if err != nil {
+		return fmt.Errorf("invalid SOFT_DELETE_PURGE_INTERVAL value: %v", err)
+	}
+
+	config.Retention.SoftDeletePeriod = softDeletePeriod
+	config.Retention.PurgeInterval = purgeInterval
+	return nil
+}
+
+// loadAccountConfig populates the account section from environment variables
+func loadAccountConfig(config *Config) error {
+	gracePeriod, err := time.ParseDuration(getEnv("ACCOUNT_DELETION_GRACE_PERIOD", "720h"))
+	if err != nil {
+		return fmt.Errorf("invalid ACCOUNT_DELETION_GRACE_PERIOD value: %v", err)
+	}
+	if gracePeriod < 0 {
+		return fmt.Errorf("invalid ACCOUNT_DELETION_GRACE_PERIOD value: must not be negative")
+	}
+	deletionInterval, err := time.ParseDuration(getEnv("ACCOUNT_DELETION_INTERVAL", "1h"))
+	if err != nil {
+		return fmt.Errorf("invalid ACCOUNT_DELETION_INTERVAL value: %v", err)
+	}
+	sensorWindow, err := time.ParseDuration(getEnv("DATA_EXPORT_SENSOR_WINDOW", "720h"))
+	if err != nil {
+		return fmt.Errorf("invalid DATA_EXPORT_SENSOR_WINDOW value: %v", err)
+	}
+	if sensorWindow <= 0 {
+		return fmt.Errorf("invalid DATA_EXPORT_SENSOR_WINDOW value: must be positive")
+	}
+	maxRows, err := strconv.Atoi(getEnv("DATA_EXPORT_MAX_ROWS", "100000"))
+	if err != nil {
+		return fmt.Errorf("invalid DATA_EXPORT_MAX_ROWS value: %v", err)
+	}
+	if maxRows <= 0 {
+		return fmt.Errorf("invalid DATA_EXPORT_MAX_ROWS value: must be positive")
+	}
+
+	config.Account.DeletionGracePeriod = gracePeriod
+	config.Account.DeletionInterval = deletionInterval
+	config.Account.ExportSensorWindow = sensorWindow
+	config.Account.ExportMaxRows = maxRows
+	return nil
+}
+
+// loadCognitoConfig populates the Cognito section from environment variables
+func loadCognitoConfig(config *Config) error {
+	jitProvisioning, err := strconv.ParseBool(getEnv("COGNITO_JIT_PROVISIONING", "true"))
+	if err != nil {
+		return fmt.Errorf("invalid COGNITO_JIT_PROVISIONING value: %v", err)
+	}
+
+	config.Cognito.UserPoolID = getEnv("COGNITO_USER_POOL_ID", "")
+	config.Cognito.ClientIDs = nil
+	for _, clientID := range strings.Split(getEnv("COGNITO_CLIENT_IDS", ""), ",") {
+		if clientID = strings.TrimSpace(clientID); clientID != "" {
+			config.Cognito.ClientIDs = append(config.Cognito.ClientIDs, clientID)
+		}
+	}
+	config.Cognito.WebhookSecret = getEnv("COGNITO_WEBHOOK_SECRET", "")
+	config.Cognito.JITProvisioning = jitProvisioning
+	return nil
+}
+
+// loadIdentityCacheConfig populates the identity cache section from environment variables
+func loadIdentityCacheConfig(config *Config) error {
+	size, err := strconv.Atoi(getEnv("IDENTITY_CACHE_SIZE", "10000"))
+	if err != nil {
+		return fmt.Errorf("invalid IDENTITY_CACHE_SIZE value: %v", err)
+	}
+	if size < 0 {
+		return fmt.Errorf("invalid IDENTITY_CACHE_SIZE value: must not be negative")
+	}
+	ttl, err := time.ParseDuration(getEnv("IDENTITY_CACHE_TTL", "1m"))
+	if err != nil {
+		return fmt.Errorf("invalid IDENTITY_CACHE_TTL value: %v", err)
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("invalid IDENTITY_CACHE_TTL value: must be positive")
+	}
+
+	config.IdentityCache.Size = size
+	config.IdentityCache.TTL = ttl
+	return nil
+}
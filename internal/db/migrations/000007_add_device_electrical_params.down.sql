@@ -0,0 +1,9 @@
+ALTER TABLE z_device
+    DROP CONSTRAINT IF EXISTS device_power_factor_range,
+    DROP CONSTRAINT IF EXISTS device_phase_count_valid,
+    DROP CONSTRAINT IF EXISTS device_voltage_positive;
+
+ALTER TABLE z_device
+    DROP COLUMN IF EXISTS power_factor,
+    DROP COLUMN IF EXISTS phase_count,
+    DROP COLUMN IF EXISTS voltage;
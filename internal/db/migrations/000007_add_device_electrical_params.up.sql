@@ -0,0 +1,9 @@
+ALTER TABLE z_device
+    ADD COLUMN IF NOT EXISTS voltage numeric(7, 2) NOT NULL DEFAULT 230,
+    ADD COLUMN IF NOT EXISTS phase_count smallint NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS power_factor numeric(4, 3) NOT NULL DEFAULT 1.0;
+
+ALTER TABLE z_device
+    ADD CONSTRAINT device_voltage_positive CHECK (voltage > 0),
+    ADD CONSTRAINT device_phase_count_valid CHECK (phase_count IN (1, 3)),
+    ADD CONSTRAINT device_power_factor_range CHECK (power_factor > 0 AND power_factor <= 1);
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS z_export_job;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT
+            1
+        FROM
+            pg_type
+        WHERE
+            typname = 'export_job_status') THEN
+    DROP TYPE export_job_status;
+END IF;
+END
+$$;
@@ -0,0 +1,36 @@
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT
+            1
+        FROM
+            pg_type
+        WHERE
+            typname = 'export_job_status') THEN
+    CREATE TYPE export_job_status AS ENUM (
+        'pending',
+        'running',
+        'completed',
+        'failed'
+);
+END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS z_export_job (
+    job_id uuid PRIMARY KEY NOT NULL DEFAULT GEN_RANDOM_UUID (),
+    user_id uuid NOT NULL,
+    mac_address varchar(17) NOT NULL,
+    format varchar(16) NOT NULL,
+    start_time timestamp with time zone NOT NULL,
+    end_time timestamp with time zone NOT NULL,
+    status export_job_status NOT NULL DEFAULT 'pending',
+    storage_key text,
+    row_count bigint NOT NULL DEFAULT 0,
+    error text,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    completed_at timestamp with time zone,
+    FOREIGN KEY (user_id) REFERENCES z_users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_export_job_user_id ON z_export_job (user_id);
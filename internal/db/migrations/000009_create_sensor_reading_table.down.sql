@@ -0,0 +1 @@
+DROP TABLE IF EXISTS z_sensor_reading;
@@ -0,0 +1,10 @@
+-- Telemetry table used when TELEMETRY_BACKEND=postgres. With TELEMETRY_TIMESCALE=true
+-- the application converts it into a TimescaleDB hypertable on startup.
+CREATE TABLE IF NOT EXISTS z_sensor_reading (
+    mac_address varchar(17) NOT NULL,
+    recorded_at timestamp with time zone NOT NULL,
+    amperage double precision,
+    temperature double precision,
+    humidity double precision,
+    PRIMARY KEY (mac_address, recorded_at)
+);
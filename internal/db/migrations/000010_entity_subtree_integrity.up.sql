@@ -0,0 +1,40 @@
+-- Deleting a parent must be handled explicitly instead of silently orphaning its subtree
+ALTER TABLE z_entity
+    DROP CONSTRAINT IF EXISTS z_entity_parent_id_fkey;
+
+ALTER TABLE z_entity
+    ADD CONSTRAINT z_entity_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES z_entity (entity_id) ON DELETE NO ACTION;
+
+-- Reject moves that would place an entity beneath its own descendant
+CREATE OR REPLACE FUNCTION update_entity_path()
+RETURNS trigger
+AS $$
+DECLARE
+    parent_path ltree;
+    new_depth integer;
+BEGIN
+    IF NEW.parent_id IS NULL THEN
+        NEW.path = text2ltree (NEW.entity_id::text);
+        NEW.depth = 1;
+        RETURN NEW;
+    END IF;
+    SELECT
+        path,
+        depth INTO parent_path,
+        new_depth
+    FROM
+        z_entity
+    WHERE
+        entity_id = NEW.parent_id;
+    IF parent_path IS NULL THEN
+        RAISE EXCEPTION 'parent entity not found';
+    END IF;
+    IF TG_OP = 'UPDATE' AND parent_path <@ OLD.path THEN
+        RAISE EXCEPTION 'entity cannot be moved beneath its own descendant';
+    END IF;
+    NEW.path = parent_path || text2ltree (NEW.entity_id::text);
+    NEW.depth = new_depth + 1;
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_entity_nonconforming;
+
+ALTER TABLE z_entity
+    DROP COLUMN IF EXISTS details_errors,
+    DROP COLUMN IF EXISTS details_conforms;
+
+ALTER TABLE z_category
+    DROP COLUMN IF EXISTS schema_version,
+    DROP COLUMN IF EXISTS details_schema;
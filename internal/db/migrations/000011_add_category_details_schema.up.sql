@@ -0,0 +1,14 @@
+-- Optional JSON Schema that entity details in the category must conform to
+ALTER TABLE z_category
+    ADD COLUMN IF NOT EXISTS details_schema jsonb,
+    ADD COLUMN IF NOT EXISTS schema_version integer NOT NULL DEFAULT 0;
+
+-- Entities are re-checked when their category's schema changes; existing rows that
+-- no longer conform are flagged rather than rejected
+ALTER TABLE z_entity
+    ADD COLUMN IF NOT EXISTS details_conforms boolean NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS details_errors jsonb;
+
+CREATE INDEX IF NOT EXISTS idx_entity_nonconforming ON z_entity (category_id)
+WHERE
+    NOT details_conforms;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS z_category_type_rule;
@@ -0,0 +1,17 @@
+-- Parenting rules for the entity tree, keyed by category type. Types without a
+-- row are unrestricted.
+CREATE TABLE IF NOT EXISTS z_category_type_rule (
+    category_type category_type PRIMARY KEY NOT NULL,
+    allowed_as_root boolean NOT NULL DEFAULT FALSE,
+    allowed_child_types category_type[] NOT NULL DEFAULT '{}',
+    -- Deepest level (roots are depth 1) an entity of this type may sit at; NULL for no limit
+    max_depth integer CHECK (max_depth IS NULL OR max_depth >= 1),
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO z_category_type_rule (category_type, allowed_as_root, allowed_child_types)
+    VALUES ('user', TRUE, '{user,office,location}'),
+    ('office', FALSE, '{office,location}'),
+    ('location', FALSE, '{location}')
+ON CONFLICT (category_type)
+    DO NOTHING;
@@ -0,0 +1,44 @@
+-- Only the original types can be represented by the enum; categories of any other
+-- type must be removed before rolling back
+CREATE TYPE category_type AS ENUM (
+    'user',
+    'office',
+    'location'
+);
+
+ALTER TABLE z_category_type_rule
+    DROP CONSTRAINT IF EXISTS z_category_type_rule_type_fkey;
+
+DELETE FROM z_category_type_rule
+WHERE category_type NOT IN ('user', 'office', 'location');
+
+UPDATE
+    z_category_type_rule
+SET
+    allowed_child_types = ARRAY (
+        SELECT
+            unnest(allowed_child_types)
+        INTERSECT
+        SELECT
+            unnest(ARRAY['user', 'office', 'location']::varchar[]));
+
+ALTER TABLE z_category_type_rule
+    ALTER COLUMN category_type TYPE category_type
+    USING category_type::category_type;
+
+ALTER TABLE z_category_type_rule
+    ALTER COLUMN allowed_child_types DROP DEFAULT,
+    ALTER COLUMN allowed_child_types TYPE category_type[]
+    USING allowed_child_types::text[]::category_type[],
+    ALTER COLUMN allowed_child_types SET DEFAULT '{}';
+
+DROP INDEX IF EXISTS idx_category_type;
+
+ALTER TABLE z_category
+    DROP CONSTRAINT IF EXISTS z_category_type_fkey;
+
+ALTER TABLE z_category
+    ALTER COLUMN type TYPE category_type
+    USING type::category_type;
+
+DROP TABLE IF EXISTS z_category_type;
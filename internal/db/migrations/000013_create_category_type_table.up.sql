@@ -0,0 +1,44 @@
+-- Category types become managed data instead of a fixed enum
+CREATE TABLE IF NOT EXISTS z_category_type (
+    name varchar(50) PRIMARY KEY NOT NULL,
+    description text NOT NULL DEFAULT '',
+    -- System types are relied on by the application and cannot be deleted
+    is_system boolean NOT NULL DEFAULT FALSE,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO z_category_type (name, is_system)
+SELECT
+    t::text,
+    t::text = 'user'
+FROM
+    unnest(enum_range(NULL::category_type)) AS t
+ON CONFLICT (name)
+    DO NOTHING;
+
+ALTER TABLE z_category
+    ALTER COLUMN type TYPE varchar(50)
+    USING type::text;
+
+ALTER TABLE z_category
+    ADD CONSTRAINT z_category_type_fkey FOREIGN KEY (type) REFERENCES z_category_type (name) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_category_type ON z_category (type);
+
+-- Parenting rules are removed together with their type; child type lists are
+-- maintained by the application since array elements cannot be foreign keys
+ALTER TABLE z_category_type_rule
+    ALTER COLUMN category_type TYPE varchar(50)
+    USING category_type::text;
+
+ALTER TABLE z_category_type_rule
+    ALTER COLUMN allowed_child_types DROP DEFAULT,
+    ALTER COLUMN allowed_child_types TYPE varchar(50)[]
+    USING allowed_child_types::text[],
+    ALTER COLUMN allowed_child_types SET DEFAULT '{}';
+
+ALTER TABLE z_category_type_rule
+    ADD CONSTRAINT z_category_type_rule_type_fkey FOREIGN KEY (category_type) REFERENCES z_category_type (name) ON DELETE CASCADE;
+
+DROP TYPE IF EXISTS category_type;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_entity_category_id;
+
+ALTER TABLE z_entity
+    DROP CONSTRAINT IF EXISTS z_entity_category_id_fkey;
+
+ALTER TABLE z_entity
+    ADD CONSTRAINT z_entity_category_id_fkey FOREIGN KEY (category_id) REFERENCES z_category (category_id) ON DELETE CASCADE;
+
+ALTER TABLE z_category
+    DROP CONSTRAINT IF EXISTS z_category_name_type_key;
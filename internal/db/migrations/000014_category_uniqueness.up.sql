@@ -0,0 +1,35 @@
+-- Merge exact duplicate categories into the oldest copy before enforcing uniqueness
+CREATE TEMP TABLE category_merge AS
+SELECT
+    category_id,
+    first_value(category_id) OVER (PARTITION BY name, type ORDER BY created_at NULLS LAST, category_id) AS keep_id
+FROM
+    z_category;
+
+UPDATE
+    z_entity e
+SET
+    category_id = m.keep_id
+FROM
+    category_merge m
+WHERE
+    e.category_id = m.category_id
+    AND m.category_id <> m.keep_id;
+
+DELETE FROM z_category c USING category_merge m
+WHERE c.category_id = m.category_id
+    AND m.category_id <> m.keep_id;
+
+DROP TABLE category_merge;
+
+ALTER TABLE z_category
+    ADD CONSTRAINT z_category_name_type_key UNIQUE (name, type);
+
+-- Deleting a category must not silently delete the entities that use it
+ALTER TABLE z_entity
+    DROP CONSTRAINT IF EXISTS z_entity_category_id_fkey;
+
+ALTER TABLE z_entity
+    ADD CONSTRAINT z_entity_category_id_fkey FOREIGN KEY (category_id) REFERENCES z_category (category_id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_entity_category_id ON z_entity (category_id);
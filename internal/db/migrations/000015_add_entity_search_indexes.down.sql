@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_entity_created_at_id;
+
+DROP INDEX IF EXISTS idx_entity_name_id;
+
+DROP INDEX IF EXISTS idx_entity_details;
+
+DROP INDEX IF EXISTS idx_entity_name_prefix;
+
+DROP INDEX IF EXISTS idx_entity_name_trgm;
@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Substring and fuzzy name search
+CREATE INDEX IF NOT EXISTS idx_entity_name_trgm ON z_entity USING gin (name gin_trgm_ops);
+
+-- Case-insensitive prefix search
+CREATE INDEX IF NOT EXISTS idx_entity_name_prefix ON z_entity (lower(name) text_pattern_ops);
+
+-- Containment filters on details
+CREATE INDEX IF NOT EXISTS idx_entity_details ON z_entity USING gin (details jsonb_path_ops);
+
+-- Keyset pagination by name and creation time
+CREATE INDEX IF NOT EXISTS idx_entity_name_id ON z_entity (name, entity_id);
+
+CREATE INDEX IF NOT EXISTS idx_entity_created_at_id ON z_entity (created_at, entity_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS z_entity_member;
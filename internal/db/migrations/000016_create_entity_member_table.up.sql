@@ -0,0 +1,17 @@
+-- Users reach entities through memberships in addition to their own user entity.
+-- A role granted on an entity applies to its whole subtree.
+CREATE TABLE IF NOT EXISTS z_entity_member (
+    entity_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role varchar(20) NOT NULL CHECK (role IN ('owner', 'admin', 'viewer')),
+    status varchar(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'active')),
+    invited_by uuid,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    accepted_at timestamp with time zone,
+    PRIMARY KEY (entity_id, user_id),
+    FOREIGN KEY (entity_id) REFERENCES z_entity (entity_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES z_users (user_id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES z_users (user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_entity_member_user_id ON z_entity_member (user_id);
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS entity_history_record ON z_entity;
+
+DROP FUNCTION IF EXISTS record_entity_history();
+
+DROP FUNCTION IF EXISTS entity_history_snapshot(z_entity);
+
+DROP TABLE IF EXISTS z_entity_history;
+
+DROP FUNCTION IF EXISTS reject_entity_history_change();
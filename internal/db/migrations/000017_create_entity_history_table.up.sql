@@ -0,0 +1,96 @@
+-- Append-only log of entity changes. Rows are written by triggers on z_entity, so
+-- cascaded deletes and subtree path rewrites are captured along with direct changes.
+CREATE TABLE IF NOT EXISTS z_entity_history (
+    history_id bigserial PRIMARY KEY,
+    entity_id uuid NOT NULL,
+    action varchar(20) NOT NULL CHECK (action IN ('create', 'update', 'move', 'delete')),
+    -- The user the change is attributed to; kept as-is after the user is deleted
+    actor_user_id uuid,
+    before jsonb,
+    after jsonb,
+    changed_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_entity_history_entity_id ON z_entity_history (entity_id, history_id);
+
+CREATE INDEX idx_entity_history_changed_at ON z_entity_history (changed_at);
+
+CREATE OR REPLACE FUNCTION reject_entity_history_change()
+RETURNS trigger
+AS $$
+BEGIN
+    RAISE EXCEPTION 'z_entity_history is append-only';
+END;
+$$
+LANGUAGE plpgsql;
+
+CREATE TRIGGER entity_history_append_only
+BEFORE UPDATE OR DELETE ON z_entity_history
+FOR EACH ROW
+EXECUTE FUNCTION reject_entity_history_change();
+
+-- The state of an entity as recorded in its history
+CREATE OR REPLACE FUNCTION entity_history_snapshot(e z_entity)
+RETURNS jsonb
+AS $$
+    SELECT jsonb_build_object(
+        'entity_id', e.entity_id,
+        'user_id', e.user_id,
+        'name', e.name,
+        'details', e.details,
+        'category_id', e.category_id,
+        'parent_id', e.parent_id,
+        'path', e.path::text,
+        'depth', e.depth,
+        'created_at', e.created_at,
+        'updated_at', e.updated_at
+    );
+$$
+LANGUAGE sql
+STABLE;
+
+-- The acting user is read from the zolaris.actor_id setting, set per transaction by the application
+CREATE OR REPLACE FUNCTION record_entity_history()
+RETURNS trigger
+AS $$
+DECLARE
+    actor uuid := NULLIF(current_setting('zolaris.actor_id', TRUE), '')::uuid;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'create', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (OLD.entity_id, 'delete', actor, entity_history_snapshot(OLD));
+        RETURN OLD;
+    END IF;
+    -- Schema conformance checks and timestamp bumps are not changes to the entity
+    IF (OLD.name, OLD.details, OLD.category_id, OLD.parent_id, OLD.user_id, OLD.path)
+        IS NOT DISTINCT FROM (NEW.name, NEW.details, NEW.category_id, NEW.parent_id, NEW.user_id, NEW.path) THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO z_entity_history (entity_id, action, actor_user_id, before, after)
+        VALUES (NEW.entity_id,
+            CASE WHEN OLD.path IS DISTINCT FROM NEW.path THEN 'move' ELSE 'update' END,
+            actor, entity_history_snapshot(OLD), entity_history_snapshot(NEW));
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+CREATE TRIGGER entity_history_record
+AFTER INSERT OR UPDATE OR DELETE ON z_entity
+FOR EACH ROW
+EXECUTE FUNCTION record_entity_history();
+
+-- Existing entities get a baseline entry holding their current state
+INSERT INTO z_entity_history (entity_id, action, after, changed_at)
+SELECT
+    e.entity_id,
+    'create',
+    entity_history_snapshot(e),
+    COALESCE(e.created_at, CURRENT_TIMESTAMP)
+FROM
+    z_entity e;
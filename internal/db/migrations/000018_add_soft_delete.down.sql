@@ -0,0 +1,53 @@
+CREATE OR REPLACE FUNCTION record_entity_history()
+RETURNS trigger
+AS $$
+DECLARE
+    actor uuid := NULLIF(current_setting('zolaris.actor_id', TRUE), '')::uuid;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'create', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (OLD.entity_id, 'delete', actor, entity_history_snapshot(OLD));
+        RETURN OLD;
+    END IF;
+    IF (OLD.name, OLD.details, OLD.category_id, OLD.parent_id, OLD.user_id, OLD.path)
+        IS NOT DISTINCT FROM (NEW.name, NEW.details, NEW.category_id, NEW.parent_id, NEW.user_id, NEW.path) THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO z_entity_history (entity_id, action, actor_user_id, before, after)
+        VALUES (NEW.entity_id,
+            CASE WHEN OLD.path IS DISTINCT FROM NEW.path THEN 'move' ELSE 'update' END,
+            actor, entity_history_snapshot(OLD), entity_history_snapshot(NEW));
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+-- Soft-deleted rows would reappear once the column is gone
+DELETE FROM z_device
+WHERE deleted_at IS NOT NULL;
+
+DELETE FROM z_entity
+WHERE deleted_at IS NOT NULL;
+
+DELETE FROM z_users
+WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_device_deleted_at;
+
+DROP INDEX IF EXISTS idx_entity_deleted_at;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+ALTER TABLE z_device
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE z_entity
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE z_users
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,71 @@
+-- Deleted users, entities and devices are kept, hidden, until they are purged after the retention period
+ALTER TABLE z_users
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+
+ALTER TABLE z_entity
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+
+ALTER TABLE z_device
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON z_users (deleted_at)
+WHERE
+    deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_entity_deleted_at ON z_entity (deleted_at)
+WHERE
+    deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_device_deleted_at ON z_device (deleted_at)
+WHERE
+    deleted_at IS NOT NULL;
+
+-- Soft deletes and restores are recorded in the entity history; hard deletes of
+-- soft-deleted entities are recorded as purges
+ALTER TABLE z_entity_history
+    DROP CONSTRAINT IF EXISTS z_entity_history_action_check;
+
+ALTER TABLE z_entity_history
+    ADD CONSTRAINT z_entity_history_action_check CHECK (action IN ('create', 'update', 'move', 'delete', 'restore', 'purge'));
+
+CREATE OR REPLACE FUNCTION record_entity_history()
+RETURNS trigger
+AS $$
+DECLARE
+    actor uuid := NULLIF(current_setting('zolaris.actor_id', TRUE), '')::uuid;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'create', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (OLD.entity_id,
+                CASE WHEN OLD.deleted_at IS NULL THEN 'delete' ELSE 'purge' END,
+                actor, entity_history_snapshot(OLD));
+        RETURN OLD;
+    END IF;
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (NEW.entity_id, 'delete', actor, entity_history_snapshot(OLD));
+        RETURN NEW;
+    END IF;
+    IF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'restore', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    -- Schema conformance checks and timestamp bumps are not changes to the entity
+    IF (OLD.name, OLD.details, OLD.category_id, OLD.parent_id, OLD.user_id, OLD.path)
+        IS NOT DISTINCT FROM (NEW.name, NEW.details, NEW.category_id, NEW.parent_id, NEW.user_id, NEW.path) THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO z_entity_history (entity_id, action, actor_user_id, before, after)
+        VALUES (NEW.entity_id,
+            CASE WHEN OLD.path IS DISTINCT FROM NEW.path THEN 'move' ELSE 'update' END,
+            actor, entity_history_snapshot(OLD), entity_history_snapshot(NEW));
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
@@ -0,0 +1,75 @@
+CREATE OR REPLACE FUNCTION record_entity_history()
+RETURNS trigger
+AS $$
+DECLARE
+    actor uuid := NULLIF(current_setting('zolaris.actor_id', TRUE), '')::uuid;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'create', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (OLD.entity_id,
+                CASE WHEN OLD.deleted_at IS NULL THEN 'delete' ELSE 'purge' END,
+                actor, entity_history_snapshot(OLD));
+        RETURN OLD;
+    END IF;
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (NEW.entity_id, 'delete', actor, entity_history_snapshot(OLD));
+        RETURN NEW;
+    END IF;
+    IF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'restore', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    -- Schema conformance checks and timestamp bumps are not changes to the entity
+    IF (OLD.name, OLD.details, OLD.category_id, OLD.parent_id, OLD.user_id, OLD.path)
+        IS NOT DISTINCT FROM (NEW.name, NEW.details, NEW.category_id, NEW.parent_id, NEW.user_id, NEW.path) THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO z_entity_history (entity_id, action, actor_user_id, before, after)
+        VALUES (NEW.entity_id,
+            CASE WHEN OLD.path IS DISTINCT FROM NEW.path THEN 'move' ELSE 'update' END,
+            actor, entity_history_snapshot(OLD), entity_history_snapshot(NEW));
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION entity_history_snapshot(e z_entity)
+RETURNS jsonb
+AS $$
+    SELECT jsonb_build_object(
+        'entity_id', e.entity_id,
+        'user_id', e.user_id,
+        'name', e.name,
+        'details', e.details,
+        'category_id', e.category_id,
+        'parent_id', e.parent_id,
+        'path', e.path::text,
+        'depth', e.depth,
+        'created_at', e.created_at,
+        'updated_at', e.updated_at
+    );
+$$
+LANGUAGE sql
+STABLE;
+
+-- The PostGIS extension is left installed; other objects may depend on it
+DROP INDEX IF EXISTS idx_entity_geog;
+
+ALTER TABLE z_entity
+    DROP COLUMN IF EXISTS geog;
+
+DROP INDEX IF EXISTS idx_entity_location;
+
+ALTER TABLE z_entity
+    DROP CONSTRAINT IF EXISTS z_entity_longitude_check,
+    DROP CONSTRAINT IF EXISTS z_entity_latitude_check,
+    DROP CONSTRAINT IF EXISTS z_entity_location_check,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
@@ -0,0 +1,101 @@
+-- Entities may be placed on a map. Both coordinates are set together or not at all.
+ALTER TABLE z_entity
+    ADD COLUMN IF NOT EXISTS latitude double precision,
+    ADD COLUMN IF NOT EXISTS longitude double precision;
+
+ALTER TABLE z_entity
+    ADD CONSTRAINT z_entity_location_check CHECK ((latitude IS NULL) = (longitude IS NULL)),
+    ADD CONSTRAINT z_entity_latitude_check CHECK (latitude BETWEEN -90 AND 90),
+    ADD CONSTRAINT z_entity_longitude_check CHECK (longitude BETWEEN -180 AND 180);
+
+-- Used for bounding-box queries, and for radius queries when PostGIS is not installed
+CREATE INDEX IF NOT EXISTS idx_entity_location ON z_entity (latitude, longitude)
+WHERE
+    latitude IS NOT NULL AND deleted_at IS NULL;
+
+-- Where PostGIS is available, radius queries use a geography column derived from the
+-- coordinates. The application checks for the column at startup and falls back to the
+-- plain columns without it.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'postgis') THEN
+        BEGIN
+            CREATE EXTENSION IF NOT EXISTS postgis;
+        EXCEPTION
+            WHEN insufficient_privilege THEN
+                RAISE NOTICE 'postgis is available but could not be installed, skipping geography column';
+                RETURN;
+        END;
+        EXECUTE $sql$
+            ALTER TABLE z_entity
+                ADD COLUMN IF NOT EXISTS geog geography(Point, 4326)
+                GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED
+        $sql$;
+        EXECUTE 'CREATE INDEX IF NOT EXISTS idx_entity_geog ON z_entity USING gist (geog)';
+    END IF;
+END
+$$;
+
+-- Location changes are recorded in the entity history
+CREATE OR REPLACE FUNCTION entity_history_snapshot(e z_entity)
+RETURNS jsonb
+AS $$
+    SELECT jsonb_build_object(
+        'entity_id', e.entity_id,
+        'user_id', e.user_id,
+        'name', e.name,
+        'details', e.details,
+        'category_id', e.category_id,
+        'parent_id', e.parent_id,
+        'path', e.path::text,
+        'depth', e.depth,
+        'latitude', e.latitude,
+        'longitude', e.longitude,
+        'created_at', e.created_at,
+        'updated_at', e.updated_at
+    );
+$$
+LANGUAGE sql
+STABLE;
+
+CREATE OR REPLACE FUNCTION record_entity_history()
+RETURNS trigger
+AS $$
+DECLARE
+    actor uuid := NULLIF(current_setting('zolaris.actor_id', TRUE), '')::uuid;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'create', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (OLD.entity_id,
+                CASE WHEN OLD.deleted_at IS NULL THEN 'delete' ELSE 'purge' END,
+                actor, entity_history_snapshot(OLD));
+        RETURN OLD;
+    END IF;
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (NEW.entity_id, 'delete', actor, entity_history_snapshot(OLD));
+        RETURN NEW;
+    END IF;
+    IF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'restore', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    -- Schema conformance checks and timestamp bumps are not changes to the entity
+    IF (OLD.name, OLD.details, OLD.category_id, OLD.parent_id, OLD.user_id, OLD.path, OLD.latitude, OLD.longitude)
+        IS NOT DISTINCT FROM (NEW.name, NEW.details, NEW.category_id, NEW.parent_id, NEW.user_id, NEW.path, NEW.latitude, NEW.longitude) THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO z_entity_history (entity_id, action, actor_user_id, before, after)
+        VALUES (NEW.entity_id,
+            CASE WHEN OLD.path IS DISTINCT FROM NEW.path THEN 'move' ELSE 'update' END,
+            actor, entity_history_snapshot(OLD), entity_history_snapshot(NEW));
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
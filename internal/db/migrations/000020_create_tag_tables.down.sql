@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS z_device_tag;
+
+DROP TABLE IF EXISTS z_entity_tag;
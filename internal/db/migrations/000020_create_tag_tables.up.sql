@@ -0,0 +1,29 @@
+-- Free-form key/value labels on entities and devices. Each resource holds at most one
+-- value per key; tags without a value store an empty string.
+CREATE TABLE IF NOT EXISTS z_entity_tag (
+    entity_id uuid NOT NULL,
+    key varchar(64) NOT NULL,
+    value varchar(256) NOT NULL DEFAULT '',
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entity_id, key),
+    FOREIGN KEY (entity_id) REFERENCES z_entity (entity_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS z_device_tag (
+    mac_address varchar(17) NOT NULL,
+    key varchar(64) NOT NULL,
+    value varchar(256) NOT NULL DEFAULT '',
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (mac_address, key),
+    FOREIGN KEY (mac_address) REFERENCES z_device (mac_address) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- Filtering by key or key and value, and prefix autocomplete of values within a key
+CREATE INDEX IF NOT EXISTS idx_entity_tag_key_value ON z_entity_tag (key, value text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS idx_device_tag_key_value ON z_device_tag (key, value text_pattern_ops);
+
+-- Prefix autocomplete of keys
+CREATE INDEX IF NOT EXISTS idx_entity_tag_key_prefix ON z_entity_tag (key text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS idx_device_tag_key_prefix ON z_device_tag (key text_pattern_ops);
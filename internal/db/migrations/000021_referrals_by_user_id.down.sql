@@ -0,0 +1,20 @@
+DROP TRIGGER IF EXISTS users_referral_cycle ON z_users;
+
+DROP FUNCTION IF EXISTS reject_referral_cycle();
+
+-- Referrals go back to being recorded by the referrer's email
+ALTER TABLE z_users
+    ADD COLUMN IF NOT EXISTS referral_mail varchar(255) DEFAULT NULL;
+
+UPDATE z_users u
+SET referral_mail = referrer.email
+FROM z_users referrer
+WHERE referrer.user_id = u.referred_by;
+
+DROP INDEX IF EXISTS idx_users_referred_by;
+
+ALTER TABLE z_users
+    DROP CONSTRAINT IF EXISTS z_users_no_self_referral,
+    DROP CONSTRAINT IF EXISTS z_users_referred_by_fkey,
+    DROP COLUMN IF EXISTS referred_at,
+    DROP COLUMN IF EXISTS referred_by;
@@ -0,0 +1,59 @@
+-- Referrals are recorded by the referrer's user ID rather than by email
+ALTER TABLE z_users
+    ADD COLUMN IF NOT EXISTS referred_by uuid,
+    ADD COLUMN IF NOT EXISTS referred_at timestamp with time zone;
+
+ALTER TABLE z_users
+    ADD CONSTRAINT z_users_referred_by_fkey FOREIGN KEY (referred_by) REFERENCES z_users (user_id) ON DELETE SET NULL,
+    ADD CONSTRAINT z_users_no_self_referral CHECK (referred_by <> user_id);
+
+CREATE INDEX IF NOT EXISTS idx_users_referred_by ON z_users (referred_by);
+
+-- Carry over referrals recorded by email where the column survives. Only referrers who
+-- signed up earlier are taken, which keeps the migrated referrals free of cycles.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'z_users' AND column_name = 'referral_mail') THEN
+        UPDATE z_users u
+        SET referred_by = referrer.user_id,
+            referred_at = u.created_at
+        FROM z_users referrer
+        WHERE lower(referrer.email) = lower(u.referral_mail)
+            AND referrer.user_id <> u.user_id
+            AND referrer.created_at < u.created_at;
+        ALTER TABLE z_users DROP COLUMN referral_mail;
+    END IF;
+END
+$$;
+
+-- A user cannot be referred, directly or through others, by someone they referred
+CREATE OR REPLACE FUNCTION reject_referral_cycle()
+RETURNS trigger
+AS $$
+BEGIN
+    IF EXISTS (
+        WITH RECURSIVE chain AS (
+            SELECT user_id, referred_by FROM z_users WHERE user_id = NEW.referred_by
+            UNION
+            SELECT u.user_id, u.referred_by
+            FROM z_users u
+            JOIN chain c ON u.user_id = c.referred_by
+        )
+        SELECT 1 FROM chain WHERE user_id = NEW.user_id) THEN
+        RAISE EXCEPTION 'referral would create a cycle'
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'z_users_referral_cycle';
+    END IF;
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_referral_cycle ON z_users;
+
+CREATE TRIGGER users_referral_cycle
+    BEFORE INSERT OR UPDATE OF referred_by ON z_users
+    FOR EACH ROW
+    WHEN (NEW.referred_by IS NOT NULL)
+    EXECUTE FUNCTION reject_referral_cycle();
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS users_parent_cycle ON z_users;
+
+DROP FUNCTION IF EXISTS reject_parent_cycle();
+
+ALTER TABLE z_users
+    DROP CONSTRAINT IF EXISTS z_users_no_self_parent,
+    DROP COLUMN IF EXISTS disabled_at;
+
+-- Sub-accounts that never signed in get a placeholder no Cognito identity can match
+UPDATE z_users SET cognito_id = 'unlinked:' || user_id WHERE cognito_id IS NULL;
+
+ALTER TABLE z_users
+    ALTER COLUMN cognito_id SET NOT NULL;
@@ -0,0 +1,41 @@
+-- Sub-accounts are created by their parent before they sign up, so they have no Cognito
+-- identity until their first sign-in links one
+ALTER TABLE z_users
+    ALTER COLUMN cognito_id DROP NOT NULL;
+
+-- Disabled accounts keep their data but can no longer sign in
+ALTER TABLE z_users
+    ADD COLUMN IF NOT EXISTS disabled_at timestamp with time zone;
+
+ALTER TABLE z_users
+    ADD CONSTRAINT z_users_no_self_parent CHECK (parent_id <> user_id);
+
+-- A user cannot become the parent of an account above them in the parent chain
+CREATE OR REPLACE FUNCTION reject_parent_cycle()
+RETURNS trigger
+AS $$
+BEGIN
+    IF EXISTS (
+        WITH RECURSIVE chain AS (
+            SELECT user_id, parent_id FROM z_users WHERE user_id = NEW.parent_id
+            UNION
+            SELECT u.user_id, u.parent_id
+            FROM z_users u
+            JOIN chain c ON u.user_id = c.parent_id
+        )
+        SELECT 1 FROM chain WHERE user_id = NEW.user_id) THEN
+        RAISE EXCEPTION 'parent would create a cycle'
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'z_users_parent_cycle';
+    END IF;
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_parent_cycle ON z_users;
+
+CREATE TRIGGER users_parent_cycle
+    BEFORE INSERT OR UPDATE OF parent_id ON z_users
+    FOR EACH ROW
+    WHEN (NEW.parent_id IS NOT NULL)
+    EXECUTE FUNCTION reject_parent_cycle();
@@ -0,0 +1,16 @@
+-- Every request an admin makes while impersonating a user. The log has no foreign keys so
+-- it outlives the accounts it mentions.
+CREATE TABLE IF NOT EXISTS z_impersonation_log (
+    log_id bigserial PRIMARY KEY,
+    admin_user_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    method varchar(10) NOT NULL,
+    path text NOT NULL,
+    status integer NOT NULL,
+    client_ip varchar(64),
+    requested_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_impersonation_log_admin ON z_impersonation_log (admin_user_id, requested_at DESC);
+
+CREATE INDEX idx_impersonation_log_user ON z_impersonation_log (user_id, requested_at DESC);
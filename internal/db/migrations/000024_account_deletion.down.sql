@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS z_user_tombstone;
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+
+ALTER TABLE z_users
+    DROP COLUMN IF EXISTS deletion_scheduled_at,
+    DROP COLUMN IF EXISTS deletion_requested_at;
@@ -0,0 +1,23 @@
+-- Users can ask for their account to be erased; it is carried out once the grace period has
+-- passed, and can be cancelled until then
+ALTER TABLE z_users
+    ADD COLUMN IF NOT EXISTS deletion_requested_at timestamp with time zone,
+    ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON z_users (deletion_scheduled_at)
+WHERE
+    deletion_scheduled_at IS NOT NULL;
+
+-- What is kept of an erased account: proof that it existed and was erased on request, with
+-- the email only as a SHA-256 hash so a returning user can be recognised without storing it
+CREATE TABLE IF NOT EXISTS z_user_tombstone (
+    user_id uuid PRIMARY KEY NOT NULL,
+    email_hash char(64) NOT NULL,
+    requested_at timestamp with time zone NOT NULL,
+    erased_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    devices_erased integer NOT NULL DEFAULT 0,
+    entities_erased integer NOT NULL DEFAULT 0,
+    children_detached integer NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tombstone_email_hash ON z_user_tombstone (email_hash);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_entity_history_after_path;
@@ -0,0 +1,2 @@
+-- Past hierarchies are rebuilt from the history rows that placed an entity under the root
+CREATE INDEX IF NOT EXISTS idx_entity_history_after_path ON z_entity_history USING gist (((after->>'path')::ltree));
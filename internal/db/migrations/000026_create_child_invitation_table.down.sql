@@ -0,0 +1 @@
+DROP TABLE IF EXISTS z_child_invitation;
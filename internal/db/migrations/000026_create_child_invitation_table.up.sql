@@ -0,0 +1,27 @@
+-- Parents invite sub-accounts by email. The parent chain is only linked once the invited
+-- user accepts, so nobody can claim an account, or access to it, without its owner's consent.
+CREATE TABLE IF NOT EXISTS z_child_invitation (
+    invitation_id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    parent_id uuid NOT NULL REFERENCES z_users (user_id) ON DELETE CASCADE,
+    email varchar(255) NOT NULL,
+    first_name varchar(100),
+    last_name varchar(100),
+    phone varchar(50),
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_child_invitation_parent_email ON z_child_invitation (parent_id, lower(email));
+
+CREATE INDEX IF NOT EXISTS idx_child_invitation_email ON z_child_invitation (lower(email));
+
+-- Child accounts nobody has signed in to yet were created without their owner's consent;
+-- they become invitations and leave the parent chain
+INSERT INTO z_child_invitation (parent_id, email, first_name, last_name, phone, created_at)
+SELECT parent_id, email, first_name, last_name, phone, COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM z_users
+WHERE parent_id IS NOT NULL AND cognito_id IS NULL AND deleted_at IS NULL
+ON CONFLICT DO NOTHING;
+
+UPDATE z_users
+SET parent_id = NULL, disabled_at = NULL
+WHERE parent_id IS NOT NULL AND cognito_id IS NULL;
@@ -0,0 +1,56 @@
+DROP FUNCTION IF EXISTS scrub_entity_history(uuid[]);
+
+CREATE OR REPLACE FUNCTION reject_entity_history_change()
+RETURNS trigger
+AS $$
+BEGIN
+    RAISE EXCEPTION 'z_entity_history is append-only';
+END;
+$$
+LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_entity_history()
+RETURNS trigger
+AS $$
+DECLARE
+    actor uuid := NULLIF(current_setting('zolaris.actor_id', TRUE), '')::uuid;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'create', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (OLD.entity_id,
+                CASE WHEN OLD.deleted_at IS NULL THEN 'delete' ELSE 'purge' END,
+                actor, entity_history_snapshot(OLD));
+        RETURN OLD;
+    END IF;
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (NEW.entity_id, 'delete', actor, entity_history_snapshot(OLD));
+        RETURN NEW;
+    END IF;
+    IF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'restore', actor, entity_history_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    -- Schema conformance checks and timestamp bumps are not changes to the entity
+    IF (OLD.name, OLD.details, OLD.category_id, OLD.parent_id, OLD.user_id, OLD.path, OLD.latitude, OLD.longitude)
+        IS NOT DISTINCT FROM (NEW.name, NEW.details, NEW.category_id, NEW.parent_id, NEW.user_id, NEW.path, NEW.latitude, NEW.longitude) THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO z_entity_history (entity_id, action, actor_user_id, before, after)
+        VALUES (NEW.entity_id,
+            CASE WHEN OLD.path IS DISTINCT FROM NEW.path THEN 'move' ELSE 'update' END,
+            actor, entity_history_snapshot(OLD), entity_history_snapshot(NEW));
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS entity_history_recorded_snapshot(z_entity);
+
+DROP FUNCTION IF EXISTS entity_history_redact(jsonb);
@@ -0,0 +1,102 @@
+-- Entity history outlives erased accounts as an audit trail, without their personal data.
+-- The names, details, owners and locations of erased entities are nulled, the rest is kept.
+CREATE OR REPLACE FUNCTION entity_history_redact(snapshot jsonb)
+RETURNS jsonb
+AS $$
+    SELECT snapshot || jsonb_build_object(
+        'user_id', NULL,
+        'name', NULL,
+        'details', NULL,
+        'latitude', NULL,
+        'longitude', NULL
+    );
+$$
+LANGUAGE sql
+IMMUTABLE;
+
+-- While an account is erased, set per transaction through the zolaris.erasing setting, the
+-- history recorded for its entities is redacted as it is written
+CREATE OR REPLACE FUNCTION entity_history_recorded_snapshot(e z_entity)
+RETURNS jsonb
+AS $$
+    SELECT CASE WHEN current_setting('zolaris.erasing', TRUE) = 'on'
+        THEN entity_history_redact(entity_history_snapshot(e))
+        ELSE entity_history_snapshot(e)
+    END;
+$$
+LANGUAGE sql
+STABLE;
+
+CREATE OR REPLACE FUNCTION record_entity_history()
+RETURNS trigger
+AS $$
+DECLARE
+    actor uuid := NULLIF(current_setting('zolaris.actor_id', TRUE), '')::uuid;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'create', actor, entity_history_recorded_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (OLD.entity_id,
+                CASE WHEN OLD.deleted_at IS NULL THEN 'delete' ELSE 'purge' END,
+                actor, entity_history_recorded_snapshot(OLD));
+        RETURN OLD;
+    END IF;
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, before)
+            VALUES (NEW.entity_id, 'delete', actor, entity_history_recorded_snapshot(OLD));
+        RETURN NEW;
+    END IF;
+    IF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        INSERT INTO z_entity_history (entity_id, action, actor_user_id, after)
+            VALUES (NEW.entity_id, 'restore', actor, entity_history_recorded_snapshot(NEW));
+        RETURN NEW;
+    END IF;
+    -- Schema conformance checks and timestamp bumps are not changes to the entity
+    IF (OLD.name, OLD.details, OLD.category_id, OLD.parent_id, OLD.user_id, OLD.path, OLD.latitude, OLD.longitude)
+        IS NOT DISTINCT FROM (NEW.name, NEW.details, NEW.category_id, NEW.parent_id, NEW.user_id, NEW.path, NEW.latitude, NEW.longitude) THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO z_entity_history (entity_id, action, actor_user_id, before, after)
+        VALUES (NEW.entity_id,
+            CASE WHEN OLD.path IS DISTINCT FROM NEW.path THEN 'move' ELSE 'update' END,
+            actor, entity_history_recorded_snapshot(OLD), entity_history_recorded_snapshot(NEW));
+    RETURN NEW;
+END;
+$$
+LANGUAGE plpgsql;
+
+-- The history stays append-only, except for the redaction done by scrub_entity_history
+CREATE OR REPLACE FUNCTION reject_entity_history_change()
+RETURNS trigger
+AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND current_setting('zolaris.history_scrub', TRUE) = 'on' THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'z_entity_history is append-only';
+END;
+$$
+LANGUAGE plpgsql;
+
+-- Redacts the history already recorded for erased entities
+CREATE OR REPLACE FUNCTION scrub_entity_history(entity_ids uuid[])
+RETURNS void
+AS $$
+BEGIN
+    PERFORM set_config('zolaris.history_scrub', 'on', TRUE);
+    UPDATE z_entity_history
+    SET
+        before = entity_history_redact(before),
+        after = entity_history_redact(after)
+    WHERE
+        entity_id = ANY (entity_ids);
+    PERFORM set_config('zolaris.history_scrub', 'off', TRUE);
+END;
+$$
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = public;
@@ -7,17 +7,89 @@ import (
 	"github.com/google/uuid"
 )
 
+// UserRole mirrors the user_role enum on z_users
+type UserRole string
+
+const (
+	UserRoleAdmin UserRole = "admin"
+	UserRoleUser  UserRole = "user"
+)
+
 // User represents a user entity in the system
 type User struct {
-	ID        string    `json:"id" db:"user_id"`
-	Email     string    `json:"email" db:"email"`
-	FirstName *string   `json:"firstName" db:"first_name"`
-	LastName  *string   `json:"lastName" db:"last_name"`
-	Phone     *string   `json:"phone" db:"phone"`
-	Address   *Address  `json:"address"`
-	ParentID  *string   `json:"parentId,omitempty" db:"parent_id"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	ID         string     `json:"id" db:"user_id"`
+	Email      string     `json:"email" db:"email"`
+	FirstName  *string    `json:"firstName" db:"first_name"`
+	LastName   *string    `json:"lastName" db:"last_name"`
+	Phone      *string    `json:"phone" db:"phone"`
+	Address    *Address   `json:"address"`
+	ParentID   *string    `json:"parentId,omitempty" db:"parent_id"`
+	ReferredBy *string    `json:"referredBy,omitempty" db:"referred_by"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty" db:"disabled_at"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// UserIdentity is what the auth path needs to know about a signed-in user
+type UserIdentity struct {
+	UserID   string
+	Role     UserRole
+	Disabled bool
+}
+
+// CognitoProfile is what Cognito says about a user, from a trigger event or an ID token.
+// Optional attributes Cognito did not send are nil.
+type CognitoProfile struct {
+	CognitoID     string
+	Email         string
+	EmailVerified bool
+	FirstName     *string
+	LastName      *string
+	Phone         *string
+}
+
+// ProvisionOutcome says what provisioning a user from Cognito did
+type ProvisionOutcome string
+
+const (
+	// ProvisionCreated means a new user was created for the Cognito identity, or a child
+	// account a parent created for its email was handed over as a fresh account
+	ProvisionCreated ProvisionOutcome = "created"
+	// ProvisionLinked means an existing user without a Cognito identity or parent was linked
+	// to it by their verified email
+	ProvisionLinked ProvisionOutcome = "linked"
+	// ProvisionUpdated means the user already had the Cognito identity
+	ProvisionUpdated ProvisionOutcome = "updated"
+)
+
+// UserAccount is a user as support staff see them: their profile with their role and
+// whether they have signed up, i.e. have a Cognito identity linked
+type UserAccount struct {
+	User
+	Role     UserRole `json:"role" db:"role"`
+	SignedUp bool     `json:"signedUp"`
+}
+
+// UserSearchFilter narrows an admin user search. Query matches the email, name or user ID; Status
+// is "active" or "disabled".
+type UserSearchFilter struct {
+	Query  string
+	Role   UserRole
+	Status string
+	Limit  int
+	Offset int
+}
+
+// ImpersonationRecord is one request an admin made while impersonating a user
+type ImpersonationRecord struct {
+	ID          int64     `json:"id" db:"log_id"`
+	AdminUserID string    `json:"adminUserId" db:"admin_user_id"`
+	UserID      string    `json:"userId" db:"user_id"`
+	Method      string    `json:"method" db:"method"`
+	Path        string    `json:"path" db:"path"`
+	Status      int       `json:"status" db:"status"`
+	ClientIP    string    `json:"clientIp" db:"client_ip"`
+	RequestedAt time.Time `json:"requestedAt" db:"requested_at"`
 }
 
 // Address represents a physical address
@@ -37,10 +109,20 @@ type Device struct {
 	Name        string    `json:"name" db:"device_name"`
 	Category    *string   `json:"category,omitempty" db:"category"`
 	Description *string   `json:"description,omitempty" db:"description"`
+	Voltage     float64   `json:"voltage" db:"voltage"`
+	PhaseCount  int       `json:"phaseCount" db:"phase_count"`
+	PowerFactor float64   `json:"powerFactor" db:"power_factor"`
 	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
 }
 
+// Default electrical parameters applied to devices that have not been configured
+const (
+	DefaultDeviceVoltage     = 230.0
+	DefaultDevicePhaseCount  = 1
+	DefaultDevicePowerFactor = 1.0
+)
+
 // SensorReading represents data from a device sensor
 type SensorReading struct {
 	DeviceID    string    `json:"deviceId" db:"mac_id"`
@@ -51,12 +133,56 @@ type SensorReading struct {
 	RawData     string    `json:"-" db:"raw_data"`
 }
 
+// SensorAggregate summarizes a device's sensor readings within a time bucket
+type SensorAggregate struct {
+	DeviceID       string    `json:"deviceId" db:"mac_id"`
+	BucketStart    time.Time `json:"bucketStart" db:"bucket_start"`
+	Count          int64     `json:"count" db:"count"`
+	AvgAmperage    float64   `json:"avgAmperage" db:"avg_amperage"`
+	MinAmperage    float64   `json:"minAmperage" db:"min_amperage"`
+	MaxAmperage    float64   `json:"maxAmperage" db:"max_amperage"`
+	AvgTemperature float64   `json:"avgTemperature" db:"avg_temperature"`
+	AvgHumidity    float64   `json:"avgHumidity" db:"avg_humidity"`
+}
+
 // Category represents a device category
 type Category struct {
-	ID        string    `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Type      string    `json:"type" db:"type"`
+	ID            string          `json:"id" db:"id"`
+	Name          string          `json:"name" db:"name"`
+	Type          string          `json:"type" db:"type"`
+	DetailsSchema json.RawMessage `json:"detailsSchema,omitempty" db:"details_schema"`
+	SchemaVersion int             `json:"schemaVersion" db:"schema_version"`
+	CreatedAt     time.Time       `json:"createdAt" db:"created_at"`
+	// EntityCount is the number of entities using the category, when loaded
+	EntityCount *int `json:"entityCount,omitempty" db:"entity_count"`
+}
+
+// CategoryDuplicateGroup is a set of categories of one type whose names share a normalized
+// form, lowercased and trimmed of surrounding whitespace, oldest first
+type CategoryDuplicateGroup struct {
+	Type           string
+	NormalizedName string
+	Categories     []*Category
+}
+
+// CategoryTypeDefinition is a managed category type such as "office" or "warehouse"
+type CategoryTypeDefinition struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+	// IsSystem marks types the application relies on, which cannot be deleted
+	IsSystem  bool      `json:"isSystem" db:"is_system"`
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// CategoryTypeRule restricts where entities of a category type may be placed in the entity tree
+type CategoryTypeRule struct {
+	CategoryType      string   `json:"categoryType" db:"category_type"`
+	AllowedAsRoot     bool     `json:"allowedAsRoot" db:"allowed_as_root"`
+	AllowedChildTypes []string `json:"allowedChildTypes" db:"allowed_child_types"`
+	// MaxDepth is the deepest level an entity of this type may sit at, with roots at depth 1
+	MaxDepth  *int      `json:"maxDepth,omitempty" db:"max_depth"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
 }
 
 // NewUser creates a new User with default values
@@ -77,11 +203,14 @@ func NewUser(email, firstName, lastName, phone string) *User {
 func NewDevice(macAddress, userID, name string) *Device {
 	now := time.Now()
 	return &Device{
-		MacAddress: macAddress,
-		UserID:     userID,
-		Name:       name,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		MacAddress:  macAddress,
+		UserID:      userID,
+		Name:        name,
+		Voltage:     DefaultDeviceVoltage,
+		PhaseCount:  DefaultDevicePhaseCount,
+		PowerFactor: DefaultDevicePowerFactor,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 }
 
@@ -99,6 +228,160 @@ type Entity struct {
 	UpdatedAt  time.Time       `json:"updatedAt" db:"updated_at"`
 }
 
+// EntityNode is an entity in a hierarchy together with its category and number of direct children
+type EntityNode struct {
+	Entity
+	CategoryName string `json:"categoryName" db:"category_name"`
+	CategoryType string `json:"categoryType" db:"category_type"`
+	ChildCount   int    `json:"childCount" db:"child_count"`
+}
+
+// EntityDraft is a new entity to be created together with its descendants
+type EntityDraft struct {
+	Name       string
+	CategoryID string
+	Details    map[string]any
+	Children   []*EntityDraft
+}
+
+// EntityConformance records whether an entity's details match its category schema
+type EntityConformance struct {
+	EntityID string          `json:"entityId" db:"entity_id"`
+	Name     string          `json:"name" db:"name"`
+	Errors   json.RawMessage `json:"errors,omitempty" db:"details_errors"`
+}
+
+// EntitySearchFilter describes an entity search. Empty fields do not filter.
+type EntitySearchFilter struct {
+	Name string
+	// NameMatch is "prefix", "contains" or "fuzzy" (trigram similarity)
+	NameMatch    string
+	CategoryType string
+	CategoryID   string
+	// UnderEntityID restricts results to descendants of this entity
+	UnderEntityID string
+	// Details must be contained in an entity's details
+	Details map[string]any
+	// Tags must all be carried by an entity
+	Tags []TagFilter
+	// SortBy is "name", "createdAt", "updatedAt" or "depth"
+	SortBy     string
+	Descending bool
+	// AfterValue and AfterID continue after the last row of the previous page;
+	// Offset is used instead when AfterID is empty
+	AfterValue string
+	AfterID    string
+	Offset     int
+	Limit      int
+}
+
+// GeoEntity is an entity placed on the map. DistanceMeters is set on results of a radius query.
+type GeoEntity struct {
+	EntityNode
+	Latitude       float64  `json:"latitude" db:"latitude"`
+	Longitude      float64  `json:"longitude" db:"longitude"`
+	DistanceMeters *float64 `json:"distanceMeters,omitempty" db:"distance_meters"`
+}
+
+// EntityGeoFilter describes a geographic entity query: either a radius around a point or a
+// bounding box. Boxes whose MinLongitude is greater than MaxLongitude cross the antimeridian.
+type EntityGeoFilter struct {
+	Latitude     float64
+	Longitude    float64
+	RadiusMeters float64
+
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+
+	// UnderEntityID restricts results to this entity and its descendants
+	UnderEntityID string
+	Limit         int
+}
+
+// EntityPlacement is an entity's position in the tree as seen by the parenting rules
+type EntityPlacement struct {
+	EntityID           string  `json:"entityId" db:"entity_id"`
+	Name               string  `json:"name" db:"name"`
+	CategoryType       string  `json:"categoryType" db:"category_type"`
+	ParentID           *string `json:"parentId,omitempty" db:"parent_id"`
+	ParentCategoryType *string `json:"parentCategoryType,omitempty" db:"parent_category_type"`
+	Depth              int     `json:"depth" db:"depth"`
+}
+
+// EntityDevice is a device reached through the user entity that owns it
+type EntityDevice struct {
+	MacAddress string `json:"macAddress" db:"mac_address"`
+	DeviceName string `json:"deviceName" db:"device_name"`
+	EntityID   string `json:"entityId" db:"entity_id"`
+	EntityPath string `json:"entityPath" db:"path"`
+}
+
+// EntityHistoryEntry is a recorded change to an entity. Before is empty for creations and
+// restores, After for deletions and purges; both hold the entity's columns as JSON.
+type EntityHistoryEntry struct {
+	ID          int64           `json:"id" db:"history_id"`
+	EntityID    string          `json:"entityId" db:"entity_id"`
+	Action      string          `json:"action" db:"action"`
+	ActorUserID *string         `json:"actorUserId,omitempty" db:"actor_user_id"`
+	Before      json.RawMessage `json:"before,omitempty" db:"before"`
+	After       json.RawMessage `json:"after,omitempty" db:"after"`
+	ChangedAt   time.Time       `json:"changedAt" db:"changed_at"`
+}
+
+// EntityRole is the role a user holds on an entity and, through inheritance, its subtree
+type EntityRole string
+
+const (
+	EntityRoleOwner  EntityRole = "owner"
+	EntityRoleAdmin  EntityRole = "admin"
+	EntityRoleViewer EntityRole = "viewer"
+)
+
+// entityRoleRanks orders roles from least to most privileged
+var entityRoleRanks = map[EntityRole]int{
+	EntityRoleViewer: 1,
+	EntityRoleAdmin:  2,
+	EntityRoleOwner:  3,
+}
+
+// AtLeast reports whether the role grants everything required does. The empty role grants nothing.
+func (r EntityRole) AtLeast(required EntityRole) bool {
+	return r != "" && entityRoleRanks[r] >= entityRoleRanks[required]
+}
+
+// EntityMemberStatus tracks whether a membership has been accepted
+type EntityMemberStatus string
+
+const (
+	EntityMemberPending EntityMemberStatus = "pending"
+	EntityMemberActive  EntityMemberStatus = "active"
+)
+
+// EntityMember grants a user a role on an entity
+type EntityMember struct {
+	EntityID   string             `json:"entityId" db:"entity_id"`
+	EntityName string             `json:"entityName" db:"entity_name"`
+	UserID     string             `json:"userId" db:"user_id"`
+	Email      string             `json:"email" db:"email"`
+	FirstName  *string            `json:"firstName" db:"first_name"`
+	LastName   *string            `json:"lastName" db:"last_name"`
+	Role       EntityRole         `json:"role" db:"role"`
+	Status     EntityMemberStatus `json:"status" db:"status"`
+	InvitedBy  *string            `json:"invitedBy,omitempty" db:"invited_by"`
+	CreatedAt  time.Time          `json:"createdAt" db:"created_at"`
+	AcceptedAt *time.Time         `json:"acceptedAt,omitempty" db:"accepted_at"`
+}
+
+// EntityAccess is an entity a user can reach, with the role they hold on it and the
+// entity that role was granted on (the entity itself or one of its ancestors)
+type EntityAccess struct {
+	EntityNode
+	Role            EntityRole `json:"role" db:"role"`
+	GrantedEntityID string     `json:"grantedEntityId" db:"granted_entity_id"`
+}
+
 // NewCategory creates a new Category with default values
 func NewCategory(name, categoryType string) *Category {
 	return &Category{
@@ -108,3 +391,139 @@ func NewCategory(name, categoryType string) *Category {
 		CreatedAt: time.Now(),
 	}
 }
+
+// ExportJobStatus represents the lifecycle state of an export job
+type ExportJobStatus string
+
+const (
+	ExportJobPending   ExportJobStatus = "pending"
+	ExportJobRunning   ExportJobStatus = "running"
+	ExportJobCompleted ExportJobStatus = "completed"
+	ExportJobFailed    ExportJobStatus = "failed"
+)
+
+// ExportJob represents an asynchronous sensor data export
+type ExportJob struct {
+	ID          string          `json:"id" db:"job_id"`
+	UserID      string          `json:"userId" db:"user_id"`
+	MacAddress  string          `json:"macAddress" db:"mac_address"`
+	Format      string          `json:"format" db:"format"`
+	StartTime   time.Time       `json:"startTime" db:"start_time"`
+	EndTime     time.Time       `json:"endTime" db:"end_time"`
+	Status      ExportJobStatus `json:"status" db:"status"`
+	StorageKey  *string         `json:"-" db:"storage_key"`
+	RowCount    int64           `json:"rowCount" db:"row_count"`
+	Error       *string         `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time       `json:"createdAt" db:"created_at"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty" db:"completed_at"`
+}
+
+// DeletedKind is the kind of record held in the trash
+type DeletedKind string
+
+const (
+	DeletedKindUser   DeletedKind = "user"
+	DeletedKindEntity DeletedKind = "entity"
+	DeletedKindDevice DeletedKind = "device"
+)
+
+// DeletedRecord is a soft-deleted user, entity or device awaiting restore or purge.
+// Devices are identified by MAC address and users are named by email.
+type DeletedRecord struct {
+	Kind      DeletedKind `json:"kind"`
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	DeletedAt time.Time   `json:"deletedAt" db:"deleted_at"`
+}
+
+// AccountDeletion is a user's pending request to have their account erased
+type AccountDeletion struct {
+	UserID      string    `json:"userId" db:"user_id"`
+	RequestedAt time.Time `json:"requestedAt" db:"deletion_requested_at"`
+	ScheduledAt time.Time `json:"scheduledAt" db:"deletion_scheduled_at"`
+}
+
+// UserTombstone records an erased account without personal data. EmailHash is the hex
+// SHA-256 of the lowercased email.
+type UserTombstone struct {
+	UserID           string    `json:"userId" db:"user_id"`
+	EmailHash        string    `json:"emailHash" db:"email_hash"`
+	RequestedAt      time.Time `json:"requestedAt" db:"requested_at"`
+	ErasedAt         time.Time `json:"erasedAt" db:"erased_at"`
+	DevicesErased    int       `json:"devicesErased" db:"devices_erased"`
+	EntitiesErased   int       `json:"entitiesErased" db:"entities_erased"`
+	ChildrenDetached int       `json:"childrenDetached" db:"children_detached"`
+}
+
+// UserErasure is the result of erasing a user: their tombstone, and what they left outside
+// the database that must be removed too
+type UserErasure struct {
+	Tombstone *UserTombstone
+	// StorageKeys locate the files of the user's export jobs
+	StorageKeys []string
+	// MacAddresses identify the user's devices, whose readings the telemetry backend keeps
+	MacAddresses []string
+}
+
+// Tag is a key/value label on an entity or device. Keys are stored lowercase; tags used as
+// plain labels have an empty value.
+type Tag struct {
+	Key   string `json:"key" db:"key"`
+	Value string `json:"value" db:"value"`
+}
+
+// TagFilter matches resources carrying a tag key, with any value unless Value is set
+type TagFilter struct {
+	Key   string
+	Value *string
+}
+
+// TagSuggestion is a tag key or value in use, with the number of entities and devices carrying it
+type TagSuggestion struct {
+	Text  string `json:"text"`
+	Count int64  `json:"count"`
+}
+
+// ChildInvitation asks the user with Email to become a sub-account of the parent. The names
+// and phone are the parent's suggestions, used to fill gaps in the profile on acceptance.
+type ChildInvitation struct {
+	ID              string    `json:"id" db:"invitation_id"`
+	ParentID        string    `json:"parentId" db:"parent_id"`
+	ParentEmail     string    `json:"parentEmail" db:"parent_email"`
+	ParentFirstName *string   `json:"parentFirstName" db:"parent_first_name"`
+	ParentLastName  *string   `json:"parentLastName" db:"parent_last_name"`
+	Email           string    `json:"email" db:"email"`
+	FirstName       *string   `json:"firstName" db:"first_name"`
+	LastName        *string   `json:"lastName" db:"last_name"`
+	Phone           *string   `json:"phone" db:"phone"`
+	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
+}
+
+// ReferralNode is a user in a referral tree, Depth levels below the user whose tree it is,
+// with the number of users they referred directly
+type ReferralNode struct {
+	UserID        string    `json:"userId" db:"user_id"`
+	Email         string    `json:"email" db:"email"`
+	FirstName     *string   `json:"firstName" db:"first_name"`
+	LastName      *string   `json:"lastName" db:"last_name"`
+	ReferredBy    string    `json:"referredBy" db:"referred_by"`
+	ReferredAt    time.Time `json:"referredAt" db:"referred_at"`
+	Depth         int       `json:"depth" db:"depth"`
+	ReferralCount int       `json:"referralCount" db:"referral_count"`
+}
+
+// ReferralStats summarizes the users a user referred, directly or through the users they
+// referred. A referral converts when the referred user registers their first device.
+type ReferralStats struct {
+	Direct    int64             `json:"direct"`
+	Total     int64             `json:"total"`
+	Converted int64             `json:"converted"`
+	Buckets   []*ReferralBucket `json:"buckets"`
+}
+
+// ReferralBucket counts the referral signups and conversions within one time bucket
+type ReferralBucket struct {
+	Start       time.Time `json:"start"`
+	Signups     int64     `json:"signups"`
+	Conversions int64     `json:"conversions"`
+}
@@ -0,0 +1,236 @@
+package energy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// BucketSize defines the width of the intervals energy is aggregated into
+type BucketSize string
+
+const (
+	HourBucket  BucketSize = "hour"
+	DayBucket   BucketSize = "day"
+	MonthBucket BucketSize = "month"
+)
+
+// DefaultMaxGap is the longest interval between two readings that is still integrated
+const DefaultMaxGap = 15 * time.Minute
+
+// ElectricalParams describes how amperage readings translate into real power.
+// For three-phase devices Voltage is the line-to-line voltage.
+type ElectricalParams struct {
+	Voltage     float64
+	PhaseCount  int
+	PowerFactor float64
+}
+
+// PowerWatts returns the real power drawn at the given current
+func (p ElectricalParams) PowerWatts(amperage float64) float64 {
+	power := p.Voltage * amperage * p.PowerFactor
+	if p.PhaseCount == 3 {
+		power *= math.Sqrt(3)
+	}
+	return power
+}
+
+// Validate checks that the parameters describe a physically meaningful device
+func (p ElectricalParams) Validate() error {
+	if p.Voltage <= 0 {
+		return fmt.Errorf("voltage must be positive")
+	}
+	if p.PhaseCount != 1 && p.PhaseCount != 3 {
+		return fmt.Errorf("phase count must be 1 or 3")
+	}
+	if p.PowerFactor <= 0 || p.PowerFactor > 1 {
+		return fmt.Errorf("power factor must be in (0, 1]")
+	}
+	return nil
+}
+
+// Sample is a single amperage reading
+type Sample struct {
+	Timestamp time.Time
+	Amperage  float64
+}
+
+// Bucket holds the energy consumed within [Start, End).
+// Covered is the part of the bucket backed by readings; intervals longer than
+// the calculator's max gap are treated as missing data and do not count.
+type Bucket struct {
+	Start   time.Time
+	End     time.Time
+	KWh     float64
+	Covered time.Duration
+}
+
+// Coverage returns the fraction of the bucket that is backed by readings
+func (b Bucket) Coverage() float64 {
+	width := b.End.Sub(b.Start)
+	if width <= 0 {
+		return 0
+	}
+	return b.Covered.Seconds() / width.Seconds()
+}
+
+// Calculator integrates amperage readings over time into energy buckets
+type Calculator struct {
+	params   ElectricalParams
+	maxGap   time.Duration
+	location *time.Location
+}
+
+// NewCalculator creates a new calculator for a device with the given parameters
+func NewCalculator(params ElectricalParams, maxGap time.Duration) *Calculator {
+	if maxGap <= 0 {
+		maxGap = DefaultMaxGap
+	}
+	return &Calculator{
+		params:   params,
+		maxGap:   maxGap,
+		location: time.UTC,
+	}
+}
+
+// WithLocation sets the time zone used to align day and month buckets
+func (c *Calculator) WithLocation(location *time.Location) *Calculator {
+	if location != nil {
+		c.location = location
+	}
+	return c
+}
+
+// Integrate computes energy per bucket over [start, end) using the trapezoidal rule.
+// Samples may be unordered; intervals between consecutive samples that exceed the
+// max gap are skipped rather than interpolated across.
+func (c *Calculator) Integrate(samples []Sample, start, end time.Time, size BucketSize) ([]Bucket, error) {
+	if !end.After(start) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	buckets, err := c.buildBuckets(start, end, size)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	idx := 0
+	for i := 1; i < len(sorted); i++ {
+		prev, next := sorted[i-1], sorted[i]
+		interval := next.Timestamp.Sub(prev.Timestamp)
+		if interval <= 0 || interval > c.maxGap {
+			continue
+		}
+
+		// Clip the interval to the requested range
+		from, to := prev.Timestamp, next.Timestamp
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if !to.After(from) {
+			continue
+		}
+
+		// Buckets are ordered and intervals are visited in time order, so the
+		// bucket index only ever moves forward
+		for idx < len(buckets) && !buckets[idx].End.After(from) {
+			idx++
+		}
+
+		for j := idx; j < len(buckets) && buckets[j].Start.Before(to); j++ {
+			segStart := maxTime(from, buckets[j].Start)
+			segEnd := minTime(to, buckets[j].End)
+			if !segEnd.After(segStart) {
+				continue
+			}
+
+			a := interpolate(prev, next, segStart)
+			b := interpolate(prev, next, segEnd)
+			avgWatts := (c.params.PowerWatts(a) + c.params.PowerWatts(b)) / 2
+			buckets[j].KWh += avgWatts * segEnd.Sub(segStart).Hours() / 1000
+			buckets[j].Covered += segEnd.Sub(segStart)
+		}
+	}
+
+	return buckets, nil
+}
+
+// buildBuckets creates consecutive empty buckets covering [start, end)
+func (c *Calculator) buildBuckets(start, end time.Time, size BucketSize) ([]Bucket, error) {
+	cursor, err := Truncate(start.In(c.location), size)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []Bucket
+	for cursor.Before(end) {
+		next := Advance(cursor, size)
+		buckets = append(buckets, Bucket{
+			Start: maxTime(cursor, start),
+			End:   minTime(next, end),
+		})
+		cursor = next
+	}
+
+	return buckets, nil
+}
+
+// Truncate aligns t to the start of the bucket containing it, in t's location
+func Truncate(t time.Time, size BucketSize) (time.Time, error) {
+	switch size {
+	case HourBucket:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case DayBucket:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	case MonthBucket:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported bucket size: %s", size)
+	}
+}
+
+// Advance returns the start of the bucket following the one starting at t
+func Advance(t time.Time, size BucketSize) time.Time {
+	switch size {
+	case HourBucket:
+		return t.Add(time.Hour)
+	case DayBucket:
+		return t.AddDate(0, 0, 1)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
+// interpolate returns the linearly interpolated amperage at t between two samples
+func interpolate(a, b Sample, t time.Time) float64 {
+	total := b.Timestamp.Sub(a.Timestamp)
+	if total <= 0 {
+		return a.Amperage
+	}
+	ratio := t.Sub(a.Timestamp).Seconds() / total.Seconds()
+	return a.Amperage + (b.Amperage-a.Amperage)*ratio
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
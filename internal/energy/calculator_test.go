@@ -0,0 +1,127 @@
+package energy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var singlePhase = ElectricalParams{Voltage: 230, PhaseCount: 1, PowerFactor: 1}
+
+func samplesEvery(start time.Time, step time.Duration, amps ...float64) []Sample {
+	samples := make([]Sample, len(amps))
+	for i, a := range amps {
+		samples[i] = Sample{Timestamp: start.Add(time.Duration(i) * step), Amperage: a}
+	}
+	return samples
+}
+
+func TestPowerWatts(t *testing.T) {
+	assert.InDelta(t, 2300.0, singlePhase.PowerWatts(10), 1e-9)
+
+	threePhase := ElectricalParams{Voltage: 400, PhaseCount: 3, PowerFactor: 0.9}
+	assert.InDelta(t, math.Sqrt(3)*400*10*0.9, threePhase.PowerWatts(10), 1e-9)
+}
+
+func TestIntegrate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ConstantLoadAcrossHours", func(t *testing.T) {
+		// 10A at 230V for two hours is 4.6 kWh, split evenly
+		samples := samplesEvery(start, 5*time.Minute, constantReadings(25, 10)...)
+		calc := NewCalculator(singlePhase, 15*time.Minute)
+
+		buckets, err := calc.Integrate(samples, start, start.Add(2*time.Hour), HourBucket)
+		require.NoError(t, err)
+		require.Len(t, buckets, 2)
+		assert.InDelta(t, 2.3, buckets[0].KWh, 1e-9)
+		assert.InDelta(t, 2.3, buckets[1].KWh, 1e-9)
+		assert.InDelta(t, 1.0, buckets[0].Coverage(), 1e-9)
+	})
+
+	t.Run("SkipsGapsLongerThanMaxGap", func(t *testing.T) {
+		samples := []Sample{
+			{Timestamp: start, Amperage: 10},
+			{Timestamp: start.Add(10 * time.Minute), Amperage: 10},
+			{Timestamp: start.Add(50 * time.Minute), Amperage: 10},
+			{Timestamp: start.Add(60 * time.Minute), Amperage: 10},
+		}
+		calc := NewCalculator(singlePhase, 15*time.Minute)
+
+		buckets, err := calc.Integrate(samples, start, start.Add(time.Hour), HourBucket)
+		require.NoError(t, err)
+		require.Len(t, buckets, 1)
+		// Only the two 10 minute intervals are integrated
+		assert.InDelta(t, 2.3*20/60, buckets[0].KWh, 1e-9)
+		assert.Equal(t, 20*time.Minute, buckets[0].Covered)
+	})
+
+	t.Run("SplitsIntervalAtBucketBoundary", func(t *testing.T) {
+		// A linear ramp from 0A to 10A straddling the hour boundary
+		samples := []Sample{
+			{Timestamp: start.Add(55 * time.Minute), Amperage: 0},
+			{Timestamp: start.Add(65 * time.Minute), Amperage: 10},
+		}
+		calc := NewCalculator(singlePhase, 15*time.Minute)
+
+		buckets, err := calc.Integrate(samples, start, start.Add(2*time.Hour), HourBucket)
+		require.NoError(t, err)
+		require.Len(t, buckets, 2)
+		assert.InDelta(t, 2.3*0.25*5/60, buckets[0].KWh, 1e-9)
+		assert.InDelta(t, 2.3*0.75*5/60, buckets[1].KWh, 1e-9)
+	})
+
+	t.Run("AlignsDaysToLocation", func(t *testing.T) {
+		ist := time.FixedZone("IST", 5*3600+1800)
+		calc := NewCalculator(singlePhase, 0).WithLocation(ist)
+
+		buckets, err := calc.Integrate(nil, start, start.Add(24*time.Hour), DayBucket)
+		require.NoError(t, err)
+		require.Len(t, buckets, 2)
+		assert.True(t, buckets[0].End.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, ist)))
+	})
+
+	t.Run("RejectsInvalidRange", func(t *testing.T) {
+		_, err := NewCalculator(singlePhase, 0).Integrate(nil, start, start, HourBucket)
+		assert.Error(t, err)
+	})
+}
+
+func TestCost(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := samplesEvery(start, 5*time.Minute, constantReadings(25, 10)...)
+	hourly, err := NewCalculator(singlePhase, 0).Integrate(samples, start, start.Add(2*time.Hour), HourBucket)
+	require.NoError(t, err)
+
+	t.Run("Flat", func(t *testing.T) {
+		costs, err := Cost(FlatTariff{Rate: 8, CurrencyCode: "INR"}, hourly, DayBucket, time.UTC)
+		require.NoError(t, err)
+		require.Len(t, costs, 1)
+		assert.InDelta(t, 4.6, costs[0].KWh, 1e-9)
+		assert.InDelta(t, 36.8, costs[0].Cost, 1e-9)
+	})
+
+	t.Run("TimeOfUse", func(t *testing.T) {
+		tariff := TimeOfUseTariff{
+			Periods:     []TimeOfUsePeriod{{Name: "night", StartHour: 22, EndHour: 1, Rate: 5}},
+			DefaultRate: 10,
+		}
+		costs, err := Cost(tariff, hourly, MonthBucket, time.UTC)
+		require.NoError(t, err)
+		require.Len(t, costs, 1)
+		// Hour 0 is inside the wrapped night period, hour 1 is not
+		assert.InDelta(t, 2.3*5+2.3*10, costs[0].Cost, 1e-9)
+	})
+}
+
+// constantReadings returns n identical amperage values
+func constantReadings(n int, amps float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = amps
+	}
+	return values
+}
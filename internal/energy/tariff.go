@@ -0,0 +1,135 @@
+package energy
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tariff prices energy consumed at a given moment
+type Tariff interface {
+	// RateAt returns the price per kWh in effect at t
+	RateAt(t time.Time) float64
+	// Currency returns the ISO 4217 code prices are expressed in
+	Currency() string
+}
+
+// FlatTariff charges the same rate around the clock
+type FlatTariff struct {
+	Rate         float64
+	CurrencyCode string
+}
+
+// RateAt returns the flat rate
+func (t FlatTariff) RateAt(time.Time) float64 {
+	return t.Rate
+}
+
+// Currency returns the tariff currency
+func (t FlatTariff) Currency() string {
+	return t.CurrencyCode
+}
+
+// TimeOfUsePeriod applies a rate between StartHour (inclusive) and EndHour (exclusive).
+// A period whose EndHour is not after its StartHour wraps past midnight.
+type TimeOfUsePeriod struct {
+	Name      string
+	StartHour int
+	EndHour   int
+	Rate      float64
+}
+
+// contains reports whether the given hour of day falls within the period
+func (p TimeOfUsePeriod) contains(hour int) bool {
+	if p.StartHour < p.EndHour {
+		return hour >= p.StartHour && hour < p.EndHour
+	}
+	return hour >= p.StartHour || hour < p.EndHour
+}
+
+// TimeOfUseTariff charges different rates depending on the local hour of day.
+// Hours not covered by any period are charged at DefaultRate.
+type TimeOfUseTariff struct {
+	Periods      []TimeOfUsePeriod
+	DefaultRate  float64
+	CurrencyCode string
+	Location     *time.Location
+}
+
+// RateAt returns the rate of the first period containing t's local hour
+func (t TimeOfUseTariff) RateAt(at time.Time) float64 {
+	if t.Location != nil {
+		at = at.In(t.Location)
+	}
+	hour := at.Hour()
+	for _, period := range t.Periods {
+		if period.contains(hour) {
+			return period.Rate
+		}
+	}
+	return t.DefaultRate
+}
+
+// Currency returns the tariff currency
+func (t TimeOfUseTariff) Currency() string {
+	return t.CurrencyCode
+}
+
+// Validate checks that all periods use valid hours and non-negative rates
+func (t TimeOfUseTariff) Validate() error {
+	if t.DefaultRate < 0 {
+		return fmt.Errorf("default rate must not be negative")
+	}
+	for _, period := range t.Periods {
+		if period.StartHour < 0 || period.StartHour > 23 || period.EndHour < 0 || period.EndHour > 24 {
+			return fmt.Errorf("period %q has invalid hours %d-%d", period.Name, period.StartHour, period.EndHour)
+		}
+		if period.Rate < 0 {
+			return fmt.Errorf("period %q has a negative rate", period.Name)
+		}
+	}
+	return nil
+}
+
+// CostBucket holds the energy and cost for a billing period
+type CostBucket struct {
+	Start time.Time
+	End   time.Time
+	KWh   float64
+	Cost  float64
+}
+
+// Cost prices hourly energy buckets with the tariff and rolls them up into
+// day or month periods aligned to location, clipped to the range covered by the
+// input. Hourly input is required so that time-of-use rates are applied to the
+// hour the energy was actually consumed.
+func Cost(tariff Tariff, hourly []Bucket, period BucketSize, location *time.Location) ([]CostBucket, error) {
+	if period != DayBucket && period != MonthBucket {
+		return nil, fmt.Errorf("unsupported billing period: %s", period)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	var result []CostBucket
+	var currentPeriod time.Time
+	for _, bucket := range hourly {
+		periodStart, err := Truncate(bucket.Start.In(location), period)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(result) == 0 || !currentPeriod.Equal(periodStart) {
+			currentPeriod = periodStart
+			result = append(result, CostBucket{
+				Start: maxTime(periodStart, bucket.Start),
+			})
+		}
+
+		current := &result[len(result)-1]
+		current.End = bucket.End
+		current.KWh += bucket.KWh
+		current.Cost += bucket.KWh * tariff.RateAt(bucket.Start)
+	}
+
+	return result, nil
+}
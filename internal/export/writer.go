@@ -0,0 +1,162 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// Format identifies a sensor data export file format
+type Format string
+
+const (
+	CSVFormat     Format = "csv"
+	ParquetFormat Format = "parquet"
+	NDJSONFormat  Format = "ndjson"
+)
+
+// parquetRowGroupSize bounds how many rows the parquet writer buffers before flushing
+const parquetRowGroupSize = 10000
+
+// ContentType returns the MIME type for the format
+func (f Format) ContentType() string {
+	switch f {
+	case CSVFormat:
+		return "text/csv"
+	case NDJSONFormat:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// Extension returns the file extension for the format
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Row is the flat representation of a sensor reading in exported files
+type Row struct {
+	DeviceID    string `json:"deviceId" parquet:"device_id"`
+	Timestamp   int64  `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	Amperage    string `json:"amperage" parquet:"amperage"`
+	Temperature string `json:"temperature" parquet:"temperature"`
+	Humidity    string `json:"humidity" parquet:"humidity"`
+}
+
+// RowFromReading converts a domain sensor reading to an export row
+func RowFromReading(reading *domain.SensorReading) Row {
+	return Row{
+		DeviceID:    reading.DeviceID,
+		Timestamp:   reading.Timestamp.UnixMilli(),
+		Amperage:    reading.Amperage,
+		Temperature: reading.Temperature,
+		Humidity:    reading.Humidity,
+	}
+}
+
+// Writer writes sensor readings to an output in a specific format.
+// Close must be called to flush buffered data; it does not close the output.
+type Writer interface {
+	Write(readings []*domain.SensorReading) error
+	Close() error
+}
+
+// NewWriter creates a writer for the given format
+func NewWriter(format Format, output io.Writer) (Writer, error) {
+	switch format {
+	case CSVFormat:
+		return newCSVWriter(output)
+	case NDJSONFormat:
+		return &ndjsonWriter{encoder: json.NewEncoder(output)}, nil
+	case ParquetFormat:
+		return &parquetWriter{
+			writer: parquet.NewGenericWriter[Row](output, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// csvWriter writes readings as comma-separated values with a header row
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(output io.Writer) (*csvWriter, error) {
+	w := csv.NewWriter(output)
+	if err := w.Write([]string{"device_id", "timestamp", "amperage", "temperature", "humidity"}); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	return &csvWriter{writer: w}, nil
+}
+
+func (w *csvWriter) Write(readings []*domain.SensorReading) error {
+	for _, reading := range readings {
+		row := RowFromReading(reading)
+		if err := w.writer.Write([]string{
+			row.DeviceID,
+			strconv.FormatInt(row.Timestamp, 10),
+			row.Amperage,
+			row.Temperature,
+			row.Humidity,
+		}); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+	// Flush per batch so rows reach the client as each page is read
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// ndjsonWriter writes one JSON object per line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(readings []*domain.SensorReading) error {
+	for _, reading := range readings {
+		if err := w.encoder.Encode(RowFromReading(reading)); err != nil {
+			return fmt.Errorf("failed to write NDJSON row: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+// parquetWriter writes readings as a parquet file, flushing row groups as they fill
+type parquetWriter struct {
+	writer *parquet.GenericWriter[Row]
+}
+
+func (w *parquetWriter) Write(readings []*domain.SensorReading) error {
+	rows := make([]Row, len(readings))
+	for i, reading := range readings {
+		rows[i] = RowFromReading(reading)
+	}
+	if _, err := w.writer.Write(rows); err != nil {
+		return fmt.Errorf("failed to write parquet rows: %w", err)
+	}
+	return nil
+}
+
+func (w *parquetWriter) Close() error {
+	if err := w.writer.Close(); err != nil {
+		return fmt.Errorf("failed to finalize parquet file: %w", err)
+	}
+	return nil
+}
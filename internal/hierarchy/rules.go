@@ -0,0 +1,76 @@
+// Package hierarchy checks entity placements against category-type parenting rules
+package hierarchy
+
+import (
+	"fmt"
+	"slices"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// Violation codes
+const (
+	CodeRootNotAllowed   = "ROOT_NOT_ALLOWED"
+	CodeChildNotAllowed  = "CHILD_NOT_ALLOWED"
+	CodeMaxDepthExceeded = "MAX_DEPTH_EXCEEDED"
+)
+
+// Violation describes one way a placement breaks the rules
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Rules is an immutable set of parenting rules indexed by category type.
+// Category types without a rule are unrestricted.
+type Rules struct {
+	byType map[string]*domain.CategoryTypeRule
+}
+
+// NewRules indexes rules by category type
+func NewRules(rules []*domain.CategoryTypeRule) *Rules {
+	byType := make(map[string]*domain.CategoryTypeRule, len(rules))
+	for _, rule := range rules {
+		byType[rule.CategoryType] = rule
+	}
+	return &Rules{byType: byType}
+}
+
+// Check validates placing an entity of categoryType at depth beneath a parent of
+// parentType. A nil parentType places the entity at the root.
+func (r *Rules) Check(categoryType string, parentType *string, depth int) []Violation {
+	var violations []Violation
+
+	if parentType == nil {
+		if rule, ok := r.byType[categoryType]; ok && !rule.AllowedAsRoot {
+			violations = append(violations, Violation{
+				Code:    CodeRootNotAllowed,
+				Message: fmt.Sprintf("%s entities cannot be roots", categoryType),
+			})
+		}
+	} else if rule, ok := r.byType[*parentType]; ok && !slices.Contains(rule.AllowedChildTypes, categoryType) {
+		violations = append(violations, Violation{
+			Code:    CodeChildNotAllowed,
+			Message: fmt.Sprintf("%s entities cannot be placed under %s entities", categoryType, *parentType),
+		})
+	}
+
+	if violation := r.CheckDepth(categoryType, depth); violation != nil {
+		violations = append(violations, *violation)
+	}
+
+	return violations
+}
+
+// CheckDepth validates only the depth limit of categoryType
+func (r *Rules) CheckDepth(categoryType string, depth int) *Violation {
+	rule, ok := r.byType[categoryType]
+	if !ok || rule.MaxDepth == nil || depth <= *rule.MaxDepth {
+		return nil
+	}
+
+	return &Violation{
+		Code:    CodeMaxDepthExceeded,
+		Message: fmt.Sprintf("%s entities cannot be deeper than level %d", categoryType, *rule.MaxDepth),
+	}
+}
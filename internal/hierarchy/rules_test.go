@@ -0,0 +1,50 @@
+package hierarchy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+func TestRulesCheck(t *testing.T) {
+	maxDepth := 3
+	rules := NewRules([]*domain.CategoryTypeRule{
+		{CategoryType: "user", AllowedAsRoot: true, AllowedChildTypes: []string{"office", "location"}},
+		{CategoryType: "office", AllowedChildTypes: []string{"location"}},
+		{CategoryType: "location", AllowedChildTypes: []string{"location"}, MaxDepth: &maxDepth},
+	})
+	user, location := "user", "location"
+
+	t.Run("Allowed", func(t *testing.T) {
+		assert.Empty(t, rules.Check("user", nil, 1))
+		assert.Empty(t, rules.Check("office", &user, 2))
+		assert.Empty(t, rules.Check("location", &location, 3))
+	})
+
+	t.Run("RootNotAllowed", func(t *testing.T) {
+		violations := rules.Check("location", nil, 1)
+		require.Len(t, violations, 1)
+		assert.Equal(t, CodeRootNotAllowed, violations[0].Code)
+	})
+
+	t.Run("ChildNotAllowed", func(t *testing.T) {
+		violations := rules.Check("user", &location, 2)
+		require.Len(t, violations, 1)
+		assert.Equal(t, CodeChildNotAllowed, violations[0].Code)
+	})
+
+	t.Run("MaxDepthExceeded", func(t *testing.T) {
+		violations := rules.Check("location", &location, 4)
+		require.Len(t, violations, 1)
+		assert.Equal(t, CodeMaxDepthExceeded, violations[0].Code)
+	})
+
+	t.Run("UnknownTypesAreUnrestricted", func(t *testing.T) {
+		site := "site"
+		assert.Empty(t, rules.Check("site", nil, 10))
+		assert.Empty(t, rules.Check("office", &site, 10))
+	})
+}
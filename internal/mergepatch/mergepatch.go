@@ -0,0 +1,70 @@
+// Package mergepatch applies JSON Merge Patch documents (RFC 7386) to JSON resources
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPatch is returned when a patch is not a single valid JSON value
+var ErrInvalidPatch = errors.New("invalid merge patch")
+
+// Apply merges patch into target and returns the resulting document. Members of a patch
+// object replace those of the target, null members remove them, and nested objects are
+// merged recursively. A patch that is not an object replaces the whole target.
+func Apply(target, patch []byte) ([]byte, error) {
+	patchValue, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var targetValue any
+	if len(bytes.TrimSpace(target)) > 0 {
+		if targetValue, err = decode(target); err != nil {
+			return nil, fmt.Errorf("invalid merge target: %w", err)
+		}
+	}
+
+	return json.Marshal(merge(targetValue, patchValue))
+}
+
+// merge is the MergePatch function of RFC 7386
+func merge(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any, len(patchObject))
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = merge(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
+
+// decode parses a single JSON value, keeping numbers as they were written
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,56 @@
+package mergepatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	// Cases from the examples in RFC 7386, appendix A
+	cases := []struct {
+		name, target, patch, want string
+	}{
+		{"ReplaceMember", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"AddMember", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"RemoveMember", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"RemoveOneOfTwo", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"ReplaceArray", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"ArrayReplacesValue", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"NestedMerge", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"ArraysAreNotMerged", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"ArrayTarget", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"ObjectReplacesArray", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"NullPatch", `{"a":"foo"}`, `null`, `null`},
+		{"StringPatch", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"KeepsNullMembers", `{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{"ScalarTarget", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"NullsInsideNewObject", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{"EmptyTarget", ``, `{"a":"b"}`, `{"a":"b"}`},
+		{"LargeNumber", `{"a":1}`, `{"b":12345678901234567890}`, `{"a":1,"b":12345678901234567890}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, err := Apply([]byte(tc.target), []byte(tc.patch))
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(merged))
+		})
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	_, err := Apply([]byte(`{}`), []byte(`{"a":`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+
+	_, err = Apply([]byte(`{}`), []byte(`{"a":1} {"b":2}`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+
+	_, err = Apply([]byte(`{}`), nil)
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+
+	_, err = Apply([]byte(`{`), []byte(`{"a":1}`))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidPatch)
+}
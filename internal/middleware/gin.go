@@ -1,21 +1,30 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
+	"n1h41/zolaris-backend-app/internal/audit"
+	"n1h41/zolaris-backend-app/internal/domain"
 	"n1h41/zolaris-backend-app/internal/services"
 )
 
+// ImpersonateUserHeader names the user an admin wants to act as for the request
+const ImpersonateUserHeader = "X-Impersonate-User"
+
 // GinAuthMiddleware checks for user authentication
 // This is a simplified version - in a real app, use JWT or OAuth2
 func GinAuthMiddleware(userService *services.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		cogntioId := c.GetHeader("X-Cognito-ID")
 
-		userID, err := userService.GetUserIdByCognitoId(c.Request.Context(), cogntioId)
+		identity, err := userService.GetIdentityByCognitoId(c.Request.Context(), cogntioId)
 		if err != nil {
 			log.Printf("Error retrieving user ID by Cognito ID: %v", err)
 			c.JSON(500, gin.H{"status": false, "message": "Internal server error"})
@@ -23,18 +32,159 @@ func GinAuthMiddleware(userService *services.UserService) gin.HandlerFunc {
 			return
 		}
 
+		// Users signing in for the first time are created from their verified ID token
+		if identity == nil {
+			identity, err = userService.ProvisionFromToken(c.Request.Context(), cogntioId, bearerToken(c))
+			if err != nil {
+				abortProvisioning(c, err)
+				return
+			}
+		}
+
 		// For demo purposes only - in production, never use a default user
-		if userID == "" {
+		if identity == nil {
 			c.JSON(401, gin.H{"status": false, "message": "Unauthorized: Invalid user ID. Could not retrieve user by Cognito ID."})
 			c.Abort()
 			return
 		}
 
+		if identity.Disabled {
+			c.JSON(403, gin.H{"status": false, "message": "Forbidden: Account is disabled"})
+			c.Abort()
+			return
+		}
+		userID := identity.UserID
+		actorID := identity.UserID
+
+		// Admins may act as another user; the request's changes stay attributed to the admin
+		targetID := c.GetHeader(ImpersonateUserHeader)
+		if targetID != "" {
+			target, ok := resolveImpersonationTarget(c, userService, identity, targetID)
+			if !ok {
+				return
+			}
+			userID = target.UserID
+			log.Printf("Admin %s impersonating user %s: %s %s", actorID, userID, c.Request.Method, c.Request.URL.Path)
+			c.Header("X-Impersonated-User", userID)
+		}
+
 		// Log authentication
 		log.Printf("Authenticated request for user: %s", userID)
 
-		// Add user ID to request context
+		// Add user ID to request context, and attribute the request's changes to the caller
 		c.Set(string(UserIDKey), userID)
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), actorID))
+
+		c.Next()
+
+		if targetID != "" {
+			recordImpersonation(c, userService, actorID, userID)
+		}
+	}
+}
+
+// bearerToken returns the token in the request's Authorization header, if any
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// abortProvisioning responds to a failure to create a user from their ID token
+func abortProvisioning(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidToken):
+		log.Printf("Rejected ID token: %v", err)
+		c.JSON(401, gin.H{"status": false, "message": "Unauthorized: Invalid ID token"})
+	case errors.Is(err, services.ErrInvalidCognitoProfile):
+		c.JSON(400, gin.H{"status": false, "message": err.Error()})
+	case errors.Is(err, services.ErrEmailInUse):
+		c.JSON(409, gin.H{"status": false, "message": "Email is already in use by another account"})
+	case errors.Is(err, services.ErrUserDeleted):
+		c.JSON(403, gin.H{"status": false, "message": "Forbidden: Account has been deleted"})
+	default:
+		log.Printf("Error provisioning user from ID token: %v", err)
+		c.JSON(500, gin.H{"status": false, "message": "Internal server error"})
+	}
+	c.Abort()
+}
+
+// resolveImpersonationTarget checks that the caller may impersonate the requested user and
+// loads them, aborting the request if not. Only admins may impersonate, and never other admins.
+func resolveImpersonationTarget(c *gin.Context, userService *services.UserService, caller *domain.UserIdentity, targetID string) (*domain.UserIdentity, bool) {
+	if caller.Role != domain.UserRoleAdmin {
+		c.JSON(403, gin.H{"status": false, "message": "Forbidden: Admin access required to impersonate users"})
+		c.Abort()
+		return nil, false
+	}
+
+	if err := uuid.Validate(targetID); err != nil {
+		c.JSON(400, gin.H{"status": false, "message": "Invalid " + ImpersonateUserHeader + " header: must be a user ID"})
+		c.Abort()
+		return nil, false
+	}
+
+	target, err := userService.GetIdentityByUserID(c.Request.Context(), targetID)
+	if err != nil {
+		log.Printf("Error retrieving impersonated user %s: %v", targetID, err)
+		c.JSON(500, gin.H{"status": false, "message": "Internal server error"})
+		c.Abort()
+		return nil, false
+	}
+
+	if target == nil {
+		c.JSON(404, gin.H{"status": false, "message": "Impersonated user not found"})
+		c.Abort()
+		return nil, false
+	}
+
+	if target.Role == domain.UserRoleAdmin {
+		c.JSON(403, gin.H{"status": false, "message": "Forbidden: Admins cannot be impersonated"})
+		c.Abort()
+		return nil, false
+	}
+
+	return target, true
+}
+
+// recordImpersonation writes a finished impersonated request to the impersonation log
+func recordImpersonation(c *gin.Context, userService *services.UserService, adminUserID, userID string) {
+	record := &domain.ImpersonationRecord{
+		AdminUserID: adminUserID,
+		UserID:      userID,
+		Method:      c.Request.Method,
+		Path:        c.Request.URL.Path,
+		Status:      c.Writer.Status(),
+		ClientIP:    c.ClientIP(),
+	}
+
+	// The log entry must be written even if the client has gone away
+	if err := userService.RecordImpersonation(context.WithoutCancel(c.Request.Context()), record); err != nil {
+		log.Printf("Error recording impersonation by admin %s of user %s: %v", adminUserID, userID, err)
+	}
+}
+
+// GinAdminMiddleware rejects requests from users without the admin role.
+// It must run after GinAuthMiddleware.
+func GinAdminMiddleware(userService *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserIDFromGin(c)
+
+		isAdmin, err := userService.IsAdmin(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("Error retrieving role for user %s: %v", userID, err)
+			c.JSON(500, gin.H{"status": false, "message": "Internal server error"})
+			c.Abort()
+			return
+		}
+
+		if !isAdmin {
+			c.JSON(403, gin.H{"status": false, "message": "Forbidden: Admin access required"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
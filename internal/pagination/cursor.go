@@ -0,0 +1,40 @@
+// Package pagination encodes the opaque cursors used for keyset pagination
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor identifies the last row of a page: its sort value and, to break ties, its ID.
+// Sort records the ordering the cursor was issued for, so it cannot be reused with another.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func Encode(cursor Cursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode parses a cursor produced by Encode
+func Decode(encoded string) (Cursor, error) {
+	var cursor Cursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == "" {
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
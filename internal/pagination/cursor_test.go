@@ -0,0 +1,23 @@
+package pagination
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{Sort: "name:asc", Value: "Plant / North", ID: "7d1f1f0e-7a43-4c1c-9f55-0c1b2a6d9e10"}
+
+	decoded, err := Decode(Encode(cursor))
+	require.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+}
+
+func TestDecodeRejectsMalformedCursors(t *testing.T) {
+	for _, encoded := range []string{"", "not base64!", Encode(Cursor{Sort: "name:asc"})} {
+		_, err := Decode(encoded)
+		assert.ErrorIs(t, err, ErrInvalidCursor, encoded)
+	}
+}
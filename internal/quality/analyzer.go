@@ -0,0 +1,228 @@
+package quality
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// maxListedIssues caps how many individual gaps, duplicates and out-of-range values
+// are kept in a report; totals are always exact
+const maxListedIssues = 100
+
+// MinSamplePeriod is the shortest expected interval between readings. Shorter periods
+// make the expected sample count meaningless and are reported in whole milliseconds as 0.
+const MinSamplePeriod = time.Second
+
+// Measurement fields checked against physical bounds
+const (
+	FieldAmperage    = "amperage"
+	FieldTemperature = "temperature"
+	FieldHumidity    = "humidity"
+)
+
+// Bounds is an inclusive range of physically plausible values
+type Bounds struct {
+	Min float64
+	Max float64
+}
+
+// Contains reports whether v lies within the bounds
+func (b Bounds) Contains(v float64) bool {
+	return v >= b.Min && v <= b.Max
+}
+
+// Config controls how readings are judged
+type Config struct {
+	// SamplePeriod is the interval at which a device is expected to report
+	SamplePeriod time.Duration
+	// GapFactor flags a gap when two readings are more than GapFactor × SamplePeriod apart
+	GapFactor float64
+	// DuplicateWindow treats readings closer together than this as duplicates of each other
+	DuplicateWindow time.Duration
+	Amperage        Bounds
+	Temperature     Bounds
+	Humidity        Bounds
+}
+
+// Validate checks that the configuration can be used for analysis
+func (c Config) Validate() error {
+	if c.SamplePeriod < MinSamplePeriod {
+		return fmt.Errorf("sample period must be at least %s", MinSamplePeriod)
+	}
+	if c.GapFactor < 1 {
+		return fmt.Errorf("gap factor must be at least 1")
+	}
+	if c.DuplicateWindow < 0 {
+		return fmt.Errorf("duplicate window must not be negative")
+	}
+	for field, bounds := range map[string]Bounds{FieldAmperage: c.Amperage, FieldTemperature: c.Temperature, FieldHumidity: c.Humidity} {
+		if bounds.Min > bounds.Max {
+			return fmt.Errorf("%s bounds are inverted", field)
+		}
+	}
+	return nil
+}
+
+// GapThreshold is the longest spacing between readings that is not reported as a gap
+func (c Config) GapThreshold() time.Duration {
+	return time.Duration(float64(c.SamplePeriod) * c.GapFactor)
+}
+
+// Gap is a stretch of the window without readings
+type Gap struct {
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+}
+
+// Duplicate is a reading that arrived within the duplicate window of the previous accepted reading
+type Duplicate struct {
+	Timestamp         time.Time
+	PreviousTimestamp time.Time
+}
+
+// OutOfRange is a measurement outside its configured bounds
+type OutOfRange struct {
+	Timestamp time.Time
+	Field     string
+	Value     float64
+	Bounds    Bounds
+}
+
+// Report summarizes the quality of a device's readings over a window
+type Report struct {
+	DeviceID        string
+	Start           time.Time
+	End             time.Time
+	SamplePeriod    time.Duration
+	ExpectedSamples int64
+	ActualSamples   int64
+	// Completeness is the share of expected samples that arrived, excluding duplicates
+	Completeness     float64
+	GapCount         int64
+	MissingDuration  time.Duration
+	Gaps             []Gap
+	DuplicateCount   int64
+	Duplicates       []Duplicate
+	OutOfRangeCount  int64
+	OutOfRange       []OutOfRange
+	UnparseableCount int64
+}
+
+// Analyzer builds a Report incrementally from readings in ascending time order,
+// so arbitrarily long windows can be analyzed page by page
+type Analyzer struct {
+	config Config
+	report *Report
+	last   time.Time
+	seen   bool
+}
+
+// NewAnalyzer creates an analyzer for a device's readings between start and end
+func NewAnalyzer(config Config, deviceID string, start, end time.Time) *Analyzer {
+	return &Analyzer{
+		config: config,
+		report: &Report{
+			DeviceID:     deviceID,
+			Start:        start,
+			End:          end,
+			SamplePeriod: config.SamplePeriod,
+		},
+	}
+}
+
+// Add folds a page of readings into the report
+func (a *Analyzer) Add(readings []*domain.SensorReading) {
+	for _, reading := range readings {
+		a.report.ActualSamples++
+
+		if a.seen && reading.Timestamp.Sub(a.last) < a.config.DuplicateWindow {
+			a.report.DuplicateCount++
+			if len(a.report.Duplicates) < maxListedIssues {
+				a.report.Duplicates = append(a.report.Duplicates, Duplicate{
+					Timestamp:         reading.Timestamp,
+					PreviousTimestamp: a.last,
+				})
+			}
+		} else {
+			previous := a.report.Start
+			if a.seen {
+				previous = a.last
+			}
+			a.checkGap(previous, reading.Timestamp)
+			a.last = reading.Timestamp
+			a.seen = true
+		}
+
+		a.checkValue(reading.Timestamp, FieldAmperage, reading.Amperage, a.config.Amperage)
+		a.checkValue(reading.Timestamp, FieldTemperature, reading.Temperature, a.config.Temperature)
+		a.checkValue(reading.Timestamp, FieldHumidity, reading.Humidity, a.config.Humidity)
+	}
+}
+
+// Report finalizes and returns the report, including any gap at the end of the window
+func (a *Analyzer) Report() *Report {
+	last := a.report.Start
+	if a.seen {
+		last = a.last
+	}
+	a.checkGap(last, a.report.End)
+
+	window := a.report.End.Sub(a.report.Start)
+	if window > 0 {
+		a.report.ExpectedSamples = int64(window / a.config.SamplePeriod)
+	}
+
+	if a.report.ExpectedSamples > 0 {
+		unique := a.report.ActualSamples - a.report.DuplicateCount
+		a.report.Completeness = min(float64(unique)/float64(a.report.ExpectedSamples), 1)
+	}
+
+	return a.report
+}
+
+// checkGap records a gap if the spacing between two points exceeds the threshold
+func (a *Analyzer) checkGap(from, to time.Time) {
+	spacing := to.Sub(from)
+	if spacing <= a.config.GapThreshold() {
+		return
+	}
+
+	a.report.GapCount++
+	// Only the time beyond one expected period is actually missing
+	a.report.MissingDuration += spacing - a.config.SamplePeriod
+	if len(a.report.Gaps) < maxListedIssues {
+		a.report.Gaps = append(a.report.Gaps, Gap{Start: from, End: to, Duration: spacing})
+	}
+}
+
+// checkValue records a measurement that cannot be parsed or lies outside its bounds.
+// Empty values are treated as not reported rather than invalid.
+func (a *Analyzer) checkValue(timestamp time.Time, field, raw string, bounds Bounds) {
+	if raw == "" {
+		return
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		a.report.UnparseableCount++
+		return
+	}
+
+	if bounds.Contains(value) {
+		return
+	}
+
+	a.report.OutOfRangeCount++
+	if len(a.report.OutOfRange) < maxListedIssues {
+		a.report.OutOfRange = append(a.report.OutOfRange, OutOfRange{
+			Timestamp: timestamp,
+			Field:     field,
+			Value:     value,
+			Bounds:    bounds,
+		})
+	}
+}
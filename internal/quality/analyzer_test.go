@@ -0,0 +1,103 @@
+package quality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+var testConfig = Config{
+	SamplePeriod:    time.Minute,
+	GapFactor:       3,
+	DuplicateWindow: time.Second,
+	Amperage:        Bounds{Min: 0, Max: 100},
+	Temperature:     Bounds{Min: -40, Max: 125},
+	Humidity:        Bounds{Min: 0, Max: 100},
+}
+
+func reading(ts time.Time, amperage string) *domain.SensorReading {
+	return &domain.SensorReading{Timestamp: ts, Amperage: amperage, Temperature: "25", Humidity: "40"}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, testConfig.Validate())
+
+	t.Run("SamplePeriodBelowFloor", func(t *testing.T) {
+		config := testConfig
+		config.SamplePeriod = 500 * time.Microsecond
+		assert.Error(t, config.Validate())
+	})
+
+	t.Run("SamplePeriodAtFloor", func(t *testing.T) {
+		config := testConfig
+		config.SamplePeriod = MinSamplePeriod
+		assert.NoError(t, config.Validate())
+	})
+}
+
+func TestAnalyzer(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	t.Run("CompleteData", func(t *testing.T) {
+		var readings []*domain.SensorReading
+		for i := range 60 {
+			readings = append(readings, reading(start.Add(time.Duration(i)*time.Minute), "5"))
+		}
+
+		analyzer := NewAnalyzer(testConfig, "aa:bb", start, end)
+		analyzer.Add(readings)
+		report := analyzer.Report()
+
+		assert.Equal(t, int64(60), report.ExpectedSamples)
+		assert.Equal(t, int64(60), report.ActualSamples)
+		assert.InDelta(t, 1.0, report.Completeness, 1e-9)
+		assert.Zero(t, report.GapCount)
+		assert.Zero(t, report.DuplicateCount)
+		assert.Zero(t, report.OutOfRangeCount)
+	})
+
+	t.Run("GapsDuplicatesAndOutOfRange", func(t *testing.T) {
+		readings := []*domain.SensorReading{
+			reading(start, "5"),
+			reading(start.Add(time.Minute), "5"),
+			reading(start.Add(time.Minute+200*time.Millisecond), "5"),
+			// 10 minute hole
+			reading(start.Add(11*time.Minute), "250"),
+			reading(start.Add(12*time.Minute), "abc"),
+		}
+
+		analyzer := NewAnalyzer(testConfig, "aa:bb", start, end)
+		// Pages may split anywhere
+		analyzer.Add(readings[:2])
+		analyzer.Add(readings[2:])
+		report := analyzer.Report()
+
+		assert.Equal(t, int64(5), report.ActualSamples)
+		assert.Equal(t, int64(1), report.DuplicateCount)
+		require.Len(t, report.Duplicates, 1)
+		assert.Equal(t, start.Add(time.Minute), report.Duplicates[0].PreviousTimestamp)
+
+		// The hole in the middle and the silent tail of the window
+		require.Equal(t, int64(2), report.GapCount)
+		assert.Equal(t, 10*time.Minute, report.Gaps[0].Duration)
+		assert.Equal(t, end, report.Gaps[1].End)
+
+		assert.Equal(t, int64(1), report.OutOfRangeCount)
+		assert.Equal(t, FieldAmperage, report.OutOfRange[0].Field)
+		assert.Equal(t, int64(1), report.UnparseableCount)
+		assert.InDelta(t, 4.0/60.0, report.Completeness, 1e-9)
+	})
+
+	t.Run("NoData", func(t *testing.T) {
+		report := NewAnalyzer(testConfig, "aa:bb", start, end).Report()
+
+		assert.Zero(t, report.Completeness)
+		require.Equal(t, int64(1), report.GapCount)
+		assert.Equal(t, time.Hour, report.Gaps[0].Duration)
+	})
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"n1h41/zolaris-backend-app/internal/cache"
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// CachedUserRepository caches the Cognito ID to user identity lookup that every authenticated
+// request makes, and drops a user's cached identity whenever a write changes it. Only users that
+// exist are cached, so a user provisioned on their first request is found on their next.
+type CachedUserRepository struct {
+	UserRepositoryInterface
+	identities *cache.Tiered[domain.UserIdentity]
+}
+
+// NewCachedUserRepository wraps repo so identity lookups go through identities
+func NewCachedUserRepository(repo UserRepositoryInterface, identities *cache.Tiered[domain.UserIdentity]) *CachedUserRepository {
+	return &CachedUserRepository{
+		UserRepositoryInterface: repo,
+		identities:              identities,
+	}
+}
+
+// IdentityCacheStats returns the identity cache's hit and miss counters
+func (r *CachedUserRepository) IdentityCacheStats() cache.Stats {
+	return r.identities.Stats()
+}
+
+func (r *CachedUserRepository) GetUserIdByCognitoId(ctx context.Context, cId string) (string, error) {
+	identity, err := r.GetIdentityByCognitoId(ctx, cId)
+	if err != nil || identity == nil {
+		return "", err
+	}
+	return identity.UserID, nil
+}
+
+func (r *CachedUserRepository) GetIdentityByCognitoId(ctx context.Context, cId string) (*domain.UserIdentity, error) {
+	if cId == "" {
+		return r.UserRepositoryInterface.GetIdentityByCognitoId(ctx, cId)
+	}
+
+	if identity, ok := r.identities.Get(ctx, cId); ok {
+		return &identity, nil
+	}
+
+	identity, err := r.UserRepositoryInterface.GetIdentityByCognitoId(ctx, cId)
+	if err != nil || identity == nil {
+		return identity, err
+	}
+
+	r.identities.Set(ctx, cId, *identity)
+	return identity, nil
+}
+
+func (r *CachedUserRepository) UpdateUser(ctx context.Context, user *domain.User) error {
+	defer r.invalidate(ctx, user.ID)()
+	return r.UserRepositoryInterface.UpdateUser(ctx, user)
+}
+
+func (r *CachedUserRepository) SetUserDisabled(ctx context.Context, userID string, disabled bool) (bool, error) {
+	defer r.invalidate(ctx, userID)()
+	return r.UserRepositoryInterface.SetUserDisabled(ctx, userID, disabled)
+}
+
+func (r *CachedUserRepository) SetUserRole(ctx context.Context, userID string, role domain.UserRole) (bool, error) {
+	defer r.invalidate(ctx, userID)()
+	return r.UserRepositoryInterface.SetUserRole(ctx, userID, role)
+}
+
+func (r *CachedUserRepository) SoftDeleteUser(ctx context.Context, userID string) (bool, error) {
+	defer r.invalidate(ctx, userID)()
+	return r.UserRepositoryInterface.SoftDeleteUser(ctx, userID)
+}
+
+func (r *CachedUserRepository) RestoreUser(ctx context.Context, userID string) (bool, error) {
+	defer r.invalidate(ctx, userID)()
+	return r.UserRepositoryInterface.RestoreUser(ctx, userID)
+}
+
+func (r *CachedUserRepository) EraseUser(ctx context.Context, userID string) (*domain.UserErasure, error) {
+	defer r.invalidate(ctx, userID)()
+	return r.UserRepositoryInterface.EraseUser(ctx, userID)
+}
+
+// invalidate looks up the Cognito ID of the user a write is about to change, while the user
+// still exists, and returns a function that drops their cached identity once the write is done.
+// The identity is dropped even if the write fails, since it may have been partly applied.
+func (r *CachedUserRepository) invalidate(ctx context.Context, userID string) func() {
+	cognitoID, err := r.UserRepositoryInterface.GetCognitoIdByUserID(ctx, userID)
+	if err != nil {
+		log.Printf("Error looking up Cognito ID of user %s to invalidate cached identity: %v", userID, err)
+		return func() {}
+	}
+	if cognitoID == "" {
+		return func() {}
+	}
+
+	return func() {
+		r.identities.Delete(context.WithoutCancel(ctx), cognitoID)
+	}
+}
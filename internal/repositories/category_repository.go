@@ -8,11 +8,21 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"n1h41/zolaris-backend-app/internal/domain"
 )
 
+var (
+	// ErrUnknownCategoryType is returned when a category type is not in z_category_type
+	ErrUnknownCategoryType = errors.New("unknown category type")
+	// ErrDuplicateCategory is returned when a category with the same name and type exists
+	ErrDuplicateCategory = errors.New("category with this name and type already exists")
+	// ErrCategoryInUse is returned when deleting a category that entities still use
+	ErrCategoryInUse = errors.New("category is used by existing entities")
+)
+
 // CategoryRepository handles all category-related database operations
 type CategoryRepository struct {
 	db *pgxpool.Pool
@@ -49,6 +59,12 @@ func (r *CategoryRepository) AddCategory(ctx context.Context, name, categoryType
 		now,
 	)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrUnknownCategoryType
+		}
+		if isUniqueViolation(err) {
+			return ErrDuplicateCategory
+		}
 		return fmt.Errorf("failed to add category: %w", err)
 	}
 
@@ -58,7 +74,7 @@ func (r *CategoryRepository) AddCategory(ctx context.Context, name, categoryType
 // GetCategoryByName retrieves a category by its name
 func (r *CategoryRepository) GetCategoryByName(ctx context.Context, name string) (*domain.Category, error) {
 	query := `
-		SELECT category_id, name, type, created_at
+		SELECT category_id, name, type, details_schema, schema_version, created_at
 		FROM z_category 
 		WHERE name = $1
 	`
@@ -70,6 +86,8 @@ func (r *CategoryRepository) GetCategoryByName(ctx context.Context, name string)
 		&category.ID,
 		&category.Name,
 		&category.Type,
+		&category.DetailsSchema,
+		&category.SchemaVersion,
 		&category.CreatedAt,
 	)
 	if err != nil {
@@ -82,10 +100,56 @@ func (r *CategoryRepository) GetCategoryByName(ctx context.Context, name string)
 	return category, nil
 }
 
+// GetCategoryByID retrieves a category by its ID
+func (r *CategoryRepository) GetCategoryByID(ctx context.Context, categoryID string) (*domain.Category, error) {
+	query := `
+		SELECT category_id, name, type, details_schema, schema_version, created_at
+		FROM z_category
+		WHERE category_id = $1
+	`
+
+	category := &domain.Category{}
+	err := r.db.QueryRow(ctx, query, categoryID).Scan(
+		&category.ID,
+		&category.Name,
+		&category.Type,
+		&category.DetailsSchema,
+		&category.SchemaVersion,
+		&category.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil // Category not found, return nil without error
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return category, nil
+}
+
+// UpdateDetailsSchema replaces a category's details schema (nil removes it) and returns the new schema version
+func (r *CategoryRepository) UpdateDetailsSchema(ctx context.Context, categoryID string, detailsSchema []byte) (int, error) {
+	query := `
+		UPDATE z_category SET
+			details_schema = $2,
+			schema_version = schema_version + 1,
+			updated_at = $3
+		WHERE category_id = $1
+		RETURNING schema_version
+	`
+
+	var version int
+	if err := r.db.QueryRow(ctx, query, categoryID, detailsSchema, time.Now()).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to update category schema: %w", err)
+	}
+
+	return version, nil
+}
+
 // GetCategoriesByType retrieves all categories of a specific type
 func (r *CategoryRepository) GetCategoriesByType(ctx context.Context, categoryType string) ([]*domain.Category, error) {
 	query := `
-		SELECT category_id, name, type, created_at
+		SELECT category_id, name, type, details_schema, schema_version, created_at
 		FROM z_category 
 		WHERE type = $1
 		ORDER BY name
@@ -104,6 +168,8 @@ func (r *CategoryRepository) GetCategoriesByType(ctx context.Context, categoryTy
 			&category.ID,
 			&category.Name,
 			&category.Type,
+			&category.DetailsSchema,
+			&category.SchemaVersion,
 			&category.CreatedAt,
 		)
 		if err != nil {
@@ -123,9 +189,10 @@ func (r *CategoryRepository) GetCategoriesByType(ctx context.Context, categoryTy
 // ListAllCategories retrieves all categories from the database
 func (r *CategoryRepository) ListAllCategories(ctx context.Context) ([]*domain.Category, error) {
 	query := `
-		SELECT category_id, name, type, created_at
-		FROM z_category
-		ORDER BY type, name
+		SELECT c.category_id, c.name, c.type, c.details_schema, c.schema_version, c.created_at,
+		       (SELECT COUNT(*) FROM z_entity e WHERE e.category_id = c.category_id) AS entity_count
+		FROM z_category c
+		ORDER BY c.type, c.name
 	`
 
 	rows, err := r.db.Query(ctx, query)
@@ -141,7 +208,10 @@ func (r *CategoryRepository) ListAllCategories(ctx context.Context) ([]*domain.C
 			&category.ID,
 			&category.Name,
 			&category.Type,
+			&category.DetailsSchema,
+			&category.SchemaVersion,
 			&category.CreatedAt,
+			&category.EntityCount,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning category row: %w", err)
@@ -156,3 +226,345 @@ func (r *CategoryRepository) ListAllCategories(ctx context.Context) ([]*domain.C
 
 	return categories, nil
 }
+
+// ListTypeRules retrieves the parenting rules of every category type that has one
+func (r *CategoryRepository) ListTypeRules(ctx context.Context) ([]*domain.CategoryTypeRule, error) {
+	query := `
+		SELECT category_type, allowed_as_root, allowed_child_types, max_depth, updated_at
+		FROM z_category_type_rule
+		ORDER BY category_type
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category type rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*domain.CategoryTypeRule, 0)
+	for rows.Next() {
+		rule := new(domain.CategoryTypeRule)
+		if err := rows.Scan(
+			&rule.CategoryType,
+			&rule.AllowedAsRoot,
+			&rule.AllowedChildTypes,
+			&rule.MaxDepth,
+			&rule.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning category type rule row: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category type rule rows: %w", err)
+	}
+
+	return rules, nil
+}
+
+// UpsertTypeRule creates or replaces the parenting rule of a category type
+func (r *CategoryRepository) UpsertTypeRule(ctx context.Context, rule *domain.CategoryTypeRule) error {
+	query := `
+		INSERT INTO z_category_type_rule (
+			category_type, allowed_as_root, allowed_child_types, max_depth, updated_at
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (category_type) DO UPDATE SET
+			allowed_as_root = EXCLUDED.allowed_as_root,
+			allowed_child_types = EXCLUDED.allowed_child_types,
+			max_depth = EXCLUDED.max_depth,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	rule.UpdatedAt = time.Now()
+	if _, err := r.db.Exec(
+		ctx,
+		query,
+		rule.CategoryType,
+		rule.AllowedAsRoot,
+		rule.AllowedChildTypes,
+		rule.MaxDepth,
+		rule.UpdatedAt,
+	); err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrUnknownCategoryType
+		}
+		return fmt.Errorf("failed to save category type rule: %w", err)
+	}
+
+	return nil
+}
+
+// ListCategoryTypes retrieves every managed category type
+func (r *CategoryRepository) ListCategoryTypes(ctx context.Context) ([]*domain.CategoryTypeDefinition, error) {
+	query := `
+		SELECT name, description, is_system, created_at, updated_at
+		FROM z_category_type
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category types: %w", err)
+	}
+	defer rows.Close()
+
+	categoryTypes := make([]*domain.CategoryTypeDefinition, 0)
+	for rows.Next() {
+		categoryType := new(domain.CategoryTypeDefinition)
+		if err := rows.Scan(
+			&categoryType.Name,
+			&categoryType.Description,
+			&categoryType.IsSystem,
+			&categoryType.CreatedAt,
+			&categoryType.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning category type row: %w", err)
+		}
+		categoryTypes = append(categoryTypes, categoryType)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category type rows: %w", err)
+	}
+
+	return categoryTypes, nil
+}
+
+// GetCategoryTypeByName retrieves a category type, returning nil if it does not exist
+func (r *CategoryRepository) GetCategoryTypeByName(ctx context.Context, name string) (*domain.CategoryTypeDefinition, error) {
+	query := `
+		SELECT name, description, is_system, created_at, updated_at
+		FROM z_category_type
+		WHERE name = $1
+	`
+
+	categoryType := new(domain.CategoryTypeDefinition)
+	err := r.db.QueryRow(ctx, query, name).Scan(
+		&categoryType.Name,
+		&categoryType.Description,
+		&categoryType.IsSystem,
+		&categoryType.CreatedAt,
+		&categoryType.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get category type: %w", err)
+	}
+
+	return categoryType, nil
+}
+
+// AddCategoryType adds a new category type
+func (r *CategoryRepository) AddCategoryType(ctx context.Context, categoryType *domain.CategoryTypeDefinition) error {
+	query := `
+		INSERT INTO z_category_type (name, description, is_system, created_at, updated_at)
+		VALUES ($1, $2, FALSE, $3, $3)
+	`
+
+	now := time.Now()
+	if _, err := r.db.Exec(ctx, query, categoryType.Name, categoryType.Description, now); err != nil {
+		return fmt.Errorf("failed to add category type: %w", err)
+	}
+
+	categoryType.CreatedAt = now
+	categoryType.UpdatedAt = now
+	return nil
+}
+
+// UpdateCategoryType replaces the description of a category type
+func (r *CategoryRepository) UpdateCategoryType(ctx context.Context, name, description string) error {
+	query := `UPDATE z_category_type SET description = $2, updated_at = $3 WHERE name = $1`
+	if _, err := r.db.Exec(ctx, query, name, description, time.Now()); err != nil {
+		return fmt.Errorf("failed to update category type: %w", err)
+	}
+
+	return nil
+}
+
+// CountCategoriesOfType returns the number of categories using a category type
+func (r *CategoryRepository) CountCategoriesOfType(ctx context.Context, name string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM z_category WHERE type = $1`
+	if err := r.db.QueryRow(ctx, query, name).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count categories of type: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteCategoryType removes a category type along with its parenting rule and any
+// mention of it in other types' allowed child types
+func (r *CategoryRepository) DeleteCategoryType(ctx context.Context, name string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	removeChildQuery := `
+		UPDATE z_category_type_rule
+		SET allowed_child_types = array_remove(allowed_child_types, $1::varchar)
+		WHERE $1 = ANY(allowed_child_types)
+	`
+	if _, err := tx.Exec(ctx, removeChildQuery, name); err != nil {
+		return fmt.Errorf("failed to update category type rules: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM z_category_type WHERE name = $1`, name); err != nil {
+		return fmt.Errorf("failed to delete category type: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateCategory replaces the name and type of a category
+func (r *CategoryRepository) UpdateCategory(ctx context.Context, categoryID, name, categoryType string) error {
+	query := `UPDATE z_category SET name = $2, type = $3, updated_at = $4 WHERE category_id = $1`
+	if _, err := r.db.Exec(ctx, query, categoryID, name, categoryType, time.Now()); err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrUnknownCategoryType
+		}
+		if isUniqueViolation(err) {
+			return ErrDuplicateCategory
+		}
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+
+	return nil
+}
+
+// CountEntitiesOfCategory returns the number of entities using a category
+func (r *CategoryRepository) CountEntitiesOfCategory(ctx context.Context, categoryID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM z_entity WHERE category_id = $1`
+	if err := r.db.QueryRow(ctx, query, categoryID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count category entities: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteCategory deletes a category that no entity uses
+func (r *CategoryRepository) DeleteCategory(ctx context.Context, categoryID string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM z_category WHERE category_id = $1`, categoryID); err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrCategoryInUse
+		}
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	return nil
+}
+
+// MergeCategory moves every entity of the source category to the target category and
+// deletes the source. Moved entities are marked conforming until the target's schema
+// is checked against them. Returns the number of entities moved.
+func (r *CategoryRepository) MergeCategory(ctx context.Context, sourceID, targetID string) (int64, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	moveQuery := `
+		UPDATE z_entity SET
+			category_id = $2,
+			details_conforms = TRUE,
+			details_errors = NULL,
+			updated_at = $3
+		WHERE category_id = $1
+	`
+	tag, err := tx.Exec(ctx, moveQuery, sourceID, targetID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to move category entities: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM z_category WHERE category_id = $1`, sourceID); err != nil {
+		return 0, fmt.Errorf("failed to delete merged category: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ListDuplicateCategories groups categories that share a type and a name once case and
+// surrounding whitespace are ignored. The normalized name is computed here only, so the
+// grouping and the key reported for it cannot disagree. Each group's oldest category comes first.
+func (r *CategoryRepository) ListDuplicateCategories(ctx context.Context) ([]*domain.CategoryDuplicateGroup, error) {
+	query := `
+		WITH keyed AS (
+			SELECT c.*, LOWER(BTRIM(c.name)) AS normalized_name
+			FROM z_category c
+		), grouped AS (
+			SELECT k.*, COUNT(*) OVER (PARTITION BY k.type, k.normalized_name) AS group_size
+			FROM keyed k
+		)
+		SELECT g.category_id, g.name, g.type, g.details_schema, g.schema_version, g.created_at,
+		       (SELECT COUNT(*) FROM z_entity e WHERE e.category_id = g.category_id) AS entity_count,
+		       g.normalized_name
+		FROM grouped g
+		WHERE g.group_size > 1
+		ORDER BY g.type, g.normalized_name, g.created_at, g.category_id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate categories: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make([]*domain.CategoryDuplicateGroup, 0)
+	var current *domain.CategoryDuplicateGroup
+	for rows.Next() {
+		category := &domain.Category{}
+		var normalizedName string
+		if err := rows.Scan(
+			&category.ID,
+			&category.Name,
+			&category.Type,
+			&category.DetailsSchema,
+			&category.SchemaVersion,
+			&category.CreatedAt,
+			&category.EntityCount,
+			&normalizedName,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning category row: %w", err)
+		}
+		if current == nil || current.Type != category.Type || current.NormalizedName != normalizedName {
+			current = &domain.CategoryDuplicateGroup{
+				Type:           category.Type,
+				NormalizedName: normalizedName,
+			}
+			groups = append(groups, current)
+		}
+		current.Categories = append(current.Categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category rows: %w", err)
+	}
+
+	return groups, nil
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -2,50 +2,37 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"n1h41/zolaris-backend-app/internal/domain"
 )
 
-// SensorDataDBModel represents how sensor readings are stored in the database
-type SensorDataDBModel struct {
-	Timestamp   int64  `dynamodbav:"timestamp"`
-	Amperage    string `dynamodbav:"amperage"`
-	Temperature string `dynamodbav:"temperature"`
-	Humidity    string `dynamodbav:"humidity"`
-}
+// ErrDeviceDeletedByOtherUser is returned when registering a device that another user deleted
+// and that has not been purged yet. It stays theirs, with its sensor data, until then.
+var ErrDeviceDeletedByOtherUser = errors.New("device was deleted by another user and has not been purged yet")
 
 // DeviceRepository handles all device-related database operations
 type DeviceRepository struct {
-	pgPool       *pgxpool.Pool    // PostgreSQL connection pool for device data
-	dynamoClient *dynamodb.Client // DynamoDB client for sensor data
-	machineTable string           // DynamoDB table for sensor readings
+	pgPool    *pgxpool.Pool  // PostgreSQL connection pool for device data
+	telemetry TelemetryStore // Storage backend for sensor data
 }
 
 // NewDeviceRepository creates a new device repository instance
-func NewDeviceRepository(pgPool *pgxpool.Pool, dynamoClient *dynamodb.Client) *DeviceRepository {
+func NewDeviceRepository(pgPool *pgxpool.Pool, telemetry TelemetryStore) *DeviceRepository {
 	return &DeviceRepository{
-		pgPool:       pgPool,
-		dynamoClient: dynamoClient,
-		machineTable: "machine_data_table",
+		pgPool:    pgPool,
+		telemetry: telemetry,
 	}
 }
 
-// WithMachineTable sets the machine data table name for the repository
-func (r *DeviceRepository) WithMachineTable(machineTable string) *DeviceRepository {
-	r.machineTable = machineTable
-	return r
-}
-
-// AddDevice adds a new device to the PostgreSQL database
+// AddDevice adds a new device to the PostgreSQL database. Registering a device the same user
+// soft-deleted revives it. Returns ErrDeviceDeletedByOtherUser if another user soft-deleted it.
 func (r *DeviceRepository) AddDevice(ctx context.Context, deviceID, deviceName, userID string) error {
 	query := `
 		INSERT INTO z_device (
@@ -53,11 +40,13 @@ func (r *DeviceRepository) AddDevice(ctx context.Context, deviceID, deviceName,
 		) VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (mac_address) DO UPDATE SET
 			device_name = $3,
-			updated_at = $5
+			updated_at = $5,
+			deleted_at = NULL
+		WHERE z_device.deleted_at IS NULL OR z_device.user_id = EXCLUDED.user_id
 	`
 
 	now := time.Now()
-	_, err := r.pgPool.Exec(
+	result, err := r.pgPool.Exec(
 		ctx,
 		query,
 		deviceID,
@@ -69,20 +58,34 @@ func (r *DeviceRepository) AddDevice(ctx context.Context, deviceID, deviceName,
 	if err != nil {
 		return fmt.Errorf("failed to add device: %w", err)
 	}
+	if result.RowsAffected() == 0 {
+		return ErrDeviceDeletedByOtherUser
+	}
 
 	return nil
 }
 
-// GetDevicesByUserID retrieves all devices for a specific user from PostgreSQL
-func (r *DeviceRepository) GetDevicesByUserID(ctx context.Context, userID string) ([]*domain.Device, error) {
+// GetDevicesByUserID retrieves all devices for a specific user from PostgreSQL, keeping
+// only those carrying every tag in tags
+func (r *DeviceRepository) GetDevicesByUserID(ctx context.Context, userID string, tags []domain.TagFilter) ([]*domain.Device, error) {
+	args := []any{userID}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"d.user_id = $1", "d.deleted_at IS NULL"}
+	conditions = append(conditions, tagFilterConditions(arg, "z_device_tag", "mac_address", "d.mac_address", tags)...)
+
 	query := `
-		SELECT mac_address, user_id, device_name, category, description, created_at, updated_at
-		FROM z_device
-		WHERE user_id = $1
+		SELECT mac_address, user_id, device_name, category, description,
+		       voltage::float8, phase_count, power_factor::float8, created_at, updated_at
+		FROM z_device d
+		WHERE ` + strings.Join(conditions, " AND ") + `
 		ORDER BY device_name
 	`
 
-	rows, err := r.pgPool.Query(ctx, query, userID)
+	rows, err := r.pgPool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
 	}
@@ -97,6 +100,9 @@ func (r *DeviceRepository) GetDevicesByUserID(ctx context.Context, userID string
 			&device.Name,
 			&device.Category,
 			&device.Description,
+			&device.Voltage,
+			&device.PhaseCount,
+			&device.PowerFactor,
 			&device.CreatedAt,
 			&device.UpdatedAt,
 		)
@@ -114,51 +120,227 @@ func (r *DeviceRepository) GetDevicesByUserID(ctx context.Context, userID string
 	return devices, nil
 }
 
-// GetSensorData retrieves sensor data from DynamoDB for a specific device within a time range
-func (r *DeviceRepository) GetSensorData(ctx context.Context, macID string, startTime, endTime int64) ([]*domain.SensorReading, error) {
-	log.Printf("Table name: %s", r.machineTable)
+// ListAllDevices retrieves every device in the fleet from PostgreSQL
+func (r *DeviceRepository) ListAllDevices(ctx context.Context) ([]*domain.Device, error) {
+	query := `
+		SELECT mac_address, user_id, device_name, category, description,
+		       voltage::float8, phase_count, power_factor::float8, created_at, updated_at
+		FROM z_device
+		WHERE deleted_at IS NULL
+		ORDER BY mac_address
+	`
 
-	input := &dynamodb.QueryInput{
-		TableName:              aws.String(r.machineTable),
-		KeyConditionExpression: aws.String("mac_id = :macId AND #ts BETWEEN :startTime AND :endTime"),
-		ExpressionAttributeNames: map[string]string{
-			"#ts": "timestamp",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":macId":     &types.AttributeValueMemberS{Value: macID},
-			":startTime": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", startTime)},
-			":endTime":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", endTime)},
-		},
+	rows, err := r.pgPool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
 	}
+	defer rows.Close()
 
-	log.Printf("Querying sensor data for device %s from %d to %d", macID, startTime, endTime)
+	var devices []*domain.Device
+	for rows.Next() {
+		device := &domain.Device{}
+		err := rows.Scan(
+			&device.MacAddress,
+			&device.UserID,
+			&device.Name,
+			&device.Category,
+			&device.Description,
+			&device.Voltage,
+			&device.PhaseCount,
+			&device.PowerFactor,
+			&device.CreatedAt,
+			&device.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning device row: %w", err)
+		}
+
+		devices = append(devices, device)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating device rows: %w", err)
+	}
+
+	return devices, nil
+}
 
-	result, err := r.dynamoClient.Query(ctx, input)
+// GetDeviceByMac retrieves a single device by its MAC address
+func (r *DeviceRepository) GetDeviceByMac(ctx context.Context, macAddress string) (*domain.Device, error) {
+	query := `
+		SELECT mac_address, user_id, device_name, category, description,
+		       voltage::float8, phase_count, power_factor::float8, created_at, updated_at
+		FROM z_device
+		WHERE mac_address = $1 AND deleted_at IS NULL
+	`
+
+	device := &domain.Device{}
+	err := r.pgPool.QueryRow(ctx, query, macAddress).Scan(
+		&device.MacAddress,
+		&device.UserID,
+		&device.Name,
+		&device.Category,
+		&device.Description,
+		&device.Voltage,
+		&device.PhaseCount,
+		&device.PowerFactor,
+		&device.CreatedAt,
+		&device.UpdatedAt,
+	)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil // Device not found, return nil without error
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return device, nil
+}
+
+// UpdateElectricalParams updates the voltage, phase count and power factor of a device
+func (r *DeviceRepository) UpdateElectricalParams(ctx context.Context, macAddress string, voltage float64, phaseCount int, powerFactor float64) error {
+	query := `
+		UPDATE z_device SET
+			voltage = $1,
+			phase_count = $2,
+			power_factor = $3,
+			updated_at = $4
+		WHERE mac_address = $5 AND deleted_at IS NULL
+	`
+
+	result, err := r.pgPool.Exec(ctx, query, voltage, phaseCount, powerFactor, time.Now(), macAddress)
+	if err != nil {
+		return fmt.Errorf("failed to update electrical parameters: %w", err)
 	}
 
-	var dbSensorData []SensorDataDBModel
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &dbSensorData)
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("device not found with MAC address: %s", macAddress)
+	}
+
+	return nil
+}
+
+// SoftDeleteDevice hides a device until it is restored or purged, reporting false if there was no such device
+func (r *DeviceRepository) SoftDeleteDevice(ctx context.Context, macAddress string) (bool, error) {
+	query := `UPDATE z_device SET deleted_at = CURRENT_TIMESTAMP WHERE mac_address = $1 AND deleted_at IS NULL`
+
+	result, err := r.pgPool.Exec(ctx, query, macAddress)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete device: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// RestoreDevice restores a soft-deleted device. Returns false if the device does not exist,
+// ErrNotDeleted if it was never deleted and ErrRestoreBlocked if its owner is still deleted.
+func (r *DeviceRepository) RestoreDevice(ctx context.Context, macAddress string) (bool, error) {
+	var deleted, ownerDeleted bool
+	checkQuery := `
+		SELECT d.deleted_at IS NOT NULL, u.deleted_at IS NOT NULL
+		FROM z_device d
+		JOIN z_users u ON u.user_id = d.user_id
+		WHERE d.mac_address = $1
+	`
+	if err := r.pgPool.QueryRow(ctx, checkQuery, macAddress).Scan(&deleted, &ownerDeleted); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check device: %w", err)
+	}
+	if !deleted {
+		return false, ErrNotDeleted
+	}
+	if ownerDeleted {
+		return false, ErrRestoreBlocked
+	}
+
+	query := `UPDATE z_device SET deleted_at = NULL, updated_at = $2 WHERE mac_address = $1`
+	if _, err := r.pgPool.Exec(ctx, query, macAddress, time.Now()); err != nil {
+		return false, fmt.Errorf("failed to restore device: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListDeletedDevices retrieves every soft-deleted device, most recently deleted first
+func (r *DeviceRepository) ListDeletedDevices(ctx context.Context) ([]*domain.DeletedRecord, error) {
+	query := `
+		SELECT mac_address, device_name, deleted_at
+		FROM z_device
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, mac_address
+	`
+
+	return queryDeletedRecords(ctx, r.pgPool, query, domain.DeletedKindDevice)
+}
+
+// PurgeDeletedDevices permanently removes devices soft-deleted before cutoff, returning how many were removed.
+// Their sensor data is left to the telemetry store's own retention.
+func (r *DeviceRepository) PurgeDeletedDevices(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.pgPool.Exec(ctx, `DELETE FROM z_device WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted devices: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// GetSensorData retrieves sensor data for a specific device within a time range
+func (r *DeviceRepository) GetSensorData(ctx context.Context, macID string, startTime, endTime int64) ([]*domain.SensorReading, error) {
+	var readings []*domain.SensorReading
+	err := r.StreamSensorData(ctx, macID, startTime, endTime, func(page []*domain.SensorReading) error {
+		readings = append(readings, page...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to domain model
-	domainReadings := make([]*domain.SensorReading, len(dbSensorData))
-	for i, reading := range dbSensorData {
-		// Convert timestamp from milliseconds to time.Time
-		timestamp := time.UnixMilli(reading.Timestamp)
+	return readings, nil
+}
+
+// StreamSensorData pages through sensor data for a device within a time range in
+// ascending timestamp order, handing each page to fn as soon as it is read
+func (r *DeviceRepository) StreamSensorData(ctx context.Context, macID string, startTime, endTime int64, fn func([]*domain.SensorReading) error) error {
+	return r.telemetry.QueryRange(ctx, macID, startTime, endTime, fn)
+}
+
+// WriteSensorData stores sensor readings in the telemetry backend
+func (r *DeviceRepository) WriteSensorData(ctx context.Context, readings []*domain.SensorReading) error {
+	return r.telemetry.WriteReadings(ctx, readings)
+}
+
+// DeleteSensorData removes every sensor reading of a device from the telemetry backend
+func (r *DeviceRepository) DeleteSensorData(ctx context.Context, macID string) error {
+	return r.telemetry.DeleteReadings(ctx, macID)
+}
+
+// ListUserMacAddresses retrieves the MAC addresses of all the user's devices, including soft-deleted ones
+func (r *DeviceRepository) ListUserMacAddresses(ctx context.Context, userID string) ([]string, error) {
+	rows, err := r.pgPool.Query(ctx, `SELECT mac_address FROM z_device WHERE user_id = $1 ORDER BY mac_address`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user devices: %w", err)
+	}
+	defer rows.Close()
 
-		domainReadings[i] = &domain.SensorReading{
-			DeviceID:    macID,
-			Timestamp:   timestamp,
-			Amperage:    reading.Amperage,
-			Temperature: reading.Temperature,
-			Humidity:    reading.Humidity,
-			RawData:     "", // We don't have this in DB currently
+	macAddresses := make([]string, 0)
+	for rows.Next() {
+		var macAddress string
+		if err := rows.Scan(&macAddress); err != nil {
+			return nil, fmt.Errorf("failed to scan device row: %w", err)
 		}
+		macAddresses = append(macAddresses, macAddress)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating device rows: %w", err)
 	}
 
-	return domainReadings, nil
+	return macAddresses, nil
+}
+
+// AggregateSensorData summarizes sensor data for a device in fixed-width buckets
+func (r *DeviceRepository) AggregateSensorData(ctx context.Context, macID string, startTime, endTime int64, interval time.Duration) ([]*domain.SensorAggregate, error) {
+	return r.telemetry.Aggregate(ctx, macID, startTime, endTime, interval)
 }
@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+const (
+	// dynamoBatchWriteLimit is the maximum number of items in a DynamoDB BatchWriteItem call
+	dynamoBatchWriteLimit = 25
+	// dynamoBatchWriteRetries bounds how often throttled items are resubmitted
+	dynamoBatchWriteRetries = 5
+)
+
+// SensorDataDBModel represents how sensor readings are stored in the database
+type SensorDataDBModel struct {
+	MacID       string `dynamodbav:"mac_id,omitempty"`
+	Timestamp   int64  `dynamodbav:"timestamp"`
+	Amperage    string `dynamodbav:"amperage"`
+	Temperature string `dynamodbav:"temperature"`
+	Humidity    string `dynamodbav:"humidity"`
+}
+
+// DynamoTelemetryStore stores sensor readings in a DynamoDB table keyed by mac_id and timestamp
+type DynamoTelemetryStore struct {
+	client       *dynamodb.Client
+	machineTable string
+}
+
+// NewDynamoTelemetryStore creates a new DynamoDB-backed telemetry store
+func NewDynamoTelemetryStore(client *dynamodb.Client, machineTable string) *DynamoTelemetryStore {
+	if machineTable == "" {
+		machineTable = "machine_data_table"
+	}
+	return &DynamoTelemetryStore{
+		client:       client,
+		machineTable: machineTable,
+	}
+}
+
+// WriteReadings stores readings using batched writes
+func (s *DynamoTelemetryStore) WriteReadings(ctx context.Context, readings []*domain.SensorReading) error {
+	for start := 0; start < len(readings); start += dynamoBatchWriteLimit {
+		end := min(start+dynamoBatchWriteLimit, len(readings))
+
+		requests := make([]types.WriteRequest, 0, end-start)
+		for _, reading := range readings[start:end] {
+			item, err := attributevalue.MarshalMap(SensorDataDBModel{
+				MacID:       reading.DeviceID,
+				Timestamp:   reading.Timestamp.UnixMilli(),
+				Amperage:    reading.Amperage,
+				Temperature: reading.Temperature,
+				Humidity:    reading.Humidity,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to marshal sensor reading: %w", err)
+			}
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+
+		if err := s.batchWrite(ctx, requests); err != nil {
+			return fmt.Errorf("failed to write sensor readings: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteReadings pages through a device's keys and removes them using batched deletes
+func (s *DynamoTelemetryStore) DeleteReadings(ctx context.Context, macID string) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.machineTable),
+		KeyConditionExpression: aws.String("mac_id = :macId"),
+		ProjectionExpression:   aws.String("mac_id, #ts"),
+		ExpressionAttributeNames: map[string]string{
+			"#ts": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":macId": &types.AttributeValueMemberS{Value: macID},
+		},
+	}
+
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query sensor readings: %w", err)
+		}
+
+		for start := 0; start < len(result.Items); start += dynamoBatchWriteLimit {
+			end := min(start+dynamoBatchWriteLimit, len(result.Items))
+
+			requests := make([]types.WriteRequest, 0, end-start)
+			for _, key := range result.Items[start:end] {
+				requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+			}
+
+			if err := s.batchWrite(ctx, requests); err != nil {
+				return fmt.Errorf("failed to delete sensor readings: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// batchWrite submits up to dynamoBatchWriteLimit requests, resubmitting throttled items
+func (s *DynamoTelemetryStore) batchWrite(ctx context.Context, requests []types.WriteRequest) error {
+	pending := map[string][]types.WriteRequest{s.machineTable: requests}
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt == dynamoBatchWriteRetries {
+			return fmt.Errorf("items still unprocessed after %d attempts", attempt)
+		}
+		if attempt > 0 {
+			// Back off before retrying items DynamoDB throttled
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+
+		result, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+		if err != nil {
+			return err
+		}
+		pending = result.UnprocessedItems
+	}
+
+	return nil
+}
+
+// QueryRange pages through a device's readings using the table's sort key
+func (s *DynamoTelemetryStore) QueryRange(ctx context.Context, macID string, startTime, endTime int64, fn func([]*domain.SensorReading) error) error {
+	log.Printf("Table name: %s", s.machineTable)
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.machineTable),
+		KeyConditionExpression: aws.String("mac_id = :macId AND #ts BETWEEN :startTime AND :endTime"),
+		ExpressionAttributeNames: map[string]string{
+			"#ts": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":macId":     &types.AttributeValueMemberS{Value: macID},
+			":startTime": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", startTime)},
+			":endTime":   &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", endTime)},
+		},
+	}
+
+	log.Printf("Querying sensor data for device %s from %d to %d", macID, startTime, endTime)
+
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		var dbSensorData []SensorDataDBModel
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &dbSensorData); err != nil {
+			return err
+		}
+
+		if err := fn(sensorDataToDomain(macID, dbSensorData)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Aggregate computes bucket statistics while paging, since DynamoDB has no server-side aggregation
+func (s *DynamoTelemetryStore) Aggregate(ctx context.Context, macID string, startTime, endTime int64, interval time.Duration) ([]*domain.SensorAggregate, error) {
+	if interval < time.Millisecond {
+		return nil, fmt.Errorf("aggregation interval must be at least one millisecond")
+	}
+
+	accumulator := newAggregateAccumulator(interval)
+	err := s.QueryRange(ctx, macID, startTime, endTime, func(page []*domain.SensorReading) error {
+		accumulator.add(page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return accumulator.result(), nil
+}
+
+// sensorDataToDomain converts stored sensor readings to domain models
+func sensorDataToDomain(macID string, dbSensorData []SensorDataDBModel) []*domain.SensorReading {
+	domainReadings := make([]*domain.SensorReading, len(dbSensorData))
+	for i, reading := range dbSensorData {
+		// Convert timestamp from milliseconds to time.Time
+		timestamp := time.UnixMilli(reading.Timestamp)
+
+		domainReadings[i] = &domain.SensorReading{
+			DeviceID:    macID,
+			Timestamp:   timestamp,
+			Amperage:    reading.Amperage,
+			Temperature: reading.Temperature,
+			Humidity:    reading.Humidity,
+			RawData:     "", // We don't have this in DB currently
+		}
+	}
+
+	return domainReadings
+}
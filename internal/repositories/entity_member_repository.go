@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// ErrDuplicateEntityMember is returned when a user is already a member, or invited, on an entity
+var ErrDuplicateEntityMember = errors.New("user is already a member of this entity")
+
+// EntityMemberRepository handles persistence of entity memberships
+type EntityMemberRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewEntityMemberRepository creates a new entity member repository instance
+func NewEntityMemberRepository(dbPool *pgxpool.Pool) *EntityMemberRepository {
+	return &EntityMemberRepository{
+		db: dbPool,
+	}
+}
+
+// entityMemberColumns are the columns scanned by scanEntityMember
+const entityMemberColumns = `
+	m.entity_id, e.name, m.user_id, u.email, u.first_name, u.last_name,
+	m.role, m.status, m.invited_by, m.created_at, m.accepted_at
+`
+
+// entityMemberJoins joins a membership "m" with its entity and user
+const entityMemberJoins = `
+	FROM z_entity_member m
+	JOIN z_entity e ON e.entity_id = m.entity_id
+	JOIN z_users u ON u.user_id = m.user_id
+`
+
+// CreateInvitation records a pending membership of userId on entityId
+func (r *EntityMemberRepository) CreateInvitation(ctx context.Context, entityId, userId string, role domain.EntityRole, invitedBy string) error {
+	query := `
+		INSERT INTO z_entity_member (entity_id, user_id, role, status, invited_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	if _, err := r.db.Exec(ctx, query, entityId, userId, role, domain.EntityMemberPending, invitedBy); err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateEntityMember
+		}
+		return fmt.Errorf("failed to create entity invitation: %w", err)
+	}
+
+	return nil
+}
+
+// GetMember retrieves the membership of userId on entityId, returning nil if there is none
+func (r *EntityMemberRepository) GetMember(ctx context.Context, entityId, userId string) (*domain.EntityMember, error) {
+	query := `SELECT ` + entityMemberColumns + entityMemberJoins + `WHERE m.entity_id = $1 AND m.user_id = $2`
+
+	member, err := scanEntityMember(r.db.QueryRow(ctx, query, entityId, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get entity member: %w", err)
+	}
+
+	return member, nil
+}
+
+// AcceptInvitation activates a pending membership, reporting false if there was none
+func (r *EntityMemberRepository) AcceptInvitation(ctx context.Context, entityId, userId string) (bool, error) {
+	query := `
+		UPDATE z_entity_member
+		SET status = $3, accepted_at = CURRENT_TIMESTAMP
+		WHERE entity_id = $1 AND user_id = $2 AND status = $4
+	`
+
+	tag, err := r.db.Exec(ctx, query, entityId, userId, domain.EntityMemberActive, domain.EntityMemberPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept entity invitation: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteMember removes a membership or invitation, reporting false if there was none
+func (r *EntityMemberRepository) DeleteMember(ctx context.Context, entityId, userId string) (bool, error) {
+	query := `DELETE FROM z_entity_member WHERE entity_id = $1 AND user_id = $2`
+
+	tag, err := r.db.Exec(ctx, query, entityId, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete entity member: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListEntityMembers retrieves the memberships and pending invitations granted directly on an entity
+func (r *EntityMemberRepository) ListEntityMembers(ctx context.Context, entityId string) ([]*domain.EntityMember, error) {
+	query := `SELECT ` + entityMemberColumns + entityMemberJoins + `WHERE m.entity_id = $1 ORDER BY m.created_at, u.email`
+
+	return r.queryEntityMembers(ctx, query, entityId)
+}
+
+// ListPendingInvitations retrieves the invitations a user has not accepted yet
+func (r *EntityMemberRepository) ListPendingInvitations(ctx context.Context, userId string) ([]*domain.EntityMember, error) {
+	query := `SELECT ` + entityMemberColumns + entityMemberJoins + `WHERE m.user_id = $1 AND m.status = $2 AND e.deleted_at IS NULL ORDER BY m.created_at`
+
+	return r.queryEntityMembers(ctx, query, userId, domain.EntityMemberPending)
+}
+
+func (r *EntityMemberRepository) queryEntityMembers(ctx context.Context, query string, args ...any) ([]*domain.EntityMember, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]*domain.EntityMember, 0)
+	for rows.Next() {
+		member, err := scanEntityMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan entity member row: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entity member rows: %w", err)
+	}
+
+	return members, nil
+}
+
+// scanEntityMember scans a row selected with entityMemberColumns
+func scanEntityMember(row pgx.Row) (*domain.EntityMember, error) {
+	member := new(domain.EntityMember)
+	err := row.Scan(
+		&member.EntityID,
+		&member.EntityName,
+		&member.UserID,
+		&member.Email,
+		&member.FirstName,
+		&member.LastName,
+		&member.Role,
+		&member.Status,
+		&member.InvitedBy,
+		&member.CreatedAt,
+		&member.AcceptedAt,
+	)
+	return member, err
+}
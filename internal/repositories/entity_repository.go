@@ -3,25 +3,48 @@ package repositories
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"n1h41/zolaris-backend-app/internal/audit"
 	"n1h41/zolaris-backend-app/internal/domain"
 )
 
 type CategoryType string
 
+// UserCategoryType is the system category type of entities that represent users.
+// Other category types are managed in z_category_type.
+const UserCategoryType CategoryType = "user"
+
+// EntityDeleteMode controls what happens to an entity's descendants when it is deleted
+type EntityDeleteMode string
+
 const (
-	UserCategoryType     CategoryType = "user"
-	OfficeCategoryType   CategoryType = "office"
-	LocationCategoryType CategoryType = "location"
+	// DeleteModeRestrict refuses to delete an entity that has children
+	DeleteModeRestrict EntityDeleteMode = "restrict"
+	// DeleteModeCascade deletes the entity and its whole subtree
+	DeleteModeCascade EntityDeleteMode = "cascade"
+	// DeleteModeReparent moves the entity's children to its parent before deleting it
+	DeleteModeReparent EntityDeleteMode = "reparent"
+)
+
+var (
+	// ErrNotDeleted is returned when restoring a record that is not deleted
+	ErrNotDeleted = errors.New("record is not deleted")
+	// ErrRestoreBlocked is returned when restoring a record whose parent or owner is still deleted
+	ErrRestoreBlocked = errors.New("record's parent or owner is still deleted")
 )
 
 type EntityRepository struct {
 	db *pgxpool.Pool
+	// geography is set when z_entity has the PostGIS geography column, see DetectGeography
+	geography bool
 }
 
 func NewEntityRepository(dbPool *pgxpool.Pool) EntityRepository {
@@ -32,7 +55,7 @@ func NewEntityRepository(dbPool *pgxpool.Pool) EntityRepository {
 
 func (r *EntityRepository) CheckEntityPresence(ctx context.Context, userId string) (bool, error) {
 	var exists bool
-	query := `select exists(select 1 from z_entity where user_id = $1)`
+	query := `select exists(select 1 from z_entity where user_id = $1 and deleted_at is null)`
 
 	if err := r.db.QueryRow(ctx, query, userId).Scan(&exists); err != nil {
 		return false, fmt.Errorf("failed to check entity presence: %w", err)
@@ -61,98 +84,1007 @@ func (r *EntityRepository) CreateRootEntity(ctx context.Context, categoryId stri
 		return "", err
 	}
 
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var entityId string
+
+	if categoryType == "" {
+		return "", fmt.Errorf("category with ID %s not found", categoryId)
+	}
+
+	if categoryType == UserCategoryType {
+		if userId == "" {
+			return "", fmt.Errorf("user ID is required for user category entities")
+		}
+
+		query := `insert into z_entity (category_id, name, user_id) values ($1, $2, $3) returning entity_id`
+
+		err = tx.QueryRow(ctx, query, categoryId, entityName, userId).Scan(&entityId)
+	} else {
+		detailsJSON, jsonErr := json.Marshal(details)
+		if jsonErr != nil {
+			return "", fmt.Errorf("failed to marshal details: %w", jsonErr)
+		}
+
+		query := `insert into z_entity (category_id, name, details) values ($1, $2, $3) returning entity_id`
+		err = tx.QueryRow(ctx, query, categoryId, entityName, detailsJSON).Scan(&entityId)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create root entity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return entityId, nil
+}
+
+func (r *EntityRepository) CreateSubEntity(ctx context.Context, categoryId string, entityName string, userId string, details map[string]any, parentEntityId string) (string, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	if parentEntityId == "" {
+		getParentEntityIDQuery := `select entity_id from z_entity where user_id = $1 and deleted_at is null limit 1`
+		err = tx.QueryRow(ctx, getParentEntityIDQuery, userId).Scan(&parentEntityId)
+		if err != nil {
+			return "", fmt.Errorf("failed to check parent entity: %w", err)
+		}
+	}
+
+	categoryType, err := r.GetCategoryType(ctx, categoryId)
+	if err != nil {
+		return "", err
+	}
+
+	if categoryType == UserCategoryType && userId == "" {
+		return "", fmt.Errorf("user ID is requried for user category entities")
+	}
+
+	var entityId string
+
+	if userId != "" {
+		var userHasEntity bool
+		checkUserQuery := `select exists(select 1 from z_entity where user_id = $1 and deleted_at is null)`
+		if err := tx.QueryRow(ctx, checkUserQuery, userId).Scan(&userHasEntity); err != nil {
+			return "", fmt.Errorf("failed to check user entities: %w", err)
+		}
+
+		if !userHasEntity {
+			return "", fmt.Errorf("user with ID %s does not have any existing entities", userId)
+		}
+
+		if categoryType == UserCategoryType {
+			query := `INSERT INTO z_entity (category_id, parent_id, name, user_id) VALUES ($1, $2, $3, $4) RETURNING entity_id`
+			err = tx.QueryRow(ctx, query, categoryId, parentEntityId, entityName, userId).Scan(&entityId)
+		} else {
+			detailsJSON, jsonErr := json.Marshal(details)
+			if jsonErr != nil {
+				return "", fmt.Errorf("failed to marshal details: %w", jsonErr)
+			}
+
+			query := `INSERT INTO z_entity (category_id, parent_id, name, details) VALUES ($1, $2, $3, $4) RETURNING entity_id`
+
+			err = tx.QueryRow(ctx, query, categoryId, parentEntityId, entityName, detailsJSON).Scan(&entityId)
+		}
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create sub-entity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return entityId, nil
+}
+
+// CreateEntityTree creates root and its descendants beneath parentEntityId in one transaction.
+// Rows are inserted a level at a time, so each parent's path exists when its children's
+// paths are computed. Returns the ID of the new root and the number of entities created.
+func (r *EntityRepository) CreateEntityTree(ctx context.Context, parentEntityId string, root *domain.EntityDraft) (string, int, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Keep the parent from being deleted or moved while its new subtree is inserted
+	var exists bool
+	lockQuery := `SELECT TRUE FROM z_entity WHERE entity_id = $1 AND deleted_at IS NULL FOR SHARE`
+	if err := tx.QueryRow(ctx, lockQuery, parentEntityId).Scan(&exists); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", 0, fmt.Errorf("entity with ID %s not found", parentEntityId)
+		}
+		return "", 0, fmt.Errorf("failed to lock parent entity: %w", err)
+	}
+
+	type pending struct {
+		draft    *domain.EntityDraft
+		parentId string
+	}
+
+	query := `INSERT INTO z_entity (category_id, parent_id, name, details) VALUES ($1, $2, $3, $4) RETURNING entity_id`
+
+	var rootId string
+	created := 0
+	level := []pending{{draft: root, parentId: parentEntityId}}
+	for len(level) > 0 {
+		batch := &pgx.Batch{}
+		for _, item := range level {
+			details := item.draft.Details
+			if details == nil {
+				details = make(map[string]any)
+			}
+			detailsJSON, err := json.Marshal(details)
+			if err != nil {
+				return "", 0, fmt.Errorf("failed to marshal details: %w", err)
+			}
+			batch.Queue(query, item.draft.CategoryID, item.parentId, item.draft.Name, detailsJSON)
+		}
+
+		results := tx.SendBatch(ctx, batch)
+		var next []pending
+		for _, item := range level {
+			var entityId string
+			if err := results.QueryRow().Scan(&entityId); err != nil {
+				results.Close()
+				return "", 0, fmt.Errorf("failed to create entity %q: %w", item.draft.Name, err)
+			}
+			if rootId == "" {
+				rootId = entityId
+			}
+			for _, child := range item.draft.Children {
+				next = append(next, pending{draft: child, parentId: entityId})
+			}
+		}
+		if err := results.Close(); err != nil {
+			return "", 0, fmt.Errorf("failed to create entities: %w", err)
+		}
+
+		created += len(level)
+		level = next
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return rootId, created, nil
+}
+
+// GetEntityByID retrieves a single entity, returning nil if it does not exist
+func (r *EntityRepository) GetEntityByID(ctx context.Context, entityId string) (*domain.Entity, error) {
+	query := `
+		SELECT entity_id, user_id, name, details, category_id,
+		       parent_id, path::text, depth, created_at, updated_at
+		FROM z_entity
+		WHERE entity_id = $1 AND deleted_at IS NULL
+	`
+
+	entity := new(domain.Entity)
+	err := r.db.QueryRow(ctx, query, entityId).Scan(
+		&entity.ID,
+		&entity.UserID,
+		&entity.Name,
+		&entity.Details,
+		&entity.CategoryID,
+		&entity.ParentID,
+		&entity.Path,
+		&entity.Depth,
+		&entity.CreatedAt,
+		&entity.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get entity: %w", err)
+	}
+
+	return entity, nil
+}
+
+// UpdateEntity updates an entity's name and details. Nil arguments leave the field unchanged.
+func (r *EntityRepository) UpdateEntity(ctx context.Context, entityId string, name *string, details map[string]any) error {
+	var detailsJSON []byte
+	if details != nil {
+		var err error
+		detailsJSON, err = json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("failed to marshal details: %w", err)
+		}
+	}
+
+	// New details have already been validated, so any earlier non-conformance is cleared
+	query := `
+		UPDATE z_entity SET
+			name = COALESCE($2, name),
+			details = COALESCE($3::jsonb, details),
+			details_conforms = CASE WHEN $3::jsonb IS NULL THEN details_conforms ELSE TRUE END,
+			details_errors = CASE WHEN $3::jsonb IS NULL THEN details_errors ELSE NULL END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE entity_id = $1 AND deleted_at IS NULL
+	`
+
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, query, entityId, name, detailsJSON); err != nil {
+		return fmt.Errorf("failed to update entity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// MoveEntity re-parents an entity and rewrites the path and depth of its whole subtree in one transaction
+func (r *EntityRepository) MoveEntity(ctx context.Context, entityId string, newParentId *string) error {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := reparentEntity(ctx, tx, entityId, newParentId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteEntity soft-deletes an entity, handling its descendants according to mode. Rows deleted
+// together share a deleted_at timestamp, so they can be restored together. Returns false
+// without deleting anything if mode is restrict and the entity has children.
+func (r *EntityRepository) DeleteEntity(ctx context.Context, entityId string, mode EntityDeleteMode) (bool, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		path     string
+		parentId *string
+	)
+	lockQuery := `SELECT path::text, parent_id FROM z_entity WHERE entity_id = $1 AND deleted_at IS NULL FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQuery, entityId).Scan(&path, &parentId); err != nil {
+		return false, fmt.Errorf("failed to lock entity: %w", err)
+	}
+
+	// CURRENT_TIMESTAMP is fixed for the transaction, so every row gets the same deleted_at
+	softDeleteQuery := `UPDATE z_entity SET deleted_at = CURRENT_TIMESTAMP WHERE entity_id = $1`
+
+	switch mode {
+	case DeleteModeCascade:
+		cascadeQuery := `UPDATE z_entity SET deleted_at = CURRENT_TIMESTAMP WHERE path <@ $1::ltree AND deleted_at IS NULL`
+		if _, err := tx.Exec(ctx, cascadeQuery, path); err != nil {
+			return false, fmt.Errorf("failed to delete entity subtree: %w", err)
+		}
+
+	case DeleteModeReparent:
+		// Children deleted earlier stay under the entity, to be restored or purged with it
+		rows, err := tx.Query(ctx, `SELECT entity_id FROM z_entity WHERE parent_id = $1 AND deleted_at IS NULL`, entityId)
+		if err != nil {
+			return false, fmt.Errorf("failed to query child entities: %w", err)
+		}
+		childIds, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return false, fmt.Errorf("failed to scan child entities: %w", err)
+		}
+
+		for _, childId := range childIds {
+			if err := reparentEntity(ctx, tx, childId, parentId); err != nil {
+				return false, err
+			}
+		}
+
+		if _, err := tx.Exec(ctx, softDeleteQuery, entityId); err != nil {
+			return false, fmt.Errorf("failed to delete entity: %w", err)
+		}
+
+	default:
+		var hasChildren bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM z_entity WHERE parent_id = $1 AND deleted_at IS NULL)`, entityId).Scan(&hasChildren); err != nil {
+			return false, fmt.Errorf("failed to check child entities: %w", err)
+		}
+		if hasChildren {
+			return false, nil
+		}
+
+		if _, err := tx.Exec(ctx, softDeleteQuery, entityId); err != nil {
+			return false, fmt.Errorf("failed to delete entity: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// RestoreEntity restores a soft-deleted entity along with the descendants deleted with it.
+// Returns false if the entity does not exist, ErrNotDeleted if it was never deleted and
+// ErrRestoreBlocked if its parent or owning user is still deleted.
+func (r *EntityRepository) RestoreEntity(ctx context.Context, entityId string) (bool, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		path      string
+		deletedAt *time.Time
+		blocked   bool
+	)
+	lockQuery := `
+		SELECT e.path::text, e.deleted_at,
+			COALESCE(p.deleted_at IS NOT NULL, FALSE) OR COALESCE(u.deleted_at IS NOT NULL, FALSE)
+		FROM z_entity e
+		LEFT JOIN z_entity p ON p.entity_id = e.parent_id
+		LEFT JOIN z_users u ON u.user_id = e.user_id
+		WHERE e.entity_id = $1
+		FOR UPDATE OF e
+	`
+	if err := tx.QueryRow(ctx, lockQuery, entityId).Scan(&path, &deletedAt, &blocked); err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to lock entity: %w", err)
+	}
+	if deletedAt == nil {
+		return false, ErrNotDeleted
+	}
+	if blocked {
+		return false, ErrRestoreBlocked
+	}
+
+	restoreQuery := `UPDATE z_entity SET deleted_at = NULL WHERE path <@ $1::ltree AND deleted_at = $2`
+	if _, err := tx.Exec(ctx, restoreQuery, path, *deletedAt); err != nil {
+		return false, fmt.Errorf("failed to restore entity: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListDeletedEntities retrieves every soft-deleted entity, most recently deleted first
+func (r *EntityRepository) ListDeletedEntities(ctx context.Context) ([]*domain.DeletedRecord, error) {
+	query := `
+		SELECT entity_id::text, name, deleted_at
+		FROM z_entity
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, path
+	`
+
+	return queryDeletedRecords(ctx, r.db, query, domain.DeletedKindEntity)
+}
+
+// PurgeDeletedEntities permanently removes entities soft-deleted before cutoff, returning how many were removed
+func (r *EntityRepository) PurgeDeletedEntities(ctx context.Context, cutoff time.Time) (int64, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// All expired rows go in one statement, so the parent foreign key is satisfied when it is checked
+	tag, err := tx.Exec(ctx, `DELETE FROM z_entity WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted entities: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// queryDeletedRecords runs a query selecting the ID, name and deletion time of soft-deleted rows of kind
+func queryDeletedRecords(ctx context.Context, db *pgxpool.Pool, query string, kind domain.DeletedKind, args ...any) ([]*domain.DeletedRecord, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted %ss: %w", kind, err)
+	}
+	defer rows.Close()
+
+	records := make([]*domain.DeletedRecord, 0)
+	for rows.Next() {
+		record := &domain.DeletedRecord{Kind: kind}
+		if err := rows.Scan(&record.ID, &record.Name, &record.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted %s row: %w", kind, err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted %s rows: %w", kind, err)
+	}
+
+	return records, nil
+}
+
+// beginAudited starts a transaction whose entity changes are recorded in the entity
+// history as made by the actor in ctx
+func beginAudited(ctx context.Context, db *pgxpool.Pool) (pgx.Tx, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	if actor := audit.ActorFrom(ctx); actor != "" {
+		if _, err := tx.Exec(ctx, `SELECT set_config('zolaris.actor_id', $1, true)`, actor); err != nil {
+			tx.Rollback(ctx)
+			return nil, fmt.Errorf("failed to set audit actor: %w", err)
+		}
+	}
+
+	return tx, nil
+}
+
+// reparentEntity changes an entity's parent within tx. The entity_path_update trigger only
+// rewrites the moved row, so the descendants' paths are rebased onto its new path here.
+func reparentEntity(ctx context.Context, tx pgx.Tx, entityId string, newParentId *string) error {
+	var oldPath string
+	lockQuery := `SELECT path::text FROM z_entity WHERE entity_id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQuery, entityId).Scan(&oldPath); err != nil {
+		return fmt.Errorf("failed to lock entity: %w", err)
+	}
+
+	var newPath string
+	moveQuery := `
+		UPDATE z_entity SET parent_id = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE entity_id = $1
+		RETURNING path::text
+	`
+	if err := tx.QueryRow(ctx, moveQuery, entityId, newParentId).Scan(&newPath); err != nil {
+		return fmt.Errorf("failed to move entity: %w", err)
+	}
+
+	rebaseQuery := `
+		UPDATE z_entity SET
+			path = $2::ltree || subpath(path, nlevel($1::ltree)),
+			depth = nlevel($2::ltree) + nlevel(path) - nlevel($1::ltree),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE path <@ $1::ltree AND entity_id != $3
+	`
+	if _, err := tx.Exec(ctx, rebaseQuery, oldPath, newPath, entityId); err != nil {
+		return fmt.Errorf("failed to rewrite subtree paths: %w", err)
+	}
+
+	return nil
+}
+
+// GetCategorySchema retrieves the details schema of a category, or nil if it has none
+func (r *EntityRepository) GetCategorySchema(ctx context.Context, categoryId string) (json.RawMessage, error) {
+	var detailsSchema json.RawMessage
+	query := `SELECT details_schema FROM z_category WHERE category_id = $1`
+	if err := r.db.QueryRow(ctx, query, categoryId).Scan(&detailsSchema); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("category with ID %s not found", categoryId)
+		}
+		return nil, fmt.Errorf("failed to get category schema: %w", err)
+	}
+
+	return detailsSchema, nil
+}
+
+// ListEntitiesByCategory retrieves the ID, name and details of every entity in a category
+func (r *EntityRepository) ListEntitiesByCategory(ctx context.Context, categoryId string) ([]*domain.Entity, error) {
+	query := `SELECT entity_id, name, details FROM z_entity WHERE category_id = $1 AND deleted_at IS NULL ORDER BY name`
+
+	rows, err := r.db.Query(ctx, query, categoryId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category entities: %w", err)
+	}
+	defer rows.Close()
+
+	entities := make([]*domain.Entity, 0)
+	for rows.Next() {
+		entity := new(domain.Entity)
+		if err := rows.Scan(&entity.ID, &entity.Name, &entity.Details); err != nil {
+			return nil, fmt.Errorf("failed to scan entity row: %w", err)
+		}
+		entities = append(entities, entity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entity rows: %w", err)
+	}
+
+	return entities, nil
+}
+
+// SetDetailsConformance records schema check results. Entities with no errors are marked conforming.
+func (r *EntityRepository) SetDetailsConformance(ctx context.Context, results []*domain.EntityConformance) error {
+	query := `
+		UPDATE z_entity SET
+			details_conforms = $2::jsonb IS NULL,
+			details_errors = $2::jsonb
+		WHERE entity_id = $1
+	`
+
+	batch := &pgx.Batch{}
+	for _, result := range results {
+		var detailsErrors []byte
+		if len(result.Errors) > 0 {
+			detailsErrors = result.Errors
+		}
+		batch.Queue(query, result.EntityID, detailsErrors)
+	}
+
+	if err := r.db.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to record details conformance: %w", err)
+	}
+
+	return nil
+}
+
+// ListNonConformingEntities retrieves entities in a category whose details do not match its schema
+func (r *EntityRepository) ListNonConformingEntities(ctx context.Context, categoryId string) ([]*domain.EntityConformance, error) {
+	query := `
+		SELECT entity_id, name, details_errors
+		FROM z_entity
+		WHERE category_id = $1 AND NOT details_conforms AND deleted_at IS NULL
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(ctx, query, categoryId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query non-conforming entities: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*domain.EntityConformance, 0)
+	for rows.Next() {
+		result := new(domain.EntityConformance)
+		if err := rows.Scan(&result.EntityID, &result.Name, &result.Errors); err != nil {
+			return nil, fmt.Errorf("failed to scan entity row: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entity rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// entityPlacementQuery selects entities with their own and their parent's category types
+const entityPlacementQuery = `
+	SELECT e.entity_id, e.name, c.type::text, e.parent_id, pc.type::text, e.depth
+	FROM z_entity e
+	JOIN z_category c ON c.category_id = e.category_id
+	LEFT JOIN z_entity p ON p.entity_id = e.parent_id
+	LEFT JOIN z_category pc ON pc.category_id = p.category_id
+	WHERE e.deleted_at IS NULL
+`
+
+// GetEntityPlacement retrieves an entity's position in the tree, returning nil if it does not exist
+func (r *EntityRepository) GetEntityPlacement(ctx context.Context, entityId string) (*domain.EntityPlacement, error) {
+	placement := new(domain.EntityPlacement)
+	err := r.db.QueryRow(ctx, entityPlacementQuery+` AND e.entity_id = $1`, entityId).Scan(
+		&placement.EntityID,
+		&placement.Name,
+		&placement.CategoryType,
+		&placement.ParentID,
+		&placement.ParentCategoryType,
+		&placement.Depth,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get entity placement: %w", err)
+	}
+
+	return placement, nil
+}
+
+// ListEntityPlacements retrieves the position of every entity in the tree, ordered by path
+func (r *EntityRepository) ListEntityPlacements(ctx context.Context) ([]*domain.EntityPlacement, error) {
+	rows, err := r.db.Query(ctx, entityPlacementQuery+` ORDER BY e.path`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity placements: %w", err)
+	}
+	defer rows.Close()
+
+	placements := make([]*domain.EntityPlacement, 0)
+	for rows.Next() {
+		placement := new(domain.EntityPlacement)
+		if err := rows.Scan(
+			&placement.EntityID,
+			&placement.Name,
+			&placement.CategoryType,
+			&placement.ParentID,
+			&placement.ParentCategoryType,
+			&placement.Depth,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan entity placement row: %w", err)
+		}
+		placements = append(placements, placement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entity placement rows: %w", err)
+	}
+
+	return placements, nil
+}
+
+// GetUserEntityID retrieves the ID of the user's own entity, or an empty string if there is none
+func (r *EntityRepository) GetUserEntityID(ctx context.Context, userId string) (string, error) {
 	var entityId string
+	query := `SELECT entity_id FROM z_entity WHERE user_id = $1 AND deleted_at IS NULL`
+	if err := r.db.QueryRow(ctx, query, userId).Scan(&entityId); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get user entity: %w", err)
+	}
+
+	return entityId, nil
+}
+
+// GetSubtreeUserIDs returns the users owning a live user entity in the subtree rooted at entityId,
+// the root included
+func (r *EntityRepository) GetSubtreeUserIDs(ctx context.Context, entityId string) ([]string, error) {
+	query := `
+		SELECT DISTINCT e.user_id::text
+		FROM z_entity root
+		JOIN z_entity e ON e.path <@ root.path
+		WHERE root.entity_id = $1 AND e.user_id IS NOT NULL AND e.deleted_at IS NULL
+	`
+
+	rows, err := r.db.Query(ctx, query, entityId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subtree users: %w", err)
+	}
+	defer rows.Close()
+
+	userIds := make([]string, 0)
+	for rows.Next() {
+		var userId string
+		if err := rows.Scan(&userId); err != nil {
+			return nil, fmt.Errorf("failed to scan subtree user row: %w", err)
+		}
+		userIds = append(userIds, userId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subtree user rows: %w", err)
+	}
+
+	return userIds, nil
+}
+
+// GetSubtreeMaxDepths returns the deepest level reached by each category type in the subtree rooted at entityId
+func (r *EntityRepository) GetSubtreeMaxDepths(ctx context.Context, entityId string) (map[string]int, error) {
+	query := `
+		SELECT c.type::text, MAX(e.depth)
+		FROM z_entity root
+		JOIN z_entity e ON e.path <@ root.path
+		JOIN z_category c ON c.category_id = e.category_id
+		WHERE root.entity_id = $1 AND e.deleted_at IS NULL
+		GROUP BY c.type
+	`
+
+	rows, err := r.db.Query(ctx, query, entityId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subtree depths: %w", err)
+	}
+	defer rows.Close()
+
+	maxDepths := make(map[string]int)
+	for rows.Next() {
+		var (
+			categoryType string
+			depth        int
+		)
+		if err := rows.Scan(&categoryType, &depth); err != nil {
+			return nil, fmt.Errorf("failed to scan subtree depth row: %w", err)
+		}
+		maxDepths[categoryType] = depth
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subtree depth rows: %w", err)
+	}
+
+	return maxDepths, nil
+}
+
+// entitySearchSorts maps sort keys to their column and the type their cursor value is cast to
+var entitySearchSorts = map[string]struct{ column, cast string }{
+	"name":      {"e.name", "text"},
+	"createdAt": {"e.created_at", "timestamptz"},
+	"updatedAt": {"e.updated_at", "timestamptz"},
+	"depth":     {"e.depth", "integer"},
+}
+
+// likeEscaper escapes LIKE wildcards so search text matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchEntities finds entities the user can reach matching filter. It returns one page
+// of results with their category, and the total number of matches ignoring pagination.
+func (r *EntityRepository) SearchEntities(ctx context.Context, userId string, filter domain.EntitySearchFilter) ([]*domain.EntityNode, int64, error) {
+	sort, ok := entitySearchSorts[filter.SortBy]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported sort %q", filter.SortBy)
+	}
 
-	if categoryType == "" {
-		return "", fmt.Errorf("category with ID %s not found", categoryId)
+	args := []any{userId}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
 	}
 
-	if categoryType == UserCategoryType {
-		if userId == "" {
-			return "", fmt.Errorf("user ID is required for user category entities")
+	conditions := []string{"e.deleted_at IS NULL", entityAccessibleSQL}
+	if filter.Name != "" {
+		switch filter.NameMatch {
+		case "contains":
+			conditions = append(conditions, "e.name ILIKE '%' || "+arg(likeEscaper.Replace(filter.Name))+" || '%'")
+		case "fuzzy":
+			conditions = append(conditions, "e.name % "+arg(filter.Name))
+		default:
+			conditions = append(conditions, "lower(e.name) LIKE lower("+arg(likeEscaper.Replace(filter.Name))+") || '%'")
 		}
-
-		query := `insert into z_entity (category_id, name, user_id) values ($1, $2, $3) returning entity_id`
-
-		err = r.db.QueryRow(ctx, query, categoryId, entityName, userId).Scan(&entityId)
-	} else {
-		detailsJSON, jsonErr := json.Marshal(details)
-		if jsonErr != nil {
-			return "", fmt.Errorf("failed to marshal details: %w", jsonErr)
+	}
+	if filter.CategoryType != "" {
+		conditions = append(conditions, "c.type = "+arg(filter.CategoryType))
+	}
+	if filter.CategoryID != "" {
+		conditions = append(conditions, "e.category_id = "+arg(filter.CategoryID))
+	}
+	if filter.UnderEntityID != "" {
+		under := arg(filter.UnderEntityID)
+		conditions = append(conditions, fmt.Sprintf(
+			"e.path <@ (SELECT path FROM z_entity WHERE entity_id = %s) AND e.entity_id <> %s", under, under))
+	}
+	if len(filter.Details) > 0 {
+		detailsJSON, err := json.Marshal(filter.Details)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to marshal details filter: %w", err)
 		}
+		conditions = append(conditions, "e.details @> "+arg(detailsJSON)+"::jsonb")
+	}
+	conditions = append(conditions, tagFilterConditions(arg, "z_entity_tag", "entity_id", "e.entity_id", filter.Tags)...)
 
-		query := `insert into z_entity (category_id, name, details) values ($1, $2, $3) returning entity_id`
-		err = r.db.QueryRow(ctx, query, categoryId, entityName, detailsJSON).Scan(&entityId)
+	from := `
+		FROM z_entity e
+		JOIN z_category c ON c.category_id = e.category_id
+		WHERE ` + strings.Join(conditions, " AND ")
+
+	var total int64
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count entity search results: %w", err)
 	}
 
-	if err != nil {
-		return "", fmt.Errorf("failed to create root entity: %w", err)
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
 	}
 
-	return entityId, nil
-}
+	query := `
+		SELECT
+			e.entity_id, e.user_id, e.name, e.details, e.category_id,
+			c.name, c.type, e.parent_id, e.path::text, e.depth,
+			e.created_at, e.updated_at` + from
+	if filter.AfterID != "" {
+		query += fmt.Sprintf(" AND (%s, e.entity_id) %s (%s::%s, %s::uuid)",
+			sort.column, comparison, arg(filter.AfterValue), sort.cast, arg(filter.AfterID))
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, e.entity_id %s LIMIT %s", sort.column, direction, direction, arg(filter.Limit))
+	if filter.AfterID == "" && filter.Offset > 0 {
+		query += " OFFSET " + arg(filter.Offset)
+	}
 
-func (r *EntityRepository) CreateSubEntity(ctx context.Context, categoryId string, entityName string, userId string, details map[string]any, parentEntityId string) (string, error) {
-	tx, err := r.db.Begin(ctx)
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		return "", fmt.Errorf("failed to start transaction: %w", err)
+		return nil, 0, fmt.Errorf("failed to search entities: %w", err)
 	}
-	defer tx.Rollback(ctx)
+	defer rows.Close()
 
-	if parentEntityId == "" {
-		getParentEntityIDQuery := `select entity_id from z_entity where user_id = $1 limit 1`
-		err = tx.QueryRow(ctx, getParentEntityIDQuery, userId).Scan(&parentEntityId)
-		if err != nil {
-			return "", fmt.Errorf("failed to check parent entity: %w", err)
+	nodes := make([]*domain.EntityNode, 0)
+	for rows.Next() {
+		node := new(domain.EntityNode)
+		if err := rows.Scan(
+			&node.ID,
+			&node.UserID,
+			&node.Name,
+			&node.Details,
+			&node.CategoryID,
+			&node.CategoryName,
+			&node.CategoryType,
+			&node.ParentID,
+			&node.Path,
+			&node.Depth,
+			&node.CreatedAt,
+			&node.UpdatedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan entity row: %w", err)
 		}
+		nodes = append(nodes, node)
 	}
 
-	categoryType, err := r.GetCategoryType(ctx, categoryId)
-	if err != nil {
-		return "", err
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating entity rows: %w", err)
 	}
 
-	if categoryType == UserCategoryType && userId == "" {
-		return "", fmt.Errorf("user ID is requried for user category entities")
-	}
+	return nodes, total, nil
+}
 
-	var entityId string
+// entityGrantsSQL selects the entities user $1 holds a role on: their own user entity, which
+// they own, the entity of every accepted membership, and the user entities of the accounts
+// below them in the parent chain, which they can view. Roles apply to the whole subtree.
+// Memberships on deleted entities are kept for a restore, so callers skip deleted grants.
+const entityGrantsSQL = `
+	SELECT entity_id, 'owner' AS role FROM z_entity WHERE user_id = $1 AND deleted_at IS NULL
+	UNION ALL
+	SELECT entity_id, role FROM z_entity_member WHERE user_id = $1 AND status = 'active'
+	UNION ALL
+	SELECT entity_id, 'viewer' FROM z_entity
+	WHERE deleted_at IS NULL AND user_id IN (
+		WITH RECURSIVE descendants AS (
+			SELECT user_id FROM z_users WHERE parent_id = $1 AND deleted_at IS NULL
+			UNION
+			SELECT u.user_id
+			FROM z_users u
+			JOIN descendants d ON u.parent_id = d.user_id
+			WHERE u.deleted_at IS NULL
+		)
+		SELECT user_id FROM descendants
+	)
+`
+
+// entityAccessibleSQL restricts entity "e" to those user $1 can reach through any grant
+const entityAccessibleSQL = `EXISTS (
+	SELECT 1
+	FROM (` + entityGrantsSQL + `) g
+	JOIN z_entity granted ON granted.entity_id = g.entity_id
+	WHERE e.path <@ granted.path AND granted.deleted_at IS NULL
+)`
+
+// entityRoleOrderSQL orders grants from most to least privileged role
+const entityRoleOrderSQL = `array_position(ARRAY['owner', 'admin', 'viewer'], g.role)`
+
+// GetEntityRole returns the user's effective role on an entity, the strongest role granted
+// on the entity or any of its ancestors, or an empty role if the user cannot reach it
+func (r *EntityRepository) GetEntityRole(ctx context.Context, userId string, entityId string) (domain.EntityRole, error) {
+	query := `
+		SELECT g.role
+		FROM (` + entityGrantsSQL + `) g
+		JOIN z_entity granted ON granted.entity_id = g.entity_id
+		JOIN z_entity target ON target.path <@ granted.path
+		WHERE target.entity_id = $2
+			AND target.deleted_at IS NULL AND granted.deleted_at IS NULL
+		ORDER BY ` + entityRoleOrderSQL + `
+		LIMIT 1
+	`
 
-	if userId != "" {
-		var userHasEntity bool
-		checkUserQuery := `select exists(select 1 from z_entity where user_id = $1)`
-		if err := tx.QueryRow(ctx, checkUserQuery, userId).Scan(&userHasEntity); err != nil {
-			return "", fmt.Errorf("failed to check user entities: %w", err)
+	var role domain.EntityRole
+	if err := r.db.QueryRow(ctx, query, userId, entityId).Scan(&role); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
 		}
+		return "", fmt.Errorf("failed to check entity access: %w", err)
+	}
 
-		if !userHasEntity {
-			return "", fmt.Errorf("user with ID %s does not have any existing entities", userId)
-		}
+	return role, nil
+}
 
-		if categoryType == UserCategoryType {
-			query := `INSERT INTO z_entity (category_id, parent_id, name, user_id) VALUES ($1, $2, $3, $4) RETURNING entity_id`
-			err = tx.QueryRow(ctx, query, categoryId, parentEntityId, entityName, userId).Scan(&entityId)
-		} else {
-			detailsJSON, jsonErr := json.Marshal(details)
-			if jsonErr != nil {
-				return "", fmt.Errorf("failed to marshal details: %w", jsonErr)
-			}
+// ListAccessibleEntities retrieves every entity the user can reach with their effective role, ordered by path
+func (r *EntityRepository) ListAccessibleEntities(ctx context.Context, userId string) ([]*domain.EntityAccess, error) {
+	query := `
+		SELECT * FROM (
+			SELECT DISTINCT ON (e.entity_id)
+				e.entity_id, e.user_id, e.name, e.details, e.category_id,
+				c.name, c.type, e.parent_id, e.path::text AS path, e.depth,
+				e.created_at, e.updated_at, g.role, g.entity_id
+			FROM (` + entityGrantsSQL + `) g
+			JOIN z_entity granted ON granted.entity_id = g.entity_id
+			JOIN z_entity e ON e.path <@ granted.path
+			JOIN z_category c ON c.category_id = e.category_id
+			WHERE e.deleted_at IS NULL AND granted.deleted_at IS NULL
+			ORDER BY e.entity_id, ` + entityRoleOrderSQL + `, granted.depth DESC
+		) accessible
+		ORDER BY accessible.path
+	`
 
-			query := `INSERT INTO z_entity (category_id, parent_id, name, details) VALUES ($1, $2, $3, $4) RETURNING entity_id`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accessible entities: %w", err)
+	}
+	defer rows.Close()
 
-			err = tx.QueryRow(ctx, query, categoryId, parentEntityId, entityName, detailsJSON).Scan(&entityId)
+	entities := make([]*domain.EntityAccess, 0)
+	for rows.Next() {
+		access := new(domain.EntityAccess)
+		if err := rows.Scan(
+			&access.ID,
+			&access.UserID,
+			&access.Name,
+			&access.Details,
+			&access.CategoryID,
+			&access.CategoryName,
+			&access.CategoryType,
+			&access.ParentID,
+			&access.Path,
+			&access.Depth,
+			&access.CreatedAt,
+			&access.UpdatedAt,
+			&access.Role,
+			&access.GrantedEntityID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan accessible entity row: %w", err)
 		}
+		entities = append(entities, access)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accessible entity rows: %w", err)
 	}
 
+	return entities, nil
+}
+
+// GetSubtreeDevices retrieves every device owned by a user entity in the subtree rooted at entityId
+func (r *EntityRepository) GetSubtreeDevices(ctx context.Context, entityId string) ([]*domain.EntityDevice, error) {
+	query := `
+		SELECT d.mac_address, d.device_name, e.entity_id, e.path::text
+		FROM z_entity root
+		JOIN z_entity e ON e.path <@ root.path
+		JOIN z_device d ON d.user_id = e.user_id
+		WHERE root.entity_id = $1 AND e.deleted_at IS NULL AND d.deleted_at IS NULL
+		ORDER BY e.path, d.mac_address
+	`
+
+	rows, err := r.db.Query(ctx, query, entityId)
 	if err != nil {
-		return "", fmt.Errorf("failed to create sub-entity: %w", err)
+		return nil, fmt.Errorf("failed to query subtree devices: %w", err)
 	}
+	defer rows.Close()
 
-	if err := tx.Commit(ctx); err != nil {
-		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	devices := make([]*domain.EntityDevice, 0)
+	for rows.Next() {
+		device := new(domain.EntityDevice)
+		if err := rows.Scan(&device.MacAddress, &device.DeviceName, &device.EntityID, &device.EntityPath); err != nil {
+			return nil, fmt.Errorf("failed to scan subtree device row: %w", err)
+		}
+		devices = append(devices, device)
 	}
 
-	return entityId, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subtree device rows: %w", err)
+	}
+
+	return devices, nil
 }
 
 // GetChildEntities retrieves all direct child entities of a given entity.
@@ -160,7 +1092,7 @@ func (r *EntityRepository) CreateSubEntity(ctx context.Context, categoryId strin
 func (r *EntityRepository) GetChildEntities(ctx context.Context, entityId string, recursive bool) ([]*domain.Entity, error) {
 	// First check if the parent entity exists
 	var exists bool
-	checkEntityQuery := `SELECT EXISTS(SELECT 1 FROM z_entity WHERE entity_id = $1)`
+	checkEntityQuery := `SELECT EXISTS(SELECT 1 FROM z_entity WHERE entity_id = $1 AND deleted_at IS NULL)`
 
 	if err := r.db.QueryRow(ctx, checkEntityQuery, entityId).Scan(&exists); err != nil {
 		return nil, fmt.Errorf("failed to check entity existence: %w", err)
@@ -191,6 +1123,7 @@ func (r *EntityRepository) GetChildEntities(ctx context.Context, entityId string
 			WHERE 
 				e.path <@ $1::ltree 
 				AND e.entity_id != $2
+				AND e.deleted_at IS NULL
 			ORDER BY 
 				e.path, e.depth
 		`
@@ -204,6 +1137,7 @@ func (r *EntityRepository) GetChildEntities(ctx context.Context, entityId string
 				z_entity e
 			WHERE 
 				e.parent_id = $1
+				AND e.deleted_at IS NULL
 			ORDER BY 
 				e.name
 		`
@@ -254,194 +1188,193 @@ func (r *EntityRepository) GetChildEntities(ctx context.Context, entityId string
 	return entities, nil
 }
 
-// GetEntityHierarchy retrieves an entity and all its descendant entities as a hierarchy
-// This method provides a structured view of the entity tree with proper parent-child relationships
-func (r *EntityRepository) GetEntityHierarchy(ctx context.Context, rootEntityId string) (map[string]any, error) {
-	// First check if the root entity exists
-	var exists bool
-	checkEntityQuery := `SELECT EXISTS(SELECT 1 FROM z_entity WHERE entity_id = $1)`
-
-	if err := r.db.QueryRow(ctx, checkEntityQuery, rootEntityId).Scan(&exists); err != nil {
-		return nil, fmt.Errorf("failed to check entity existence: %w", err)
-	}
-
-	if !exists {
-		return nil, fmt.Errorf("entity with ID %s not found", rootEntityId)
-	}
-
-	// Get all entities in the hierarchy using recursive CTE
+// GetEntityHierarchy retrieves an entity and its descendants up to maxDepth levels below it,
+// using the ltree index. Nodes are ordered by depth then name; the result is empty if the
+// entity does not exist.
+func (r *EntityRepository) GetEntityHierarchy(ctx context.Context, rootEntityId string, maxDepth int) ([]*domain.EntityNode, error) {
 	query := `
-		WITH RECURSIVE entity_hierarchy AS (
-			-- Base case: the root entity
-			SELECT 
-				e.entity_id, 
-				e.user_id, 
-				e.name, 
-				e.details, 
-				e.category_id,
-				c.name AS category_name,
-				c.type AS category_type,
-				e.parent_id, 
-				e.path::text,
-				e.depth, 
-				e.created_at, 
-				e.updated_at
-			FROM 
-				z_entity e
-				JOIN z_category c ON e.category_id = c.category_id
-			WHERE 
-				e.entity_id = $1
-				
-			UNION ALL
-			
-			-- Recursive case: find all children
-			SELECT 
-				child.entity_id, 
-				child.user_id, 
-				child.name, 
-				child.details, 
-				child.category_id,
-				c.name AS category_name,
-				c.type AS category_type,
-				child.parent_id, 
-				child.path::text,
-				child.depth, 
-				child.created_at, 
-				child.updated_at
-			FROM 
-				z_entity child
-				JOIN entity_hierarchy parent ON child.parent_id = parent.entity_id
-				JOIN z_category c ON child.category_id = c.category_id
-		)
-		SELECT 
-			entity_id, 
-			user_id, 
-			name, 
-			details, 
-			category_id,
-			category_name,
-			category_type,
-			parent_id, 
-			path,
-			depth, 
-			created_at, 
-			updated_at
-		FROM 
-			entity_hierarchy
-		ORDER BY 
-			depth, name
+		SELECT
+			e.entity_id, e.user_id, e.name, e.details, e.category_id,
+			c.name, c.type, e.parent_id, e.path::text, e.depth,
+			e.created_at, e.updated_at,
+			(SELECT count(*) FROM z_entity child WHERE child.parent_id = e.entity_id AND child.deleted_at IS NULL)
+		FROM z_entity root
+		JOIN z_entity e ON e.path <@ root.path
+			AND nlevel(e.path) <= nlevel(root.path) + $2
+			AND e.deleted_at IS NULL
+		JOIN z_category c ON c.category_id = e.category_id
+		WHERE root.entity_id = $1
+		ORDER BY e.depth, e.name
 	`
 
-	rows, err := r.db.Query(ctx, query, rootEntityId)
+	rows, err := r.db.Query(ctx, query, rootEntityId, maxDepth)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query entity hierarchy: %w", err)
 	}
 	defer rows.Close()
 
-	// Store all entities to build the hierarchy
-	allEntities := make(map[string]map[string]any)
-	var rootEntity map[string]any
-
-	// Build a flat map of all entities
+	nodes := make([]*domain.EntityNode, 0)
 	for rows.Next() {
-		var (
-			entityId     string
-			userId       *string
-			name         string
-			details      []byte
-			categoryId   string
-			categoryName string
-			categoryType string
-			parentId     *string
-			path         string
-			depth        int
-			createdAt    time.Time
-			updatedAt    time.Time
-		)
-
+		node := new(domain.EntityNode)
 		if err := rows.Scan(
-			&entityId,
-			&userId,
-			&name,
-			&details,
-			&categoryId,
-			&categoryName,
-			&categoryType,
-			&parentId,
-			&path,
-			&depth,
-			&createdAt,
-			&updatedAt,
+			&node.ID,
+			&node.UserID,
+			&node.Name,
+			&node.Details,
+			&node.CategoryID,
+			&node.CategoryName,
+			&node.CategoryType,
+			&node.ParentID,
+			&node.Path,
+			&node.Depth,
+			&node.CreatedAt,
+			&node.UpdatedAt,
+			&node.ChildCount,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan entity row: %w", err)
 		}
+		nodes = append(nodes, node)
+	}
 
-		// Create entity object
-		entity := make(map[string]any)
-		entity["id"] = entityId
-		entity["name"] = name
-		entity["category_id"] = categoryId
-		entity["category_name"] = categoryName
-		entity["category_type"] = categoryType
-		entity["path"] = path
-		entity["depth"] = depth
-		entity["created_at"] = createdAt
-		entity["updated_at"] = updatedAt
-		entity["children"] = []map[string]any{} // Will be populated later
-
-		// Add optional fields
-		if userId != nil {
-			entity["user_id"] = *userId
-		}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entity rows: %w", err)
+	}
 
-		if parentId != nil {
-			entity["parent_id"] = *parentId
-		}
+	return nodes, nil
+}
 
-		// Parse details JSON if present
-		if len(details) > 0 && string(details) != "null" {
-			var detailsMap map[string]any
-			if err := json.Unmarshal(details, &detailsMap); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal details JSON: %w", err)
-			}
-			entity["details"] = detailsMap
-		} else {
-			entity["details"] = map[string]any{}
-		}
+// GetEntityHierarchyAsOf reconstructs an entity and its descendants up to maxDepth levels below
+// it as they were at asOf, from the entity history. Categories are shown as they are now.
+// Moves rewrite the path of the whole subtree, so every entity beneath the root at asOf has a
+// history row placing it under the root's path then; only those entities' rows are read.
+func (r *EntityRepository) GetEntityHierarchyAsOf(ctx context.Context, rootEntityId string, maxDepth int, asOf time.Time) ([]*domain.EntityNode, error) {
+	query := `
+		WITH root AS (
+			SELECT (after->>'path')::ltree AS path
+			FROM (
+				SELECT after
+				FROM z_entity_history
+				WHERE entity_id = $1 AND changed_at <= $3
+				ORDER BY history_id DESC
+				LIMIT 1
+			) latest
+			WHERE after IS NOT NULL
+		), candidates AS (
+			SELECT DISTINCT h.entity_id
+			FROM z_entity_history h, root
+			WHERE (h.after->>'path')::ltree <@ root.path AND h.changed_at <= $3
+		), subtree AS (
+			SELECT c.entity_id, latest.after, nlevel((latest.after->>'path')::ltree) - nlevel(root.path) AS level
+			FROM candidates c
+			CROSS JOIN root
+			CROSS JOIN LATERAL (
+				SELECT after
+				FROM z_entity_history h
+				WHERE h.entity_id = c.entity_id AND h.changed_at <= $3
+				ORDER BY h.history_id DESC
+				LIMIT 1
+			) latest
+			WHERE latest.after IS NOT NULL AND (latest.after->>'path')::ltree <@ root.path
+		), child_counts AS (
+			SELECT (after->>'parent_id')::uuid AS parent_id, count(*) AS child_count
+			FROM subtree
+			WHERE level > 0
+			GROUP BY 1
+		)
+		SELECT
+			t.entity_id, (t.after->>'user_id')::uuid, COALESCE(t.after->>'name', ''), t.after->'details',
+			(t.after->>'category_id')::uuid, COALESCE(c.name, ''), COALESCE(c.type, ''),
+			(t.after->>'parent_id')::uuid, t.after->>'path', (t.after->>'depth')::int,
+			(t.after->>'created_at')::timestamptz, (t.after->>'updated_at')::timestamptz,
+			COALESCE(cc.child_count, 0)
+		FROM subtree t
+		LEFT JOIN z_category c ON c.category_id = (t.after->>'category_id')::uuid
+		LEFT JOIN child_counts cc ON cc.parent_id = t.entity_id
+		WHERE t.level <= $2
+		ORDER BY t.level, t.after->>'name'
+	`
 
-		// Store entity in the map
-		allEntities[entityId] = entity
+	rows, err := r.db.Query(ctx, query, rootEntityId, maxDepth, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity hierarchy history: %w", err)
+	}
+	defer rows.Close()
 
-		// If this is the root entity, save a reference
-		if entityId == rootEntityId {
-			rootEntity = entity
+	nodes := make([]*domain.EntityNode, 0)
+	for rows.Next() {
+		node := new(domain.EntityNode)
+		if err := rows.Scan(
+			&node.ID,
+			&node.UserID,
+			&node.Name,
+			&node.Details,
+			&node.CategoryID,
+			&node.CategoryName,
+			&node.CategoryType,
+			&node.ParentID,
+			&node.Path,
+			&node.Depth,
+			&node.CreatedAt,
+			&node.UpdatedAt,
+			&node.ChildCount,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan entity row: %w", err)
 		}
+		nodes = append(nodes, node)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating entity rows: %w", err)
 	}
 
-	// If no root entity was found, something went wrong
-	if rootEntity == nil {
-		return nil, fmt.Errorf("root entity not found in query results")
+	return nodes, nil
+}
+
+// ListEntityHistory retrieves a page of an entity's recorded changes, newest first,
+// and the total number of changes recorded
+func (r *EntityRepository) ListEntityHistory(ctx context.Context, entityId string, limit, offset int) ([]*domain.EntityHistoryEntry, int64, error) {
+	var total int64
+	countQuery := `SELECT COUNT(*) FROM z_entity_history WHERE entity_id = $1`
+	if err := r.db.QueryRow(ctx, countQuery, entityId).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count entity history: %w", err)
 	}
 
-	// Build the hierarchy - for each entity, add it to its parent's children array
-	for _, entity := range allEntities {
-		parentId, hasParent := entity["parent_id"]
-		if hasParent && parentId != nil {
-			// Check if this entity is not the root entity itself
-			if entity["id"].(string) != rootEntityId {
-				if parent, exists := allEntities[parentId.(string)]; exists {
-					children := parent["children"].([]map[string]any)
-					parent["children"] = append(children, entity)
-				}
-			}
+	query := `
+		SELECT history_id, entity_id, action, actor_user_id, before, after, changed_at
+		FROM z_entity_history
+		WHERE entity_id = $1
+		ORDER BY history_id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, entityId, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query entity history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*domain.EntityHistoryEntry, 0)
+	for rows.Next() {
+		entry := new(domain.EntityHistoryEntry)
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.EntityID,
+			&entry.Action,
+			&entry.ActorUserID,
+			&entry.Before,
+			&entry.After,
+			&entry.ChangedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan entity history row: %w", err)
 		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating entity history rows: %w", err)
 	}
 
-	return rootEntity, nil
+	return entries, total, nil
 }
 
 // ListEntityChildren lists all children of a given entity with optional filtering
@@ -450,7 +1383,7 @@ func (r *EntityRepository) GetEntityHierarchy(ctx context.Context, rootEntityId
 func (r *EntityRepository) ListEntityChildren(ctx context.Context, entityId string, level int, categoryType string) ([]*domain.Entity, error) {
 	// First check if the entity exists
 	var exists bool
-	checkEntityQuery := `SELECT EXISTS(SELECT 1 FROM z_entity WHERE entity_id = $1)`
+	checkEntityQuery := `SELECT EXISTS(SELECT 1 FROM z_entity WHERE entity_id = $1 AND deleted_at IS NULL)`
 
 	if err := r.db.QueryRow(ctx, checkEntityQuery, entityId).Scan(&exists); err != nil {
 		return nil, fmt.Errorf("failed to check entity existence: %w", err)
@@ -460,6 +1393,18 @@ func (r *EntityRepository) ListEntityChildren(ctx context.Context, entityId stri
 		return nil, fmt.Errorf("entity with ID %s not found", entityId)
 	}
 
+	// Reject filters on category types that do not exist rather than returning nothing
+	if categoryType != "" {
+		checkTypeQuery := `SELECT EXISTS(SELECT 1 FROM z_category_type WHERE name = $1)`
+		if err := r.db.QueryRow(ctx, checkTypeQuery, categoryType).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check category type: %w", err)
+		}
+
+		if !exists {
+			return nil, ErrUnknownCategoryType
+		}
+	}
+
 	// Build the query based on parameters
 	var args []any
 	args = append(args, entityId)
@@ -478,6 +1423,7 @@ func (r *EntityRepository) ListEntityChildren(ctx context.Context, entityId stri
 				JOIN z_category c ON e.category_id = c.category_id
 			WHERE 
 				e.parent_id = $1
+				AND e.deleted_at IS NULL
 	`
 
 	// Add category filter if provided
@@ -500,11 +1446,13 @@ func (r *EntityRepository) ListEntityChildren(ctx context.Context, entityId stri
 				z_entity child
 				JOIN entity_children parent ON child.parent_id = parent.entity_id
 				JOIN z_category c ON child.category_id = c.category_id
+			WHERE
+				child.deleted_at IS NULL
 	`
 
 	// Apply level filter for recursive case if specified
 	if level > 0 {
-		query += fmt.Sprintf(" WHERE parent.level < %d", level)
+		query += fmt.Sprintf(" AND parent.level < %d", level)
 	}
 
 	// Close the CTE and add final selection
@@ -563,3 +1511,229 @@ func (r *EntityRepository) ListEntityChildren(ctx context.Context, entityId stri
 
 	return entities, nil
 }
+
+// earthRadiusMeters is the mean radius used for distances when PostGIS is not available
+const earthRadiusMeters = 6371008.8
+
+// DetectGeography checks whether z_entity has the PostGIS geography column, which the
+// location migration only adds where PostGIS is installed. Radius queries use it when
+// present and fall back to great-circle distances over the plain coordinates otherwise.
+func (r *EntityRepository) DetectGeography(ctx context.Context) error {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'z_entity' AND column_name = 'geog'
+		)
+	`
+
+	if err := r.db.QueryRow(ctx, query).Scan(&r.geography); err != nil {
+		return fmt.Errorf("failed to check for geography column: %w", err)
+	}
+
+	return nil
+}
+
+// UsesGeography reports whether radius queries run on PostGIS
+func (r *EntityRepository) UsesGeography() bool {
+	return r.geography
+}
+
+// SetEntityLocation sets an entity's coordinates, or clears them when both are nil
+func (r *EntityRepository) SetEntityLocation(ctx context.Context, entityId string, latitude, longitude *float64) error {
+	query := `
+		UPDATE z_entity SET
+			latitude = $2,
+			longitude = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE entity_id = $1 AND deleted_at IS NULL
+	`
+
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, query, entityId, latitude, longitude); err != nil {
+		return fmt.Errorf("failed to set entity location: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// geoEntityColumns selects a located entity "e" with its category "c"
+const geoEntityColumns = `
+	e.entity_id, e.user_id, e.name, e.details, e.category_id,
+	c.name, c.type, e.parent_id, e.path::text, e.depth,
+	e.created_at, e.updated_at, e.latitude, e.longitude`
+
+// FindEntitiesNear finds the located entities the user can reach within filter.RadiusMeters
+// of a point, nearest first
+func (r *EntityRepository) FindEntitiesNear(ctx context.Context, userId string, filter domain.EntityGeoFilter) ([]*domain.GeoEntity, error) {
+	args := []any{userId}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := geoScopeConditions(arg, filter.UnderEntityID)
+	lat, lon, radius := arg(filter.Latitude), arg(filter.Longitude), arg(filter.RadiusMeters)
+
+	var distance string
+	if r.geography {
+		point := fmt.Sprintf("ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography", lon, lat)
+		distance = fmt.Sprintf("ST_Distance(e.geog, %s)", point)
+		conditions = append(conditions, fmt.Sprintf("ST_DWithin(e.geog, %s, %s)", point, radius))
+	} else {
+		// Haversine distance, with a bounding box around the circle so the location index can be used
+		distance = fmt.Sprintf(`(2 * %v * asin(LEAST(1, sqrt(
+			power(sin(radians(e.latitude - %s) / 2), 2) +
+			cos(radians(%s)) * cos(radians(e.latitude)) * power(sin(radians(e.longitude - %s) / 2), 2)
+		))))`, earthRadiusMeters, lat, lat, lon)
+		conditions = append(conditions, radiusBoxConditions(arg, filter.Latitude, filter.Longitude, filter.RadiusMeters)...)
+		conditions = append(conditions, distance+" <= "+radius)
+	}
+
+	query := `
+		SELECT` + geoEntityColumns + `, ` + distance + ` AS distance_meters
+		FROM z_entity e
+		JOIN z_category c ON c.category_id = e.category_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY distance_meters, e.entity_id
+		LIMIT ` + arg(filter.Limit)
+
+	return r.queryGeoEntities(ctx, query, args...)
+}
+
+// FindEntitiesInBox finds the located entities the user can reach inside a bounding box, by name
+func (r *EntityRepository) FindEntitiesInBox(ctx context.Context, userId string, filter domain.EntityGeoFilter) ([]*domain.GeoEntity, error) {
+	args := []any{userId}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := geoScopeConditions(arg, filter.UnderEntityID)
+	conditions = append(conditions, geoBoxConditions(arg, filter.MinLatitude, filter.MinLongitude, filter.MaxLatitude, filter.MaxLongitude)...)
+
+	query := `
+		SELECT` + geoEntityColumns + `, NULL::double precision
+		FROM z_entity e
+		JOIN z_category c ON c.category_id = e.category_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY e.name, e.entity_id
+		LIMIT ` + arg(filter.Limit)
+
+	return r.queryGeoEntities(ctx, query, args...)
+}
+
+// GetSubtreeLocations returns the located entities among an entity and its descendants up to
+// maxDepth levels below it, shallowest first
+func (r *EntityRepository) GetSubtreeLocations(ctx context.Context, rootEntityId string, maxDepth int) ([]*domain.GeoEntity, error) {
+	query := `
+		SELECT` + geoEntityColumns + `, NULL::double precision
+		FROM z_entity root
+		JOIN z_entity e ON e.path <@ root.path
+			AND nlevel(e.path) <= nlevel(root.path) + $2
+			AND e.deleted_at IS NULL
+			AND e.latitude IS NOT NULL
+		JOIN z_category c ON c.category_id = e.category_id
+		WHERE root.entity_id = $1
+		ORDER BY e.depth, e.name
+	`
+
+	return r.queryGeoEntities(ctx, query, rootEntityId, maxDepth)
+}
+
+// geoScopeConditions restricts entity "e" to live, located entities user $1 can reach,
+// optionally within the subtree of underEntityId
+func geoScopeConditions(arg func(any) string, underEntityId string) []string {
+	conditions := []string{"e.deleted_at IS NULL", "e.latitude IS NOT NULL", entityAccessibleSQL}
+	if underEntityId != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"e.path <@ (SELECT path FROM z_entity WHERE entity_id = %s AND deleted_at IS NULL)", arg(underEntityId)))
+	}
+	return conditions
+}
+
+// geoBoxConditions restricts entity "e" to a bounding box. A box whose minimum longitude is
+// greater than its maximum crosses the antimeridian and matches either side of it.
+func geoBoxConditions(arg func(any) string, minLat, minLon, maxLat, maxLon float64) []string {
+	conditions := []string{fmt.Sprintf("e.latitude BETWEEN %s AND %s", arg(minLat), arg(maxLat))}
+	if minLon <= maxLon {
+		conditions = append(conditions, fmt.Sprintf("e.longitude BETWEEN %s AND %s", arg(minLon), arg(maxLon)))
+	} else {
+		conditions = append(conditions, fmt.Sprintf("(e.longitude >= %s OR e.longitude <= %s)", arg(minLon), arg(maxLon)))
+	}
+	return conditions
+}
+
+// radiusBoxConditions bounds a circle for the haversine fallback. Longitude is left open
+// when the circle reaches a pole or spans every meridian.
+func radiusBoxConditions(arg func(any) string, lat, lon, radiusMeters float64) []string {
+	angular := radiusMeters / earthRadiusMeters
+	latDelta := angular * 180 / math.Pi
+	minLat, maxLat := lat-latDelta, lat+latDelta
+	if minLat <= -90 || maxLat >= 90 {
+		return []string{fmt.Sprintf("e.latitude BETWEEN %s AND %s", arg(math.Max(minLat, -90)), arg(math.Min(maxLat, 90)))}
+	}
+
+	ratio := math.Sin(angular) / math.Cos(lat*math.Pi/180)
+	if angular >= math.Pi/2 || ratio >= 1 {
+		return []string{fmt.Sprintf("e.latitude BETWEEN %s AND %s", arg(minLat), arg(maxLat))}
+	}
+
+	lonDelta := math.Asin(ratio) * 180 / math.Pi
+	minLon, maxLon := lon-lonDelta, lon+lonDelta
+	if minLon < -180 {
+		minLon += 360
+	}
+	if maxLon > 180 {
+		maxLon -= 360
+	}
+	return geoBoxConditions(arg, minLat, minLon, maxLat, maxLon)
+}
+
+// queryGeoEntities runs a query selecting geoEntityColumns and a distance
+func (r *EntityRepository) queryGeoEntities(ctx context.Context, query string, args ...any) ([]*domain.GeoEntity, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entity locations: %w", err)
+	}
+	defer rows.Close()
+
+	entities := make([]*domain.GeoEntity, 0)
+	for rows.Next() {
+		entity := new(domain.GeoEntity)
+		if err := rows.Scan(
+			&entity.ID,
+			&entity.UserID,
+			&entity.Name,
+			&entity.Details,
+			&entity.CategoryID,
+			&entity.CategoryName,
+			&entity.CategoryType,
+			&entity.ParentID,
+			&entity.Path,
+			&entity.Depth,
+			&entity.CreatedAt,
+			&entity.UpdatedAt,
+			&entity.Latitude,
+			&entity.Longitude,
+			&entity.DistanceMeters,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan entity location row: %w", err)
+		}
+		entities = append(entities, entity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating entity location rows: %w", err)
+	}
+
+	return entities, nil
+}
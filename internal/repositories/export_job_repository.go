@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// ExportJobRepository handles persistence of asynchronous export jobs
+type ExportJobRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewExportJobRepository creates a new export job repository instance
+func NewExportJobRepository(dbPool *pgxpool.Pool) *ExportJobRepository {
+	return &ExportJobRepository{
+		db: dbPool,
+	}
+}
+
+// CreateExportJob inserts a new pending export job and returns its ID
+func (r *ExportJobRepository) CreateExportJob(ctx context.Context, job *domain.ExportJob) (string, error) {
+	query := `
+		INSERT INTO z_export_job (
+			user_id, mac_address, format, start_time, end_time, status
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING job_id, created_at
+	`
+
+	err := r.db.QueryRow(
+		ctx,
+		query,
+		job.UserID,
+		job.MacAddress,
+		job.Format,
+		job.StartTime,
+		job.EndTime,
+		domain.ExportJobPending,
+	).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	job.Status = domain.ExportJobPending
+	return job.ID, nil
+}
+
+// GetExportJob retrieves an export job by ID
+func (r *ExportJobRepository) GetExportJob(ctx context.Context, jobID string) (*domain.ExportJob, error) {
+	query := `
+		SELECT job_id, user_id, mac_address, format, start_time, end_time,
+		       status, storage_key, row_count, error, created_at, completed_at
+		FROM z_export_job
+		WHERE job_id = $1
+	`
+
+	job := &domain.ExportJob{}
+	err := r.db.QueryRow(ctx, query, jobID).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.MacAddress,
+		&job.Format,
+		&job.StartTime,
+		&job.EndTime,
+		&job.Status,
+		&job.StorageKey,
+		&job.RowCount,
+		&job.Error,
+		&job.CreatedAt,
+		&job.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil // Job not found, return nil without error
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return job, nil
+}
+
+// MarkExportJobRunning moves a job into the running state
+func (r *ExportJobRepository) MarkExportJobRunning(ctx context.Context, jobID string) error {
+	query := `UPDATE z_export_job SET status = $1 WHERE job_id = $2`
+
+	if _, err := r.db.Exec(ctx, query, domain.ExportJobRunning, jobID); err != nil {
+		return fmt.Errorf("failed to update export job: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteExportJob records the output of a successful job
+func (r *ExportJobRepository) CompleteExportJob(ctx context.Context, jobID, storageKey string, rowCount int64) error {
+	query := `
+		UPDATE z_export_job SET
+			status = $1,
+			storage_key = $2,
+			row_count = $3,
+			completed_at = $4
+		WHERE job_id = $5
+	`
+
+	if _, err := r.db.Exec(ctx, query, domain.ExportJobCompleted, storageKey, rowCount, time.Now(), jobID); err != nil {
+		return fmt.Errorf("failed to complete export job: %w", err)
+	}
+
+	return nil
+}
+
+// FailExportJob records the error of a failed job
+func (r *ExportJobRepository) FailExportJob(ctx context.Context, jobID, errorMessage string) error {
+	query := `
+		UPDATE z_export_job SET
+			status = $1,
+			error = $2,
+			completed_at = $3
+		WHERE job_id = $4
+	`
+
+	if _, err := r.db.Exec(ctx, query, domain.ExportJobFailed, errorMessage, time.Now(), jobID); err != nil {
+		return fmt.Errorf("failed to mark export job as failed: %w", err)
+	}
+
+	return nil
+}
+
+// FailStaleExportJobs fails the jobs still pending or running that were created before cutoff,
+// whose process is gone, and returns how many were failed
+func (r *ExportJobRepository) FailStaleExportJobs(ctx context.Context, cutoff time.Time, errorMessage string) (int64, error) {
+	query := `
+		UPDATE z_export_job SET
+			status = $1,
+			error = $2,
+			completed_at = $3
+		WHERE status IN ($4, $5) AND created_at < $6
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		domain.ExportJobFailed, errorMessage, time.Now(),
+		domain.ExportJobPending, domain.ExportJobRunning, cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail stale export jobs: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"n1h41/zolaris-backend-app/internal/domain"
 )
@@ -9,20 +11,69 @@ import (
 // UserRepositoryInterface defines the operations for user data
 type UserRepositoryInterface interface {
 	GetUserIdByCognitoId(ctx context.Context, cId string) (string, error)
+	GetIdentityByCognitoId(ctx context.Context, cId string) (*domain.UserIdentity, error)
 	GetUserByID(ctx context.Context, userID string) (*domain.User, error)
 	CreateUser(ctx context.Context, user *domain.User) error
 	UpdateUser(ctx context.Context, user *domain.User) error
-	CheckHasParentID(ctx context.Context, userID string) (bool, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetChildUsers(ctx context.Context, parentID string) ([]*domain.User, error)
-	ListReferredUsers(ctx context.Context, userID string) ([]*domain.User, error)
+	IsUserAncestor(ctx context.Context, ancestorID, userID string) (bool, error)
+	CreateChildInvitation(ctx context.Context, invitation *domain.ChildInvitation) error
+	GetChildInvitation(ctx context.Context, invitationID string) (*domain.ChildInvitation, error)
+	ListChildInvitationsByParent(ctx context.Context, parentID string) ([]*domain.ChildInvitation, error)
+	ListChildInvitationsForEmail(ctx context.Context, email string) ([]*domain.ChildInvitation, error)
+	AcceptChildInvitation(ctx context.Context, invitationID, userID string) (bool, error)
+	DeleteChildInvitation(ctx context.Context, invitationID string) (bool, error)
+	SetUserDisabled(ctx context.Context, userID string, disabled bool) (bool, error)
+	SetReferrer(ctx context.Context, userID, referrerID string) (bool, error)
+	ListReferralTree(ctx context.Context, userID string, maxDepth int) ([]*domain.ReferralNode, error)
+	GetReferralStats(ctx context.Context, userID string, maxDepth int, from, to time.Time, interval string) (*domain.ReferralStats, error)
+	GetUserRole(ctx context.Context, userID string) (domain.UserRole, error)
+	SoftDeleteUser(ctx context.Context, userID string) (bool, error)
+	RestoreUser(ctx context.Context, userID string) (bool, error)
+	ListDeletedUsers(ctx context.Context) ([]*domain.DeletedRecord, error)
+	PurgeDeletedUsers(ctx context.Context, cutoff time.Time) (int64, error)
+	GetUserAccount(ctx context.Context, userID string) (*domain.UserAccount, error)
+	SearchUsers(ctx context.Context, filter domain.UserSearchFilter) ([]*domain.UserAccount, int64, error)
+	GetIdentityByUserID(ctx context.Context, userID string) (*domain.UserIdentity, error)
+	GetCognitoIdByUserID(ctx context.Context, userID string) (string, error)
+	SetUserRole(ctx context.Context, userID string, role domain.UserRole) (bool, error)
+	RecordImpersonation(ctx context.Context, record *domain.ImpersonationRecord) error
+	ListImpersonations(ctx context.Context, adminUserID, userID string, limit, offset int) ([]*domain.ImpersonationRecord, int64, error)
+	ScheduleUserDeletion(ctx context.Context, userID string, gracePeriod time.Duration) (*domain.AccountDeletion, error)
+	GetUserDeletion(ctx context.Context, userID string) (*domain.AccountDeletion, error)
+	CancelUserDeletion(ctx context.Context, userID string) (bool, error)
+	ListDueUserDeletions(ctx context.Context, limit int) ([]string, error)
+	EraseUser(ctx context.Context, userID string) (*domain.UserErasure, error)
+	UpsertCognitoUser(ctx context.Context, profile *domain.CognitoProfile, overwrite bool) (string, domain.ProvisionOutcome, error)
 }
 
 // DeviceRepositoryInterface defines the operations for device data
 type DeviceRepositoryInterface interface {
 	AddDevice(ctx context.Context, device *domain.Device) error
-	GetDevicesByUserID(ctx context.Context, userID string) ([]*domain.Device, error)
+	GetDevicesByUserID(ctx context.Context, userID string, tags []domain.TagFilter) ([]*domain.Device, error)
+	GetDeviceByMac(ctx context.Context, macAddress string) (*domain.Device, error)
+	ListAllDevices(ctx context.Context) ([]*domain.Device, error)
+	UpdateElectricalParams(ctx context.Context, macAddress string, voltage float64, phaseCount int, powerFactor float64) error
+	SoftDeleteDevice(ctx context.Context, macAddress string) (bool, error)
+	RestoreDevice(ctx context.Context, macAddress string) (bool, error)
+	ListDeletedDevices(ctx context.Context) ([]*domain.DeletedRecord, error)
+	PurgeDeletedDevices(ctx context.Context, cutoff time.Time) (int64, error)
 	GetSensorData(ctx context.Context, macID string, startTime, endTime int64) ([]*domain.SensorReading, error)
+	StreamSensorData(ctx context.Context, macID string, startTime, endTime int64, fn func([]*domain.SensorReading) error) error
+	WriteSensorData(ctx context.Context, readings []*domain.SensorReading) error
+	AggregateSensorData(ctx context.Context, macID string, startTime, endTime int64, interval time.Duration) ([]*domain.SensorAggregate, error)
+	DeleteSensorData(ctx context.Context, macID string) error
+	ListUserMacAddresses(ctx context.Context, userID string) ([]string, error)
+}
+
+// ExportJobRepositoryInterface defines the operations for export job data
+type ExportJobRepositoryInterface interface {
+	CreateExportJob(ctx context.Context, job *domain.ExportJob) (string, error)
+	GetExportJob(ctx context.Context, jobID string) (*domain.ExportJob, error)
+	MarkExportJobRunning(ctx context.Context, jobID string) error
+	CompleteExportJob(ctx context.Context, jobID, storageKey string, rowCount int64) error
+	FailExportJob(ctx context.Context, jobID, errorMessage string) error
 }
 
 // CategoryRepositoryInterface defines the operations for category data
@@ -30,6 +81,21 @@ type CategoryRepositoryInterface interface {
 	AddCategory(ctx context.Context, category *domain.Category) error
 	GetCategoriesByType(ctx context.Context, categoryType string) ([]*domain.Category, error)
 	ListAllCategories(ctx context.Context) ([]*domain.Category, error)
+	GetCategoryByID(ctx context.Context, categoryID string) (*domain.Category, error)
+	UpdateDetailsSchema(ctx context.Context, categoryID string, detailsSchema []byte) (int, error)
+	ListTypeRules(ctx context.Context) ([]*domain.CategoryTypeRule, error)
+	UpsertTypeRule(ctx context.Context, rule *domain.CategoryTypeRule) error
+	ListCategoryTypes(ctx context.Context) ([]*domain.CategoryTypeDefinition, error)
+	GetCategoryTypeByName(ctx context.Context, name string) (*domain.CategoryTypeDefinition, error)
+	AddCategoryType(ctx context.Context, categoryType *domain.CategoryTypeDefinition) error
+	UpdateCategoryType(ctx context.Context, name, description string) error
+	CountCategoriesOfType(ctx context.Context, name string) (int, error)
+	DeleteCategoryType(ctx context.Context, name string) error
+	UpdateCategory(ctx context.Context, categoryID, name, categoryType string) error
+	CountEntitiesOfCategory(ctx context.Context, categoryID string) (int, error)
+	DeleteCategory(ctx context.Context, categoryID string) error
+	MergeCategory(ctx context.Context, sourceID, targetID string) (int64, error)
+	ListDuplicateCategories(ctx context.Context) ([]*domain.CategoryDuplicateGroup, error)
 }
 
 // PolicyRepositoryInterface defines the operations for policy data
@@ -43,5 +109,52 @@ type EntityRepositoryInterface interface {
 	GetCategoryType(ctx context.Context, categoryId string) (CategoryType, error)
 	CreateRootEntity(ctx context.Context, categoryId string, entityName string, userId string, details map[string]any) (string, error)
 	CreateSubEntity(ctx context.Context, categoryId string, entityName string, parentEntityId string, userId string, details map[string]any) (string, error)
+	CreateEntityTree(ctx context.Context, parentEntityId string, root *domain.EntityDraft) (string, int, error)
 	GetChildEntities(ctx context.Context, entityId string, recursive bool) ([]*domain.Entity, error)
+	GetEntityByID(ctx context.Context, entityId string) (*domain.Entity, error)
+	GetEntityHierarchy(ctx context.Context, rootEntityId string, maxDepth int) ([]*domain.EntityNode, error)
+	GetEntityHierarchyAsOf(ctx context.Context, rootEntityId string, maxDepth int, asOf time.Time) ([]*domain.EntityNode, error)
+	ListEntityHistory(ctx context.Context, entityId string, limit, offset int) ([]*domain.EntityHistoryEntry, int64, error)
+	GetEntityRole(ctx context.Context, userId string, entityId string) (domain.EntityRole, error)
+	ListAccessibleEntities(ctx context.Context, userId string) ([]*domain.EntityAccess, error)
+	GetSubtreeDevices(ctx context.Context, entityId string) ([]*domain.EntityDevice, error)
+	UpdateEntity(ctx context.Context, entityId string, name *string, details map[string]any) error
+	MoveEntity(ctx context.Context, entityId string, newParentId *string) error
+	DeleteEntity(ctx context.Context, entityId string, mode EntityDeleteMode) (bool, error)
+	RestoreEntity(ctx context.Context, entityId string) (bool, error)
+	ListDeletedEntities(ctx context.Context) ([]*domain.DeletedRecord, error)
+	PurgeDeletedEntities(ctx context.Context, cutoff time.Time) (int64, error)
+	GetCategorySchema(ctx context.Context, categoryId string) (json.RawMessage, error)
+	ListEntitiesByCategory(ctx context.Context, categoryId string) ([]*domain.Entity, error)
+	SetDetailsConformance(ctx context.Context, results []*domain.EntityConformance) error
+	ListNonConformingEntities(ctx context.Context, categoryId string) ([]*domain.EntityConformance, error)
+	GetEntityPlacement(ctx context.Context, entityId string) (*domain.EntityPlacement, error)
+	ListEntityPlacements(ctx context.Context) ([]*domain.EntityPlacement, error)
+	GetUserEntityID(ctx context.Context, userId string) (string, error)
+	GetSubtreeMaxDepths(ctx context.Context, entityId string) (map[string]int, error)
+	SearchEntities(ctx context.Context, userId string, filter domain.EntitySearchFilter) ([]*domain.EntityNode, int64, error)
+	SetEntityLocation(ctx context.Context, entityId string, latitude, longitude *float64) error
+	FindEntitiesNear(ctx context.Context, userId string, filter domain.EntityGeoFilter) ([]*domain.GeoEntity, error)
+	FindEntitiesInBox(ctx context.Context, userId string, filter domain.EntityGeoFilter) ([]*domain.GeoEntity, error)
+	GetSubtreeLocations(ctx context.Context, rootEntityId string, maxDepth int) ([]*domain.GeoEntity, error)
+}
+
+// EntityMemberRepositoryInterface defines the operations for entity membership data
+type EntityMemberRepositoryInterface interface {
+	CreateInvitation(ctx context.Context, entityId, userId string, role domain.EntityRole, invitedBy string) error
+	GetMember(ctx context.Context, entityId, userId string) (*domain.EntityMember, error)
+	AcceptInvitation(ctx context.Context, entityId, userId string) (bool, error)
+	DeleteMember(ctx context.Context, entityId, userId string) (bool, error)
+	ListEntityMembers(ctx context.Context, entityId string) ([]*domain.EntityMember, error)
+	ListPendingInvitations(ctx context.Context, userId string) ([]*domain.EntityMember, error)
+}
+
+// TagRepositoryInterface defines the operations for entity and device tags
+type TagRepositoryInterface interface {
+	ApplyTags(ctx context.Context, entityIds []string, macAddresses []string, tags []domain.Tag) error
+	RemoveTags(ctx context.Context, entityIds []string, macAddresses []string, keys []string) (int64, error)
+	GetEntityTags(ctx context.Context, entityIds []string) (map[string]map[string]string, error)
+	GetDeviceTags(ctx context.Context, macAddresses []string) (map[string]map[string]string, error)
+	SuggestTagKeys(ctx context.Context, userId string, prefix string, limit int) ([]*domain.TagSuggestion, error)
+	SuggestTagValues(ctx context.Context, userId string, key string, prefix string, limit int) ([]*domain.TagSuggestion, error)
 }
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// postgresTelemetryPageSize is the number of rows read per page in QueryRange
+const postgresTelemetryPageSize = 5000
+
+// PostgresTelemetryStore stores sensor readings in the z_sensor_reading table,
+// optionally converted to a TimescaleDB hypertable
+type PostgresTelemetryStore struct {
+	db        *pgxpool.Pool
+	timescale bool
+}
+
+// NewPostgresTelemetryStore creates a new Postgres-backed telemetry store
+func NewPostgresTelemetryStore(dbPool *pgxpool.Pool) *PostgresTelemetryStore {
+	return &PostgresTelemetryStore{
+		db: dbPool,
+	}
+}
+
+// WithTimescale enables TimescaleDB-specific features such as time_bucket aggregation
+func (s *PostgresTelemetryStore) WithTimescale(enabled bool) *PostgresTelemetryStore {
+	s.timescale = enabled
+	return s
+}
+
+// EnsureHypertable converts z_sensor_reading into a TimescaleDB hypertable.
+// It is a no-op unless Timescale support is enabled and safe to call on every start.
+func (s *PostgresTelemetryStore) EnsureHypertable(ctx context.Context) error {
+	if !s.timescale {
+		return nil
+	}
+
+	if _, err := s.db.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+		return fmt.Errorf("failed to enable timescaledb extension: %w", err)
+	}
+
+	query := `SELECT create_hypertable('z_sensor_reading', 'recorded_at', if_not_exists => TRUE, migrate_data => TRUE)`
+	if _, err := s.db.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create sensor reading hypertable: %w", err)
+	}
+
+	return nil
+}
+
+// WriteReadings upserts readings in a single batch
+func (s *PostgresTelemetryStore) WriteReadings(ctx context.Context, readings []*domain.SensorReading) error {
+	query := `
+		INSERT INTO z_sensor_reading (
+			mac_address, recorded_at, amperage, temperature, humidity
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (mac_address, recorded_at) DO UPDATE SET
+			amperage = EXCLUDED.amperage,
+			temperature = EXCLUDED.temperature,
+			humidity = EXCLUDED.humidity
+	`
+
+	batch := &pgx.Batch{}
+	for _, reading := range readings {
+		batch.Queue(
+			query,
+			reading.DeviceID,
+			reading.Timestamp,
+			parseMeasurement(reading.Amperage),
+			parseMeasurement(reading.Temperature),
+			parseMeasurement(reading.Humidity),
+		)
+	}
+
+	if err := s.db.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to write sensor readings: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteReadings removes every reading of a device
+func (s *PostgresTelemetryStore) DeleteReadings(ctx context.Context, macID string) error {
+	if _, err := s.db.Exec(ctx, `DELETE FROM z_sensor_reading WHERE mac_address = $1`, macID); err != nil {
+		return fmt.Errorf("failed to delete sensor readings: %w", err)
+	}
+
+	return nil
+}
+
+// QueryRange pages through a device's readings using keyset pagination on recorded_at
+func (s *PostgresTelemetryStore) QueryRange(ctx context.Context, macID string, startTime, endTime int64, fn func([]*domain.SensorReading) error) error {
+	query := `
+		SELECT recorded_at, amperage, temperature, humidity
+		FROM z_sensor_reading
+		WHERE mac_address = $1
+		  AND recorded_at > $2
+		  AND recorded_at <= $3
+		ORDER BY recorded_at
+		LIMIT $4
+	`
+
+	log.Printf("Querying sensor data for device %s from %d to %d", macID, startTime, endTime)
+
+	// Start just before the range so the first page includes startTime itself
+	cursor := time.UnixMilli(startTime).Add(-time.Microsecond)
+	end := time.UnixMilli(endTime)
+
+	for {
+		rows, err := s.db.Query(ctx, query, macID, cursor, end, postgresTelemetryPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to query sensor readings: %w", err)
+		}
+
+		page := make([]*domain.SensorReading, 0, postgresTelemetryPageSize)
+		for rows.Next() {
+			var (
+				recordedAt                      time.Time
+				amperage, temperature, humidity *float64
+			)
+			if err := rows.Scan(&recordedAt, &amperage, &temperature, &humidity); err != nil {
+				rows.Close()
+				return fmt.Errorf("error scanning sensor reading row: %w", err)
+			}
+
+			page = append(page, &domain.SensorReading{
+				DeviceID:    macID,
+				Timestamp:   recordedAt,
+				Amperage:    formatMeasurement(amperage),
+				Temperature: formatMeasurement(temperature),
+				Humidity:    formatMeasurement(humidity),
+			})
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating sensor reading rows: %w", err)
+		}
+
+		if len(page) == 0 {
+			return nil
+		}
+
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if len(page) < postgresTelemetryPageSize {
+			return nil
+		}
+		cursor = page[len(page)-1].Timestamp
+	}
+}
+
+// Aggregate summarizes readings in SQL using time_bucket on TimescaleDB or date_bin otherwise
+func (s *PostgresTelemetryStore) Aggregate(ctx context.Context, macID string, startTime, endTime int64, interval time.Duration) ([]*domain.SensorAggregate, error) {
+	if interval < time.Millisecond {
+		return nil, fmt.Errorf("aggregation interval must be at least one millisecond")
+	}
+
+	bucketExpr := `date_bin($4::interval, recorded_at, TIMESTAMPTZ 'epoch')`
+	if s.timescale {
+		bucketExpr = `time_bucket($4::interval, recorded_at, TIMESTAMPTZ 'epoch')`
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s AS bucket_start,
+			count(*),
+			coalesce(avg(amperage), 0),
+			coalesce(min(amperage), 0),
+			coalesce(max(amperage), 0),
+			coalesce(avg(temperature), 0),
+			coalesce(avg(humidity), 0)
+		FROM z_sensor_reading
+		WHERE mac_address = $1
+		  AND recorded_at BETWEEN $2 AND $3
+		GROUP BY bucket_start
+		ORDER BY bucket_start
+	`, bucketExpr)
+
+	rows, err := s.db.Query(ctx, query, macID, time.UnixMilli(startTime), time.UnixMilli(endTime), interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	var aggregates []*domain.SensorAggregate
+	for rows.Next() {
+		aggregate := &domain.SensorAggregate{DeviceID: macID}
+		if err := rows.Scan(
+			&aggregate.BucketStart,
+			&aggregate.Count,
+			&aggregate.AvgAmperage,
+			&aggregate.MinAmperage,
+			&aggregate.MaxAmperage,
+			&aggregate.AvgTemperature,
+			&aggregate.AvgHumidity,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning aggregate row: %w", err)
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating aggregate rows: %w", err)
+	}
+
+	return aggregates, nil
+}
+
+// parseMeasurement converts a reading value to a nullable number for storage
+func parseMeasurement(value string) *float64 {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// formatMeasurement converts a stored number back to the string form used by readings
+func formatMeasurement(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,228 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// TagRepository handles persistence of entity and device tags
+type TagRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewTagRepository creates a new tag repository instance
+func NewTagRepository(dbPool *pgxpool.Pool) *TagRepository {
+	return &TagRepository{
+		db: dbPool,
+	}
+}
+
+// ApplyTags sets tags on every given entity and device in one transaction, replacing the
+// value of keys they already carry
+func (r *TagRepository) ApplyTags(ctx context.Context, entityIds []string, macAddresses []string, tags []domain.Tag) error {
+	keys := make([]string, len(tags))
+	values := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = tag.Key
+		values[i] = tag.Value
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if len(entityIds) > 0 {
+		query := `
+			INSERT INTO z_entity_tag (entity_id, key, value)
+			SELECT target, t.key, t.value
+			FROM unnest($1::uuid[]) AS target
+			CROSS JOIN unnest($2::text[], $3::text[]) AS t(key, value)
+			ON CONFLICT (entity_id, key) DO UPDATE SET value = EXCLUDED.value
+		`
+		if _, err := tx.Exec(ctx, query, entityIds, keys, values); err != nil {
+			return fmt.Errorf("failed to tag entities: %w", err)
+		}
+	}
+
+	if len(macAddresses) > 0 {
+		query := `
+			INSERT INTO z_device_tag (mac_address, key, value)
+			SELECT target, t.key, t.value
+			FROM unnest($1::text[]) AS target
+			CROSS JOIN unnest($2::text[], $3::text[]) AS t(key, value)
+			ON CONFLICT (mac_address, key) DO UPDATE SET value = EXCLUDED.value
+		`
+		if _, err := tx.Exec(ctx, query, macAddresses, keys, values); err != nil {
+			return fmt.Errorf("failed to tag devices: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveTags removes tag keys from every given entity and device in one transaction,
+// returning how many tags were removed
+func (r *TagRepository) RemoveTags(ctx context.Context, entityIds []string, macAddresses []string, keys []string) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var removed int64
+	if len(entityIds) > 0 {
+		result, err := tx.Exec(ctx, `DELETE FROM z_entity_tag WHERE entity_id = ANY($1::uuid[]) AND key = ANY($2)`, entityIds, keys)
+		if err != nil {
+			return 0, fmt.Errorf("failed to untag entities: %w", err)
+		}
+		removed += result.RowsAffected()
+	}
+
+	if len(macAddresses) > 0 {
+		result, err := tx.Exec(ctx, `DELETE FROM z_device_tag WHERE mac_address = ANY($1) AND key = ANY($2)`, macAddresses, keys)
+		if err != nil {
+			return 0, fmt.Errorf("failed to untag devices: %w", err)
+		}
+		removed += result.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return removed, nil
+}
+
+// GetEntityTags returns the tags of each given entity as key/value maps, keyed by entity ID.
+// Entities without tags are left out.
+func (r *TagRepository) GetEntityTags(ctx context.Context, entityIds []string) (map[string]map[string]string, error) {
+	query := `SELECT entity_id::text, key, value FROM z_entity_tag WHERE entity_id = ANY($1::uuid[])`
+	return r.queryTags(ctx, query, entityIds)
+}
+
+// GetDeviceTags returns the tags of each given device as key/value maps, keyed by MAC address.
+// Devices without tags are left out.
+func (r *TagRepository) GetDeviceTags(ctx context.Context, macAddresses []string) (map[string]map[string]string, error) {
+	query := `SELECT mac_address, key, value FROM z_device_tag WHERE mac_address = ANY($1)`
+	return r.queryTags(ctx, query, macAddresses)
+}
+
+// queryTags runs a query selecting owner, key and value and groups the tags by owner
+func (r *TagRepository) queryTags(ctx context.Context, query string, owners []string) (map[string]map[string]string, error) {
+	tags := make(map[string]map[string]string)
+	if len(owners) == 0 {
+		return tags, nil
+	}
+
+	rows, err := r.db.Query(ctx, query, owners)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var owner, key, value string
+		if err := rows.Scan(&owner, &key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan tag row: %w", err)
+		}
+		if tags[owner] == nil {
+			tags[owner] = make(map[string]string)
+		}
+		tags[owner][key] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag rows: %w", err)
+	}
+
+	return tags, nil
+}
+
+// visibleTagsSQL selects the tags on the live entities user $1 can reach and on their own live devices
+const visibleTagsSQL = `
+	SELECT t.key, t.value
+	FROM z_entity_tag t
+	JOIN z_entity e ON e.entity_id = t.entity_id
+	WHERE e.deleted_at IS NULL AND ` + entityAccessibleSQL + `
+	UNION ALL
+	SELECT t.key, t.value
+	FROM z_device_tag t
+	JOIN z_device d ON d.mac_address = t.mac_address
+	WHERE d.user_id = $1 AND d.deleted_at IS NULL
+`
+
+// SuggestTagKeys lists the tag keys starting with prefix that the user can see, most used first
+func (r *TagRepository) SuggestTagKeys(ctx context.Context, userId string, prefix string, limit int) ([]*domain.TagSuggestion, error) {
+	query := `
+		SELECT key, count(*)
+		FROM (` + visibleTagsSQL + `) tags
+		WHERE key LIKE $2 || '%'
+		GROUP BY key
+		ORDER BY count(*) DESC, key
+		LIMIT $3
+	`
+	return r.querySuggestions(ctx, query, userId, likeEscaper.Replace(prefix), limit)
+}
+
+// SuggestTagValues lists the values of a tag key starting with prefix that the user can see,
+// most used first. The empty value of plain labels is not suggested.
+func (r *TagRepository) SuggestTagValues(ctx context.Context, userId string, key string, prefix string, limit int) ([]*domain.TagSuggestion, error) {
+	query := `
+		SELECT value, count(*)
+		FROM (` + visibleTagsSQL + `) tags
+		WHERE key = $2 AND value <> '' AND value LIKE $3 || '%'
+		GROUP BY value
+		ORDER BY count(*) DESC, value
+		LIMIT $4
+	`
+	return r.querySuggestions(ctx, query, userId, key, likeEscaper.Replace(prefix), limit)
+}
+
+// querySuggestions runs a query selecting suggestion text and count
+func (r *TagRepository) querySuggestions(ctx context.Context, query string, args ...any) ([]*domain.TagSuggestion, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := make([]*domain.TagSuggestion, 0)
+	for rows.Next() {
+		suggestion := new(domain.TagSuggestion)
+		if err := rows.Scan(&suggestion.Text, &suggestion.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag suggestion row: %w", err)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag suggestion rows: %w", err)
+	}
+
+	return suggestions, nil
+}
+
+// tagFilterConditions restricts rows to those carrying every tag in filters. table and column
+// name the tag table and its owner column, matched against owner in the outer query.
+func tagFilterConditions(arg func(any) string, table, column, owner string, filters []domain.TagFilter) []string {
+	conditions := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		condition := fmt.Sprintf("EXISTS (SELECT 1 FROM %s t WHERE t.%s = %s AND t.key = %s",
+			table, column, owner, arg(filter.Key))
+		if filter.Value != nil {
+			condition += " AND t.value = " + arg(*filter.Value)
+		}
+		conditions = append(conditions, condition+")")
+	}
+	return conditions
+}
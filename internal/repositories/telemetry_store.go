@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// Supported telemetry storage backends
+const (
+	DynamoTelemetryBackend   = "dynamodb"
+	PostgresTelemetryBackend = "postgres"
+)
+
+// TelemetryStore abstracts where device sensor readings are stored.
+// Timestamps are Unix milliseconds and ranges are inclusive on both ends.
+type TelemetryStore interface {
+	// WriteReadings stores readings, replacing any existing reading with the same device and timestamp
+	WriteReadings(ctx context.Context, readings []*domain.SensorReading) error
+	// QueryRange pages through a device's readings in ascending timestamp order,
+	// handing each page to fn as soon as it is read
+	QueryRange(ctx context.Context, macID string, startTime, endTime int64, fn func([]*domain.SensorReading) error) error
+	// Aggregate summarizes a device's readings in fixed-width buckets aligned to the Unix epoch
+	Aggregate(ctx context.Context, macID string, startTime, endTime int64, interval time.Duration) ([]*domain.SensorAggregate, error)
+	// DeleteReadings removes every reading of a device
+	DeleteReadings(ctx context.Context, macID string) error
+}
+
+// aggregateAccumulator computes bucket statistics in memory for stores without native aggregation
+type aggregateAccumulator struct {
+	interval time.Duration
+	buckets  []*domain.SensorAggregate
+	sums     []aggregateSums
+}
+
+type aggregateSums struct {
+	amperage, temperature, humidity    float64
+	amperageN, temperatureN, humidityN int64
+}
+
+func newAggregateAccumulator(interval time.Duration) *aggregateAccumulator {
+	return &aggregateAccumulator{interval: interval}
+}
+
+// add folds readings into their buckets; readings must arrive in ascending time order
+func (a *aggregateAccumulator) add(readings []*domain.SensorReading) {
+	for _, reading := range readings {
+		bucketStart := truncateToEpoch(reading.Timestamp, a.interval)
+		if len(a.buckets) == 0 || !a.buckets[len(a.buckets)-1].BucketStart.Equal(bucketStart) {
+			a.buckets = append(a.buckets, &domain.SensorAggregate{
+				DeviceID:    reading.DeviceID,
+				BucketStart: bucketStart,
+				MinAmperage: math.Inf(1),
+				MaxAmperage: math.Inf(-1),
+			})
+			a.sums = append(a.sums, aggregateSums{})
+		}
+
+		bucket := a.buckets[len(a.buckets)-1]
+		sums := &a.sums[len(a.sums)-1]
+		bucket.Count++
+
+		if v, err := strconv.ParseFloat(reading.Amperage, 64); err == nil {
+			sums.amperage += v
+			sums.amperageN++
+			bucket.MinAmperage = math.Min(bucket.MinAmperage, v)
+			bucket.MaxAmperage = math.Max(bucket.MaxAmperage, v)
+		}
+		if v, err := strconv.ParseFloat(reading.Temperature, 64); err == nil {
+			sums.temperature += v
+			sums.temperatureN++
+		}
+		if v, err := strconv.ParseFloat(reading.Humidity, 64); err == nil {
+			sums.humidity += v
+			sums.humidityN++
+		}
+	}
+}
+
+// result finalizes averages and returns the buckets
+func (a *aggregateAccumulator) result() []*domain.SensorAggregate {
+	for i, bucket := range a.buckets {
+		sums := a.sums[i]
+		if sums.amperageN > 0 {
+			bucket.AvgAmperage = sums.amperage / float64(sums.amperageN)
+		} else {
+			bucket.MinAmperage, bucket.MaxAmperage = 0, 0
+		}
+		if sums.temperatureN > 0 {
+			bucket.AvgTemperature = sums.temperature / float64(sums.temperatureN)
+		}
+		if sums.humidityN > 0 {
+			bucket.AvgHumidity = sums.humidity / float64(sums.humidityN)
+		}
+	}
+	return a.buckets
+}
+
+// truncateToEpoch rounds t down to a multiple of interval since the Unix epoch
+func truncateToEpoch(t time.Time, interval time.Duration) time.Time {
+	ms := t.UnixMilli()
+	step := interval.Milliseconds()
+	offset := ms % step
+	if offset < 0 {
+		offset += step
+	}
+	return time.UnixMilli(ms - offset)
+}
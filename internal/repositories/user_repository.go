@@ -5,14 +5,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"n1h41/zolaris-backend-app/internal/domain"
 )
 
+var (
+	// ErrReferralCycle is returned when a referral would make a user their own referrer,
+	// directly or through the users they referred
+	ErrReferralCycle = errors.New("referral would create a cycle")
+	// ErrEmailInUse is returned when creating a user with an email another user already has
+	ErrEmailInUse = errors.New("email is already in use")
+	// ErrUserDeleted is returned when provisioning a Cognito identity whose user has been deleted
+	ErrUserDeleted = errors.New("user has been deleted")
+	// ErrDuplicateChildInvitation is returned when a parent invites an email they already invited
+	ErrDuplicateChildInvitation = errors.New("email has already been invited")
+	// ErrParentAlreadySet is returned when a user who already has a parent accepts a child invitation
+	ErrParentAlreadySet = errors.New("user already has a parent account")
+	// ErrParentCycle is returned when accepting a child invitation would make a user their own ancestor
+	ErrParentCycle = errors.New("parent would create a cycle")
+)
+
 // UserRepository handles all user-related database operations with PostgreSQL
 type UserRepository struct {
 	db *pgxpool.Pool
@@ -28,7 +46,7 @@ func NewUserRepository(dbPool *pgxpool.Pool) UserRepositoryInterface {
 func (r *UserRepository) GetUserIdByCognitoId(ctx context.Context, cId string) (string, error) {
 	var userId string
 
-	query := `select user_id from z_users where cognito_id = $1`
+	query := `select user_id from z_users where cognito_id = $1 and deleted_at is null`
 
 	if err := r.db.QueryRow(ctx, query, cId).Scan(&userId); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -40,13 +58,33 @@ func (r *UserRepository) GetUserIdByCognitoId(ctx context.Context, cId string) (
 	return userId, nil
 }
 
+// GetIdentityByCognitoId resolves a Cognito ID to the user's ID, role and whether their
+// account is disabled, returning nil if no user has the Cognito ID
+func (r *UserRepository) GetIdentityByCognitoId(ctx context.Context, cId string) (*domain.UserIdentity, error) {
+	query := `
+		SELECT user_id, role, disabled_at IS NOT NULL
+		FROM z_users
+		WHERE cognito_id = $1 AND deleted_at IS NULL
+	`
+
+	identity := new(domain.UserIdentity)
+	if err := r.db.QueryRow(ctx, query, cId).Scan(&identity.UserID, &identity.Role, &identity.Disabled); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user identity by Cognito ID: %w", err)
+	}
+
+	return identity, nil
+}
+
 // GetUserByID retrieves a user by ID from PostgreSQL
 func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*domain.User, error) {
 	query := `
 		SELECT user_id, email, first_name, last_name, phone, 
-		       address, parent_id, created_at, updated_at
+		       address, parent_id, referred_by, disabled_at, created_at, updated_at
 		FROM z_users 
-		WHERE user_id = $1
+		WHERE user_id = $1 AND deleted_at IS NULL
 	`
 
 	row := r.db.QueryRow(ctx, query, userID)
@@ -63,6 +101,8 @@ func (r *UserRepository) GetUserByID(ctx context.Context, userID string) (*domai
 		&user.Phone,
 		&addressJSON,
 		&parentID,
+		&user.ReferredBy,
+		&user.DisabledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -113,6 +153,9 @@ func (r *UserRepository) CreateUser(ctx context.Context, user *domain.User) erro
 		user.UpdatedAt,
 	)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailInUse
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -134,7 +177,7 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *domain.User) erro
 			phone = $3,
 			address = $4,
 			updated_at = $5
-		WHERE user_id = $6
+		WHERE user_id = $6 AND deleted_at IS NULL
 	`
 
 	// Update the timestamp
@@ -162,34 +205,28 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *domain.User) erro
 	return nil
 }
 
-// CheckHasParentID checks if a user has a parent ID in PostgreSQL
-func (r *UserRepository) CheckHasParentID(ctx context.Context, userID string) (bool, error) {
-	query := `
-		SELECT 
-			CASE WHEN parent_id IS NULL THEN false ELSE true END 
-		FROM z_users 
-		WHERE user_id = $1
-	`
+// GetUserRole retrieves a user's role, returning an empty role if the user does not exist
+func (r *UserRepository) GetUserRole(ctx context.Context, userID string) (domain.UserRole, error) {
+	query := `SELECT role FROM z_users WHERE user_id = $1 AND deleted_at IS NULL`
 
-	var hasParent bool
-	err := r.db.QueryRow(ctx, query, userID).Scan(&hasParent)
-	if err != nil {
+	var role string
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&role); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return false, fmt.Errorf("user not found: %w", err)
+			return "", nil
 		}
-		return false, fmt.Errorf("database error: %w", err)
+		return "", fmt.Errorf("failed to get user role: %w", err)
 	}
 
-	return hasParent, nil
+	return domain.UserRole(role), nil
 }
 
 // GetUserByEmail retrieves a user by their email address
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	query := `
 		SELECT user_id, email, first_name, last_name, phone, 
-		       address, parent_id, created_at, updated_at
+		       address, parent_id, referred_by, disabled_at, created_at, updated_at
 		FROM z_users 
-		WHERE email = $1
+		WHERE email = $1 AND deleted_at IS NULL
 	`
 
 	row := r.db.QueryRow(ctx, query, email)
@@ -206,6 +243,8 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*dom
 		&user.Phone,
 		&addressJSON,
 		&parentID,
+		&user.ReferredBy,
+		&user.DisabledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -231,9 +270,10 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*dom
 func (r *UserRepository) GetChildUsers(ctx context.Context, parentID string) ([]*domain.User, error) {
 	query := `
 		SELECT user_id, email, first_name, last_name, phone, 
-		       address, parent_id, created_at, updated_at
+		       address, parent_id, referred_by, disabled_at, created_at, updated_at
 		FROM z_users 
-		WHERE parent_id = $1
+		WHERE parent_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at, user_id
 	`
 
 	rows, err := r.db.Query(ctx, query, parentID)
@@ -242,7 +282,7 @@ func (r *UserRepository) GetChildUsers(ctx context.Context, parentID string) ([]
 	}
 	defer rows.Close()
 
-	var users []*domain.User
+	users := make([]*domain.User, 0)
 	for rows.Next() {
 		user := &domain.User{}
 		var addressJSON []byte
@@ -256,6 +296,8 @@ func (r *UserRepository) GetChildUsers(ctx context.Context, parentID string) ([]
 			&user.Phone,
 			&addressJSON,
 			&parentID,
+			&user.ReferredBy,
+			&user.DisabledAt,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -281,48 +323,1200 @@ func (r *UserRepository) GetChildUsers(ctx context.Context, parentID string) ([]
 	return users, nil
 }
 
-func (r *UserRepository) ListReferredUsers(ctx context.Context, userID string) ([]*domain.User, error) {
+// IsUserAncestor reports whether ancestorID is above userID in the parent chain: the user's
+// parent, the parent's parent and so on
+func (r *UserRepository) IsUserAncestor(ctx context.Context, ancestorID, userID string) (bool, error) {
 	query := `
-		SELECT user_id, email, first_name, last_name, phone, 
-		       address, parent_id, created_at, updated_at
+		WITH RECURSIVE chain AS (
+			SELECT parent_id FROM z_users WHERE user_id = $2 AND deleted_at IS NULL
+			UNION
+			SELECT u.parent_id
+			FROM z_users u
+			JOIN chain c ON u.user_id = c.parent_id
+			WHERE u.deleted_at IS NULL
+		)
+		SELECT EXISTS (SELECT 1 FROM chain WHERE parent_id = $1)
+	`
+
+	var isAncestor bool
+	if err := r.db.QueryRow(ctx, query, ancestorID, userID).Scan(&isAncestor); err != nil {
+		return false, fmt.Errorf("failed to check user ancestry: %w", err)
+	}
+
+	return isAncestor, nil
+}
+
+// childInvitationColumns selects a child invitation with its parent, from z_child_invitation i joined to z_users p
+const childInvitationColumns = `
+	i.invitation_id, i.parent_id, p.email, p.first_name, p.last_name,
+	i.email, i.first_name, i.last_name, i.phone, i.created_at
+`
+
+// CreateChildInvitation records an invitation for the user with the invitation's email to
+// become a sub-account of its parent, setting the invitation's ID and creation time
+func (r *UserRepository) CreateChildInvitation(ctx context.Context, invitation *domain.ChildInvitation) error {
+	query := `
+		INSERT INTO z_child_invitation (parent_id, email, first_name, last_name, phone)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING invitation_id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query, invitation.ParentID, invitation.Email, invitation.FirstName, invitation.LastName, invitation.Phone).
+		Scan(&invitation.ID, &invitation.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateChildInvitation
+		}
+		return fmt.Errorf("failed to create child invitation: %w", err)
+	}
+
+	return nil
+}
+
+// GetChildInvitation retrieves a child invitation, returning nil if there is none
+func (r *UserRepository) GetChildInvitation(ctx context.Context, invitationID string) (*domain.ChildInvitation, error) {
+	query := `SELECT ` + childInvitationColumns + ` FROM z_child_invitation i JOIN z_users p ON p.user_id = i.parent_id WHERE i.invitation_id = $1`
+
+	invitation, err := scanChildInvitation(r.db.QueryRow(ctx, query, invitationID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get child invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// ListChildInvitationsByParent retrieves the invitations a parent sent that were not accepted yet, oldest first
+func (r *UserRepository) ListChildInvitationsByParent(ctx context.Context, parentID string) ([]*domain.ChildInvitation, error) {
+	query := `
+		SELECT ` + childInvitationColumns + `
+		FROM z_child_invitation i
+		JOIN z_users p ON p.user_id = i.parent_id
+		WHERE i.parent_id = $1
+		ORDER BY i.created_at, i.email
+	`
+
+	return r.queryChildInvitations(ctx, query, parentID)
+}
+
+// ListChildInvitationsForEmail retrieves the invitations waiting for the user with email,
+// from parents that still exist, oldest first
+func (r *UserRepository) ListChildInvitationsForEmail(ctx context.Context, email string) ([]*domain.ChildInvitation, error) {
+	query := `
+		SELECT ` + childInvitationColumns + `
+		FROM z_child_invitation i
+		JOIN z_users p ON p.user_id = i.parent_id
+		WHERE lower(i.email) = lower($1) AND p.deleted_at IS NULL
+		ORDER BY i.created_at
+	`
+
+	return r.queryChildInvitations(ctx, query, email)
+}
+
+// AcceptChildInvitation makes the user a sub-account of the invitation's parent if the
+// invitation was sent to the user's email, filling gaps in their profile from it. Every
+// invitation for the email is then withdrawn, since a user has one parent. Returns false if
+// there was no such invitation for the user.
+func (r *UserRepository) AcceptChildInvitation(ctx context.Context, invitationID, userID string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		email      string
+		invitation domain.ChildInvitation
+	)
+	lockQuery := `
+		SELECT u.email, i.parent_id, i.first_name, i.last_name, i.phone
+		FROM z_child_invitation i
+		JOIN z_users u ON lower(u.email) = lower(i.email)
+		JOIN z_users p ON p.user_id = i.parent_id
+		WHERE i.invitation_id = $1 AND u.user_id = $2
+			AND u.deleted_at IS NULL AND p.deleted_at IS NULL
+		FOR UPDATE OF i, u
+	`
+	err = tx.QueryRow(ctx, lockQuery, invitationID, userID).
+		Scan(&email, &invitation.ParentID, &invitation.FirstName, &invitation.LastName, &invitation.Phone)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to lock child invitation: %w", err)
+	}
+
+	updateQuery := `
+		UPDATE z_users SET
+			parent_id = $2,
+			first_name = COALESCE(first_name, $3),
+			last_name = COALESCE(last_name, $4),
+			phone = COALESCE(phone, $5),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND parent_id IS NULL
+	`
+	result, err := tx.Exec(ctx, updateQuery, userID, invitation.ParentID, invitation.FirstName, invitation.LastName, invitation.Phone)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (pgErr.ConstraintName == "z_users_parent_cycle" || pgErr.ConstraintName == "z_users_no_self_parent") {
+			return false, ErrParentCycle
+		}
+		return false, fmt.Errorf("failed to set parent: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, ErrParentAlreadySet
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM z_child_invitation WHERE lower(email) = lower($1)`, email); err != nil {
+		return false, fmt.Errorf("failed to withdraw child invitations: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteChildInvitation withdraws or declines a child invitation, returning false if there was none
+func (r *UserRepository) DeleteChildInvitation(ctx context.Context, invitationID string) (bool, error) {
+	result, err := r.db.Exec(ctx, `DELETE FROM z_child_invitation WHERE invitation_id = $1`, invitationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete child invitation: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *UserRepository) queryChildInvitations(ctx context.Context, query string, args ...any) ([]*domain.ChildInvitation, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query child invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := make([]*domain.ChildInvitation, 0)
+	for rows.Next() {
+		invitation, err := scanChildInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan child invitation row: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating child invitation rows: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// scanChildInvitation scans a row selected with childInvitationColumns
+func scanChildInvitation(row pgx.Row) (*domain.ChildInvitation, error) {
+	invitation := new(domain.ChildInvitation)
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.ParentID,
+		&invitation.ParentEmail,
+		&invitation.ParentFirstName,
+		&invitation.ParentLastName,
+		&invitation.Email,
+		&invitation.FirstName,
+		&invitation.LastName,
+		&invitation.Phone,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// SetUserDisabled disables or re-enables a user's account. Disabling an account that is
+// already disabled keeps its original disabled_at. Returns false if there was no such user.
+func (r *UserRepository) SetUserDisabled(ctx context.Context, userID string, disabled bool) (bool, error) {
+	query := `
+		UPDATE z_users SET
+			disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, CURRENT_TIMESTAMP) END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, disabled)
+	if err != nil {
+		return false, fmt.Errorf("failed to update user status: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// SetReferrer records who referred a user. A user's referrer is only ever set once;
+// returns false if the user does not exist or already has one.
+func (r *UserRepository) SetReferrer(ctx context.Context, userID, referrerID string) (bool, error) {
+	query := `
+		UPDATE z_users SET referred_by = $2, referred_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND deleted_at IS NULL AND referred_by IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, referrerID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (pgErr.ConstraintName == "z_users_referral_cycle" || pgErr.ConstraintName == "z_users_no_self_referral") {
+			return false, ErrReferralCycle
+		}
+		return false, fmt.Errorf("failed to set referrer: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// referralTreeSQL selects the live users referred by user $1, directly or through the users
+// they referred, down to $2 levels
+const referralTreeSQL = `
+	WITH RECURSIVE tree AS (
+		SELECT user_id, 1 AS depth
 		FROM z_users
-		WHERE referral_mail = (
-        SELECT
-            email
-        FROM
-            z_users
-        WHERE
-            user_id = $1
-		);
+		WHERE referred_by = $1 AND deleted_at IS NULL
+		UNION ALL
+		SELECT u.user_id, t.depth + 1
+		FROM z_users u
+		JOIN tree t ON u.referred_by = t.user_id
+		WHERE u.deleted_at IS NULL AND t.depth < $2
+	)
+`
+
+// ListReferralTree lists the users referred by a user down to maxDepth levels, shallowest
+// first and in the order they were referred
+func (r *UserRepository) ListReferralTree(ctx context.Context, userID string, maxDepth int) ([]*domain.ReferralNode, error) {
+	query := referralTreeSQL + `
+		SELECT
+			u.user_id, u.email, u.first_name, u.last_name, u.referred_by,
+			COALESCE(u.referred_at, u.created_at), t.depth,
+			(SELECT count(*) FROM z_users c WHERE c.referred_by = u.user_id AND c.deleted_at IS NULL)
+		FROM tree t
+		JOIN z_users u ON u.user_id = t.user_id
+		ORDER BY t.depth, COALESCE(u.referred_at, u.created_at), u.user_id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, maxDepth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referral tree: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := make([]*domain.ReferralNode, 0)
+	for rows.Next() {
+		node := new(domain.ReferralNode)
+		if err := rows.Scan(
+			&node.UserID,
+			&node.Email,
+			&node.FirstName,
+			&node.LastName,
+			&node.ReferredBy,
+			&node.ReferredAt,
+			&node.Depth,
+			&node.ReferralCount,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan referral row: %w", err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating referral rows: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// GetReferralStats counts the users referred by a user down to maxDepth levels, and buckets
+// their signups and conversions between from and to by interval ("day", "week" or "month")
+func (r *UserRepository) GetReferralStats(ctx context.Context, userID string, maxDepth int, from, to time.Time, interval string) (*domain.ReferralStats, error) {
+	// A referral converts when the referred user registers their first device
+	referred := referralTreeSQL + `,
+	referred AS (
+		SELECT
+			t.depth,
+			COALESCE(u.referred_at, u.created_at) AS signed_up_at,
+			(SELECT min(d.created_at) FROM z_device d WHERE d.user_id = u.user_id AND d.deleted_at IS NULL) AS converted_at
+		FROM tree t
+		JOIN z_users u ON u.user_id = t.user_id
+	)
 	`
 
-	rows, _ := r.db.Query(ctx, query, userID)
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("database error: %w", rows.Err())
+	stats := &domain.ReferralStats{Buckets: make([]*domain.ReferralBucket, 0)}
+	totalsQuery := referred + `
+		SELECT
+			count(*) FILTER (WHERE depth = 1),
+			count(*),
+			count(converted_at)
+		FROM referred
+	`
+	if err := r.db.QueryRow(ctx, totalsQuery, userID, maxDepth).Scan(&stats.Direct, &stats.Total, &stats.Converted); err != nil {
+		return nil, fmt.Errorf("failed to count referrals: %w", err)
 	}
 
+	bucketsQuery := referred + `,
+	buckets AS (
+		SELECT bucket, bucket + ('1 ' || $5)::interval AS bucket_end
+		FROM generate_series(date_trunc($5, $3::timestamptz), $4::timestamptz, ('1 ' || $5)::interval) AS bucket
+		WHERE bucket < $4
+	)
+	SELECT
+		b.bucket,
+		count(*) FILTER (WHERE r.signed_up_at >= b.bucket AND r.signed_up_at < b.bucket_end
+			AND r.signed_up_at >= $3 AND r.signed_up_at < $4),
+		count(*) FILTER (WHERE r.converted_at >= b.bucket AND r.converted_at < b.bucket_end
+			AND r.converted_at >= $3 AND r.converted_at < $4)
+	FROM buckets b
+	LEFT JOIN referred r ON TRUE
+	GROUP BY b.bucket
+	ORDER BY b.bucket
+	`
+
+	rows, err := r.db.Query(ctx, bucketsQuery, userID, maxDepth, from, to, interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referral buckets: %w", err)
+	}
 	defer rows.Close()
 
-	var users []*domain.User
 	for rows.Next() {
-		user := &domain.User{}
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.FirstName,
-			&user.LastName,
-			&user.Phone,
-			&user.Address,
-			&user.ParentID,
-			&user.CreatedAt,
-			&user.UpdatedAt,
+		bucket := new(domain.ReferralBucket)
+		if err := rows.Scan(&bucket.Start, &bucket.Signups, &bucket.Conversions); err != nil {
+			return nil, fmt.Errorf("failed to scan referral bucket row: %w", err)
+		}
+		stats.Buckets = append(stats.Buckets, bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating referral bucket rows: %w", err)
+	}
+
+	return stats, nil
+}
+
+// SoftDeleteUser hides a user, their devices and their entity subtree until they are restored
+// or purged. Everything is stamped with the same deleted_at so RestoreUser can reverse exactly
+// this deletion. Returns false if there was no such user.
+func (r *UserRepository) SoftDeleteUser(ctx context.Context, userID string) (bool, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// CURRENT_TIMESTAMP is fixed for the transaction, so every row gets the same deleted_at
+	result, err := tx.Exec(ctx, `UPDATE z_users SET deleted_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND deleted_at IS NULL`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE z_device SET deleted_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND deleted_at IS NULL`, userID); err != nil {
+		return false, fmt.Errorf("failed to delete user devices: %w", err)
+	}
+
+	entityQuery := `
+		UPDATE z_entity SET deleted_at = CURRENT_TIMESTAMP
+		WHERE path <@ ARRAY(SELECT path FROM z_entity WHERE user_id = $1 AND deleted_at IS NULL)
+			AND deleted_at IS NULL
+	`
+	if _, err := tx.Exec(ctx, entityQuery, userID); err != nil {
+		return false, fmt.Errorf("failed to delete user entities: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// RestoreUser restores a soft-deleted user with the devices and entities deleted with them.
+// Returns false if the user does not exist, ErrNotDeleted if they were never deleted and
+// ErrRestoreBlocked if the parent of their entity is still deleted.
+func (r *UserRepository) RestoreUser(ctx context.Context, userID string) (bool, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt *time.Time
+	lockQuery := `SELECT deleted_at FROM z_users WHERE user_id = $1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, lockQuery, userID).Scan(&deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+	if deletedAt == nil {
+		return false, ErrNotDeleted
+	}
+
+	var blocked bool
+	blockedQuery := `
+		SELECT EXISTS(
+			SELECT 1
+			FROM z_entity e
+			JOIN z_entity p ON p.entity_id = e.parent_id
+			WHERE e.user_id = $1 AND p.deleted_at IS NOT NULL AND p.deleted_at <> $2
 		)
+	`
+	if err := tx.QueryRow(ctx, blockedQuery, userID, *deletedAt).Scan(&blocked); err != nil {
+		return false, fmt.Errorf("failed to check user entity: %w", err)
+	}
+	if blocked {
+		return false, ErrRestoreBlocked
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE z_users SET deleted_at = NULL WHERE user_id = $1`, userID); err != nil {
+		return false, fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE z_device SET deleted_at = NULL WHERE user_id = $1 AND deleted_at = $2`, userID, *deletedAt); err != nil {
+		return false, fmt.Errorf("failed to restore user devices: %w", err)
+	}
+
+	entityQuery := `
+		UPDATE z_entity SET deleted_at = NULL
+		WHERE path <@ ARRAY(SELECT path FROM z_entity WHERE user_id = $1 AND deleted_at = $2)
+			AND deleted_at = $2
+	`
+	if _, err := tx.Exec(ctx, entityQuery, userID, *deletedAt); err != nil {
+		return false, fmt.Errorf("failed to restore user entities: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListDeletedUsers retrieves every soft-deleted user, most recently deleted first
+func (r *UserRepository) ListDeletedUsers(ctx context.Context) ([]*domain.DeletedRecord, error) {
+	query := `
+		SELECT user_id::text, email, deleted_at
+		FROM z_users
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, email
+	`
+
+	return queryDeletedRecords(ctx, r.db, query, domain.DeletedKindUser)
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted before cutoff, returning how many
+// were removed. Their remaining devices, entities and memberships go with them; child users
+// are detached from a purged parent.
+func (r *UserRepository) PurgeDeletedUsers(ctx context.Context, cutoff time.Time) (int64, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	detachQuery := `
+		UPDATE z_users SET parent_id = NULL
+		WHERE parent_id IN (SELECT user_id FROM z_users WHERE deleted_at < $1)
+	`
+	if _, err := tx.Exec(ctx, detachQuery, cutoff); err != nil {
+		return 0, fmt.Errorf("failed to detach child users: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM z_users WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// userAccountColumnsSQL selects a user account from z_users aliased u, in the order scanUserAccount reads
+const userAccountColumnsSQL = `
+	u.user_id, u.email, u.first_name, u.last_name, u.phone, u.address, u.parent_id,
+	u.referred_by, u.disabled_at, u.created_at, u.updated_at, u.role, u.cognito_id IS NOT NULL
+`
+
+// scanUserAccount reads a row selected with userAccountColumnsSQL
+func scanUserAccount(row pgx.Row) (*domain.UserAccount, error) {
+	account := new(domain.UserAccount)
+	var addressJSON []byte
+	if err := row.Scan(
+		&account.ID,
+		&account.Email,
+		&account.FirstName,
+		&account.LastName,
+		&account.Phone,
+		&addressJSON,
+		&account.ParentID,
+		&account.ReferredBy,
+		&account.DisabledAt,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+		&account.Role,
+		&account.SignedUp,
+	); err != nil {
+		return nil, err
+	}
+
+	if len(addressJSON) > 0 && string(addressJSON) != "null" {
+		if err := json.Unmarshal(addressJSON, &account.Address); err != nil {
+			return nil, fmt.Errorf("failed to parse address JSON: %w", err)
+		}
+	}
+
+	return account, nil
+}
+
+// GetUserAccount retrieves a live user with their role and sign-up status, or nil if there is none
+func (r *UserRepository) GetUserAccount(ctx context.Context, userID string) (*domain.UserAccount, error) {
+	query := `SELECT ` + userAccountColumnsSQL + ` FROM z_users u WHERE u.user_id = $1 AND u.deleted_at IS NULL`
+
+	account, err := scanUserAccount(r.db.QueryRow(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user account: %w", err)
+	}
+
+	return account, nil
+}
+
+// SearchUsers retrieves a page of live users matching the filter, newest first, and the
+// total number of matches
+func (r *UserRepository) SearchUsers(ctx context.Context, filter domain.UserSearchFilter) ([]*domain.UserAccount, int64, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"u.deleted_at IS NULL"}
+	if filter.Query != "" {
+		pattern := arg("%" + likeEscaper.Replace(filter.Query) + "%")
+		conditions = append(conditions, fmt.Sprintf(
+			"(u.email ILIKE %[1]s OR concat_ws(' ', u.first_name, u.last_name) ILIKE %[1]s OR u.user_id::text = %[2]s)",
+			pattern, arg(filter.Query)))
+	}
+	if filter.Role != "" {
+		conditions = append(conditions, "u.role = "+arg(string(filter.Role))+"::user_role")
+	}
+	switch filter.Status {
+	case "active":
+		conditions = append(conditions, "u.disabled_at IS NULL")
+	case "disabled":
+		conditions = append(conditions, "u.disabled_at IS NOT NULL")
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int64
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM z_users u WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `SELECT ` + userAccountColumnsSQL + ` FROM z_users u WHERE ` + where +
+		` ORDER BY u.created_at DESC, u.user_id LIMIT ` + arg(filter.Limit) + ` OFFSET ` + arg(filter.Offset)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := make([]*domain.UserAccount, 0)
+	for rows.Next() {
+		account, err := scanUserAccount(rows)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning user row: %w", err)
+			return nil, 0, fmt.Errorf("failed to scan user row: %w", err)
 		}
+		accounts = append(accounts, account)
+	}
 
-		users = append(users, user)
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating user rows: %w", err)
 	}
 
-	return users, nil
+	return accounts, total, nil
+}
+
+// GetIdentityByUserID returns a live user's ID, role and disabled status, or nil if there is none
+func (r *UserRepository) GetIdentityByUserID(ctx context.Context, userID string) (*domain.UserIdentity, error) {
+	query := `
+		SELECT user_id, role, disabled_at IS NOT NULL
+		FROM z_users
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`
+
+	identity := new(domain.UserIdentity)
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&identity.UserID, &identity.Role, &identity.Disabled); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+// GetCognitoIdByUserID returns the Cognito ID a user signs in with, including for deleted
+// users, or "" if there is no such user or they have no Cognito identity
+func (r *UserRepository) GetCognitoIdByUserID(ctx context.Context, userID string) (string, error) {
+	var cognitoID *string
+	if err := r.db.QueryRow(ctx, `SELECT cognito_id FROM z_users WHERE user_id = $1`, userID).Scan(&cognitoID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get Cognito ID by user ID: %w", err)
+	}
+
+	if cognitoID == nil {
+		return "", nil
+	}
+	return *cognitoID, nil
+}
+
+// SetUserRole changes a user's role, returning false if there was no such user
+func (r *UserRepository) SetUserRole(ctx context.Context, userID string, role domain.UserRole) (bool, error) {
+	query := `
+		UPDATE z_users SET role = $2::user_role, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, string(role))
+	if err != nil {
+		return false, fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// RecordImpersonation logs a request an admin made while impersonating a user
+func (r *UserRepository) RecordImpersonation(ctx context.Context, record *domain.ImpersonationRecord) error {
+	query := `
+		INSERT INTO z_impersonation_log (admin_user_id, user_id, method, path, status, client_ip)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING log_id, requested_at
+	`
+
+	if err := r.db.QueryRow(ctx, query,
+		record.AdminUserID,
+		record.UserID,
+		record.Method,
+		record.Path,
+		record.Status,
+		record.ClientIP,
+	).Scan(&record.ID, &record.RequestedAt); err != nil {
+		return fmt.Errorf("failed to record impersonation: %w", err)
+	}
+
+	return nil
+}
+
+// ListImpersonations retrieves a page of impersonated requests, newest first, optionally
+// only those by one admin or of one user, and the total number of matches
+func (r *UserRepository) ListImpersonations(ctx context.Context, adminUserID, userID string, limit, offset int) ([]*domain.ImpersonationRecord, int64, error) {
+	where := `($1 = '' OR admin_user_id::text = $1) AND ($2 = '' OR user_id::text = $2)`
+
+	var total int64
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM z_impersonation_log WHERE `+where, adminUserID, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count impersonations: %w", err)
+	}
+
+	query := `
+		SELECT log_id, admin_user_id, user_id, method, path, status, COALESCE(client_ip, ''), requested_at
+		FROM z_impersonation_log
+		WHERE ` + where + `
+		ORDER BY log_id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.Query(ctx, query, adminUserID, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query impersonations: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]*domain.ImpersonationRecord, 0)
+	for rows.Next() {
+		record := new(domain.ImpersonationRecord)
+		if err := rows.Scan(
+			&record.ID,
+			&record.AdminUserID,
+			&record.UserID,
+			&record.Method,
+			&record.Path,
+			&record.Status,
+			&record.ClientIP,
+			&record.RequestedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan impersonation row: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating impersonation rows: %w", err)
+	}
+
+	return records, total, nil
+}
+
+// ScheduleUserDeletion records a user's request to have their account erased once the grace
+// period has passed. Asking again keeps the original schedule. Returns nil if there is no such user.
+func (r *UserRepository) ScheduleUserDeletion(ctx context.Context, userID string, gracePeriod time.Duration) (*domain.AccountDeletion, error) {
+	query := `
+		UPDATE z_users SET
+			deletion_requested_at = COALESCE(deletion_requested_at, CURRENT_TIMESTAMP),
+			deletion_scheduled_at = COALESCE(deletion_scheduled_at, CURRENT_TIMESTAMP + $2 * interval '1 millisecond')
+		WHERE user_id = $1 AND deleted_at IS NULL
+		RETURNING user_id, deletion_requested_at, deletion_scheduled_at
+	`
+
+	deletion := new(domain.AccountDeletion)
+	if err := r.db.QueryRow(ctx, query, userID, gracePeriod.Milliseconds()).Scan(
+		&deletion.UserID,
+		&deletion.RequestedAt,
+		&deletion.ScheduledAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to schedule user deletion: %w", err)
+	}
+
+	return deletion, nil
+}
+
+// GetUserDeletion retrieves a user's pending deletion request, or nil if there is none
+func (r *UserRepository) GetUserDeletion(ctx context.Context, userID string) (*domain.AccountDeletion, error) {
+	query := `
+		SELECT user_id, deletion_requested_at, deletion_scheduled_at
+		FROM z_users
+		WHERE user_id = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL
+	`
+
+	deletion := new(domain.AccountDeletion)
+	if err := r.db.QueryRow(ctx, query, userID).Scan(
+		&deletion.UserID,
+		&deletion.RequestedAt,
+		&deletion.ScheduledAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user deletion: %w", err)
+	}
+
+	return deletion, nil
+}
+
+// CancelUserDeletion withdraws a user's pending deletion request, returning false if there was none
+func (r *UserRepository) CancelUserDeletion(ctx context.Context, userID string) (bool, error) {
+	query := `
+		UPDATE z_users SET deletion_requested_at = NULL, deletion_scheduled_at = NULL
+		WHERE user_id = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel user deletion: %w", err)
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// ListDueUserDeletions retrieves up to limit users whose deletion grace period has passed, longest overdue first
+func (r *UserRepository) ListDueUserDeletions(ctx context.Context, limit int) ([]string, error) {
+	query := `
+		SELECT user_id
+		FROM z_users
+		WHERE deletion_scheduled_at <= CURRENT_TIMESTAMP AND deleted_at IS NULL
+		ORDER BY deletion_scheduled_at
+		LIMIT $1
+	`
+
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due user deletions: %w", err)
+	}
+	defer rows.Close()
+
+	userIDs := make([]string, 0)
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan user deletion row: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user deletion rows: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// EraseUser permanently removes a user whose deletion is due, with their devices, entity
+// subtree, export jobs and the child invitations addressed to them, and leaves a tombstone
+// in their place. Entities of other users below theirs are moved out first and become roots,
+// and child accounts are detached. The history of the erased entities is kept for the audit
+// trail, with their PII scrubbed.
+// Returns the tombstone with the storage keys of the removed export files and the MAC
+// addresses of the removed devices, whose telemetry the caller must delete, or nil if the
+// deletion is no longer due, e.g. because it was cancelled.
+func (r *UserRepository) EraseUser(ctx context.Context, userID string) (*domain.UserErasure, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	lockQuery := `
+		SELECT user_id FROM z_users
+		WHERE user_id = $1 AND deletion_scheduled_at <= CURRENT_TIMESTAMP AND deleted_at IS NULL
+		FOR UPDATE
+	`
+	if err := tx.QueryRow(ctx, lockQuery, userID).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	// Moving the shallowest foreign entity takes any foreign entities below it along
+	foreignQuery := `
+		SELECT entity_id FROM z_entity
+		WHERE path <@ ARRAY(SELECT path FROM z_entity WHERE user_id = $1)
+			AND user_id IS NOT NULL AND user_id <> $1 AND deleted_at IS NULL
+		ORDER BY depth
+		LIMIT 1
+	`
+	for {
+		var entityID string
+		if err := tx.QueryRow(ctx, foreignQuery, userID).Scan(&entityID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				break
+			}
+			return nil, fmt.Errorf("failed to find entities of other users: %w", err)
+		}
+		if err := reparentEntity(ctx, tx, entityID, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// History written from here on, for the deletions below, carries no PII
+	if _, err := tx.Exec(ctx, `SELECT set_config('zolaris.erasing', 'on', true)`); err != nil {
+		return nil, fmt.Errorf("failed to suppress entity snapshots: %w", err)
+	}
+
+	// The whole subtree goes in one statement, so the parent foreign key is satisfied when it is checked
+	entityIDs, err := collectStrings(ctx, tx, `DELETE FROM z_entity WHERE path <@ ARRAY(SELECT path FROM z_entity WHERE user_id = $1) RETURNING entity_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase user entities: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT scrub_entity_history($1::uuid[])`, entityIDs); err != nil {
+		return nil, fmt.Errorf("failed to scrub entity history: %w", err)
+	}
+
+	macAddresses, err := collectStrings(ctx, tx, `DELETE FROM z_device WHERE user_id = $1 RETURNING mac_address`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase user devices: %w", err)
+	}
+
+	childResult, err := tx.Exec(ctx, `UPDATE z_users SET parent_id = NULL WHERE parent_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detach child users: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `DELETE FROM z_export_job WHERE user_id = $1 RETURNING storage_key`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase user export jobs: %w", err)
+	}
+	storageKeys := make([]string, 0)
+	for rows.Next() {
+		var storageKey *string
+		if err := rows.Scan(&storageKey); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan export job row: %w", err)
+		}
+		if storageKey != nil {
+			storageKeys = append(storageKeys, *storageKey)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating export job rows: %w", err)
+	}
+
+	// Invitations the user sent go with them by cascade, but those addressed to them are only
+	// tied to their email and carry the personal details the inviting parent entered
+	invitationQuery := `
+		DELETE FROM z_child_invitation
+		WHERE lower(email) = (SELECT lower(email) FROM z_users WHERE user_id = $1)
+	`
+	if _, err := tx.Exec(ctx, invitationQuery, userID); err != nil {
+		return nil, fmt.Errorf("failed to erase child invitations: %w", err)
+	}
+
+	tombstoneQuery := `
+		INSERT INTO z_user_tombstone (user_id, email_hash, requested_at, devices_erased, entities_erased, children_detached)
+		SELECT user_id, encode(sha256(convert_to(lower(email), 'UTF8')), 'hex'), deletion_requested_at, $2, $3, $4
+		FROM z_users
+		WHERE user_id = $1
+		RETURNING user_id, email_hash, requested_at, erased_at, devices_erased, entities_erased, children_detached
+	`
+	tombstone := new(domain.UserTombstone)
+	if err := tx.QueryRow(ctx, tombstoneQuery, userID,
+		len(macAddresses),
+		len(entityIDs),
+		childResult.RowsAffected(),
+	).Scan(
+		&tombstone.UserID,
+		&tombstone.EmailHash,
+		&tombstone.RequestedAt,
+		&tombstone.ErasedAt,
+		&tombstone.DevicesErased,
+		&tombstone.EntitiesErased,
+		&tombstone.ChildrenDetached,
+	); err != nil {
+		return nil, fmt.Errorf("failed to record user tombstone: %w", err)
+	}
+
+	// Memberships cascade and referrals made by the user are cleared by their foreign keys
+	if _, err := tx.Exec(ctx, `DELETE FROM z_users WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &domain.UserErasure{
+		Tombstone:    tombstone,
+		StorageKeys:  storageKeys,
+		MacAddresses: macAddresses,
+	}, nil
+}
+
+// collectStrings runs a query returning a single text column and collects its values
+func collectStrings(ctx context.Context, tx pgx.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+// UpsertCognitoUser makes sure a user exists for the Cognito identity. A user who already has
+// it gets Cognito's attributes: with overwrite they replace the stored ones, without it they only
+// fill in what is missing, and the email only changes when Cognito has verified it. Otherwise a
+// user without a Cognito identity and the same email is linked to it if the email is verified;
+// a child account is only handed over fresh, its parent link left as a pending invitation.
+// Failing that a new user is created. Returns ErrEmailInUse if the
+// email belongs to another user and ErrUserDeleted if the identity's user was deleted.
+func (r *UserRepository) UpsertCognitoUser(ctx context.Context, profile *domain.CognitoProfile, overwrite bool) (string, domain.ProvisionOutcome, error) {
+	tx, err := beginAudited(ctx, r.db)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var verifiedEmail *string
+	if profile.EmailVerified && profile.Email != "" {
+		verifiedEmail = &profile.Email
+	}
+
+	var (
+		userID  string
+		deleted bool
+	)
+	outcome := domain.ProvisionUpdated
+	lockQuery := `SELECT user_id, deleted_at IS NOT NULL FROM z_users WHERE cognito_id = $1 FOR UPDATE`
+	err = tx.QueryRow(ctx, lockQuery, profile.CognitoID).Scan(&userID, &deleted)
+	switch {
+	case err == nil:
+		if deleted {
+			return "", "", ErrUserDeleted
+		}
+
+	case errors.Is(err, pgx.ErrNoRows):
+		userID, outcome, err = r.linkOrCreateCognitoUser(ctx, tx, profile, verifiedEmail != nil)
+		if err != nil {
+			return "", "", err
+		}
+
+	default:
+		return "", "", fmt.Errorf("failed to lock user by Cognito ID: %w", err)
+	}
+
+	if err := r.applyCognitoProfile(ctx, tx, userID, profile, verifiedEmail, overwrite); err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, outcome, nil
+}
+
+// linkOrCreateCognitoUser links the user with the profile's email to the Cognito identity, or
+// creates a user for it if nobody has the email
+func (r *UserRepository) linkOrCreateCognitoUser(ctx context.Context, tx pgx.Tx, profile *domain.CognitoProfile, emailVerified bool) (string, domain.ProvisionOutcome, error) {
+	var (
+		userID    string
+		cognitoID *string
+		parentID  *string
+		deleted   bool
+	)
+	emailQuery := `
+		SELECT user_id, cognito_id, parent_id, deleted_at IS NOT NULL
+		FROM z_users
+		WHERE lower(email) = lower($1)
+		ORDER BY created_at
+		LIMIT 1
+		FOR UPDATE
+	`
+	err := tx.QueryRow(ctx, emailQuery, profile.Email).Scan(&userID, &cognitoID, &parentID, &deleted)
+	if err == nil {
+		// Only a verified email proves the account is theirs
+		if cognitoID != nil || deleted || !emailVerified {
+			return "", "", ErrEmailInUse
+		}
+		if parentID != nil {
+			return r.claimChildPlaceholder(ctx, tx, userID, profile.CognitoID)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE z_users SET cognito_id = $2 WHERE user_id = $1`, userID, profile.CognitoID); err != nil {
+			return "", "", fmt.Errorf("failed to link user to Cognito ID: %w", err)
+		}
+		return userID, domain.ProvisionLinked, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", fmt.Errorf("failed to look up user by email: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO z_users (email, first_name, last_name, phone, cognito_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (cognito_id) DO NOTHING
+		RETURNING user_id
+	`
+	err = tx.QueryRow(ctx, insertQuery, profile.Email, profile.FirstName, profile.LastName, profile.Phone, profile.CognitoID).Scan(&userID)
+	if err == nil {
+		return userID, domain.ProvisionCreated, nil
+	}
+	if isUniqueViolation(err) {
+		return "", "", ErrEmailInUse
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// A concurrent request created the user for the identity after the lookup above
+	if err := tx.QueryRow(ctx, `SELECT user_id FROM z_users WHERE cognito_id = $1`, profile.CognitoID).Scan(&userID); err != nil {
+		return "", "", fmt.Errorf("failed to get user by Cognito ID: %w", err)
+	}
+	return userID, domain.ProvisionUpdated, nil
+}
+
+// claimChildPlaceholder hands a child account created by a parent to the owner of its email
+// as a fresh account. Whoever created it chose its profile and holds parent-chain access to
+// it, neither of which the owner agreed to, so the parent link becomes a pending invitation
+// the owner can accept or decline, and the parent's profile values and disabling are dropped.
+func (r *UserRepository) claimChildPlaceholder(ctx context.Context, tx pgx.Tx, userID, cognitoID string) (string, domain.ProvisionOutcome, error) {
+	invitationQuery := `
+		INSERT INTO z_child_invitation (parent_id, email, first_name, last_name, phone)
+		SELECT parent_id, email, first_name, last_name, phone FROM z_users WHERE user_id = $1
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := tx.Exec(ctx, invitationQuery, userID); err != nil {
+		return "", "", fmt.Errorf("failed to record child invitation: %w", err)
+	}
+
+	claimQuery := `
+		UPDATE z_users SET
+			cognito_id = $2, parent_id = NULL, disabled_at = NULL,
+			first_name = NULL, last_name = NULL, phone = NULL, address = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+	`
+	if _, err := tx.Exec(ctx, claimQuery, userID, cognitoID); err != nil {
+		return "", "", fmt.Errorf("failed to claim child account: %w", err)
+	}
+
+	return userID, domain.ProvisionCreated, nil
+}
+
+// applyCognitoProfile stores Cognito's attributes on the user, leaving the row untouched if
+// nothing changes
+func (r *UserRepository) applyCognitoProfile(ctx context.Context, tx pgx.Tx, userID string, profile *domain.CognitoProfile, verifiedEmail *string, overwrite bool) error {
+	if overwrite && verifiedEmail != nil {
+		var taken bool
+		takenQuery := `SELECT EXISTS(SELECT 1 FROM z_users WHERE lower(email) = lower($1) AND user_id <> $2)`
+		if err := tx.QueryRow(ctx, takenQuery, *verifiedEmail, userID).Scan(&taken); err != nil {
+			return fmt.Errorf("failed to check email: %w", err)
+		}
+		if taken {
+			return ErrEmailInUse
+		}
+	}
+
+	query := `
+		UPDATE z_users SET
+			first_name = COALESCE(first_name, $2),
+			last_name = COALESCE(last_name, $3),
+			phone = COALESCE(phone, $4),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+			AND (first_name, last_name, phone) IS DISTINCT FROM
+				(COALESCE(first_name, $2), COALESCE(last_name, $3), COALESCE(phone, $4))
+	`
+	args := []any{userID, profile.FirstName, profile.LastName, profile.Phone}
+	if overwrite {
+		query = `
+			UPDATE z_users SET
+				first_name = COALESCE($2, first_name),
+				last_name = COALESCE($3, last_name),
+				phone = COALESCE($4, phone),
+				email = COALESCE($5, email),
+				updated_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1
+				AND (first_name, last_name, phone, email) IS DISTINCT FROM
+					(COALESCE($2, first_name), COALESCE($3, last_name), COALESCE($4, phone), COALESCE($5, email))
+		`
+		args = append(args, verifiedEmail)
+	}
+
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		if isUniqueViolation(err) {
+			return ErrEmailInUse
+		}
+		return fmt.Errorf("failed to update user from Cognito: %w", err)
+	}
+
+	return nil
 }
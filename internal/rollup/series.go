@@ -0,0 +1,90 @@
+// Package rollup combines per-device sensor aggregates into series for groups of devices
+package rollup
+
+import (
+	"sort"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+// Point is the combined value of a group of devices for one time bucket
+type Point struct {
+	BucketStart time.Time
+	// DeviceCount is the number of devices that reported in the bucket
+	DeviceCount int
+	SampleCount int64
+	// TotalAmperage sums each device's average amperage, i.e. the group's total current draw
+	TotalAmperage float64
+	// AvgTemperature and AvgHumidity are averaged over all samples in the bucket
+	AvgTemperature float64
+	AvgHumidity    float64
+}
+
+type pointSums struct {
+	point       Point
+	temperature float64
+	humidity    float64
+}
+
+// Series accumulates aggregates from any number of devices into one bucketed series.
+// All aggregates must use the same bucket interval.
+type Series struct {
+	buckets map[int64]*pointSums
+	devices map[string]struct{}
+}
+
+// NewSeries creates an empty series
+func NewSeries() *Series {
+	return &Series{
+		buckets: make(map[int64]*pointSums),
+		devices: make(map[string]struct{}),
+	}
+}
+
+// Add folds one device's aggregates into the series
+func (s *Series) Add(deviceID string, aggregates []*domain.SensorAggregate) {
+	s.devices[deviceID] = struct{}{}
+
+	for _, aggregate := range aggregates {
+		if aggregate.Count == 0 {
+			continue
+		}
+
+		key := aggregate.BucketStart.UnixMilli()
+		sums, ok := s.buckets[key]
+		if !ok {
+			sums = &pointSums{point: Point{BucketStart: aggregate.BucketStart}}
+			s.buckets[key] = sums
+		}
+
+		sums.point.DeviceCount++
+		sums.point.SampleCount += aggregate.Count
+		sums.point.TotalAmperage += aggregate.AvgAmperage
+		sums.temperature += aggregate.AvgTemperature * float64(aggregate.Count)
+		sums.humidity += aggregate.AvgHumidity * float64(aggregate.Count)
+	}
+}
+
+// DeviceCount returns the number of devices added to the series, including silent ones
+func (s *Series) DeviceCount() int {
+	return len(s.devices)
+}
+
+// Points returns the combined series in ascending time order
+func (s *Series) Points() []Point {
+	points := make([]Point, 0, len(s.buckets))
+	for _, sums := range s.buckets {
+		point := sums.point
+		if point.SampleCount > 0 {
+			point.AvgTemperature = sums.temperature / float64(point.SampleCount)
+			point.AvgHumidity = sums.humidity / float64(point.SampleCount)
+		}
+		points = append(points, point)
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].BucketStart.Before(points[j].BucketStart)
+	})
+	return points
+}
@@ -0,0 +1,115 @@
+// Package schema validates entity details against per-category JSON Schemas
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// resourceName is the in-memory location schemas are compiled from
+const resourceName = "details.schema.json"
+
+// FieldError is a validation failure at a location within the details object
+type FieldError struct {
+	// Field is the dotted path of the offending value, e.g. "details.address.city"
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Schema is a compiled JSON Schema for entity details
+type Schema struct {
+	compiled *jsonschema.Schema
+}
+
+// Compile parses and compiles a JSON Schema document. References to external
+// documents are rejected so that validation never reaches out over the network.
+func Compile(raw json.RawMessage) (*Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external schema reference %q is not allowed", url)
+	}
+
+	if err := compiler.AddResource(resourceName, bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	compiled, err := compiler.Compile(resourceName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	return &Schema{compiled: compiled}, nil
+}
+
+// Validate checks details against the schema and returns one error per failing
+// location, or nil if the details conform
+func (s *Schema) Validate(details map[string]any) ([]FieldError, error) {
+	// Round-trip through JSON so values have the types the validator expects
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode details: %w", err)
+	}
+
+	var instance any
+	if err := json.Unmarshal(raw, &instance); err != nil {
+		return nil, fmt.Errorf("failed to decode details: %w", err)
+	}
+	if instance == nil {
+		instance = map[string]any{}
+	}
+
+	err = s.compiled.Validate(instance)
+	if err == nil {
+		return nil, nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	var fieldErrors []FieldError
+	collectLeafErrors(validationErr, &fieldErrors)
+
+	sort.SliceStable(fieldErrors, func(i, j int) bool {
+		return fieldErrors[i].Field < fieldErrors[j].Field
+	})
+	return fieldErrors, nil
+}
+
+// collectLeafErrors flattens the error tree to its most specific causes
+func collectLeafErrors(err *jsonschema.ValidationError, out *[]FieldError) {
+	if len(err.Causes) == 0 {
+		*out = append(*out, FieldError{
+			Field:   fieldPath(err.InstanceLocation),
+			Message: err.Message,
+		})
+		return
+	}
+
+	for _, cause := range err.Causes {
+		collectLeafErrors(cause, out)
+	}
+}
+
+// fieldPath converts a JSON pointer such as "/address/city" to "details.address.city"
+func fieldPath(pointer string) string {
+	if pointer == "" || pointer == "/" {
+		return "details"
+	}
+
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, part := range parts {
+		parts[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+	}
+	return "details." + strings.Join(parts, ".")
+}
@@ -0,0 +1,65 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const officeSchema = `{
+	"type": "object",
+	"required": ["address"],
+	"properties": {
+		"address": {
+			"type": "object",
+			"required": ["city"],
+			"properties": {
+				"city": {"type": "string", "minLength": 1}
+			}
+		},
+		"floors": {"type": "integer", "minimum": 1}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Compile(json.RawMessage(officeSchema))
+	require.NoError(t, err)
+
+	t.Run("Conforming", func(t *testing.T) {
+		errs, err := s.Validate(map[string]any{
+			"address": map[string]any{"city": "Kochi"},
+			"floors":  3,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("FieldLevelErrors", func(t *testing.T) {
+		errs, err := s.Validate(map[string]any{
+			"addr":   map[string]any{"city": "Kochi"},
+			"floors": 0,
+		})
+		require.NoError(t, err)
+		require.Len(t, errs, 2)
+		assert.Equal(t, "details", errs[0].Field)
+		assert.Contains(t, errs[0].Message, "address")
+		assert.Equal(t, "details.floors", errs[1].Field)
+	})
+
+	t.Run("NilDetails", func(t *testing.T) {
+		errs, err := s.Validate(nil)
+		require.NoError(t, err)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "details", errs[0].Field)
+	})
+}
+
+func TestCompileRejectsInvalidSchemas(t *testing.T) {
+	_, err := Compile(json.RawMessage(`{"type": 5}`))
+	assert.Error(t, err)
+
+	_, err = Compile(json.RawMessage(`{"$ref": "https://example.com/schema.json"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/audit"
+	"n1h41/zolaris-backend-app/internal/config"
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/export"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/storage"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrDeletionNotScheduled is returned when reading or cancelling an account deletion that was never requested
+	ErrDeletionNotScheduled = errors.New("account deletion is not scheduled")
+)
+
+// errExportRowLimit stops a device's sensor data stream once the export row cap is reached
+var errExportRowLimit = errors.New("export row limit reached")
+
+// dueDeletionBatchSize caps the accounts erased per run of the deletion job
+const dueDeletionBatchSize = 100
+
+// AccountService handles the self-service parts of data protection: exporting a user's
+// personal data and erasing their account on request
+type AccountService struct {
+	userRepo      repositories.UserRepositoryInterface
+	entityRepo    repositories.EntityRepository
+	deviceRepo    *repositories.DeviceRepository
+	storage       storage.Storage
+	accountConfig config.AccountConfig
+}
+
+// NewAccountService creates a new account service instance. store holds the export files
+// removed along with an erased account.
+func NewAccountService(userRepo repositories.UserRepositoryInterface, entityRepo repositories.EntityRepository, deviceRepo *repositories.DeviceRepository, store storage.Storage, accountConfig config.AccountConfig) *AccountService {
+	return &AccountService{
+		userRepo:      userRepo,
+		entityRepo:    entityRepo,
+		deviceRepo:    deviceRepo,
+		storage:       store,
+		accountConfig: accountConfig,
+	}
+}
+
+// PersonalDataExport holds everything in a personal data export apart from sensor data.
+// It is loaded up front so failures can be reported before the archive starts streaming.
+type PersonalDataExport struct {
+	UserID      string
+	GeneratedAt time.Time
+	Profile     *dto.UserResponse
+	Devices     []*dto.DeviceResponse
+	Entities    []*dto.EntityResponse
+}
+
+// PrepareDataExport loads the user's profile, devices and entity tree for a data export
+func (s *AccountService) PrepareDataExport(ctx context.Context, userID string) (*PersonalDataExport, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	devices, err := s.deviceRepo.GetDevicesByUserID(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := getUserEntityTree(ctx, s.entityRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PersonalDataExport{
+		UserID:      userID,
+		GeneratedAt: time.Now().UTC(),
+		Profile:     mappers.UserToResponse(user),
+		Devices:     mappers.DevicesToResponses(devices),
+		Entities:    entities,
+	}, nil
+}
+
+// StreamDataExport writes a prepared export to output as a zip archive: profile.json,
+// devices.json and entities.json, a CSV of each device's recent sensor readings under
+// sensor-data/, and a manifest.json describing the rest. Sensor data covers the configured
+// window before the export was generated and is capped per device.
+func (s *AccountService) StreamDataExport(ctx context.Context, data *PersonalDataExport, output io.Writer) error {
+	archive := zip.NewWriter(output)
+	manifest := &dto.DataExportManifest{
+		UserID:           data.UserID,
+		GeneratedAt:      data.GeneratedAt,
+		SensorDataFrom:   data.GeneratedAt.Add(-s.accountConfig.ExportSensorWindow),
+		SensorDataTo:     data.GeneratedAt,
+		MaxRowsPerDevice: s.accountConfig.ExportMaxRows,
+		SensorData:       make([]*dto.DataExportSensorSummary, 0, len(data.Devices)),
+	}
+
+	create := func(name string) (io.Writer, error) {
+		manifest.Files = append(manifest.Files, name)
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: data.GeneratedAt})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to export: %w", name, err)
+		}
+		return w, nil
+	}
+	writeJSON := func(name string, v any) error {
+		w, err := create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(v); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := writeJSON("profile.json", data.Profile); err != nil {
+		return err
+	}
+	if err := writeJSON("devices.json", data.Devices); err != nil {
+		return err
+	}
+	if err := writeJSON("entities.json", data.Entities); err != nil {
+		return err
+	}
+
+	for _, device := range data.Devices {
+		name := "sensor-data/" + strings.ReplaceAll(device.DeviceID, ":", "-") + ".csv"
+		w, err := create(name)
+		if err != nil {
+			return err
+		}
+
+		summary, err := s.writeSensorData(ctx, device.DeviceID, manifest.SensorDataFrom, manifest.SensorDataTo, w)
+		if err != nil {
+			return err
+		}
+		summary.File = name
+		manifest.SensorData = append(manifest.SensorData, summary)
+
+		// Push each device's readings to the client before reading the next device
+		if err := archive.Flush(); err != nil {
+			return fmt.Errorf("failed to flush export: %w", err)
+		}
+		if f, ok := output.(flusher); ok {
+			f.Flush()
+		}
+	}
+
+	manifest.Files = append(manifest.Files, "manifest.json")
+	if err := writeJSON("manifest.json", manifest); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finalize export: %w", err)
+	}
+
+	return nil
+}
+
+// writeSensorData writes a device's readings in the range as CSV, stopping at the row cap
+func (s *AccountService) writeSensorData(ctx context.Context, macID string, from, to time.Time, output io.Writer) (*dto.DataExportSensorSummary, error) {
+	writer, err := export.NewWriter(export.CSVFormat, output)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &dto.DataExportSensorSummary{DeviceID: macID}
+	err = s.deviceRepo.StreamSensorData(ctx, macID, from.UnixMilli(), to.UnixMilli(), func(page []*domain.SensorReading) error {
+		if remaining := s.accountConfig.ExportMaxRows - summary.Rows; len(page) > remaining {
+			page = page[:remaining]
+			summary.Truncated = true
+		}
+		if err := writer.Write(page); err != nil {
+			return err
+		}
+		summary.Rows += len(page)
+		if summary.Truncated {
+			return errExportRowLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errExportRowLimit) {
+		return nil, fmt.Errorf("failed to export sensor data for device %s: %w", macID, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// ScheduleDeletion schedules the user's account to be erased once the grace period has
+// passed. Asking again keeps the original schedule. Admins must give up their role first.
+func (s *AccountService) ScheduleDeletion(ctx context.Context, userID string) (*dto.AccountDeletionResponse, error) {
+	role, err := s.userRepo.GetUserRole(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == domain.UserRoleAdmin {
+		return nil, ErrSelfDeletion
+	}
+
+	deletion, err := s.userRepo.ScheduleUserDeletion(ctx, userID, s.accountConfig.DeletionGracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	if deletion == nil {
+		return nil, ErrUserNotFound
+	}
+
+	log.Printf("User %s requested account deletion, scheduled for %s", userID, deletion.ScheduledAt.Format(time.RFC3339))
+	return mappers.AccountDeletionToResponse(deletion), nil
+}
+
+// GetDeletion retrieves the user's pending account deletion
+func (s *AccountService) GetDeletion(ctx context.Context, userID string) (*dto.AccountDeletionResponse, error) {
+	deletion, err := s.userRepo.GetUserDeletion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if deletion == nil {
+		return nil, ErrDeletionNotScheduled
+	}
+
+	return mappers.AccountDeletionToResponse(deletion), nil
+}
+
+// CancelDeletion withdraws the user's pending account deletion
+func (s *AccountService) CancelDeletion(ctx context.Context, userID string) error {
+	cancelled, err := s.userRepo.CancelUserDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrDeletionNotScheduled
+	}
+
+	log.Printf("User %s cancelled account deletion", userID)
+	return nil
+}
+
+// EraseDueAccounts erases up to one batch of accounts whose deletion grace period has passed,
+// scrubbing their PII from the entity history, and removes their sensor data and export files.
+// An account that fails is logged and retried on the next run. Returns the number of accounts erased.
+func (s *AccountService) EraseDueAccounts(ctx context.Context) (int, error) {
+	userIDs, err := s.userRepo.ListDueUserDeletions(ctx, dueDeletionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	erased := 0
+	for _, userID := range userIDs {
+		// Sensor data goes first, so an account is only erased once its devices' data is gone
+		// and a failure leaves the devices in place for the next run to find
+		macAddresses, err := s.deviceRepo.ListUserMacAddresses(ctx, userID)
+		if err != nil {
+			log.Printf("Error listing devices of user %s for erasure: %v", userID, err)
+			continue
+		}
+		if err := s.deleteSensorData(ctx, userID, macAddresses); err != nil {
+			log.Printf("Error erasing sensor data of user %s: %v", userID, err)
+			continue
+		}
+
+		// The erasure is attributed to the user who asked for it
+		erasure, err := s.userRepo.EraseUser(audit.WithActor(ctx, userID), userID)
+		if err != nil {
+			log.Printf("Error erasing user %s: %v", userID, err)
+			continue
+		}
+		if erasure == nil {
+			continue
+		}
+		erased++
+
+		// Readings may have arrived since the first pass
+		if err := s.deleteSensorData(ctx, userID, erasure.MacAddresses); err != nil {
+			log.Printf("Error erasing remaining sensor data of erased user %s: %v", userID, err)
+		}
+
+		for _, key := range erasure.StorageKeys {
+			if err := s.storage.Delete(ctx, key); err != nil {
+				log.Printf("Error removing export file %s of erased user %s: %v", key, userID, err)
+			}
+		}
+
+		tombstone := erasure.Tombstone
+		log.Printf("Erased user %s: %d devices, %d entities, %d child accounts detached",
+			userID, tombstone.DevicesErased, tombstone.EntitiesErased, tombstone.ChildrenDetached)
+	}
+
+	return erased, nil
+}
+
+// deleteSensorData removes the sensor data of each of the user's devices, stopping at the first failure
+func (s *AccountService) deleteSensorData(ctx context.Context, userID string, macAddresses []string) error {
+	for _, macAddress := range macAddresses {
+		if err := s.deviceRepo.DeleteSensorData(ctx, macAddress); err != nil {
+			return fmt.Errorf("device %s: %w", macAddress, err)
+		}
+	}
+
+	return nil
+}
+
+// RunDeletionJob erases due accounts every deletion interval until ctx is cancelled.
+// It returns immediately if the interval is not positive.
+func (s *AccountService) RunDeletionJob(ctx context.Context) {
+	interval := s.accountConfig.DeletionInterval
+	if interval <= 0 {
+		log.Println("Account deletion job disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.EraseDueAccounts(ctx); err != nil {
+			log.Printf("Error erasing due accounts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrSelfLockout is returned when admins try to disable their own account or drop their own admin role
+	ErrSelfLockout = errors.New("admins cannot disable or demote themselves")
+)
+
+const (
+	// defaultAdminPageSize is used when an admin listing does not ask for a page size
+	defaultAdminPageSize = 20
+	// maxAdminPageSize caps the number of results per admin listing page
+	maxAdminPageSize = 100
+)
+
+// AdminUserService lets admins find, inspect and manage any user account
+type AdminUserService struct {
+	userRepo   repositories.UserRepositoryInterface
+	entityRepo repositories.EntityRepository
+	deviceRepo *repositories.DeviceRepository
+
+	identityCache *repositories.CachedUserRepository
+}
+
+// NewAdminUserService creates a new admin user service instance
+func NewAdminUserService(userRepo repositories.UserRepositoryInterface, entityRepo repositories.EntityRepository, deviceRepo *repositories.DeviceRepository) *AdminUserService {
+	return &AdminUserService{
+		userRepo:   userRepo,
+		entityRepo: entityRepo,
+		deviceRepo: deviceRepo,
+	}
+}
+
+// WithIdentityCache lets admins see how the identity cache in the auth path is performing
+func (s *AdminUserService) WithIdentityCache(cached *repositories.CachedUserRepository) *AdminUserService {
+	s.identityCache = cached
+	return s
+}
+
+// SearchUsers lists a page of users matching the request, newest first
+func (s *AdminUserService) SearchUsers(ctx context.Context, request dto.AdminUserSearchRequest) (*dto.PaginatedResponse, error) {
+	page, pageSize := adminPage(request.PaginationParams)
+
+	accounts, total, err := s.userRepo.SearchUsers(ctx, domain.UserSearchFilter{
+		Query:  strings.TrimSpace(request.Query),
+		Role:   domain.UserRole(request.Role),
+		Status: request.Status,
+		Limit:  pageSize,
+		Offset: page * pageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PaginatedResponse{
+		Items:      mappers.UserAccountsToAdminResponses(accounts),
+		TotalItems: total,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// GetUserDetail retrieves a user with their devices, entities and direct child accounts
+func (s *AdminUserService) GetUserDetail(ctx context.Context, userID string) (*dto.AdminUserDetailResponse, error) {
+	account, err := s.userRepo.GetUserAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrUserNotFound
+	}
+
+	devices, err := s.deviceRepo.GetDevicesByUserID(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := getUserEntityTree(ctx, s.entityRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := s.userRepo.GetChildUsers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AdminUserDetailResponse{
+		AdminUserResponse: *mappers.UserAccountToAdminResponse(account),
+		Devices:           mappers.DevicesToResponses(devices),
+		Entities:          entities,
+		Children:          mappers.UsersToResponses(children),
+	}, nil
+}
+
+// SetUserDisabled disables or re-enables any user account. Disabled users are refused by
+// the auth middleware on their next request.
+func (s *AdminUserService) SetUserDisabled(ctx context.Context, adminID, userID string, disabled bool) error {
+	if disabled && adminID == userID {
+		return ErrSelfLockout
+	}
+
+	log.Printf("Admin %s setting disabled=%t on user %s", adminID, disabled, userID)
+	updated, err := s.userRepo.SetUserDisabled(ctx, userID, disabled)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetUserRole changes a user's role
+func (s *AdminUserService) SetUserRole(ctx context.Context, adminID, userID string, role domain.UserRole) error {
+	if role != domain.UserRoleAdmin && adminID == userID {
+		return ErrSelfLockout
+	}
+
+	log.Printf("Admin %s setting role %s on user %s", adminID, role, userID)
+	updated, err := s.userRepo.SetUserRole(ctx, userID, role)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ListImpersonations lists a page of requests made by admins impersonating users, newest
+// first, optionally only those by one admin or of one user
+func (s *AdminUserService) ListImpersonations(ctx context.Context, request dto.ImpersonationLogRequest) (*dto.PaginatedResponse, error) {
+	page, pageSize := adminPage(request.PaginationParams)
+
+	records, total, err := s.userRepo.ListImpersonations(ctx, request.AdminID, request.UserID, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PaginatedResponse{
+		Items:      mappers.ImpersonationRecordsToResponses(records),
+		TotalItems: total,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// adminPage applies the default and maximum page size of admin listings
+func adminPage(params dto.PaginationParams) (page, pageSize int) {
+	pageSize = params.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultAdminPageSize
+	}
+	return max(params.Page, 0), min(pageSize, maxAdminPageSize)
+}
+
+// GetIdentityCacheStats reports the identity cache's hits and misses since this instance started
+func (s *AdminUserService) GetIdentityCacheStats() *dto.IdentityCacheStatsResponse {
+	if s.identityCache == nil {
+		return &dto.IdentityCacheStatsResponse{}
+	}
+	return mappers.CacheStatsToIdentityCacheResponse(s.identityCache.IdentityCacheStats())
+}
@@ -2,17 +2,45 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"strings"
 
+	"n1h41/zolaris-backend-app/internal/domain"
 	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/schema"
 	"n1h41/zolaris-backend-app/internal/transport/dto"
 	"n1h41/zolaris-backend-app/internal/transport/mappers"
 )
 
+var (
+	// ErrCategoryNotFound is returned when a category does not exist
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryExists is returned when a category with the same name and type exists
+	ErrCategoryExists = repositories.ErrDuplicateCategory
+	// ErrCategoryInUse is returned when deleting, or changing the type of, a category that entities use
+	ErrCategoryInUse = repositories.ErrCategoryInUse
+	// ErrInvalidMerge is returned when two categories cannot be merged
+	ErrInvalidMerge = errors.New("categories can only be merged into a different category of the same type")
+	// ErrInvalidSchema is returned when a details schema cannot be compiled
+	ErrInvalidSchema = errors.New("invalid details schema")
+	// ErrUnknownCategoryType is returned when a category type does not exist
+	ErrUnknownCategoryType = repositories.ErrUnknownCategoryType
+	// ErrCategoryTypeExists is returned when adding a category type that already exists
+	ErrCategoryTypeExists = errors.New("category type already exists")
+	// ErrCategoryTypeInUse is returned when deleting a category type that categories still use
+	ErrCategoryTypeInUse = errors.New("category type is used by existing categories")
+	// ErrCategoryTypeProtected is returned when deleting a system category type
+	ErrCategoryTypeProtected = errors.New("system category types cannot be deleted")
+)
+
 // CategoryService handles business logic for category operations
 type CategoryService struct {
 	categoryRepo *repositories.CategoryRepository
+	entityRepo   repositories.EntityRepository
 }
 
 // NewCategoryService creates a new category service instance
@@ -20,20 +48,21 @@ func NewCategoryService(categoryRepo *repositories.CategoryRepository) *Category
 	return &CategoryService{categoryRepo: categoryRepo}
 }
 
+// WithEntityRepository sets the repository used to re-check entities when a category's schema changes
+func (s *CategoryService) WithEntityRepository(entityRepo repositories.EntityRepository) *CategoryService {
+	s.entityRepo = entityRepo
+	return s
+}
+
 // AddCategory handles the business logic for adding a new category
 func (s *CategoryService) AddCategory(ctx context.Context, name, categoryType string) error {
 	log.Printf("Adding category %s of type %s", name, categoryType)
 
-	// Check if category already exists
-	existingCategory, err := s.categoryRepo.GetCategoryByName(ctx, name)
-	if err != nil {
+	if err := s.checkCategoryType(ctx, categoryType); err != nil {
 		return err
 	}
 
-	if existingCategory != nil {
-		return errors.New("category with this name already exists")
-	}
-
+	// Uniqueness of (name, type) is enforced by the database
 	return s.categoryRepo.AddCategory(ctx, name, categoryType)
 }
 
@@ -71,3 +100,385 @@ func (s *CategoryService) GetAllCategories(ctx context.Context) ([]*dto.Category
 
 	return mappers.CategoriesToResponses(categories), nil
 }
+
+// UpdateDetailsSchema replaces a category's details schema and re-checks its existing entities.
+// Entities that no longer conform are flagged rather than rejected, so schemas can evolve
+// ahead of the data. An empty schema removes validation for the category.
+func (s *CategoryService) UpdateDetailsSchema(ctx context.Context, categoryID string, schemaDoc map[string]any) (*dto.CategorySchemaUpdateResponse, error) {
+	category, err := s.categoryRepo.GetCategoryByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	var (
+		rawSchema     json.RawMessage
+		detailsSchema *schema.Schema
+	)
+	if len(schemaDoc) > 0 {
+		if rawSchema, err = json.Marshal(schemaDoc); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+		}
+		if detailsSchema, err = schema.Compile(rawSchema); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+		}
+	}
+
+	version, err := s.categoryRepo.UpdateDetailsSchema(ctx, categoryID, rawSchema)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Updated details schema of category %s to version %d", categoryID, version)
+
+	checked, nonConforming, err := s.recheckEntities(ctx, categoryID, detailsSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.CategorySchemaUpdateResponse{
+		CategoryID:         categoryID,
+		SchemaVersion:      version,
+		EntitiesChecked:    checked,
+		NonConformingCount: nonConforming,
+	}, nil
+}
+
+// recheckEntities validates every entity of a category against detailsSchema and records
+// the results. A nil schema marks all of them conforming.
+func (s *CategoryService) recheckEntities(ctx context.Context, categoryID string, detailsSchema *schema.Schema) (int, int, error) {
+	entities, err := s.entityRepo.ListEntitiesByCategory(ctx, categoryID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	results := make([]*domain.EntityConformance, len(entities))
+	nonConforming := 0
+	for i, entity := range entities {
+		results[i] = &domain.EntityConformance{EntityID: entity.ID, Name: entity.Name}
+		if detailsSchema == nil {
+			continue
+		}
+
+		var details map[string]any
+		if len(entity.Details) > 0 {
+			if err := json.Unmarshal(entity.Details, &details); err != nil {
+				return 0, 0, fmt.Errorf("failed to decode details of entity %s: %w", entity.ID, err)
+			}
+		}
+
+		fieldErrors, err := detailsSchema.Validate(details)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(fieldErrors) == 0 {
+			continue
+		}
+
+		results[i].Errors, err = json.Marshal(fieldErrors)
+		if err != nil {
+			return 0, 0, err
+		}
+		nonConforming++
+	}
+
+	if err := s.entityRepo.SetDetailsConformance(ctx, results); err != nil {
+		return 0, 0, err
+	}
+
+	return len(entities), nonConforming, nil
+}
+
+// ListNonConformingEntities retrieves the entities of a category whose details do not match its schema
+func (s *CategoryService) ListNonConformingEntities(ctx context.Context, categoryID string) ([]*dto.NonConformingEntityResponse, error) {
+	category, err := s.categoryRepo.GetCategoryByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	results, err := s.entityRepo.ListNonConformingEntities(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EntityConformancesToResponses(results), nil
+}
+
+// ListTypeRules retrieves the parenting rules of every category type
+func (s *CategoryService) ListTypeRules(ctx context.Context) ([]*dto.CategoryTypeRuleResponse, error) {
+	rules, err := s.categoryRepo.ListTypeRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.CategoryTypeRulesToResponses(rules), nil
+}
+
+// UpdateTypeRule replaces the parenting rule of a category type. Existing entities are
+// not changed; use the entity rule audit to find placements the new rule forbids.
+func (s *CategoryService) UpdateTypeRule(ctx context.Context, categoryType string, request dto.CategoryTypeRuleRequest) (*dto.CategoryTypeRuleResponse, error) {
+	known, err := s.CategoryTypeNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range append([]string{categoryType}, request.AllowedChildTypes...) {
+		if !slices.Contains(known, t) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCategoryType, t)
+		}
+	}
+
+	rule := &domain.CategoryTypeRule{
+		CategoryType:      categoryType,
+		AllowedAsRoot:     request.AllowedAsRoot,
+		AllowedChildTypes: request.AllowedChildTypes,
+		MaxDepth:          request.MaxDepth,
+	}
+	if rule.AllowedChildTypes == nil {
+		rule.AllowedChildTypes = []string{}
+	}
+
+	log.Printf("Updating parenting rule for category type %s", categoryType)
+	if err := s.categoryRepo.UpsertTypeRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return mappers.CategoryTypeRuleToResponse(rule), nil
+}
+
+// CategoryTypeNames returns the names of all category types
+func (s *CategoryService) CategoryTypeNames(ctx context.Context) ([]string, error) {
+	categoryTypes, err := s.categoryRepo.ListCategoryTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(categoryTypes))
+	for i, categoryType := range categoryTypes {
+		names[i] = categoryType.Name
+	}
+	return names, nil
+}
+
+// checkCategoryType returns ErrUnknownCategoryType, listing the valid names, unless
+// categoryType is one of the current category types
+func (s *CategoryService) checkCategoryType(ctx context.Context, categoryType string) error {
+	known, err := s.CategoryTypeNames(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(known, categoryType) {
+		return fmt.Errorf("%w %q: must be one of: %s", ErrUnknownCategoryType, categoryType, strings.Join(known, " "))
+	}
+	return nil
+}
+
+// ListCategoryTypes retrieves every category type
+func (s *CategoryService) ListCategoryTypes(ctx context.Context) ([]*dto.CategoryTypeResponse, error) {
+	categoryTypes, err := s.categoryRepo.ListCategoryTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.CategoryTypesToResponses(categoryTypes), nil
+}
+
+// AddCategoryType registers a new category type
+func (s *CategoryService) AddCategoryType(ctx context.Context, name, description string) (*dto.CategoryTypeResponse, error) {
+	log.Printf("Adding category type %s", name)
+
+	existing, err := s.categoryRepo.GetCategoryTypeByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrCategoryTypeExists
+	}
+
+	categoryType := &domain.CategoryTypeDefinition{Name: name, Description: description}
+	if err := s.categoryRepo.AddCategoryType(ctx, categoryType); err != nil {
+		return nil, err
+	}
+
+	return mappers.CategoryTypeToResponse(categoryType), nil
+}
+
+// UpdateCategoryType replaces the description of a category type
+func (s *CategoryService) UpdateCategoryType(ctx context.Context, name, description string) (*dto.CategoryTypeResponse, error) {
+	existing, err := s.categoryRepo.GetCategoryTypeByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrUnknownCategoryType
+	}
+
+	if err := s.categoryRepo.UpdateCategoryType(ctx, name, description); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.categoryRepo.GetCategoryTypeByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return mappers.CategoryTypeToResponse(updated), nil
+}
+
+// DeleteCategoryType removes a category type that no category uses
+func (s *CategoryService) DeleteCategoryType(ctx context.Context, name string) error {
+	existing, err := s.categoryRepo.GetCategoryTypeByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrUnknownCategoryType
+	}
+	if existing.IsSystem {
+		return ErrCategoryTypeProtected
+	}
+
+	count, err := s.categoryRepo.CountCategoriesOfType(ctx, name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d categories", ErrCategoryTypeInUse, count)
+	}
+
+	log.Printf("Deleting category type %s", name)
+	return s.categoryRepo.DeleteCategoryType(ctx, name)
+}
+
+// UpdateCategory renames a category and/or changes its type. The type of a category
+// cannot change while entities use it, since that would bypass the parenting rules.
+func (s *CategoryService) UpdateCategory(ctx context.Context, categoryID, name, categoryType string) (*dto.CategoryResponse, error) {
+	category, err := s.categoryRepo.GetCategoryByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	if name == "" {
+		name = category.Name
+	}
+	if categoryType == "" {
+		categoryType = category.Type
+	}
+
+	if categoryType != category.Type {
+		if err := s.checkCategoryType(ctx, categoryType); err != nil {
+			return nil, err
+		}
+
+		count, err := s.categoryRepo.CountEntitiesOfCategory(ctx, categoryID)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("%w: %d entities", ErrCategoryInUse, count)
+		}
+	}
+
+	log.Printf("Updating category %s to %s of type %s", categoryID, name, categoryType)
+	if err := s.categoryRepo.UpdateCategory(ctx, categoryID, name, categoryType); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.categoryRepo.GetCategoryByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	return mappers.CategoryToResponse(updated), nil
+}
+
+// DeleteCategory deletes a category. If entities use it they are first moved to
+// replacementID; without a replacement the deletion is refused.
+func (s *CategoryService) DeleteCategory(ctx context.Context, categoryID, replacementID string) (*dto.CategoryMergeResponse, error) {
+	if replacementID != "" {
+		return s.MergeCategories(ctx, categoryID, replacementID)
+	}
+
+	category, err := s.categoryRepo.GetCategoryByID(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	count, err := s.categoryRepo.CountEntitiesOfCategory(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: %d entities, give a replacement category to move them to", ErrCategoryInUse, count)
+	}
+
+	log.Printf("Deleting category %s", categoryID)
+	if err := s.categoryRepo.DeleteCategory(ctx, categoryID); err != nil {
+		return nil, err
+	}
+
+	return &dto.CategoryMergeResponse{SourceCategoryID: categoryID}, nil
+}
+
+// MergeCategories moves every entity of the source category to the target category,
+// deletes the source and re-checks the moved entities against the target's schema
+func (s *CategoryService) MergeCategories(ctx context.Context, sourceID, targetID string) (*dto.CategoryMergeResponse, error) {
+	source, err := s.categoryRepo.GetCategoryByID(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.categoryRepo.GetCategoryByID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil || target == nil {
+		return nil, ErrCategoryNotFound
+	}
+	if source.ID == target.ID || source.Type != target.Type {
+		return nil, ErrInvalidMerge
+	}
+
+	var detailsSchema *schema.Schema
+	if len(target.DetailsSchema) > 0 {
+		if detailsSchema, err = schema.Compile(target.DetailsSchema); err != nil {
+			return nil, fmt.Errorf("category %s has an unusable details schema: %w", targetID, err)
+		}
+	}
+
+	log.Printf("Merging category %s into %s", sourceID, targetID)
+	moved, err := s.categoryRepo.MergeCategory(ctx, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.CategoryMergeResponse{
+		SourceCategoryID: sourceID,
+		TargetCategoryID: targetID,
+		EntitiesMoved:    moved,
+	}
+	if detailsSchema != nil {
+		if _, result.NonConformingCount, err = s.recheckEntities(ctx, targetID, detailsSchema); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ListDuplicateCategories groups categories whose names differ only by case or surrounding
+// whitespace within the same type. The oldest category of each group comes first.
+func (s *CategoryService) ListDuplicateCategories(ctx context.Context) ([]*dto.CategoryDuplicateGroupResponse, error) {
+	groups, err := s.categoryRepo.ListDuplicateCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.CategoryDuplicateGroupsToResponses(groups), nil
+}
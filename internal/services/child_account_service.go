@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrUserAccessDenied is returned when a user acts on an account that is not below them in the parent chain
+	ErrUserAccessDenied = errors.New("user is not managed by requester")
+	// ErrChildInvitationExists is returned when a parent invites an email they already invited
+	ErrChildInvitationExists = repositories.ErrDuplicateChildInvitation
+	// ErrSelfInvitation is returned when users invite their own email as a sub-account
+	ErrSelfInvitation = errors.New("users cannot invite themselves")
+	// ErrParentAlreadySet is returned when a user who already has a parent accepts a child invitation
+	ErrParentAlreadySet = repositories.ErrParentAlreadySet
+	// ErrParentCycle is returned when accepting a child invitation from an account below the user
+	ErrParentCycle = repositories.ErrParentCycle
+)
+
+// ChildAccountService lets parent users create and manage the sub-accounts below them
+type ChildAccountService struct {
+	userRepo   repositories.UserRepositoryInterface
+	entityRepo repositories.EntityRepository
+	deviceRepo *repositories.DeviceRepository
+}
+
+// NewChildAccountService creates a new child account service instance
+func NewChildAccountService(userRepo repositories.UserRepositoryInterface, entityRepo repositories.EntityRepository, deviceRepo *repositories.DeviceRepository) *ChildAccountService {
+	return &ChildAccountService{
+		userRepo:   userRepo,
+		entityRepo: entityRepo,
+		deviceRepo: deviceRepo,
+	}
+}
+
+// CreateChild invites the user with the request's email to become a sub-account of the parent.
+// The parent chain only reaches the account once its owner accepts, so no account is created
+// and nothing is shared until then.
+func (s *ChildAccountService) CreateChild(ctx context.Context, parentID string, request dto.CreateChildUserRequest) (*dto.ChildInvitationResponse, error) {
+	parent, err := s.userRepo.GetUserByID(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, ErrUserNotFound
+	}
+
+	email := strings.TrimSpace(request.Email)
+	if strings.EqualFold(email, parent.Email) {
+		return nil, ErrSelfInvitation
+	}
+
+	invitation := &domain.ChildInvitation{
+		ParentID:        parentID,
+		ParentEmail:     parent.Email,
+		ParentFirstName: parent.FirstName,
+		ParentLastName:  parent.LastName,
+		Email:           email,
+		FirstName:       optionalString(request.FirstName),
+		LastName:        optionalString(request.LastName),
+		Phone:           optionalString(request.Phone),
+	}
+
+	log.Printf("User %s inviting %s as a child account", parentID, email)
+	if err := s.userRepo.CreateChildInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	return mappers.ChildInvitationToResponse(invitation), nil
+}
+
+// ListChildInvitations lists the invitations the parent sent that were not accepted yet
+func (s *ChildAccountService) ListChildInvitations(ctx context.Context, parentID string) ([]*dto.ChildInvitationResponse, error) {
+	invitations, err := s.userRepo.ListChildInvitationsByParent(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.ChildInvitationsToResponses(invitations), nil
+}
+
+// CancelChildInvitation withdraws an invitation the parent sent
+func (s *ChildAccountService) CancelChildInvitation(ctx context.Context, parentID, invitationID string) error {
+	invitation, err := s.userRepo.GetChildInvitation(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation == nil || invitation.ParentID != parentID {
+		return ErrInvitationNotFound
+	}
+
+	return s.deleteChildInvitation(ctx, invitationID)
+}
+
+// ListParentInvitations lists the invitations for the user to become someone's sub-account
+func (s *ChildAccountService) ListParentInvitations(ctx context.Context, userID string) ([]*dto.ChildInvitationResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	invitations, err := s.userRepo.ListChildInvitationsForEmail(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.ChildInvitationsToResponses(invitations), nil
+}
+
+// AcceptParentInvitation makes the user a sub-account of the parent who invited them, giving
+// the parent chain access to their account. Their other invitations are withdrawn.
+func (s *ChildAccountService) AcceptParentInvitation(ctx context.Context, userID, invitationID string) error {
+	log.Printf("User %s accepting child invitation %s", userID, invitationID)
+	accepted, err := s.userRepo.AcceptChildInvitation(ctx, invitationID, userID)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// DeclineParentInvitation rejects an invitation for the user to become someone's sub-account
+func (s *ChildAccountService) DeclineParentInvitation(ctx context.Context, userID, invitationID string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	invitation, err := s.userRepo.GetChildInvitation(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation == nil || !strings.EqualFold(invitation.Email, user.Email) {
+		return ErrInvitationNotFound
+	}
+
+	return s.deleteChildInvitation(ctx, invitationID)
+}
+
+func (s *ChildAccountService) deleteChildInvitation(ctx context.Context, invitationID string) error {
+	deleted, err := s.userRepo.DeleteChildInvitation(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// ListChildren lists the accounts directly below the user, disabled ones included
+func (s *ChildAccountService) ListChildren(ctx context.Context, parentID string) ([]*dto.UserResponse, error) {
+	children, err := s.userRepo.GetChildUsers(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.UsersToResponses(children), nil
+}
+
+// SetChildDisabled disables or re-enables an account anywhere below the user in the parent chain
+func (s *ChildAccountService) SetChildDisabled(ctx context.Context, parentID, childID string, disabled bool) error {
+	if _, err := getManagedUser(ctx, s.userRepo, parentID, childID); err != nil {
+		return err
+	}
+
+	log.Printf("User %s setting disabled=%t on child account %s", parentID, disabled, childID)
+	updated, err := s.userRepo.SetUserDisabled(ctx, childID, disabled)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetChildDevices lists the devices of an account below the user in the parent chain
+func (s *ChildAccountService) GetChildDevices(ctx context.Context, parentID, childID string) ([]*dto.DeviceResponse, error) {
+	if _, err := getManagedUser(ctx, s.userRepo, parentID, childID); err != nil {
+		return nil, err
+	}
+
+	devices, err := s.deviceRepo.GetDevicesByUserID(ctx, childID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.DevicesToResponses(devices), nil
+}
+
+// GetChildEntities lists the user entity of an account below the user in the parent chain
+// with all its descendants, or nothing if the account has no entity yet
+func (s *ChildAccountService) GetChildEntities(ctx context.Context, parentID, childID string) ([]*dto.EntityResponse, error) {
+	if _, err := getManagedUser(ctx, s.userRepo, parentID, childID); err != nil {
+		return nil, err
+	}
+
+	return getUserEntityTree(ctx, s.entityRepo, childID)
+}
+
+// getUserEntityTree lists a user's entity with all its descendants, or nothing if the user has no entity yet
+func getUserEntityTree(ctx context.Context, entityRepo repositories.EntityRepository, userID string) ([]*dto.EntityResponse, error) {
+	entityId, err := entityRepo.GetUserEntityID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if entityId == "" {
+		return []*dto.EntityResponse{}, nil
+	}
+
+	nodes, err := entityRepo.GetEntityHierarchy(ctx, entityId, maxTreeDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EntityNodesToResponses(nodes), nil
+}
+
+// getManagedUser loads a user and verifies the manager is above them in the parent chain
+func getManagedUser(ctx context.Context, userRepo repositories.UserRepositoryInterface, managerID, userID string) (*domain.User, error) {
+	user, err := userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	isAncestor, err := userRepo.IsUserAncestor(ctx, managerID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAncestor {
+		return nil, ErrUserAccessDenied
+	}
+
+	return user, nil
+}
+
+// optionalString returns nil for a blank value, so it does not overwrite anything it fills in
+func optionalString(value string) *string {
+	if value = strings.TrimSpace(value); value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/config"
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/quality"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+// fleetQualityWorkers bounds how many devices are analyzed concurrently for the fleet summary
+const fleetQualityWorkers = 4
+
+// ErrInvalidQualityParams is returned when the analysis overrides are unusable
+var ErrInvalidQualityParams = errors.New("invalid data quality parameters")
+
+// DataQualityService analyzes sensor data for gaps, duplicates and implausible values
+type DataQualityService struct {
+	deviceRepo    *repositories.DeviceRepository
+	qualityConfig config.DataQualityConfig
+}
+
+// NewDataQualityService creates a new data quality service instance
+func NewDataQualityService(deviceRepo *repositories.DeviceRepository, qualityConfig config.DataQualityConfig) *DataQualityService {
+	return &DataQualityService{
+		deviceRepo:    deviceRepo,
+		qualityConfig: qualityConfig,
+	}
+}
+
+// GetDeviceReport analyzes the data quality of a device owned by the user
+func (s *DataQualityService) GetDeviceReport(ctx context.Context, userID, macID string, req *dto.DataQualityRequest) (*dto.DataQualityReportResponse, error) {
+	analysisConfig, err := s.analysisConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := getOwnedDevice(ctx, s.deviceRepo, userID, macID); err != nil {
+		return nil, err
+	}
+
+	report, err := s.analyze(ctx, analysisConfig, macID, time.UnixMilli(req.StartTime), time.UnixMilli(req.EndTime))
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.DataQualityReportToResponse(report, analysisConfig.GapThreshold()), nil
+}
+
+// GetFleetSummary analyzes every device and returns one summary line per device, worst first
+func (s *DataQualityService) GetFleetSummary(ctx context.Context, req *dto.DataQualityRequest) (*dto.FleetDataQualityResponse, error) {
+	analysisConfig, err := s.analysisConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.deviceRepo.ListAllDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := time.UnixMilli(req.StartTime), time.UnixMilli(req.EndTime)
+	log.Printf("Analyzing data quality for %d devices from %d to %d", len(devices), req.StartTime, req.EndTime)
+
+	summaries := make([]*dto.DeviceDataQualitySummaryResponse, len(devices))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(fleetQualityWorkers, len(devices)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				device := devices[i]
+				report, err := s.analyze(ctx, analysisConfig, device.MacAddress, start, end)
+				summaries[i] = mappers.DataQualitySummaryToResponse(device, report)
+				if err != nil {
+					// One unreadable device should not hide the rest of the fleet
+					log.Printf("Error analyzing data quality for device %s: %v", device.MacAddress, err)
+					summaries[i].Error = "failed to read sensor data"
+				}
+			}
+		}()
+	}
+	for i := range devices {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Completeness < summaries[j].Completeness
+	})
+
+	fleet := &dto.FleetDataQualityResponse{
+		StartTime:      req.StartTime,
+		EndTime:        req.EndTime,
+		SamplePeriodMs: analysisConfig.SamplePeriod.Milliseconds(),
+		DeviceCount:    len(summaries),
+		Devices:        summaries,
+	}
+
+	var completenessSum float64
+	for _, summary := range summaries {
+		completenessSum += summary.Completeness
+		fleet.TotalGaps += summary.GapCount
+		fleet.TotalDuplicates += summary.DuplicateCount
+		fleet.TotalOutOfRange += summary.OutOfRangeCount
+		if summary.Error != "" || summary.GapCount > 0 || summary.DuplicateCount > 0 ||
+			summary.OutOfRangeCount > 0 || summary.UnparseableCount > 0 {
+			fleet.DevicesWithIssues++
+		}
+	}
+	if len(summaries) > 0 {
+		fleet.AvgCompleteness = completenessSum / float64(len(summaries))
+	}
+
+	return fleet, nil
+}
+
+// analyze streams a device's readings through a quality analyzer
+func (s *DataQualityService) analyze(ctx context.Context, analysisConfig quality.Config, macID string, start, end time.Time) (*quality.Report, error) {
+	analyzer := quality.NewAnalyzer(analysisConfig, macID, start, end)
+
+	err := s.deviceRepo.StreamSensorData(ctx, macID, start.UnixMilli(), end.UnixMilli(), func(page []*domain.SensorReading) error {
+		analyzer.Add(page)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sensor data: %w", err)
+	}
+
+	return analyzer.Report(), nil
+}
+
+// analysisConfig builds the analyzer configuration from the defaults and any request overrides
+func (s *DataQualityService) analysisConfig(req *dto.DataQualityRequest) (quality.Config, error) {
+	analysisConfig := quality.Config{
+		SamplePeriod:    s.qualityConfig.SamplePeriod,
+		GapFactor:       s.qualityConfig.GapFactor,
+		DuplicateWindow: s.qualityConfig.DuplicateWindow,
+		Amperage:        quality.Bounds{Min: s.qualityConfig.AmperageMin, Max: s.qualityConfig.AmperageMax},
+		Temperature:     quality.Bounds{Min: s.qualityConfig.TemperatureMin, Max: s.qualityConfig.TemperatureMax},
+		Humidity:        quality.Bounds{Min: s.qualityConfig.HumidityMin, Max: s.qualityConfig.HumidityMax},
+	}
+
+	if req.SamplePeriod != "" {
+		samplePeriod, err := time.ParseDuration(req.SamplePeriod)
+		if err != nil {
+			return quality.Config{}, fmt.Errorf("%w: sample period: %v", ErrInvalidQualityParams, err)
+		}
+		analysisConfig.SamplePeriod = samplePeriod
+	}
+	if req.GapFactor != 0 {
+		analysisConfig.GapFactor = req.GapFactor
+	}
+
+	if err := analysisConfig.Validate(); err != nil {
+		return quality.Config{}, fmt.Errorf("%w: %v", ErrInvalidQualityParams, err)
+	}
+
+	return analysisConfig, nil
+}
@@ -2,23 +2,58 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"time"
 
+	"n1h41/zolaris-backend-app/internal/config"
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/energy"
 	"n1h41/zolaris-backend-app/internal/repositories"
 	"n1h41/zolaris-backend-app/internal/transport/dto"
 	"n1h41/zolaris-backend-app/internal/transport/mappers"
 )
 
+var (
+	// ErrDeviceNotFound is returned when a device does not exist
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceAccessDenied is returned when a user does not own the requested device
+	ErrDeviceAccessDenied = errors.New("device does not belong to user")
+	// ErrDeviceDeletedByOtherUser is returned when registering a device in another user's trash
+	ErrDeviceDeletedByOtherUser = repositories.ErrDeviceDeletedByOtherUser
+)
+
 // DeviceService handles business logic for device operations
 type DeviceService struct {
-	deviceRepo *repositories.DeviceRepository
+	deviceRepo   *repositories.DeviceRepository
+	tagRepo      *repositories.TagRepository
+	energyConfig config.EnergyConfig
 }
 
 // NewDeviceService creates a new device service instance
 func NewDeviceService(deviceRepo *repositories.DeviceRepository) *DeviceService {
-	return &DeviceService{deviceRepo: deviceRepo}
+	return &DeviceService{
+		deviceRepo: deviceRepo,
+		energyConfig: config.EnergyConfig{
+			MaxSampleGap: energy.DefaultMaxGap,
+			Currency:     "INR",
+			Timezone:     "UTC",
+		},
+	}
+}
+
+// WithEnergyConfig sets the settings used for energy and cost computation
+func (s *DeviceService) WithEnergyConfig(energyConfig config.EnergyConfig) *DeviceService {
+	s.energyConfig = energyConfig
+	return s
+}
+
+// WithTagRepository adds each device's tags to device listings
+func (s *DeviceService) WithTagRepository(tagRepo *repositories.TagRepository) *DeviceService {
+	s.tagRepo = tagRepo
+	return s
 }
 
 // AddDevice handles the business logic for adding a new device
@@ -28,15 +63,55 @@ func (s *DeviceService) AddDevice(ctx context.Context, deviceID, deviceName, use
 	return s.deviceRepo.AddDevice(ctx, deviceID, deviceName, userID)
 }
 
-// GetUserDevices retrieves all devices for a user
-func (s *DeviceService) GetUserDevices(ctx context.Context, userID string) ([]*dto.DeviceResponse, error) {
+// DeleteDevice soft-deletes a device owned by the user. It can be restored by an admin
+// until the retention period ends.
+func (s *DeviceService) DeleteDevice(ctx context.Context, userID, macID string) error {
+	if _, err := getOwnedDevice(ctx, s.deviceRepo, userID, macID); err != nil {
+		return err
+	}
+
+	log.Printf("Deleting device %s for user %s", macID, userID)
+	deleted, err := s.deviceRepo.SoftDeleteDevice(ctx, macID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+// GetUserDevices retrieves all devices for a user, keeping only those carrying every tag
+// in tagFilters ("key" or "key=value")
+func (s *DeviceService) GetUserDevices(ctx context.Context, userID string, tagFilters []string) ([]*dto.DeviceResponse, error) {
+	tags, err := parseTagFilters(tagFilters)
+	if err != nil {
+		return nil, err
+	}
+
 	log.Printf("Getting devices for user %s", userID)
-	devices, err := s.deviceRepo.GetDevicesByUserID(ctx, userID)
+	devices, err := s.deviceRepo.GetDevicesByUserID(ctx, userID, tags)
 	if err != nil {
 		return nil, err
 	}
 
-	return mappers.DevicesToResponses(devices), nil
+	responses := mappers.DevicesToResponses(devices)
+	if s.tagRepo != nil {
+		macAddresses := make([]string, len(responses))
+		for i, device := range responses {
+			macAddresses[i] = device.DeviceID
+		}
+		deviceTags, err := s.tagRepo.GetDeviceTags(ctx, macAddresses)
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range responses {
+			device.Tags = deviceTags[device.DeviceID]
+		}
+	}
+
+	return responses, nil
 }
 
 // GetDeviceSensorData retrieves sensor data for a device within a time range
@@ -49,7 +124,7 @@ func (s *DeviceService) GetDeviceSensorData(ctx context.Context, macID, dateMode
 	}
 
 	// Calculate time range based on dateMode
-	startTime, endTime := s.calculateTimeRange(timestampMs, dateMode)
+	startTime, endTime := calculateTimeRange(timestampMs, dateMode)
 	log.Printf("Getting sensor data for device %s from %d to %d", macID, startTime, endTime)
 
 	// Get raw sensor data
@@ -63,7 +138,7 @@ func (s *DeviceService) GetDeviceSensorData(ctx context.Context, macID, dateMode
 }
 
 // calculateTimeRange calculates a time range looking backward from the provided timestamp
-func (s *DeviceService) calculateTimeRange(baseTimeMs int64, dateMode string) (int64, int64) {
+func calculateTimeRange(baseTimeMs int64, dateMode string) (int64, int64) {
 	// Convert milliseconds to seconds and nanoseconds for time package
 	seconds := baseTimeMs / 1000
 	nanoseconds := (baseTimeMs % 1000) * 1000000
@@ -95,3 +170,160 @@ func (s *DeviceService) calculateTimeRange(baseTimeMs int64, dateMode string) (i
 	// Return timestamps in milliseconds
 	return startTime.UnixMilli(), endTime.UnixMilli()
 }
+
+// IngestSensorData stores readings for a device owned by the user
+func (s *DeviceService) IngestSensorData(ctx context.Context, userID, macID string, inputs []dto.SensorReadingInput) error {
+	if _, err := s.getOwnedDevice(ctx, userID, macID); err != nil {
+		return err
+	}
+
+	log.Printf("Ingesting %d readings for device %s", len(inputs), macID)
+	return s.deviceRepo.WriteSensorData(ctx, mappers.SensorReadingInputsToDomain(macID, inputs))
+}
+
+// AggregateSensorData summarizes a device's readings in fixed-width buckets
+func (s *DeviceService) AggregateSensorData(ctx context.Context, userID, macID string, start, end time.Time, interval time.Duration) ([]*dto.SensorAggregateResponse, error) {
+	if interval < time.Minute {
+		return nil, fmt.Errorf("aggregation interval must be at least one minute")
+	}
+
+	if _, err := s.getOwnedDevice(ctx, userID, macID); err != nil {
+		return nil, err
+	}
+
+	aggregates, err := s.deviceRepo.AggregateSensorData(ctx, macID, start.UnixMilli(), end.UnixMilli(), interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.SensorAggregatesToResponses(aggregates), nil
+}
+
+// getOwnedDevice retrieves a device and verifies that it belongs to the user
+func (s *DeviceService) getOwnedDevice(ctx context.Context, userID, macID string) (*domain.Device, error) {
+	return getOwnedDevice(ctx, s.deviceRepo, userID, macID)
+}
+
+// getOwnedDevice retrieves a device from the repository and verifies that it belongs to the user
+func getOwnedDevice(ctx context.Context, deviceRepo *repositories.DeviceRepository, userID, macID string) (*domain.Device, error) {
+	device, err := deviceRepo.GetDeviceByMac(ctx, macID)
+	if err != nil {
+		return nil, err
+	}
+
+	if device == nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	if device.UserID != userID {
+		return nil, ErrDeviceAccessDenied
+	}
+
+	return device, nil
+}
+
+// UpdateElectricalParams updates the electrical parameters used for energy computation
+func (s *DeviceService) UpdateElectricalParams(ctx context.Context, userID, macID string, params energy.ElectricalParams) (*dto.DeviceResponse, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	device, err := s.getOwnedDevice(ctx, userID, macID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Updating electrical parameters for device %s", macID)
+	if err := s.deviceRepo.UpdateElectricalParams(ctx, macID, params.Voltage, params.PhaseCount, params.PowerFactor); err != nil {
+		return nil, err
+	}
+
+	device.Voltage = params.Voltage
+	device.PhaseCount = params.PhaseCount
+	device.PowerFactor = params.PowerFactor
+	return mappers.DeviceToResponse(device), nil
+}
+
+// GetDeviceEnergy computes the energy consumed by a device per bucket within a time range
+func (s *DeviceService) GetDeviceEnergy(ctx context.Context, userID, macID string, start, end time.Time, bucket energy.BucketSize) (*dto.EnergyResponse, error) {
+	buckets, err := s.integrateEnergy(ctx, userID, macID, start, end, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EnergyBucketsToResponse(macID, bucket, buckets), nil
+}
+
+// GetDeviceEnergyCost computes the cost of the energy consumed by a device per day or month.
+// When tariffReq is nil the configured flat rate is used.
+func (s *DeviceService) GetDeviceEnergyCost(ctx context.Context, userID, macID string, start, end time.Time, period energy.BucketSize, tariffReq *dto.TariffRequest) (*dto.EnergyCostResponse, error) {
+	location, err := time.LoadLocation(s.energyConfig.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid energy timezone: %w", err)
+	}
+
+	var tariff energy.Tariff = energy.FlatTariff{
+		Rate:         s.energyConfig.TariffRate,
+		CurrencyCode: s.energyConfig.Currency,
+	}
+	if tariffReq != nil {
+		tariff = mappers.TariffRequestToTariff(tariffReq, s.energyConfig.Currency, location)
+		if tou, ok := tariff.(energy.TimeOfUseTariff); ok {
+			if err := tou.Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Time-of-use rates are applied per hour, so always integrate hourly first
+	hourly, err := s.integrateEnergy(ctx, userID, macID, start, end, energy.HourBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	costs, err := energy.Cost(tariff, hourly, period, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.CostBucketsToResponse(macID, period, tariff.Currency(), costs), nil
+}
+
+// integrateEnergy loads a device's readings and integrates them into energy buckets
+func (s *DeviceService) integrateEnergy(ctx context.Context, userID, macID string, start, end time.Time, bucket energy.BucketSize) ([]energy.Bucket, error) {
+	device, err := s.getOwnedDevice(ctx, userID, macID)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(s.energyConfig.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid energy timezone: %w", err)
+	}
+
+	// Fetch one extra gap on either side so intervals crossing the range edges are integrated
+	margin := s.energyConfig.MaxSampleGap
+	readings, err := s.deviceRepo.GetSensorData(ctx, macID, start.Add(-margin).UnixMilli(), end.Add(margin).UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]energy.Sample, 0, len(readings))
+	for _, reading := range readings {
+		amperage, err := strconv.ParseFloat(reading.Amperage, 64)
+		if err != nil {
+			continue // Skip readings without a numeric amperage
+		}
+		samples = append(samples, energy.Sample{Timestamp: reading.Timestamp, Amperage: amperage})
+	}
+
+	params := energy.ElectricalParams{
+		Voltage:     device.Voltage,
+		PhaseCount:  device.PhaseCount,
+		PowerFactor: device.PowerFactor,
+	}
+
+	log.Printf("Computing %s energy for device %s from %d samples", bucket, macID, len(samples))
+	calculator := energy.NewCalculator(params, s.energyConfig.MaxSampleGap).WithLocation(location)
+	return calculator.Integrate(samples, start, end, bucket)
+}
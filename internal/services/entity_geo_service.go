@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+// ErrInvalidGeoQuery is returned when geographic query parameters cannot be applied
+var ErrInvalidGeoQuery = errors.New("invalid geographic query")
+
+const (
+	// defaultGeoResults is used when a geographic query does not ask for a limit
+	defaultGeoResults = 100
+	// defaultGeoJSONDepth is used when a GeoJSON request does not ask for a depth
+	defaultGeoJSONDepth = 10
+)
+
+// EntityGeoService places entities on the map and finds them by location
+type EntityGeoService struct {
+	repo repositories.EntityRepository
+}
+
+// NewEntityGeoService creates a new entity geolocation service instance
+func NewEntityGeoService(repo repositories.EntityRepository) *EntityGeoService {
+	return &EntityGeoService{repo: repo}
+}
+
+// SetLocation places an entity at the given coordinates. Requires the admin role on the entity.
+func (s *EntityGeoService) SetLocation(ctx context.Context, userId string, entityId string, request dto.SetEntityLocationRequest) error {
+	if _, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repo.SetEntityLocation(ctx, entityId, request.Latitude, request.Longitude)
+}
+
+// ClearLocation removes an entity from the map. Requires the admin role on the entity.
+func (s *EntityGeoService) ClearLocation(ctx context.Context, userId string, entityId string) error {
+	if _, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleAdmin); err != nil {
+		return err
+	}
+
+	return s.repo.SetEntityLocation(ctx, entityId, nil, nil)
+}
+
+// FindNearby lists the located entities the user can reach within a radius of a point, nearest first
+func (s *EntityGeoService) FindNearby(ctx context.Context, userId string, request dto.EntityNearbyRequest) ([]*dto.GeoEntityResponse, error) {
+	filter := domain.EntityGeoFilter{
+		Latitude:      *request.Latitude,
+		Longitude:     *request.Longitude,
+		RadiusMeters:  request.Radius,
+		UnderEntityID: request.Under,
+		Limit:         geoResultLimit(request.Limit),
+	}
+
+	entities, err := s.repo.FindEntitiesNear(ctx, userId, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.GeoEntitiesToResponses(entities), nil
+}
+
+// FindInBounds lists the located entities the user can reach inside a bounding box, by name
+func (s *EntityGeoService) FindInBounds(ctx context.Context, userId string, request dto.EntityBoundsRequest) ([]*dto.GeoEntityResponse, error) {
+	if *request.MinLatitude > *request.MaxLatitude {
+		return nil, fmt.Errorf("%w: minLat must not be greater than maxLat", ErrInvalidGeoQuery)
+	}
+
+	filter := domain.EntityGeoFilter{
+		MinLatitude:   *request.MinLatitude,
+		MinLongitude:  *request.MinLongitude,
+		MaxLatitude:   *request.MaxLatitude,
+		MaxLongitude:  *request.MaxLongitude,
+		UnderEntityID: request.Under,
+		Limit:         geoResultLimit(request.Limit),
+	}
+
+	entities, err := s.repo.FindEntitiesInBox(ctx, userId, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.GeoEntitiesToResponses(entities), nil
+}
+
+// GetHierarchyGeoJSON returns the located entities among an entity and its descendants up to
+// maxDepth levels below it as a GeoJSON feature collection. Requires any role on the entity.
+func (s *EntityGeoService) GetHierarchyGeoJSON(ctx context.Context, userId string, entityId string, maxDepth int) (*dto.GeoJSONFeatureCollection, error) {
+	if _, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleViewer); err != nil {
+		return nil, err
+	}
+
+	if maxDepth <= 0 {
+		maxDepth = defaultGeoJSONDepth
+	}
+
+	entities, err := s.repo.GetSubtreeLocations(ctx, entityId, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.GeoEntitiesToFeatureCollection(entities), nil
+}
+
+// geoResultLimit applies the default to an unset result limit
+func geoResultLimit(limit int) int {
+	if limit <= 0 {
+		return defaultGeoResults
+	}
+	return limit
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrEntityMemberNotFound is returned when a user holds no membership on an entity
+	ErrEntityMemberNotFound = errors.New("entity member not found")
+	// ErrEntityMemberExists is returned when inviting a user who is already a member or invited
+	ErrEntityMemberExists = repositories.ErrDuplicateEntityMember
+	// ErrInvitationNotFound is returned when accepting an invitation that does not exist
+	ErrInvitationNotFound = errors.New("invitation not found")
+	// ErrInviteeNotFound is returned when no user has the invited email address
+	ErrInviteeNotFound = errors.New("no user with this email address")
+	// ErrInvalidInvitation is returned when an invitation cannot be issued
+	ErrInvalidInvitation = errors.New("invalid invitation")
+)
+
+// EntityMemberService manages the roles users hold on entities
+type EntityMemberService struct {
+	entityRepo repositories.EntityRepository
+	memberRepo repositories.EntityMemberRepositoryInterface
+	userRepo   repositories.UserRepositoryInterface
+}
+
+// NewEntityMemberService creates a new entity member service instance
+func NewEntityMemberService(entityRepo repositories.EntityRepository, memberRepo repositories.EntityMemberRepositoryInterface, userRepo repositories.UserRepositoryInterface) *EntityMemberService {
+	return &EntityMemberService{
+		entityRepo: entityRepo,
+		memberRepo: memberRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// ListUserEntities lists every entity the user can reach, through their own entity or
+// accepted memberships, with the strongest role they hold on it
+func (s *EntityMemberService) ListUserEntities(ctx context.Context, userId string) ([]*dto.UserEntityResponse, error) {
+	entities, err := s.entityRepo.ListAccessibleEntities(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EntityAccessesToResponses(entities), nil
+}
+
+// ListMembers lists the memberships and pending invitations granted directly on an entity
+func (s *EntityMemberService) ListMembers(ctx context.Context, userId string, entityId string) ([]*dto.EntityMemberResponse, error) {
+	if _, err := getAccessibleEntity(ctx, s.entityRepo, userId, entityId, domain.EntityRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err := s.memberRepo.ListEntityMembers(ctx, entityId)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EntityMembersToResponses(members), nil
+}
+
+// InviteMember invites the user with the given email to an entity. Admins may invite
+// admins and viewers; only owners may invite owners.
+func (s *EntityMemberService) InviteMember(ctx context.Context, userId string, entityId string, request dto.InviteEntityMemberRequest) (*dto.EntityMemberResponse, error) {
+	role := domain.EntityRole(request.Role)
+	required := domain.EntityRoleAdmin
+	if role == domain.EntityRoleOwner {
+		required = domain.EntityRoleOwner
+	}
+	if _, err := getAccessibleEntity(ctx, s.entityRepo, userId, entityId, required); err != nil {
+		return nil, err
+	}
+
+	invitee, err := s.userRepo.GetUserByEmail(ctx, strings.TrimSpace(request.Email))
+	if err != nil {
+		return nil, err
+	}
+	if invitee == nil {
+		return nil, ErrInviteeNotFound
+	}
+	if invitee.ID == userId {
+		return nil, fmt.Errorf("%w: users cannot invite themselves", ErrInvalidInvitation)
+	}
+
+	log.Printf("User %s inviting %s to entity %s as %s", userId, invitee.ID, entityId, role)
+	if err := s.memberRepo.CreateInvitation(ctx, entityId, invitee.ID, role, userId); err != nil {
+		return nil, err
+	}
+
+	member, err := s.memberRepo.GetMember(ctx, entityId, invitee.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EntityMemberToResponse(member), nil
+}
+
+// ListInvitations lists the invitations the user has not accepted yet
+func (s *EntityMemberService) ListInvitations(ctx context.Context, userId string) ([]*dto.EntityMemberResponse, error) {
+	invitations, err := s.memberRepo.ListPendingInvitations(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EntityMembersToResponses(invitations), nil
+}
+
+// AcceptInvitation activates the user's pending invitation to an entity
+func (s *EntityMemberService) AcceptInvitation(ctx context.Context, userId string, entityId string) (*dto.EntityMemberResponse, error) {
+	accepted, err := s.memberRepo.AcceptInvitation(ctx, entityId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvitationNotFound
+	}
+
+	member, err := s.memberRepo.GetMember(ctx, entityId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.EntityMemberToResponse(member), nil
+}
+
+// RemoveMember removes a membership or withdraws an invitation. Users may always remove
+// their own membership; removing someone else takes an admin, or an owner for owners.
+func (s *EntityMemberService) RemoveMember(ctx context.Context, userId string, entityId string, memberUserId string) error {
+	if memberUserId != userId {
+		if _, err := getAccessibleEntity(ctx, s.entityRepo, userId, entityId, domain.EntityRoleAdmin); err != nil {
+			return err
+		}
+	}
+
+	member, err := s.memberRepo.GetMember(ctx, entityId, memberUserId)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrEntityMemberNotFound
+	}
+
+	if memberUserId != userId && member.Role == domain.EntityRoleOwner {
+		if _, err := getAccessibleEntity(ctx, s.entityRepo, userId, entityId, domain.EntityRoleOwner); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("User %s removing %s from entity %s", userId, memberUserId, entityId)
+	removed, err := s.memberRepo.DeleteMember(ctx, entityId, memberUserId)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrEntityMemberNotFound
+	}
+
+	return nil
+}
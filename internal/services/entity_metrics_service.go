@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/rollup"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+const (
+	// entityMetricsWorkers bounds how many devices' telemetry is fetched concurrently
+	entityMetricsWorkers = 8
+	// maxEntityMetricBuckets caps the length of each returned series
+	maxEntityMetricBuckets = 10000
+)
+
+// ErrInvalidMetricsRange is returned when the requested range and interval are unusable
+var ErrInvalidMetricsRange = errors.New("invalid metrics range")
+
+// EntityMetricsService rolls up device telemetry across entity subtrees
+type EntityMetricsService struct {
+	entityRepo repositories.EntityRepository
+	deviceRepo *repositories.DeviceRepository
+}
+
+// NewEntityMetricsService creates a new entity metrics service instance
+func NewEntityMetricsService(entityRepo repositories.EntityRepository, deviceRepo *repositories.DeviceRepository) *EntityMetricsService {
+	return &EntityMetricsService{
+		entityRepo: entityRepo,
+		deviceRepo: deviceRepo,
+	}
+}
+
+// GetSubtreeMetrics aggregates the telemetry of every device beneath an entity, both for
+// the whole subtree and per direct child entity. Devices owned by the entity itself only
+// count towards the subtree totals.
+func (s *EntityMetricsService) GetSubtreeMetrics(ctx context.Context, userID, entityID string, start, end time.Time, interval time.Duration) (*dto.EntityMetricsResponse, error) {
+	if interval < time.Minute {
+		return nil, fmt.Errorf("%w: interval must be at least one minute", ErrInvalidMetricsRange)
+	}
+	if end.Sub(start)/interval > maxEntityMetricBuckets {
+		return nil, fmt.Errorf("%w: range would produce more than %d buckets", ErrInvalidMetricsRange, maxEntityMetricBuckets)
+	}
+
+	entity, err := getAccessibleEntity(ctx, s.entityRepo, userID, entityID, domain.EntityRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := s.entityRepo.GetChildEntities(ctx, entityID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.entityRepo.GetSubtreeDevices(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Rolling up metrics for entity %s across %d devices", entityID, len(devices))
+
+	aggregates, err := s.fetchAggregates(ctx, devices, start, end, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	subtree := rollup.NewSeries()
+	childSeries := make(map[string]*rollup.Series, len(children))
+	for _, child := range children {
+		childSeries[child.ID] = rollup.NewSeries()
+	}
+
+	// The child owning a device is the path label right after the entity's own path
+	childIndex := len(strings.Split(entity.Path, "."))
+	for i, device := range devices {
+		subtree.Add(device.MacAddress, aggregates[i])
+
+		labels := strings.Split(device.EntityPath, ".")
+		if len(labels) > childIndex {
+			if series, ok := childSeries[labels[childIndex]]; ok {
+				series.Add(device.MacAddress, aggregates[i])
+			}
+		}
+	}
+
+	result := &dto.EntityMetricsResponse{
+		EntityID:    entity.ID,
+		Name:        entity.Name,
+		StartTime:   start.UnixMilli(),
+		EndTime:     end.UnixMilli(),
+		IntervalMs:  interval.Milliseconds(),
+		DeviceCount: subtree.DeviceCount(),
+		Series:      mappers.RollupPointsToResponse(subtree.Points()),
+		Children:    make([]*dto.ChildEntityMetricsResponse, len(children)),
+	}
+	for i, child := range children {
+		series := childSeries[child.ID]
+		result.Children[i] = &dto.ChildEntityMetricsResponse{
+			EntityID:    child.ID,
+			Name:        child.Name,
+			DeviceCount: series.DeviceCount(),
+			Series:      mappers.RollupPointsToResponse(series.Points()),
+		}
+	}
+
+	return result, nil
+}
+
+// fetchAggregates retrieves bucketed telemetry for each device concurrently.
+// The result is indexed like devices.
+func (s *EntityMetricsService) fetchAggregates(ctx context.Context, devices []*domain.EntityDevice, start, end time.Time, interval time.Duration) ([][]*domain.SensorAggregate, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]*domain.SensorAggregate, len(devices))
+	jobs := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for range min(entityMetricsWorkers, len(devices)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				aggregates, err := s.deviceRepo.AggregateSensorData(ctx, devices[i].MacAddress, start.UnixMilli(), end.UnixMilli(), interval)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("failed to aggregate telemetry for device %s: %w", devices[i].MacAddress, err)
+						cancel()
+					})
+					continue
+				}
+				results[i] = aggregates
+			}
+		}()
+	}
+
+	for i := range devices {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
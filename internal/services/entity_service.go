@@ -2,15 +2,100 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 
 	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/hierarchy"
+	"n1h41/zolaris-backend-app/internal/pagination"
 	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/schema"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrEntityNotFound is returned when an entity does not exist
+	ErrEntityNotFound = errors.New("entity not found")
+	// ErrEntityAccessDenied is returned when the user has no role, or too weak a role, on an entity
+	ErrEntityAccessDenied = errors.New("entity is not accessible to user")
+	// ErrEntityCycle is returned when moving an entity beneath itself or its own descendant
+	ErrEntityCycle = errors.New("entity cannot be moved beneath its own subtree")
+	// ErrEntityHasChildren is returned when deleting a non-empty entity in restrict mode
+	ErrEntityHasChildren = errors.New("entity has child entities")
+	// ErrEntityProtected is returned when a user tries to move or delete an entity that is, or
+	// holds, another user's user entity, which roles inherited from an ancestor or membership
+	// would otherwise allow
+	ErrEntityProtected = errors.New("another user's entity cannot be moved or deleted")
+	// ErrUserEntityExport is returned when exporting a user entity, which cannot be imported
+	ErrUserEntityExport = errors.New("user entities cannot be exported; export the entities below it instead")
+	// ErrInvalidSearch is returned when search parameters cannot be applied
+	ErrInvalidSearch = errors.New("invalid search")
+	// ErrInvalidCursor is returned when a search cursor is malformed, tampered with or was issued for another ordering
+	ErrInvalidCursor = pagination.ErrInvalidCursor
+)
+
+const (
+	// defaultSearchPageSize is used when a search does not ask for a page size
+	defaultSearchPageSize = 20
+	// maxSearchPageSize caps the number of results per search page
+	maxSearchPageSize = 100
+	// defaultHistoryPageSize is used when a history request does not ask for a page size
+	defaultHistoryPageSize = 50
+	// maxHistoryPageSize caps the number of history entries per page
+	maxHistoryPageSize = 200
+	// maxImportEntities caps the number of entities created by one tree import
+	maxImportEntities = 5000
+	// maxTreeDepth is deeper than any real tree, so exports are never truncated
+	maxTreeDepth = 1000
 )
 
+// DetailsValidationError is returned when entity details do not match their category's schema
+type DetailsValidationError struct {
+	Errors []schema.FieldError
+}
+
+func (e *DetailsValidationError) Error() string {
+	return fmt.Sprintf("entity details do not match category schema: %d error(s)", len(e.Errors))
+}
+
+// RuleViolationError is returned when an entity placement breaks the category-type parenting rules
+type RuleViolationError struct {
+	Violations []hierarchy.Violation
+}
+
+func (e *RuleViolationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// TreeImportError is returned when an imported tree fails validation. Each error names the
+// offending node by its position in the tree, e.g. "children[2].children[0].category".
+type TreeImportError struct {
+	Errors []dto.ValidationError
+}
+
+func (e *TreeImportError) Error() string {
+	return fmt.Sprintf("entity tree is invalid: %d error(s)", len(e.Errors))
+}
+
 // EntityService provides entity-related business operations
 type EntityService struct {
-	repo repositories.EntityRepository
+	repo         repositories.EntityRepository
+	categoryRepo *repositories.CategoryRepository
+	tagRepo      *repositories.TagRepository
 }
 
 // NewEntityService creates a new entity service with the provided repository
@@ -20,6 +105,18 @@ func NewEntityService(repo repositories.EntityRepository) *EntityService {
 	}
 }
 
+// WithCategoryRepository enables enforcement of the category-type parenting rules
+func (s *EntityService) WithCategoryRepository(categoryRepo *repositories.CategoryRepository) *EntityService {
+	s.categoryRepo = categoryRepo
+	return s
+}
+
+// WithTagRepository adds each entity's tags to search results
+func (s *EntityService) WithTagRepository(tagRepo *repositories.TagRepository) *EntityService {
+	s.tagRepo = tagRepo
+	return s
+}
+
 // CheckEntityExists determines if an entity exists for a given user
 func (s *EntityService) CheckEntityExists(ctx context.Context, userId string) (bool, error) {
 	if userId == "" {
@@ -44,6 +141,18 @@ func (s *EntityService) CreateRootEntity(ctx context.Context, categoryId string,
 		details = make(map[string]any)
 	}
 
+	if err := validateEntityDetails(ctx, s.repo, categoryId, details); err != nil {
+		return "", err
+	}
+
+	categoryType, err := s.repo.GetCategoryType(ctx, categoryId)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkPlacement(ctx, string(categoryType), nil, 1); err != nil {
+		return "", err
+	}
+
 	return s.repo.CreateRootEntity(ctx, categoryId, entityName, userId, details)
 }
 
@@ -62,6 +171,29 @@ func (s *EntityService) CreateSubEntity(ctx context.Context, categoryId string,
 		details = make(map[string]any)
 	}
 
+	if err := validateEntityDetails(ctx, s.repo, categoryId, details); err != nil {
+		return "", err
+	}
+
+	// Without a parent the entity goes under the user's own entity, which needs the same role
+	accessCheckID := parentEntityID
+	if accessCheckID == "" {
+		userEntityID, err := s.repo.GetUserEntityID(ctx, userId)
+		if err != nil {
+			return "", err
+		}
+		accessCheckID = userEntityID
+	}
+	if accessCheckID != "" {
+		if _, err := getAccessibleEntity(ctx, s.repo, userId, accessCheckID, domain.EntityRoleAdmin); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.checkSubEntityPlacement(ctx, categoryId, userId, parentEntityID); err != nil {
+		return "", err
+	}
+
 	return s.repo.CreateSubEntity(ctx, categoryId, entityName, userId, details, parentEntityID)
 }
 
@@ -75,13 +207,69 @@ func (s *EntityService) GetChildEntities(ctx context.Context, entityId string, r
 	return s.repo.GetChildEntities(ctx, entityId, recursive)
 }
 
-// GetEntityHierarchy retrieves an entity and all its descendant entities as a hierarchical structure
-func (s *EntityService) GetEntityHierarchy(ctx context.Context, rootEntityId string) (map[string]any, error) {
+// GetEntityHierarchy retrieves an entity and its descendants up to maxDepth levels below it
+func (s *EntityService) GetEntityHierarchy(ctx context.Context, rootEntityId string, maxDepth int) (*dto.EntityHierarchyResponse, error) {
 	if rootEntityId == "" {
 		return nil, fmt.Errorf("root entity ID cannot be empty")
 	}
 
-	return s.repo.GetEntityHierarchy(ctx, rootEntityId)
+	nodes, err := s.repo.GetEntityHierarchy(ctx, rootEntityId, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := mappers.EntityNodesToHierarchy(rootEntityId, nodes)
+	if hierarchy == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	return hierarchy, nil
+}
+
+// GetEntityHierarchyAsOf reconstructs an entity and its descendants up to maxDepth levels
+// below it as they were at asOf. Past snapshots include deleted entities and their details,
+// so the user needs the viewer role on the entity as it is now.
+func (s *EntityService) GetEntityHierarchyAsOf(ctx context.Context, userId string, rootEntityId string, maxDepth int, asOf time.Time) (*dto.EntityHierarchyResponse, error) {
+	if _, err := getAccessibleEntity(ctx, s.repo, userId, rootEntityId, domain.EntityRoleViewer); err != nil {
+		return nil, err
+	}
+
+	nodes, err := s.repo.GetEntityHierarchyAsOf(ctx, rootEntityId, maxDepth, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := mappers.EntityNodesToHierarchy(rootEntityId, nodes)
+	if hierarchy == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	return hierarchy, nil
+}
+
+// GetEntityHistory returns a page of an entity's recorded changes, newest first
+func (s *EntityService) GetEntityHistory(ctx context.Context, userId string, entityId string, page, pageSize int) (*dto.PaginatedResponse, error) {
+	if _, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleViewer); err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultHistoryPageSize
+	}
+	pageSize = min(pageSize, maxHistoryPageSize)
+	page = max(page, 0)
+
+	entries, total, err := s.repo.ListEntityHistory(ctx, entityId, pageSize, page*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.PaginatedResponse{
+		Items:      mappers.EntityHistoryToResponses(entries),
+		TotalItems: total,
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
 }
 
 // ListEntityChildren lists all children of a given entity with optional filtering
@@ -108,3 +296,669 @@ func (s *EntityService) GetCategoryType(ctx context.Context, categoryId string)
 
 	return s.repo.GetCategoryType(ctx, categoryId)
 }
+
+// UpdateEntity renames an entity and/or replaces its details
+func (s *EntityService) UpdateEntity(ctx context.Context, userId string, entityId string, name *string, details map[string]any) (*domain.Entity, error) {
+	entity, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if details != nil {
+		if err := validateEntityDetails(ctx, s.repo, entity.CategoryID, details); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.UpdateEntity(ctx, entityId, name, details); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetEntityByID(ctx, entityId)
+}
+
+// MoveEntity re-parents an entity, carrying its whole subtree along
+func (s *EntityService) MoveEntity(ctx context.Context, userId string, entityId string, newParentId string) (*domain.Entity, error) {
+	entity, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSubtreeUnprotected(ctx, entity, userId); err != nil {
+		return nil, err
+	}
+
+	newParent, err := getAccessibleEntity(ctx, s.repo, userId, newParentId, domain.EntityRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if isPathWithin(newParent.Path, entity.Path) {
+		return nil, ErrEntityCycle
+	}
+
+	if err := s.checkMovePlacement(ctx, entity, newParent); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Moving entity %s under %s", entityId, newParentId)
+	if err := s.repo.MoveEntity(ctx, entityId, &newParent.ID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetEntityByID(ctx, entityId)
+}
+
+// DeleteEntity deletes an entity, handling its children according to mode
+func (s *EntityService) DeleteEntity(ctx context.Context, userId string, entityId string, mode repositories.EntityDeleteMode) error {
+	entity, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkSubtreeUnprotected(ctx, entity, userId); err != nil {
+		return err
+	}
+
+	log.Printf("Deleting entity %s with mode %s", entityId, mode)
+	deleted, err := s.repo.DeleteEntity(ctx, entityId, mode)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrEntityHasChildren
+	}
+
+	return nil
+}
+
+// ImportEntityTree validates a whole tree and then creates it beneath parentEntityId in one
+// transaction. Nothing is created if any node is invalid, and every problem is reported at once.
+func (s *EntityService) ImportEntityTree(ctx context.Context, userId string, parentEntityId string, root *dto.EntityTreeNode) (*dto.EntityTreeImportResponse, error) {
+	if _, err := getAccessibleEntity(ctx, s.repo, userId, parentEntityId, domain.EntityRoleAdmin); err != nil {
+		return nil, err
+	}
+	if s.categoryRepo == nil {
+		return nil, fmt.Errorf("tree import requires the category repository")
+	}
+
+	parent, err := s.repo.GetEntityPlacement(ctx, parentEntityId)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	categories, err := s.categoryRepo.ListAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	importer := newTreeImporter(categories, rules)
+	draft := importer.convert(root, "", parent.CategoryType, parent.Depth+1)
+	if importer.err != nil {
+		return nil, importer.err
+	}
+	if len(importer.errors) > 0 {
+		return nil, &TreeImportError{Errors: importer.errors}
+	}
+
+	log.Printf("Importing %d entities under %s", importer.count, parentEntityId)
+	rootId, created, err := s.repo.CreateEntityTree(ctx, parentEntityId, draft)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.EntityTreeImportResponse{RootEntityID: rootId, EntitiesCreated: created}, nil
+}
+
+// ExportEntityTree returns an entity and its subtree in the tree import/export format. Import
+// does not create user entities, so user entities below the entity are left out together with
+// their own subtrees, which belong to those users, and a user entity cannot be exported itself.
+func (s *EntityService) ExportEntityTree(ctx context.Context, userId string, entityId string) (*dto.EntityTreeNode, error) {
+	if _, err := getAccessibleEntity(ctx, s.repo, userId, entityId, domain.EntityRoleViewer); err != nil {
+		return nil, err
+	}
+
+	nodes, err := s.repo.GetEntityHierarchy(ctx, entityId, maxTreeDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]*domain.EntityNode, 0, len(nodes))
+	for _, node := range nodes {
+		if repositories.CategoryType(node.CategoryType) != repositories.UserCategoryType {
+			exported = append(exported, node)
+			continue
+		}
+		if node.ID == entityId {
+			return nil, ErrUserEntityExport
+		}
+	}
+
+	// Descendants of a left out user entity have no parent in the tree and are dropped with it
+	tree := mappers.EntityNodesToTree(entityId, exported)
+	if tree == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	return tree, nil
+}
+
+// treeImporter resolves and validates the nodes of an imported tree, collecting every problem
+type treeImporter struct {
+	byID    map[string]*domain.Category
+	byName  map[string][]*domain.Category
+	schemas map[string]*schema.Schema
+	rules   *hierarchy.Rules
+	count   int
+	errors  []dto.ValidationError
+	// err is a failure that is not the tree's fault, such as an unusable category schema
+	err error
+}
+
+func newTreeImporter(categories []*domain.Category, rules *hierarchy.Rules) *treeImporter {
+	importer := &treeImporter{
+		byID:    make(map[string]*domain.Category, len(categories)),
+		byName:  make(map[string][]*domain.Category),
+		schemas: make(map[string]*schema.Schema),
+		rules:   rules,
+	}
+	for _, category := range categories {
+		importer.byID[category.ID] = category
+		importer.byName[category.Name] = append(importer.byName[category.Name], category)
+	}
+	return importer
+}
+
+func (t *treeImporter) fail(field, message string) {
+	t.errors = append(t.errors, dto.ValidationError{Field: field, Message: message})
+}
+
+// convert validates node, to be placed at depth beneath an entity of parentType, and its
+// descendants. An empty parentType means the parent's category is unknown and rules are skipped.
+func (t *treeImporter) convert(node *dto.EntityTreeNode, path string, parentType string, depth int) *domain.EntityDraft {
+	t.count++
+	if t.count == maxImportEntities+1 {
+		t.fail(path, fmt.Sprintf("a tree may hold at most %d entities", maxImportEntities))
+	}
+	if node == nil {
+		t.fail(path, "entity is empty")
+		return nil
+	}
+
+	draft := &domain.EntityDraft{
+		Name:    strings.TrimSpace(node.Name),
+		Details: node.Details,
+	}
+	if draft.Name == "" {
+		t.fail(treeField(path, "name"), "name is required")
+	}
+
+	categoryType := ""
+	if category := t.resolveCategory(node, path); category != nil {
+		draft.CategoryID = category.ID
+		categoryType = category.Type
+		t.checkDetails(category, node.Details, path)
+		if t.rules != nil && parentType != "" {
+			for _, violation := range t.rules.Check(category.Type, &parentType, depth) {
+				t.fail(treeField(path, "category"), violation.Message)
+			}
+		}
+	}
+
+	draft.Children = make([]*domain.EntityDraft, len(node.Children))
+	for i, child := range node.Children {
+		draft.Children[i] = t.convert(child, treeField(path, fmt.Sprintf("children[%d]", i)), categoryType, depth+1)
+	}
+
+	return draft
+}
+
+// resolveCategory finds the category a node refers to by ID, or by name and optional type
+func (t *treeImporter) resolveCategory(node *dto.EntityTreeNode, path string) *domain.Category {
+	field := treeField(path, "category")
+	ref := strings.TrimSpace(node.Category)
+	if ref == "" {
+		t.fail(field, "category is required")
+		return nil
+	}
+
+	category := t.byID[ref]
+	if category == nil {
+		var matches []*domain.Category
+		for _, candidate := range t.byName[ref] {
+			if node.CategoryType == "" || candidate.Type == node.CategoryType {
+				matches = append(matches, candidate)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			t.fail(field, fmt.Sprintf("unknown category %q", ref))
+			return nil
+		case 1:
+			category = matches[0]
+		default:
+			t.fail(field, fmt.Sprintf("category %q exists for several types; set categoryType", ref))
+			return nil
+		}
+	} else if node.CategoryType != "" && node.CategoryType != category.Type {
+		t.fail(field, fmt.Sprintf("category %q is of type %s, not %s", ref, category.Type, node.CategoryType))
+		return nil
+	}
+
+	if repositories.CategoryType(category.Type) == repositories.UserCategoryType {
+		t.fail(field, "user entities cannot be imported")
+		return nil
+	}
+
+	return category
+}
+
+// checkDetails validates a node's details against its category's schema
+func (t *treeImporter) checkDetails(category *domain.Category, details map[string]any, path string) {
+	detailsSchema, compiled := t.schemas[category.ID]
+	if !compiled {
+		if len(category.DetailsSchema) > 0 {
+			var err error
+			if detailsSchema, err = schema.Compile(category.DetailsSchema); err != nil && t.err == nil {
+				t.err = fmt.Errorf("category %s has an unusable details schema: %w", category.ID, err)
+			}
+		}
+		t.schemas[category.ID] = detailsSchema
+	}
+	if detailsSchema == nil {
+		return
+	}
+
+	if details == nil {
+		details = make(map[string]any)
+	}
+	fieldErrors, err := detailsSchema.Validate(details)
+	if err != nil {
+		t.fail(treeField(path, "details"), err.Error())
+		return
+	}
+	for _, fieldError := range fieldErrors {
+		t.fail(treeField(path, fieldError.Field), fieldError.Message)
+	}
+}
+
+// treeField joins a node's position in an imported tree with one of its fields
+func treeField(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// getAccessibleEntity retrieves an entity and verifies that the user holds at least the required role on it
+func getAccessibleEntity(ctx context.Context, repo repositories.EntityRepository, userId string, entityId string, required domain.EntityRole) (*domain.Entity, error) {
+	entity, err := repo.GetEntityByID(ctx, entityId)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	role, err := repo.GetEntityRole(ctx, userId, entityId)
+	if err != nil {
+		return nil, err
+	}
+	if !role.AtLeast(required) {
+		return nil, ErrEntityAccessDenied
+	}
+
+	return entity, nil
+}
+
+// checkSubtreeUnprotected allows moving or deleting an entity unless its subtree holds a user
+// entity of someone other than userId. Moving or deleting an entity carries its descendants
+// along, and only its owner may restructure a user entity, whatever role an ancestor or
+// membership grants anyone else.
+func (s *EntityService) checkSubtreeUnprotected(ctx context.Context, entity *domain.Entity, userId string) error {
+	ownerIds, err := s.repo.GetSubtreeUserIDs(ctx, entity.ID)
+	if err != nil {
+		return err
+	}
+	return checkOwnersUnprotected(ownerIds, userId)
+}
+
+// checkOwnersUnprotected returns ErrEntityProtected if any of the user entity owners is not userId
+func checkOwnersUnprotected(ownerIds []string, userId string) error {
+	for _, ownerId := range ownerIds {
+		if ownerId != userId {
+			return ErrEntityProtected
+		}
+	}
+	return nil
+}
+
+// isPathWithin reports whether the ltree path lies at or beneath ancestor
+func isPathWithin(path, ancestor string) bool {
+	return path == ancestor || strings.HasPrefix(path, ancestor+".")
+}
+
+// SearchEntities finds entities the user can reach. The result holds one page of
+// entities, the total number of matches and, when more results follow, the next cursor.
+func (s *EntityService) SearchEntities(ctx context.Context, userId string, request dto.EntitySearchRequest) (*dto.PaginatedResponse, error) {
+	filter := domain.EntitySearchFilter{
+		Name:          strings.TrimSpace(request.Name),
+		NameMatch:     request.Match,
+		CategoryType:  request.CategoryType,
+		CategoryID:    request.CategoryID,
+		UnderEntityID: request.Under,
+		SortBy:        request.Sort,
+		Descending:    request.Order == "desc",
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = "name"
+	}
+
+	details, err := parseDetailsFilters(request.Details)
+	if err != nil {
+		return nil, err
+	}
+	filter.Details = details
+
+	if filter.Tags, err = parseTagFilters(request.Tags); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSearch, err)
+	}
+
+	pageSize := request.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultSearchPageSize
+	}
+	pageSize = min(pageSize, maxSearchPageSize)
+
+	// A cursor is only valid for the ordering it was issued with
+	order := "asc"
+	if filter.Descending {
+		order = "desc"
+	}
+	sortKey := filter.SortBy + ":" + order
+	if request.Cursor != "" {
+		cursor, err := decodeSearchCursor(request.Cursor, sortKey, filter.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		filter.AfterValue = cursor.Value
+		filter.AfterID = cursor.ID
+	} else {
+		filter.Offset = max(request.Page, 0) * pageSize
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	filter.Limit = pageSize + 1
+	nodes, total, err := s.repo.SearchEntities(ctx, userId, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.PaginatedResponse{
+		TotalItems: total,
+		Page:       request.Page,
+		PageSize:   pageSize,
+	}
+	if len(nodes) > pageSize {
+		nodes = nodes[:pageSize]
+		last := nodes[len(nodes)-1]
+		result.NextCursor = pagination.Encode(pagination.Cursor{
+			Sort:  sortKey,
+			Value: searchSortValue(last, filter.SortBy),
+			ID:    last.ID,
+		})
+	}
+	items := mappers.EntityNodesToResponses(nodes)
+
+	if s.tagRepo != nil {
+		ids := make([]string, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		tags, err := s.tagRepo.GetEntityTags(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			item.Tags = tags[item.ID]
+		}
+	}
+	result.Items = items
+
+	return result, nil
+}
+
+// parseDetailsFilters turns "key=value" pairs into a details containment object. Dotted
+// keys address nested objects; values are read as JSON when possible and as strings otherwise.
+func parseDetailsFilters(filters []string) (map[string]any, error) {
+	details := make(map[string]any)
+	for _, filter := range filters {
+		key, raw, ok := strings.Cut(filter, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w: details filter %q must be key=value", ErrInvalidSearch, filter)
+		}
+
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			value = raw
+		}
+
+		parts := strings.Split(key, ".")
+		target := details
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+	return details, nil
+}
+
+// searchSortValue returns the value of the sort column of a search result, as stored in cursors
+func searchSortValue(node *domain.EntityNode, sortBy string) string {
+	switch sortBy {
+	case "createdAt":
+		return node.CreatedAt.Format(time.RFC3339Nano)
+	case "updatedAt":
+		return node.UpdatedAt.Format(time.RFC3339Nano)
+	case "depth":
+		return strconv.Itoa(node.Depth)
+	default:
+		return node.Name
+	}
+}
+
+// decodeSearchCursor decodes a search cursor and checks it was issued for the ordering in
+// sortKey, with a sort value and entity ID of the types the search query compares them as
+func decodeSearchCursor(encoded, sortKey, sortBy string) (pagination.Cursor, error) {
+	cursor, err := pagination.Decode(encoded)
+	if err != nil {
+		return cursor, err
+	}
+	if cursor.Sort != sortKey {
+		return cursor, fmt.Errorf("%w: cursor does not match this search", ErrInvalidCursor)
+	}
+	if _, err := uuid.Parse(cursor.ID); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	switch sortBy {
+	case "createdAt", "updatedAt":
+		_, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case "depth":
+		_, err = strconv.Atoi(cursor.Value)
+	}
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// AuditTreeRules checks every existing entity against the current parenting rules
+func (s *EntityService) AuditTreeRules(ctx context.Context) (*dto.EntityRuleAuditResponse, error) {
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	placements, err := s.repo.ListEntityPlacements(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.EntityRuleAuditResponse{
+		EntitiesChecked: len(placements),
+		Violations:      make([]*dto.EntityRuleViolationResponse, 0),
+	}
+	if rules == nil {
+		return result, nil
+	}
+
+	for _, placement := range placements {
+		violations := rules.Check(placement.CategoryType, placement.ParentCategoryType, placement.Depth)
+		if len(violations) > 0 {
+			result.Violations = append(result.Violations, mappers.EntityRuleViolationToResponse(placement, violations))
+		}
+	}
+
+	return result, nil
+}
+
+// loadRules fetches the current parenting rules, or nil if rule enforcement is not configured
+func (s *EntityService) loadRules(ctx context.Context) (*hierarchy.Rules, error) {
+	if s.categoryRepo == nil {
+		return nil, nil
+	}
+
+	rules, err := s.categoryRepo.ListTypeRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return hierarchy.NewRules(rules), nil
+}
+
+// checkPlacement verifies that an entity of categoryType may sit at depth beneath a parent of parentType
+func (s *EntityService) checkPlacement(ctx context.Context, categoryType string, parentType *string, depth int) error {
+	rules, err := s.loadRules(ctx)
+	if err != nil || rules == nil {
+		return err
+	}
+
+	if violations := rules.Check(categoryType, parentType, depth); len(violations) > 0 {
+		return &RuleViolationError{Violations: violations}
+	}
+
+	return nil
+}
+
+// checkSubEntityPlacement verifies a new sub-entity against the rules. Like the repository,
+// it places the entity under the user's own entity when no parent is given.
+func (s *EntityService) checkSubEntityPlacement(ctx context.Context, categoryId string, userId string, parentEntityID string) error {
+	if parentEntityID == "" {
+		userEntityID, err := s.repo.GetUserEntityID(ctx, userId)
+		if err != nil || userEntityID == "" {
+			// A missing user entity is reported by the repository on insert
+			return err
+		}
+		parentEntityID = userEntityID
+	}
+
+	parent, err := s.repo.GetEntityPlacement(ctx, parentEntityID)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return ErrEntityNotFound
+	}
+
+	categoryType, err := s.repo.GetCategoryType(ctx, categoryId)
+	if err != nil {
+		return err
+	}
+
+	return s.checkPlacement(ctx, string(categoryType), &parent.CategoryType, parent.Depth+1)
+}
+
+// checkMovePlacement verifies that entity may move under newParent and that no entity
+// in its subtree ends up deeper than its category type allows
+func (s *EntityService) checkMovePlacement(ctx context.Context, entity *domain.Entity, newParent *domain.Entity) error {
+	rules, err := s.loadRules(ctx)
+	if err != nil || rules == nil {
+		return err
+	}
+
+	placement, err := s.repo.GetEntityPlacement(ctx, entity.ID)
+	if err != nil {
+		return err
+	}
+	parentPlacement, err := s.repo.GetEntityPlacement(ctx, newParent.ID)
+	if err != nil {
+		return err
+	}
+	if placement == nil || parentPlacement == nil {
+		return ErrEntityNotFound
+	}
+
+	newDepth := parentPlacement.Depth + 1
+	violations := rules.Check(placement.CategoryType, &parentPlacement.CategoryType, newDepth)
+
+	maxDepths, err := s.repo.GetSubtreeMaxDepths(ctx, entity.ID)
+	if err != nil {
+		return err
+	}
+	for _, categoryType := range slices.Sorted(maps.Keys(maxDepths)) {
+		depth := maxDepths[categoryType]
+		if categoryType == placement.CategoryType && depth == placement.Depth {
+			// The entity itself was already checked above
+			continue
+		}
+		if violation := rules.CheckDepth(categoryType, depth+newDepth-placement.Depth); violation != nil {
+			violations = append(violations, *violation)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &RuleViolationError{Violations: violations}
+	}
+
+	return nil
+}
+
+// validateEntityDetails checks details against the category's schema, if the category has one
+func validateEntityDetails(ctx context.Context, repo repositories.EntityRepository, categoryId string, details map[string]any) error {
+	rawSchema, err := repo.GetCategorySchema(ctx, categoryId)
+	if err != nil {
+		return err
+	}
+	if len(rawSchema) == 0 {
+		return nil
+	}
+
+	detailsSchema, err := schema.Compile(rawSchema)
+	if err != nil {
+		return fmt.Errorf("category %s has an unusable details schema: %w", categoryId, err)
+	}
+
+	fieldErrors, err := detailsSchema.Validate(details)
+	if err != nil {
+		return err
+	}
+	if len(fieldErrors) > 0 {
+		return &DetailsValidationError{Errors: fieldErrors}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"n1h41/zolaris-backend-app/internal/pagination"
+)
+
+func TestCheckOwnersUnprotected(t *testing.T) {
+	owner := "11111111-1111-1111-1111-111111111111"
+	other := "22222222-2222-2222-2222-222222222222"
+
+	t.Run("OwnerMayRestructureOwnEntity", func(t *testing.T) {
+		assert.NoError(t, checkOwnersUnprotected([]string{owner}, owner))
+	})
+
+	t.Run("AncestorAdminCannotRestructureUserEntity", func(t *testing.T) {
+		// Admins of an ancestor inherit the admin role on every user entity beneath it
+		assert.ErrorIs(t, checkOwnersUnprotected([]string{owner}, other), ErrEntityProtected)
+	})
+
+	t.Run("DescendantUserEntityProtectsSubtree", func(t *testing.T) {
+		// The office the admin targets holds the user entity of someone else further down
+		assert.ErrorIs(t, checkOwnersUnprotected([]string{other, owner}, other), ErrEntityProtected)
+	})
+
+	t.Run("SubtreesWithoutUserEntitiesAreUnprotected", func(t *testing.T) {
+		assert.NoError(t, checkOwnersUnprotected([]string{}, other))
+	})
+}
+
+func TestDecodeSearchCursor(t *testing.T) {
+	entityID := "7d1f1f0e-7a43-4c1c-9f55-0c1b2a6d9e10"
+	createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339Nano)
+
+	t.Run("ValidCursor", func(t *testing.T) {
+		encoded := pagination.Encode(pagination.Cursor{Sort: "createdAt:desc", Value: createdAt, ID: entityID})
+
+		cursor, err := decodeSearchCursor(encoded, "createdAt:desc", "createdAt")
+		require.NoError(t, err)
+		assert.Equal(t, entityID, cursor.ID)
+	})
+
+	invalid := map[string]pagination.Cursor{
+		"OtherOrdering":     {Sort: "createdAt:asc", Value: createdAt, ID: entityID},
+		"IDNotUUID":         {Sort: "createdAt:desc", Value: createdAt, ID: "1 OR 1=1"},
+		"ValueNotTimestamp": {Sort: "createdAt:desc", Value: "yesterday", ID: entityID},
+	}
+	for name, cursor := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeSearchCursor(pagination.Encode(cursor), "createdAt:desc", "createdAt")
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+
+	t.Run("DepthNotInteger", func(t *testing.T) {
+		encoded := pagination.Encode(pagination.Cursor{Sort: "depth:asc", Value: "two", ID: entityID})
+
+		_, err := decodeSearchCursor(encoded, "depth:asc", "depth")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("NameAcceptsAnyValue", func(t *testing.T) {
+		encoded := pagination.Encode(pagination.Cursor{Sort: "name:asc", Value: "2026-01-01", ID: entityID})
+
+		_, err := decodeSearchCursor(encoded, "name:asc", "name")
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/config"
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/export"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/storage"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrExportJobNotFound is returned when an export job does not exist or belongs to another user
+	ErrExportJobNotFound = errors.New("export job not found")
+	// ErrExportJobNotReady is returned when downloading a job that has not completed
+	ErrExportJobNotReady = errors.New("export job has not completed")
+	// ErrInvalidExportTimestamp is returned when an export timestamp is not a Unix time in milliseconds
+	ErrInvalidExportTimestamp = errors.New("invalid export timestamp")
+)
+
+// staleExportJobError is recorded on export jobs that were cut off by a server restart
+const staleExportJobError = "export was interrupted by a server restart"
+
+// flusher is implemented by writers that can push buffered data to the client
+type flusher interface {
+	Flush()
+}
+
+// ExportService handles sensor data exports, both streamed and asynchronous
+type ExportService struct {
+	deviceRepo   *repositories.DeviceRepository
+	jobRepo      *repositories.ExportJobRepository
+	storage      storage.Storage
+	exportConfig config.ExportConfig
+	baseURL      string
+	jobCtx       context.Context // Parent of running jobs, cancelled on shutdown
+	jobs         sync.WaitGroup
+}
+
+// NewExportService creates a new export service instance.
+// baseURL is the externally reachable server URL used to build download links.
+func NewExportService(deviceRepo *repositories.DeviceRepository, jobRepo *repositories.ExportJobRepository, store storage.Storage, exportConfig config.ExportConfig, baseURL string) *ExportService {
+	return &ExportService{
+		deviceRepo:   deviceRepo,
+		jobRepo:      jobRepo,
+		storage:      store,
+		exportConfig: exportConfig,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		jobCtx:       context.Background(),
+	}
+}
+
+// WithJobContext runs export jobs under ctx, so cancelling it stops the jobs in flight
+func (s *ExportService) WithJobContext(ctx context.Context) *ExportService {
+	s.jobCtx = ctx
+	return s
+}
+
+// WaitForJobs blocks until every export job started by this service has finished
+func (s *ExportService) WaitForJobs() {
+	s.jobs.Wait()
+}
+
+// FailStaleJobs fails the export jobs left pending or running by a previous server process.
+// Jobs younger than the job timeout are left alone, as another instance may still be running them.
+func (s *ExportService) FailStaleJobs(ctx context.Context) error {
+	failed, err := s.jobRepo.FailStaleExportJobs(ctx, time.Now().Add(-s.exportConfig.JobTimeout), staleExportJobError)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		log.Printf("Failed %d stale export jobs", failed)
+	}
+	return nil
+}
+
+// ResolveExportRange verifies device ownership and computes the export time range
+// using the same semantics as the sensor data endpoint
+func (s *ExportService) ResolveExportRange(ctx context.Context, userID, macID, timestamp, dateMode string) (time.Time, time.Time, error) {
+	timestampMs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %v", ErrInvalidExportTimestamp, err)
+	}
+
+	if _, err := getOwnedDevice(ctx, s.deviceRepo, userID, macID); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	startMs, endMs := calculateTimeRange(timestampMs, dateMode)
+	return time.UnixMilli(startMs), time.UnixMilli(endMs), nil
+}
+
+// RequiresAsync reports whether a range is too large to stream within a request
+func (s *ExportService) RequiresAsync(start, end time.Time) bool {
+	return end.Sub(start) > s.exportConfig.SyncMaxRange
+}
+
+// StreamExport writes sensor data for the range to output page by page, so memory
+// use stays flat regardless of the range size. Returns the number of rows written.
+func (s *ExportService) StreamExport(ctx context.Context, macID string, start, end time.Time, format export.Format, output io.Writer) (int64, error) {
+	writer, err := export.NewWriter(format, output)
+	if err != nil {
+		return 0, err
+	}
+
+	var rowCount int64
+	err = s.deviceRepo.StreamSensorData(ctx, macID, start.UnixMilli(), end.UnixMilli(), func(page []*domain.SensorReading) error {
+		if err := writer.Write(page); err != nil {
+			return err
+		}
+		rowCount += int64(len(page))
+		if f, ok := output.(flusher); ok {
+			f.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return rowCount, fmt.Errorf("failed to export sensor data: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return rowCount, err
+	}
+
+	return rowCount, nil
+}
+
+// StartExportJob records an export job and runs it in the background
+func (s *ExportService) StartExportJob(ctx context.Context, userID, macID string, start, end time.Time, format export.Format) (*dto.ExportJobResponse, error) {
+	job := &domain.ExportJob{
+		UserID:     userID,
+		MacAddress: macID,
+		Format:     string(format),
+		StartTime:  start,
+		EndTime:    end,
+	}
+
+	if _, err := s.jobRepo.CreateExportJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Starting export job %s for device %s", job.ID, macID)
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		s.runExportJob(*job)
+	}()
+
+	return mappers.ExportJobToResponse(job, ""), nil
+}
+
+// runExportJob writes the export to storage and records the outcome on the job. The
+// outcome is recorded even if the job timed out or was cancelled by a shutdown.
+func (s *ExportService) runExportJob(job domain.ExportJob) {
+	ctx, cancel := context.WithTimeout(s.jobCtx, s.exportConfig.JobTimeout)
+	defer cancel()
+	recordCtx := context.WithoutCancel(ctx)
+
+	if err := s.jobRepo.MarkExportJobRunning(ctx, job.ID); err != nil {
+		log.Printf("Error marking export job %s as running: %v", job.ID, err)
+	}
+
+	format := export.Format(job.Format)
+	key := fmt.Sprintf("%s/%s.%s", job.UserID, job.ID, format.Extension())
+
+	rowCount, err := s.writeExportObject(ctx, job, format, key)
+	if err != nil {
+		log.Printf("Export job %s failed: %v", job.ID, err)
+		if delErr := s.storage.Delete(recordCtx, key); delErr != nil {
+			log.Printf("Error removing partial export %s: %v", key, delErr)
+		}
+		if failErr := s.jobRepo.FailExportJob(recordCtx, job.ID, err.Error()); failErr != nil {
+			log.Printf("Error marking export job %s as failed: %v", job.ID, failErr)
+		}
+		return
+	}
+
+	if err := s.jobRepo.CompleteExportJob(recordCtx, job.ID, key, rowCount); err != nil {
+		log.Printf("Error completing export job %s: %v", job.ID, err)
+		return
+	}
+
+	log.Printf("Export job %s completed with %d rows", job.ID, rowCount)
+}
+
+// writeExportObject streams a job's export into a storage object
+func (s *ExportService) writeExportObject(ctx context.Context, job domain.ExportJob, format export.Format, key string) (int64, error) {
+	object, err := s.storage.Create(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	rowCount, err := s.StreamExport(ctx, job.MacAddress, job.StartTime, job.EndTime, format, object)
+	if closeErr := object.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close export object: %w", closeErr)
+	}
+
+	return rowCount, err
+}
+
+// GetExportJob returns the status of a user's export job
+func (s *ExportService) GetExportJob(ctx context.Context, userID, jobID string) (*dto.ExportJobResponse, error) {
+	job, err := s.getOwnedJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.ExportJobToResponse(job, s.downloadURL(job.ID)), nil
+}
+
+// OpenExportDownload opens the output of a completed export job for reading
+func (s *ExportService) OpenExportDownload(ctx context.Context, userID, jobID string) (io.ReadCloser, *domain.ExportJob, error) {
+	job, err := s.getOwnedJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if job.Status != domain.ExportJobCompleted || job.StorageKey == nil {
+		return nil, nil, ErrExportJobNotReady
+	}
+
+	reader, err := s.storage.Open(ctx, *job.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, nil, ErrExportJobNotFound
+		}
+		return nil, nil, err
+	}
+
+	return reader, job, nil
+}
+
+// getOwnedJob retrieves an export job and verifies that it belongs to the user
+func (s *ExportService) getOwnedJob(ctx context.Context, userID, jobID string) (*domain.ExportJob, error) {
+	job, err := s.jobRepo.GetExportJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if job == nil || job.UserID != userID {
+		return nil, ErrExportJobNotFound
+	}
+
+	return job, nil
+}
+
+// downloadURL builds the download link for an export job
+func (s *ExportService) downloadURL(jobID string) string {
+	return fmt.Sprintf("%s/export-jobs/%s/download", s.baseURL, jobID)
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrInvalidTag is returned when a tag or tag filter cannot be used
+	ErrInvalidTag = errors.New("invalid tag")
+	// ErrNoTagTargets is returned when a bulk tag operation names no entities or devices
+	ErrNoTagTargets = errors.New("no entities or devices given")
+)
+
+// defaultTagSuggestions is used when an autocomplete query does not ask for a limit
+const defaultTagSuggestions = 10
+
+// TagService manages the tags on entities and devices
+type TagService struct {
+	tagRepo    *repositories.TagRepository
+	entityRepo repositories.EntityRepository
+	deviceRepo *repositories.DeviceRepository
+}
+
+// NewTagService creates a new tag service instance
+func NewTagService(tagRepo *repositories.TagRepository, entityRepo repositories.EntityRepository, deviceRepo *repositories.DeviceRepository) *TagService {
+	return &TagService{
+		tagRepo:    tagRepo,
+		entityRepo: entityRepo,
+		deviceRepo: deviceRepo,
+	}
+}
+
+// ApplyTags sets tags on several entities and devices at once. The user needs the admin role
+// on every entity and must own every device; otherwise nothing is tagged.
+func (s *TagService) ApplyTags(ctx context.Context, userId string, request dto.BulkTagRequest) error {
+	tags := make([]domain.Tag, 0, len(request.Tags))
+	seen := make(map[string]bool, len(request.Tags))
+	for _, tag := range request.Tags {
+		key, err := normalizeTagKey(tag.Key)
+		if err != nil {
+			return err
+		}
+		if seen[key] {
+			return fmt.Errorf("%w: key %q is given more than once", ErrInvalidTag, key)
+		}
+		seen[key] = true
+		tags = append(tags, domain.Tag{Key: key, Value: strings.TrimSpace(tag.Value)})
+	}
+
+	entityIds, macAddresses, err := s.checkTargets(ctx, userId, request.EntityIDs, request.DeviceIDs)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("User %s tagging %d entities and %d devices", userId, len(entityIds), len(macAddresses))
+	return s.tagRepo.ApplyTags(ctx, entityIds, macAddresses, tags)
+}
+
+// RemoveTags removes tag keys from several entities and devices at once, with the same
+// permissions as ApplyTags
+func (s *TagService) RemoveTags(ctx context.Context, userId string, request dto.BulkUntagRequest) (*dto.BulkUntagResponse, error) {
+	keys := make([]string, len(request.Keys))
+	for i, key := range request.Keys {
+		var err error
+		if keys[i], err = normalizeTagKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	entityIds, macAddresses, err := s.checkTargets(ctx, userId, request.EntityIDs, request.DeviceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %s untagging %d entities and %d devices", userId, len(entityIds), len(macAddresses))
+	removed, err := s.tagRepo.RemoveTags(ctx, entityIds, macAddresses, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.BulkUntagResponse{Removed: removed}, nil
+}
+
+// SuggestTags offers tag keys, or the values of a key, starting with a prefix. Only tags on
+// entities and devices the user can reach are considered.
+func (s *TagService) SuggestTags(ctx context.Context, userId string, request dto.TagSuggestRequest) ([]*dto.TagSuggestionResponse, error) {
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultTagSuggestions
+	}
+
+	var (
+		suggestions []*domain.TagSuggestion
+		err         error
+	)
+	if request.Key == "" {
+		prefix := strings.ToLower(strings.TrimSpace(request.Prefix))
+		suggestions, err = s.tagRepo.SuggestTagKeys(ctx, userId, prefix, limit)
+	} else {
+		key := strings.ToLower(strings.TrimSpace(request.Key))
+		suggestions, err = s.tagRepo.SuggestTagValues(ctx, userId, key, strings.TrimSpace(request.Prefix), limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.TagSuggestionsToResponses(suggestions), nil
+}
+
+// checkTargets verifies the user may tag every entity and device and returns them without duplicates
+func (s *TagService) checkTargets(ctx context.Context, userId string, entityIds, macAddresses []string) ([]string, []string, error) {
+	entityIds, macAddresses = uniqueStrings(entityIds), uniqueStrings(macAddresses)
+	if len(entityIds) == 0 && len(macAddresses) == 0 {
+		return nil, nil, ErrNoTagTargets
+	}
+
+	for _, entityId := range entityIds {
+		if _, err := getAccessibleEntity(ctx, s.entityRepo, userId, entityId, domain.EntityRoleAdmin); err != nil {
+			return nil, nil, fmt.Errorf("entity %s: %w", entityId, err)
+		}
+	}
+
+	for _, macAddress := range macAddresses {
+		if _, err := getOwnedDevice(ctx, s.deviceRepo, userId, macAddress); err != nil {
+			return nil, nil, fmt.Errorf("device %s: %w", macAddress, err)
+		}
+	}
+
+	return entityIds, macAddresses, nil
+}
+
+// normalizeTagKey trims and lowercases a tag key, so keys differing only in case are one key
+func normalizeTagKey(key string) (string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return "", fmt.Errorf("%w: key cannot be empty", ErrInvalidTag)
+	}
+	if strings.Contains(key, "=") {
+		return "", fmt.Errorf("%w: key %q cannot contain '='", ErrInvalidTag, key)
+	}
+	return key, nil
+}
+
+// parseTagFilters turns "key" and "key=value" filters into tag filters
+func parseTagFilters(filters []string) ([]domain.TagFilter, error) {
+	tagFilters := make([]domain.TagFilter, 0, len(filters))
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		key, err := normalizeTagKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w in tag filter %q", err, filter)
+		}
+
+		tagFilter := domain.TagFilter{Key: key}
+		if hasValue {
+			value = strings.TrimSpace(value)
+			tagFilter.Value = &value
+		}
+		tagFilters = append(tagFilters, tagFilter)
+	}
+	return tagFilters, nil
+}
+
+// uniqueStrings drops repeated values, keeping the first occurrence of each
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"n1h41/zolaris-backend-app/internal/config"
+	"n1h41/zolaris-backend-app/internal/repositories"
+	"n1h41/zolaris-backend-app/internal/transport/dto"
+	"n1h41/zolaris-backend-app/internal/transport/mappers"
+)
+
+var (
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSelfDeletion is returned when an admin tries to delete their own account
+	ErrSelfDeletion = errors.New("admins cannot delete their own account")
+	// ErrNotDeleted is returned when restoring a record that is not deleted
+	ErrNotDeleted = repositories.ErrNotDeleted
+	// ErrRestoreBlocked is returned when restoring a record whose parent or owner is still deleted
+	ErrRestoreBlocked = repositories.ErrRestoreBlocked
+)
+
+// TrashService manages soft-deleted users, entities and devices: deleting users, restoring
+// records within the retention period and purging them once it has passed
+type TrashService struct {
+	userRepo        repositories.UserRepositoryInterface
+	entityRepo      repositories.EntityRepository
+	deviceRepo      *repositories.DeviceRepository
+	retentionConfig config.RetentionConfig
+}
+
+// NewTrashService creates a new trash service instance
+func NewTrashService(userRepo repositories.UserRepositoryInterface, entityRepo repositories.EntityRepository, deviceRepo *repositories.DeviceRepository, retentionConfig config.RetentionConfig) *TrashService {
+	return &TrashService{
+		userRepo:        userRepo,
+		entityRepo:      entityRepo,
+		deviceRepo:      deviceRepo,
+		retentionConfig: retentionConfig,
+	}
+}
+
+// DeleteUser soft-deletes a user together with their devices and entity subtree
+func (s *TrashService) DeleteUser(ctx context.Context, adminID, userID string) error {
+	if adminID == userID {
+		return ErrSelfDeletion
+	}
+
+	log.Printf("Admin %s deleting user %s", adminID, userID)
+	deleted, err := s.userRepo.SoftDeleteUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RestoreUser restores a deleted user along with the devices and entities deleted with them
+func (s *TrashService) RestoreUser(ctx context.Context, userID string) error {
+	log.Printf("Restoring user %s", userID)
+	restored, err := s.userRepo.RestoreUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RestoreEntity restores a deleted entity along with the descendants deleted with it
+func (s *TrashService) RestoreEntity(ctx context.Context, entityID string) error {
+	log.Printf("Restoring entity %s", entityID)
+	restored, err := s.entityRepo.RestoreEntity(ctx, entityID)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return ErrEntityNotFound
+	}
+
+	return nil
+}
+
+// RestoreDevice restores a deleted device to its owner
+func (s *TrashService) RestoreDevice(ctx context.Context, macID string) error {
+	log.Printf("Restoring device %s", macID)
+	restored, err := s.deviceRepo.RestoreDevice(ctx, macID)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+// ListTrash lists every deleted record that has not been purged yet, with its purge time
+func (s *TrashService) ListTrash(ctx context.Context) (*dto.TrashResponse, error) {
+	users, err := s.userRepo.ListDeletedUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := s.entityRepo.ListDeletedEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.deviceRepo.ListDeletedDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	retention := s.retentionConfig.SoftDeletePeriod
+	return &dto.TrashResponse{
+		Users:    mappers.DeletedRecordsToResponses(users, retention),
+		Entities: mappers.DeletedRecordsToResponses(entities, retention),
+		Devices:  mappers.DeletedRecordsToResponses(devices, retention),
+	}, nil
+}
+
+// PurgeExpired permanently removes records deleted longer ago than the retention period.
+// Devices and entities go first so a user's rows are gone before the user is.
+func (s *TrashService) PurgeExpired(ctx context.Context) (*dto.PurgeResponse, error) {
+	cutoff := time.Now().Add(-s.retentionConfig.SoftDeletePeriod)
+	result := &dto.PurgeResponse{}
+
+	var err error
+	if result.Devices, err = s.deviceRepo.PurgeDeletedDevices(ctx, cutoff); err != nil {
+		return nil, err
+	}
+	if result.Entities, err = s.entityRepo.PurgeDeletedEntities(ctx, cutoff); err != nil {
+		return nil, err
+	}
+	if result.Users, err = s.userRepo.PurgeDeletedUsers(ctx, cutoff); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Purged records deleted before %s: %d users, %d entities, %d devices",
+		cutoff.Format(time.RFC3339), result.Users, result.Entities, result.Devices)
+	return result, nil
+}
+
+// RunPurger purges expired records every purge interval until ctx is cancelled.
+// It returns immediately if the interval is not positive.
+func (s *TrashService) RunPurger(ctx context.Context) {
+	interval := s.retentionConfig.PurgeInterval
+	if interval <= 0 {
+		log.Println("Soft delete purge job disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.PurgeExpired(ctx); err != nil {
+			log.Printf("Error purging deleted records: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
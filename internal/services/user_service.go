@@ -1,19 +1,96 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"n1h41/zolaris-backend-app/internal/cognito"
 	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/mergepatch"
 	"n1h41/zolaris-backend-app/internal/repositories"
 	"n1h41/zolaris-backend-app/internal/transport/dto"
 	"n1h41/zolaris-backend-app/internal/transport/mappers"
+	"n1h41/zolaris-backend-app/internal/utils"
+)
+
+var (
+	// ErrReferrerNotFound is returned when a referral names an email no user has
+	ErrReferrerNotFound = errors.New("referrer not found")
+	// ErrSelfReferral is returned when users name themselves as their referrer
+	ErrSelfReferral = errors.New("users cannot refer themselves")
+	// ErrReferralExists is returned when a user who was already referred names a different referrer
+	ErrReferralExists = errors.New("user was already referred by someone else")
+	// ErrReferralCycle is returned when a user names as referrer someone they referred, directly or indirectly
+	ErrReferralCycle = repositories.ErrReferralCycle
+	// ErrInvalidReferralStats is returned when referral statistics cannot be computed for a window
+	ErrInvalidReferralStats = errors.New("invalid referral statistics window")
+	// ErrEmailInUse is returned when a user would get an email another user already has
+	ErrEmailInUse = repositories.ErrEmailInUse
+	// ErrEmailChangeUnverified is returned when a profile update changes the email. Other users find
+	// a user by their email, so it only changes once Cognito has verified the new address.
+	ErrEmailChangeUnverified = errors.New("email can only be changed by verifying the new address with the sign-in account")
+	// ErrInvalidProfilePatch is returned when a merge patch cannot be applied to a user's profile
+	ErrInvalidProfilePatch = errors.New("invalid profile patch")
+	// ErrAddressNotSet is returned when reading the address of a user who has none
+	ErrAddressNotSet = errors.New("user has no address")
+	// ErrInvalidCognitoProfile is returned when Cognito attributes cannot be stored on a user
+	ErrInvalidCognitoProfile = errors.New("invalid Cognito user attributes")
+	// ErrInvalidToken is returned when a token is not a valid ID token of the user pool
+	ErrInvalidToken = cognito.ErrInvalidToken
+	// ErrUserDeleted is returned when provisioning a Cognito identity whose user has been deleted
+	ErrUserDeleted = repositories.ErrUserDeleted
+)
+
+// ProfileValidationError is returned when a patched profile fails validation
+type ProfileValidationError struct {
+	Errors []dto.ValidationError
+}
+
+func (e *ProfileValidationError) Error() string {
+	return fmt.Sprintf("patched profile is invalid: %d error(s)", len(e.Errors))
+}
+
+const (
+	// defaultReferralDepth is used when a referral tree request does not ask for a depth
+	defaultReferralDepth = 1
+	// maxReferralDepth is the deepest level of referrals that is followed
+	maxReferralDepth = 10
+	// maxReferralBuckets caps the number of buckets in referral statistics
+	maxReferralBuckets = 500
+)
+
+// cognitoTriggers maps the Cognito trigger sources whose events provision users to whether
+// their attributes replace the stored ones. Sign-up confirmations only fill in what is
+// missing, so details a parent entered for a child account are kept; attribute changes
+// carry the user's current attributes and replace them.
+var cognitoTriggers = map[string]bool{
+	"PostConfirmation_ConfirmSignUp":         false,
+	"PostConfirmation_ConfirmForgotPassword": false,
+	"CustomMessage_UpdateUserAttribute":      true,
+	"CustomMessage_VerifyUserAttribute":      true,
+}
+
+// Onboarding steps, in the order a new user is expected to complete them
+const (
+	onboardingStepProfile = "profile"
+	onboardingStepEntity  = "entity"
+	onboardingStepDevice  = "device"
 )
 
 // UserService handles business logic for user operations
 type UserService struct {
-	userRepo repositories.UserRepositoryInterface
+	userRepo   repositories.UserRepositoryInterface
+	entityRepo *repositories.EntityRepository
+	deviceRepo *repositories.DeviceRepository
+	// tokenVerifier is set when users may be created from verified ID tokens
+	tokenVerifier *cognito.TokenVerifier
 }
 
 // NewUserService creates a new user service instance
@@ -21,11 +98,45 @@ func NewUserService(userRepo repositories.UserRepositoryInterface) *UserService
 	return &UserService{userRepo: userRepo}
 }
 
+// WithEntityRepository lets the onboarding status report whether the user has an entity
+func (s *UserService) WithEntityRepository(entityRepo repositories.EntityRepository) *UserService {
+	s.entityRepo = &entityRepo
+	return s
+}
+
+// WithDeviceRepository lets the onboarding status count the user's devices
+func (s *UserService) WithDeviceRepository(deviceRepo *repositories.DeviceRepository) *UserService {
+	s.deviceRepo = deviceRepo
+	return s
+}
+
+// WithTokenVerifier lets users be created on their first request carrying a verified ID token
+func (s *UserService) WithTokenVerifier(verifier *cognito.TokenVerifier) *UserService {
+	s.tokenVerifier = verifier
+	return s
+}
+
 func (s *UserService) GetUserIdByCognitoId(ctx context.Context, cId string) (string, error) {
 	log.Printf("Getting user ID by Cognito ID: %s", cId)
 	return s.userRepo.GetUserIdByCognitoId(ctx, cId)
 }
 
+// GetIdentityByCognitoId resolves a Cognito ID to the user's ID, role and disabled status,
+// returning nil if no user has the Cognito ID
+func (s *UserService) GetIdentityByCognitoId(ctx context.Context, cId string) (*domain.UserIdentity, error) {
+	return s.userRepo.GetIdentityByCognitoId(ctx, cId)
+}
+
+// GetIdentityByUserID returns a user's ID, role and disabled status, or nil if there is no such user
+func (s *UserService) GetIdentityByUserID(ctx context.Context, userID string) (*domain.UserIdentity, error) {
+	return s.userRepo.GetIdentityByUserID(ctx, userID)
+}
+
+// RecordImpersonation logs a request an admin made while impersonating a user
+func (s *UserService) RecordImpersonation(ctx context.Context, record *domain.ImpersonationRecord) error {
+	return s.userRepo.RecordImpersonation(ctx, record)
+}
+
 // GetUserByID retrieves a user by their ID
 func (s *UserService) GetUserByID(ctx context.Context, userID string) (*domain.User, error) {
 	log.Printf("Getting user details for user %s", userID)
@@ -58,6 +169,17 @@ func (s *UserService) UpdateUserDetails(ctx context.Context, userID string, req
 		return nil, fmt.Errorf("user not found with ID: %s", userID)
 	}
 
+	if err := checkEmailUnchanged(existingUser, req.Email); err != nil {
+		return nil, err
+	}
+
+	// Record the referral first so an invalid one leaves the profile untouched
+	if req.ReferralMail != "" {
+		if err := s.setReferrer(ctx, existingUser, req.ReferralMail); err != nil {
+			return nil, err
+		}
+	}
+
 	// Update user with new details
 	updatedUser := mappers.UserRequestToEntity(req, existingUser)
 
@@ -70,12 +192,388 @@ func (s *UserService) UpdateUserDetails(ctx context.Context, userID string, req
 	return updatedUser, nil
 }
 
-// CheckHasParentID checks if a user has a parent ID
-func (s *UserService) CheckHasParentID(ctx context.Context, userID string) (bool, error) {
-	return s.userRepo.CheckHasParentID(ctx, userID)
+// PatchUserDetails applies a JSON Merge Patch (RFC 7386) to the user's profile document, as
+// returned by UserToProfileDocument, and saves the result if it is valid
+func (s *UserService) PatchUserDetails(ctx context.Context, userID string, patch []byte) (*domain.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := json.Marshal(mappers.UserToProfileDocument(user))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode profile: %w", err)
+	}
+
+	merged, err := mergepatch.Apply(current, patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfilePatch, err)
+	}
+
+	// Members the profile does not have, such as id or createdAt, cannot be patched
+	var document dto.UserProfileDocument
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfilePatch, err)
+	}
+
+	normalizeProfileDocument(&document)
+	if validationErrs := utils.Validate(document); validationErrs != nil {
+		return nil, &ProfileValidationError{Errors: utils.CreateDtoValidationErrors(validationErrs)}
+	}
+
+	if err := checkEmailUnchanged(user, document.Email); err != nil {
+		return nil, err
+	}
+
+	mappers.ApplyProfileDocument(&document, user)
+	log.Printf("Patching profile of user %s", userID)
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserAddress returns the user's address, or ErrAddressNotSet if they have none
+func (s *UserService) GetUserAddress(ctx context.Context, userID string) (*dto.AddressOutput, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Address == nil || *user.Address == (domain.Address{}) {
+		return nil, ErrAddressNotSet
+	}
+
+	return mappers.AddressToResponse(user.Address), nil
+}
+
+// ReplaceUserAddress sets the user's address, replacing any they had
+func (s *UserService) ReplaceUserAddress(ctx context.Context, userID string, request dto.AddressRequest) (*dto.AddressOutput, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizeAddress(&request)
+	user.Address = mappers.AddressRequestToDomain(&request)
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return mappers.AddressToResponse(user.Address), nil
+}
+
+// PatchUserAddress applies a JSON Merge Patch to the user's address. It is the same as
+// patching the profile with the patch under "address", so a null patch removes the address.
+func (s *UserService) PatchUserAddress(ctx context.Context, userID string, patch []byte) (*dto.AddressOutput, error) {
+	profilePatch, err := json.Marshal(map[string]json.RawMessage{"address": patch})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfilePatch, err)
+	}
+
+	user, err := s.PatchUserDetails(ctx, userID, profilePatch)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.AddressToResponse(user.Address), nil
+}
+
+// DeleteUserAddress removes the user's address
+func (s *UserService) DeleteUserAddress(ctx context.Context, userID string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	user.Address = nil
+	return s.userRepo.UpdateUser(ctx, user)
+}
+
+// getUser loads a user, returning ErrUserNotFound if there is none
+func (s *UserService) getUser(ctx context.Context, userID string) (*domain.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// checkEmailUnchanged returns ErrEmailChangeUnverified unless email is the user's email,
+// ignoring case. Invitations to entities and child accounts are addressed by email, so the
+// profile endpoints leave it alone; it follows the verified email of the Cognito identity.
+func checkEmailUnchanged(user *domain.User, email string) error {
+	if !strings.EqualFold(email, user.Email) {
+		return ErrEmailChangeUnverified
+	}
+	return nil
+}
+
+// normalizeProfileDocument trims a patched profile and clears optional fields left empty
+func normalizeProfileDocument(document *dto.UserProfileDocument) {
+	document.Email = strings.TrimSpace(document.Email)
+	for _, field := range []**string{&document.FirstName, &document.LastName, &document.Phone} {
+		if *field == nil {
+			continue
+		}
+		if value := strings.TrimSpace(**field); value != "" {
+			*field = &value
+		} else {
+			*field = nil
+		}
+	}
+
+	if document.Address != nil {
+		normalizeAddress(document.Address)
+	}
+}
+
+// normalizeAddress trims an address and upper-cases its country code
+func normalizeAddress(address *dto.AddressRequest) {
+	address.Street1 = strings.TrimSpace(address.Street1)
+	address.Street2 = strings.TrimSpace(address.Street2)
+	address.City = strings.TrimSpace(address.City)
+	address.Region = strings.TrimSpace(address.Region)
+	address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+	address.Zip = strings.TrimSpace(address.Zip)
+}
+
+// GetOnboardingStatus reports which account setup steps the user has completed: filling in
+// their profile, creating their entity and adding a device. Users without a parent also learn
+// how many invitations to become a sub-account are waiting for them.
+func (s *UserService) GetOnboardingStatus(ctx context.Context, userID string) (*dto.OnboardingStatusResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	status := &dto.OnboardingStatusResponse{
+		ProfileComplete: isProfileComplete(user),
+		HasParentID:     user.ParentID != nil,
+		PendingSteps:    make([]string, 0, 3),
+	}
+
+	if user.ParentID == nil {
+		invitations, err := s.userRepo.ListChildInvitationsForEmail(ctx, user.Email)
+		if err != nil {
+			return nil, err
+		}
+		status.ParentInvitations = len(invitations)
+	}
+
+	if s.entityRepo != nil {
+		if status.HasEntity, err = s.entityRepo.CheckEntityPresence(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.deviceRepo != nil {
+		devices, err := s.deviceRepo.GetDevicesByUserID(ctx, userID, nil)
+		if err != nil {
+			return nil, err
+		}
+		status.DeviceCount = len(devices)
+	}
+
+	if !status.ProfileComplete {
+		status.PendingSteps = append(status.PendingSteps, onboardingStepProfile)
+	}
+	if !status.HasEntity {
+		status.PendingSteps = append(status.PendingSteps, onboardingStepEntity)
+	}
+	if status.DeviceCount == 0 {
+		status.PendingSteps = append(status.PendingSteps, onboardingStepDevice)
+	}
+	status.Complete = len(status.PendingSteps) == 0
+
+	return status, nil
+}
+
+// isProfileComplete reports whether the user has filled in every required profile field
+func isProfileComplete(user *domain.User) bool {
+	for _, field := range []*string{user.FirstName, user.LastName, user.Phone} {
+		if field == nil || *field == "" {
+			return false
+		}
+	}
+
+	address := user.Address
+	return address != nil && address.Street1 != "" && address.City != "" &&
+		address.Region != "" && address.Country != "" && address.Zip != ""
+}
+
+// setReferrer records the user with referrerEmail as the one who referred user. Naming the
+// existing referrer again is accepted so clients can resend the whole profile.
+func (s *UserService) setReferrer(ctx context.Context, user *domain.User, referrerEmail string) error {
+	referrer, err := s.userRepo.GetUserByEmail(ctx, referrerEmail)
+	if err != nil {
+		return fmt.Errorf("error retrieving referrer: %w", err)
+	}
+	if referrer == nil {
+		return ErrReferrerNotFound
+	}
+	if referrer.ID == user.ID {
+		return ErrSelfReferral
+	}
+
+	if user.ReferredBy != nil {
+		if *user.ReferredBy == referrer.ID {
+			return nil
+		}
+		return ErrReferralExists
+	}
+
+	log.Printf("Recording user %s as referred by %s", user.ID, referrer.ID)
+	set, err := s.userRepo.SetReferrer(ctx, user.ID, referrer.ID)
+	if err != nil {
+		return err
+	}
+	if !set {
+		// Another request recorded a referrer in the meantime
+		return ErrReferralExists
+	}
+
+	user.ReferredBy = &referrer.ID
+	return nil
 }
 
-func (s *UserService) ListReferredUsers(ctx context.Context, userID string) ([]*domain.User, error) {
-	log.Printf("Listing referred users for user %s", userID)
-	return s.userRepo.ListReferredUsers(ctx, userID)
+// ListReferralTree returns the users a user referred, each with the users they referred in
+// turn, down to depth levels
+func (s *UserService) ListReferralTree(ctx context.Context, userID string, depth int) ([]*dto.ReferralNodeResponse, error) {
+	if depth <= 0 {
+		depth = defaultReferralDepth
+	}
+	depth = min(depth, maxReferralDepth)
+
+	log.Printf("Listing referral tree for user %s to depth %d", userID, depth)
+	nodes, err := s.userRepo.ListReferralTree(ctx, userID, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.ReferralNodesToTree(nodes, depth), nil
+}
+
+// GetReferralStats counts a user's referrals and buckets their signups and conversions over
+// the requested window. All levels of referrals are counted unless a depth is given.
+func (s *UserService) GetReferralStats(ctx context.Context, userID string, request dto.ReferralStatsRequest) (*dto.ReferralStatsResponse, error) {
+	interval := request.Interval
+	if interval == "" {
+		interval = "day"
+	}
+
+	depth := request.Depth
+	if depth <= 0 {
+		depth = maxReferralDepth
+	}
+
+	from := time.UnixMilli(request.StartTime)
+	to := time.UnixMilli(request.EndTime)
+	bucketSize := map[string]time.Duration{"day": 24 * time.Hour, "week": 7 * 24 * time.Hour, "month": 28 * 24 * time.Hour}[interval]
+	if to.Sub(from)/bucketSize >= maxReferralBuckets {
+		return nil, fmt.Errorf("%w: at most %d %ss can be requested", ErrInvalidReferralStats, maxReferralBuckets, interval)
+	}
+
+	stats, err := s.userRepo.GetReferralStats(ctx, userID, depth, from, to, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappers.ReferralStatsToResponse(stats, interval), nil
+}
+
+// IsAdmin reports whether the user has the admin role
+func (s *UserService) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	role, err := s.userRepo.GetUserRole(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return role == domain.UserRoleAdmin, nil
+}
+
+// ProvisionCognitoUser makes sure a user exists for the Cognito identity, creating one or
+// linking an existing account with the same verified email if needed. With overwrite,
+// Cognito's attributes replace the stored ones; without it they only fill in what is missing.
+func (s *UserService) ProvisionCognitoUser(ctx context.Context, profile *domain.CognitoProfile, overwrite bool) (*dto.ProvisionedUserResponse, error) {
+	if err := validateCognitoProfile(profile); err != nil {
+		return nil, err
+	}
+
+	userID, outcome, err := s.userRepo.UpsertCognitoUser(ctx, profile, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Provisioned user %s for Cognito ID %s: %s", userID, profile.CognitoID, outcome)
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ProvisionedUserResponse{
+		Outcome: string(outcome),
+		User:    mappers.UserToResponse(user),
+	}, nil
+}
+
+// HandleCognitoEvent provisions the user a Cognito trigger event is about. Events of triggers
+// that do not provision users are ignored and return nil.
+func (s *UserService) HandleCognitoEvent(ctx context.Context, event *dto.CognitoTriggerEvent) (*dto.ProvisionedUserResponse, error) {
+	overwrite, ok := cognitoTriggers[event.TriggerSource]
+	if !ok {
+		log.Printf("Ignoring Cognito %s event for %s", event.TriggerSource, event.UserName)
+		return nil, nil
+	}
+
+	return s.ProvisionCognitoUser(ctx, mappers.CognitoAttributesToProfile(event.Request.UserAttributes), overwrite)
+}
+
+// ProvisionFromToken verifies an ID token and provisions its user, returning their identity.
+// If cognitoID is set it must be the token's subject. Returns nil if there is no token or
+// tokens are not verified.
+func (s *UserService) ProvisionFromToken(ctx context.Context, cognitoID, token string) (*domain.UserIdentity, error) {
+	if s.tokenVerifier == nil || token == "" {
+		return nil, nil
+	}
+
+	attributes, err := s.tokenVerifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if cognitoID != "" && attributes["sub"] != cognitoID {
+		return nil, fmt.Errorf("%w: token subject does not match the Cognito ID", ErrInvalidToken)
+	}
+
+	if _, err := s.ProvisionCognitoUser(ctx, mappers.CognitoAttributesToProfile(attributes), false); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetIdentityByCognitoId(ctx, attributes["sub"])
+}
+
+// validateCognitoProfile checks Cognito's attributes fit the user columns
+func validateCognitoProfile(profile *domain.CognitoProfile) error {
+	switch {
+	case profile.CognitoID == "" || utf8.RuneCountInString(profile.CognitoID) > 255:
+		return fmt.Errorf("%w: sub is required and at most 255 characters", ErrInvalidCognitoProfile)
+	case !strings.Contains(profile.Email, "@") || utf8.RuneCountInString(profile.Email) > 255:
+		return fmt.Errorf("%w: email is required and at most 255 characters", ErrInvalidCognitoProfile)
+	case profile.FirstName != nil && utf8.RuneCountInString(*profile.FirstName) > 100:
+		return fmt.Errorf("%w: given_name must be at most 100 characters", ErrInvalidCognitoProfile)
+	case profile.LastName != nil && utf8.RuneCountInString(*profile.LastName) > 100:
+		return fmt.Errorf("%w: family_name must be at most 100 characters", ErrInvalidCognitoProfile)
+	case profile.Phone != nil && utf8.RuneCountInString(*profile.Phone) > 50:
+		return fmt.Errorf("%w: phone_number must be at most 50 characters", ErrInvalidCognitoProfile)
+	}
+	return nil
 }
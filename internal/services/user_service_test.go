@@ -0,0 +1,25 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"n1h41/zolaris-backend-app/internal/domain"
+)
+
+func TestCheckEmailUnchanged(t *testing.T) {
+	user := &domain.User{ID: "user-1", Email: "alice@example.com"}
+
+	t.Run("SameEmail", func(t *testing.T) {
+		assert.NoError(t, checkEmailUnchanged(user, "alice@example.com"))
+	})
+
+	t.Run("CaseOnly", func(t *testing.T) {
+		assert.NoError(t, checkEmailUnchanged(user, "Alice@Example.com"))
+	})
+
+	t.Run("OtherEmail", func(t *testing.T) {
+		assert.ErrorIs(t, checkEmailUnchanged(user, "bob@example.com"), ErrEmailChangeUnverified)
+	})
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrObjectNotFound is returned when a stored object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// Storage persists generated files such as data exports
+type Storage interface {
+	// Create opens a new object for writing, replacing any existing object with the same key
+	Create(ctx context.Context, key string) (io.WriteCloser, error)
+	// Open opens an existing object for reading
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalStorage stores objects as files below a base directory
+type LocalStorage struct {
+	baseDir string
+}
+
+// NewLocalStorage creates a local filesystem storage rooted at baseDir
+func NewLocalStorage(baseDir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStorage{baseDir: baseDir}, nil
+}
+
+// Create opens a new file for writing
+func (s *LocalStorage) Create(_ context.Context, key string) (io.WriteCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object: %w", err)
+	}
+	return file, nil
+}
+
+// Open opens an existing file for reading
+func (s *LocalStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes a file
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// path resolves a key to a file path, rejecting keys that escape the base directory
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid object key: %s", key)
+	}
+	return filepath.Join(s.baseDir, cleaned), nil
+}
@@ -2,20 +2,43 @@ package dto
 
 import "time"
 
-// UserDetailsRequest represents a request to create or update user details
+// UserDetailsRequest represents a request to fill in a user's whole profile, replacing what
+// was there. Phone numbers are in E.164 form and countries are ISO 3166-1 alpha-2 codes.
+// ReferralMail is the email of the user who referred this one; it is recorded once, and
+// later requests must repeat the same referrer or leave it out.
 type UserDetailsRequest struct {
-	Email        string `json:"email" validate:"required,email"`
-	FirstName    string `json:"firstName" validate:"required"`
-	LastName     string `json:"lastName" validate:"required"`
-	Phone        string `json:"phone" validate:"required"`
-	Street1      string `json:"street1" validate:"required"`
-	Street2      string `json:"street2"`
-	City         string `json:"city" validate:"required"`
-	Region       string `json:"region" validate:"required"`
-	Country      string `json:"country" validate:"required"`
-	Zip          string `json:"zip" validate:"required"`
+	Email        string `json:"email" validate:"required,email,max=255"`
+	FirstName    string `json:"firstName" validate:"required,max=100"`
+	LastName     string `json:"lastName" validate:"required,max=100"`
+	Phone        string `json:"phone" validate:"required,e164"`
+	Street1      string `json:"street1" validate:"required,max=255"`
+	Street2      string `json:"street2" validate:"max=255"`
+	City         string `json:"city" validate:"required,max=100"`
+	Region       string `json:"region" validate:"required,max=100"`
+	Country      string `json:"country" validate:"required,iso3166_1_alpha2"`
+	Zip          string `json:"zip" validate:"required,max=20"`
 	ParentID     string `json:"parentId,omitempty"`
-	ReferralMail string `json:"referralMail,omitempty"`
+	ReferralMail string `json:"referralMail,omitempty" validate:"omitempty,email"`
+}
+
+// UserProfileDocument is the editable part of a user's profile. PATCH /user/details merges
+// a JSON Merge Patch into it and validates the result; null members clear optional fields.
+type UserProfileDocument struct {
+	Email     string          `json:"email" validate:"required,email,max=255"`
+	FirstName *string         `json:"firstName" validate:"omitempty,max=100"`
+	LastName  *string         `json:"lastName" validate:"omitempty,max=100"`
+	Phone     *string         `json:"phone" validate:"omitempty,e164"`
+	Address   *AddressRequest `json:"address"`
+}
+
+// AddressRequest represents a user's postal address, with the country as an ISO 3166-1 alpha-2 code
+type AddressRequest struct {
+	Street1 string `json:"street1" validate:"required,max=255"`
+	Street2 string `json:"street2,omitempty" validate:"max=255"`
+	City    string `json:"city" validate:"required,max=100"`
+	Region  string `json:"region" validate:"required,max=100"`
+	Country string `json:"country" validate:"required,iso3166_1_alpha2"`
+	Zip     string `json:"zip" validate:"required,max=20"`
 }
 
 // DeviceRequest represents a request to add a new device
@@ -32,6 +55,48 @@ type CategoryRequest struct {
 	Type string `json:"type" validate:"required,min=2,max=50"`
 }
 
+// UpdateCategoryRequest represents a request to rename a category or change its type
+type UpdateCategoryRequest struct {
+	Name string `json:"name" validate:"omitempty,min=2,max=50"`
+	Type string `json:"type" validate:"omitempty,min=2,max=50"`
+}
+
+// DeleteCategoryRequest represents the options for deleting a category
+type DeleteCategoryRequest struct {
+	// ReplacementCategoryID receives the category's entities before it is deleted
+	ReplacementCategoryID string `json:"replacementCategoryId" form:"replacementCategoryId" validate:"omitempty,uuid"`
+}
+
+// MergeCategoryRequest represents a request to merge a category into another
+type MergeCategoryRequest struct {
+	TargetCategoryID string `json:"targetCategoryId" validate:"required,uuid"`
+}
+
+// CategoryTypeRequest represents a request to add a new category type
+type CategoryTypeRequest struct {
+	Name        string `json:"name" validate:"required,min=2,max=50"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+// UpdateCategoryTypeRequest represents a request to update a category type
+type UpdateCategoryTypeRequest struct {
+	Description string `json:"description" validate:"max=255"`
+}
+
+// CategorySchemaRequest represents a request to set a category's details schema
+type CategorySchemaRequest struct {
+	// Schema is a JSON Schema (draft 2020-12) document; omit or send an empty object to remove validation
+	Schema map[string]any `json:"schema"`
+}
+
+// CategoryTypeRuleRequest represents a request to set the parenting rule of a category type
+type CategoryTypeRuleRequest struct {
+	AllowedAsRoot     bool     `json:"allowedAsRoot"`
+	AllowedChildTypes []string `json:"allowedChildTypes" validate:"dive,required"`
+	// MaxDepth is the deepest level an entity of this type may sit at (roots are level 1); omit for no limit
+	MaxDepth *int `json:"maxDepth" validate:"omitempty,min=1"`
+}
+
 // PolicyAttachRequest represents a request to attach an IoT policy
 type PolicyAttachRequest struct {
 	IdentityID string `json:"identityId" validate:"required"`
@@ -44,6 +109,81 @@ type SensorDataRequest struct {
 	DateMode    string `json:"dateMode" validate:"required,oneof=hourly daily weekly monthly yearly"`
 }
 
+// SensorReadingInput represents a single reading in an ingest request
+type SensorReadingInput struct {
+	Timestamp   int64  `json:"timestamp" validate:"required,gt=0"`
+	Amperage    string `json:"amperage"`
+	Temperature string `json:"temperature"`
+	Humidity    string `json:"humidity"`
+}
+
+// SensorDataIngestRequest represents a request to store sensor readings for a device
+type SensorDataIngestRequest struct {
+	Readings []SensorReadingInput `json:"readings" validate:"required,min=1,max=1000,dive"`
+}
+
+// SensorDataAggregateRequest represents a request to aggregate device sensor data
+type SensorDataAggregateRequest struct {
+	StartTime int64  `json:"startTime" validate:"required,gt=0"`
+	EndTime   int64  `json:"endTime" validate:"required,gtfield=StartTime"`
+	Interval  string `json:"interval" validate:"required"`
+}
+
+// SensorDataExportRequest represents a request to export device sensor data
+type SensorDataExportRequest struct {
+	Timestamp string `json:"timestamp" form:"timestamp" validate:"required,number"`
+	DateMode  string `json:"dateMode" form:"dateMode" validate:"required,oneof=hourly daily weekly monthly yearly"`
+	Format    string `json:"format" form:"format" validate:"required,oneof=csv parquet ndjson"`
+	Async     bool   `json:"async" form:"async"`
+}
+
+// DataQualityRequest represents a request to analyze sensor data quality over a window.
+// SamplePeriod and GapFactor override the configured defaults when set.
+type DataQualityRequest struct {
+	StartTime    int64   `json:"startTime" form:"startTime" validate:"required,gt=0"`
+	EndTime      int64   `json:"endTime" form:"endTime" validate:"required,gtfield=StartTime"`
+	SamplePeriod string  `json:"samplePeriod" form:"samplePeriod"`
+	GapFactor    float64 `json:"gapFactor" form:"gapFactor" validate:"omitempty,gte=1"`
+}
+
+// DeviceElectricalParamsRequest represents a request to update a device's electrical parameters
+type DeviceElectricalParamsRequest struct {
+	Voltage     float64 `json:"voltage" validate:"required,gt=0,lte=1000"`
+	PhaseCount  int     `json:"phaseCount" validate:"required,oneof=1 3"`
+	PowerFactor float64 `json:"powerFactor" validate:"required,gt=0,lte=1"`
+}
+
+// EnergyRequest represents a request to compute a device's energy consumption
+type EnergyRequest struct {
+	StartTime int64  `json:"startTime" validate:"required,gt=0"`
+	EndTime   int64  `json:"endTime" validate:"required,gtfield=StartTime"`
+	Bucket    string `json:"bucket" validate:"required,oneof=hour day month"`
+}
+
+// TariffPeriodRequest represents a time-of-use period in a tariff
+type TariffPeriodRequest struct {
+	Name      string  `json:"name"`
+	StartHour int     `json:"startHour" validate:"gte=0,lte=23"`
+	EndHour   int     `json:"endHour" validate:"gte=0,lte=24"`
+	Rate      float64 `json:"rate" validate:"gte=0"`
+}
+
+// TariffRequest represents a flat or time-of-use tariff used for cost computation
+type TariffRequest struct {
+	Type     string                `json:"type" validate:"required,oneof=flat tou"`
+	Currency string                `json:"currency,omitempty" validate:"omitempty,len=3"`
+	Rate     float64               `json:"rate" validate:"gte=0"`
+	Periods  []TariffPeriodRequest `json:"periods,omitempty" validate:"required_if=Type tou,dive"`
+}
+
+// EnergyCostRequest represents a request to compute a device's energy cost
+type EnergyCostRequest struct {
+	StartTime int64          `json:"startTime" validate:"required,gt=0"`
+	EndTime   int64          `json:"endTime" validate:"required,gtfield=StartTime"`
+	Period    string         `json:"period" validate:"required,oneof=day month"`
+	Tariff    *TariffRequest `json:"tariff,omitempty"`
+}
+
 // TimeRange defines start and end times for data filtering
 type TimeRange struct {
 	StartTime time.Time `json:"startTime"`
@@ -56,6 +196,26 @@ type PaginationParams struct {
 	PageSize int `json:"pageSize" form:"pageSize" default:"20"`
 }
 
+// EntitySearchRequest represents the query parameters of an entity search
+type EntitySearchRequest struct {
+	PaginationParams
+	Name string `json:"name" form:"name" validate:"omitempty,max=100"`
+	// Match selects how name is matched: prefix (default), contains or fuzzy
+	Match        string `json:"match" form:"match" validate:"omitempty,oneof=prefix contains fuzzy"`
+	CategoryType string `json:"categoryType" form:"categoryType" validate:"omitempty,max=50"`
+	CategoryID   string `json:"categoryId" form:"categoryId" validate:"omitempty,uuid"`
+	// Under restricts results to descendants of this entity
+	Under string `json:"under" form:"under" validate:"omitempty,uuid"`
+	// Details filters are "key=value" pairs; nested keys are dotted, e.g. "address.city=Kochi"
+	Details []string `json:"details" form:"details" validate:"max=10,dive,contains=="`
+	// Tags filters are "key" or "key=value"; entities must carry every tag
+	Tags  []string `json:"tags" form:"tags" validate:"max=10,dive,min=1,max=321"`
+	Sort  string   `json:"sort" form:"sort" validate:"omitempty,oneof=name createdAt updatedAt depth"`
+	Order string   `json:"order" form:"order" validate:"omitempty,oneof=asc desc"`
+	// Cursor continues from the end of a previous page and takes precedence over page
+	Cursor string `json:"cursor" form:"cursor"`
+}
+
 // CreateRootEntityRequest represents a request to create a root entity
 type CreateRootEntityRequest struct {
 	CategoryID string         `json:"categoryId" validate:"required,uuid"`
@@ -71,6 +231,28 @@ type CreateSubEntityRequest struct {
 	ParentEntityID string         `json:"parentEntityId,omitempty" validate:"omitempty,uuid"`
 }
 
+// UpdateEntityRequest represents a request to rename an entity or replace its details
+type UpdateEntityRequest struct {
+	Name    *string        `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// MoveEntityRequest represents a request to re-parent an entity
+type MoveEntityRequest struct {
+	ParentEntityID string `json:"parentEntityId" validate:"required,uuid"`
+}
+
+// DeleteEntityRequest represents a request to delete an entity
+type DeleteEntityRequest struct {
+	Mode string `json:"mode" form:"mode" validate:"omitempty,oneof=restrict cascade reparent"`
+}
+
+// InviteEntityMemberRequest represents a request to invite a user to an entity
+type InviteEntityMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner admin viewer"`
+}
+
 // GetEntityChildrenRequest represents a request to get children of an entity
 type GetEntityChildrenRequest struct {
 	Recursive    bool   `json:"recursive" form:"recursive" default:"false"`
@@ -80,5 +262,158 @@ type GetEntityChildrenRequest struct {
 
 // GetEntityHierarchyRequest represents a request to get an entity hierarchy
 type GetEntityHierarchyRequest struct {
-	MaxDepth int `json:"maxDepth" form:"maxDepth" default:"10"`
+	MaxDepth int `json:"maxDepth" form:"maxDepth" default:"10" validate:"omitempty,min=1,max=50"`
+}
+
+// GetEntityHierarchyAsOfRequest represents query parameters for reconstructing a past hierarchy
+type GetEntityHierarchyAsOfRequest struct {
+	MaxDepth int `json:"maxDepth" form:"maxDepth" default:"10" validate:"omitempty,min=1,max=50"`
+	// AsOf, in Unix milliseconds, reconstructs the hierarchy as it was at that time
+	AsOf int64 `json:"asOf" form:"asOf" validate:"required,gt=0"`
+}
+
+// EntityTreeNode is an entity and its descendants in the tree import/export format. Category
+// holds a category name or ID; CategoryType picks between categories that share a name.
+type EntityTreeNode struct {
+	Name         string            `json:"name" yaml:"name"`
+	Category     string            `json:"category" yaml:"category"`
+	CategoryType string            `json:"categoryType,omitempty" yaml:"categoryType,omitempty"`
+	Details      map[string]any    `json:"details,omitempty" yaml:"details,omitempty"`
+	Children     []*EntityTreeNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// ExportEntityTreeRequest represents a request to export an entity and its subtree
+type ExportEntityTreeRequest struct {
+	Format string `json:"format" form:"format" validate:"omitempty,oneof=json yaml"`
+}
+
+// EntityHistoryRequest represents a request to page through an entity's history
+type EntityHistoryRequest struct {
+	PaginationParams
+}
+
+// SetEntityLocationRequest represents a request to place an entity on the map
+type SetEntityLocationRequest struct {
+	Latitude  *float64 `json:"latitude" validate:"required,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" validate:"required,min=-180,max=180"`
+}
+
+// EntityNearbyRequest represents the query parameters of a search around a point
+type EntityNearbyRequest struct {
+	Latitude  *float64 `json:"lat" form:"lat" validate:"required,min=-90,max=90"`
+	Longitude *float64 `json:"lon" form:"lon" validate:"required,min=-180,max=180"`
+	// Radius is in meters
+	Radius float64 `json:"radius" form:"radius" validate:"required,gt=0,max=20000000"`
+	// Under restricts results to this entity and its descendants
+	Under string `json:"under" form:"under" validate:"omitempty,uuid"`
+	Limit int    `json:"limit" form:"limit" default:"100" validate:"omitempty,min=1,max=1000"`
+}
+
+// EntityBoundsRequest represents the query parameters of a bounding-box search. A box
+// whose minLon is greater than its maxLon crosses the antimeridian.
+type EntityBoundsRequest struct {
+	MinLatitude  *float64 `json:"minLat" form:"minLat" validate:"required,min=-90,max=90"`
+	MinLongitude *float64 `json:"minLon" form:"minLon" validate:"required,min=-180,max=180"`
+	MaxLatitude  *float64 `json:"maxLat" form:"maxLat" validate:"required,min=-90,max=90"`
+	MaxLongitude *float64 `json:"maxLon" form:"maxLon" validate:"required,min=-180,max=180"`
+	// Under restricts results to this entity and its descendants
+	Under string `json:"under" form:"under" validate:"omitempty,uuid"`
+	Limit int    `json:"limit" form:"limit" default:"100" validate:"omitempty,min=1,max=1000"`
+}
+
+// EntityGeoJSONRequest represents a request for the located entities of a hierarchy as GeoJSON
+type EntityGeoJSONRequest struct {
+	MaxDepth int `json:"maxDepth" form:"maxDepth" default:"10" validate:"omitempty,min=1,max=50"`
+}
+
+// ListUserDevicesRequest represents the query parameters of a device listing
+type ListUserDevicesRequest struct {
+	// Tags filters are "key" or "key=value"; devices must carry every tag
+	Tags []string `json:"tags" form:"tags" validate:"max=10,dive,min=1,max=321"`
+}
+
+// TagRequest represents a tag to set. Keys are case-insensitive; the value may be empty
+// for tags used as plain labels.
+type TagRequest struct {
+	Key   string `json:"key" validate:"required,max=64,excludes=="`
+	Value string `json:"value" validate:"max=256"`
+}
+
+// BulkTagRequest represents a request to set tags on several entities and devices at once
+type BulkTagRequest struct {
+	EntityIDs []string     `json:"entityIds" validate:"max=100,dive,uuid"`
+	DeviceIDs []string     `json:"deviceIds" validate:"max=100,dive,required,max=50"`
+	Tags      []TagRequest `json:"tags" validate:"required,min=1,max=20,dive"`
+}
+
+// BulkUntagRequest represents a request to remove tag keys from several entities and devices at once
+type BulkUntagRequest struct {
+	EntityIDs []string `json:"entityIds" validate:"max=100,dive,uuid"`
+	DeviceIDs []string `json:"deviceIds" validate:"max=100,dive,required,max=50"`
+	Keys      []string `json:"keys" validate:"required,min=1,max=20,dive,required,max=64"`
+}
+
+// TagSuggestRequest represents a tag autocomplete query. Without a key, keys starting with
+// prefix are suggested; with one, that key's values starting with prefix are.
+type TagSuggestRequest struct {
+	Key    string `json:"key" form:"key" validate:"omitempty,max=64"`
+	Prefix string `json:"prefix" form:"prefix" validate:"max=256"`
+	Limit  int    `json:"limit" form:"limit" default:"10" validate:"omitempty,min=1,max=50"`
+}
+
+// CreateChildUserRequest represents a request to create a sub-account under the requesting user
+type CreateChildUserRequest struct {
+	Email     string `json:"email" validate:"required,email,max=255"`
+	FirstName string `json:"firstName" validate:"required,max=100"`
+	LastName  string `json:"lastName" validate:"required,max=100"`
+	Phone     string `json:"phone" validate:"max=50"`
+}
+
+// ReferralTreeRequest represents a request for the users a user referred
+type ReferralTreeRequest struct {
+	// Depth is how many levels of referrals to include; 1 lists direct referrals only
+	Depth int `json:"depth" form:"depth" default:"1" validate:"omitempty,min=1,max=10"`
+}
+
+// ReferralStatsRequest represents a request for referral statistics over a time window
+type ReferralStatsRequest struct {
+	StartTime int64  `json:"startTime" form:"startTime" validate:"required,gt=0"`
+	EndTime   int64  `json:"endTime" form:"endTime" validate:"required,gtfield=StartTime"`
+	Interval  string `json:"interval" form:"interval" validate:"omitempty,oneof=day week month"`
+	// Depth is how many levels of referrals to count; all levels by default
+	Depth int `json:"depth" form:"depth" validate:"omitempty,min=1,max=10"`
+}
+
+// AdminUserSearchRequest represents the query parameters of an admin user search.
+// Query matches email, name or user ID; Status is "active" or "disabled".
+type AdminUserSearchRequest struct {
+	PaginationParams
+	Query  string `json:"q" form:"q" validate:"max=255"`
+	Role   string `json:"role" form:"role" validate:"omitempty,oneof=admin user"`
+	Status string `json:"status" form:"status" validate:"omitempty,oneof=active disabled"`
+}
+
+// UpdateUserRoleRequest represents a request to change a user's role
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin user"`
+}
+
+// ImpersonationLogRequest represents the query parameters of an impersonation log listing
+type ImpersonationLogRequest struct {
+	PaginationParams
+	AdminID string `json:"adminId" form:"adminId" validate:"omitempty,uuid"`
+	UserID  string `json:"userId" form:"userId" validate:"omitempty,uuid"`
+}
+
+// CognitoTriggerEvent is a Cognito Lambda trigger event forwarded to the webhook as-is.
+// Only the fields needed to provision the user are read.
+type CognitoTriggerEvent struct {
+	Version       string `json:"version"`
+	TriggerSource string `json:"triggerSource" validate:"required"`
+	Region        string `json:"region"`
+	UserPoolID    string `json:"userPoolId" validate:"required"`
+	UserName      string `json:"userName"`
+	Request       struct {
+		UserAttributes map[string]string `json:"userAttributes" validate:"required"`
+	} `json:"request"`
 }
@@ -27,14 +27,29 @@ type ValidationError struct {
 
 // UserResponse represents user data in API responses
 type UserResponse struct {
-	ID        string         `json:"id"`
-	Email     string         `json:"email"`
-	FirstName *string        `json:"firstName"`
-	LastName  *string        `json:"lastName"`
-	Phone     *string        `json:"phone"`
-	Address   *AddressOutput `json:"address"`
-	ParentID  string         `json:"parentId,omitempty"`
-	CreatedAt time.Time      `json:"createdAt"`
+	ID         string         `json:"id"`
+	Email      string         `json:"email"`
+	FirstName  *string        `json:"firstName"`
+	LastName   *string        `json:"lastName"`
+	Phone      *string        `json:"phone"`
+	Address    *AddressOutput `json:"address"`
+	ParentID   string         `json:"parentId,omitempty"`
+	ReferredBy string         `json:"referredBy,omitempty"`
+	DisabledAt *time.Time     `json:"disabledAt,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt"`
+}
+
+// OnboardingStatusResponse reports how far a user has got in setting up their account.
+// PendingSteps lists what is left to do, in order, from "profile", "entity" and "device".
+type OnboardingStatusResponse struct {
+	Complete        bool     `json:"complete"`
+	PendingSteps    []string `json:"pendingSteps"`
+	ProfileComplete bool     `json:"profileComplete"`
+	HasEntity       bool     `json:"hasEntity"`
+	DeviceCount     int      `json:"deviceCount"`
+	HasParentID     bool     `json:"hasParentId"`
+	// ParentInvitations counts the invitations to become a sub-account waiting for the user
+	ParentInvitations int `json:"parentInvitations"`
 }
 
 // AddressOutput represents address data in responses
@@ -47,13 +62,17 @@ type AddressOutput struct {
 	Zip     string `json:"zip"`
 }
 
-// DeviceResponse represents device data in API responses
+// DeviceResponse represents device data in API responses. Tags are only included when listing a user's devices.
 type DeviceResponse struct {
-	DeviceID    string    `json:"deviceId"`
-	DeviceName  string    `json:"deviceName"`
-	Category    string    `json:"category,omitempty"`
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `json:"createdAt"`
+	DeviceID    string            `json:"deviceId"`
+	DeviceName  string            `json:"deviceName"`
+	Category    string            `json:"category,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Voltage     float64           `json:"voltage"`
+	PhaseCount  int               `json:"phaseCount"`
+	PowerFactor float64           `json:"powerFactor"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
 }
 
 // SensorDataResponse represents sensor readings in API responses
@@ -64,11 +83,248 @@ type SensorDataResponse struct {
 	Humidity    string `json:"humidity"`
 }
 
+// SensorAggregateResponse represents summarized sensor readings for a time bucket
+type SensorAggregateResponse struct {
+	BucketStart    int64   `json:"bucketStart"`
+	Count          int64   `json:"count"`
+	AvgAmperage    float64 `json:"avgAmperage"`
+	MinAmperage    float64 `json:"minAmperage"`
+	MaxAmperage    float64 `json:"maxAmperage"`
+	AvgTemperature float64 `json:"avgTemperature"`
+	AvgHumidity    float64 `json:"avgHumidity"`
+}
+
+// DataGapResponse represents a stretch of time without readings
+type DataGapResponse struct {
+	StartTime  int64 `json:"startTime"`
+	EndTime    int64 `json:"endTime"`
+	DurationMs int64 `json:"durationMs"`
+}
+
+// DuplicateReadingResponse represents a reading that repeated the previous one
+type DuplicateReadingResponse struct {
+	Timestamp         int64 `json:"timestamp"`
+	PreviousTimestamp int64 `json:"previousTimestamp"`
+}
+
+// OutOfRangeValueResponse represents a measurement outside its physical bounds
+type OutOfRangeValueResponse struct {
+	Timestamp int64   `json:"timestamp"`
+	Field     string  `json:"field"`
+	Value     float64 `json:"value"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+}
+
+// DataQualityReportResponse represents the data quality analysis of one device.
+// Issue lists are capped; the counts are always exact.
+type DataQualityReportResponse struct {
+	MacAddress       string                      `json:"macAddress"`
+	StartTime        int64                       `json:"startTime"`
+	EndTime          int64                       `json:"endTime"`
+	SamplePeriodMs   int64                       `json:"samplePeriodMs"`
+	GapThresholdMs   int64                       `json:"gapThresholdMs"`
+	ExpectedSamples  int64                       `json:"expectedSamples"`
+	ActualSamples    int64                       `json:"actualSamples"`
+	Completeness     float64                     `json:"completeness"`
+	GapCount         int64                       `json:"gapCount"`
+	MissingMs        int64                       `json:"missingMs"`
+	Gaps             []*DataGapResponse          `json:"gaps"`
+	DuplicateCount   int64                       `json:"duplicateCount"`
+	Duplicates       []*DuplicateReadingResponse `json:"duplicates"`
+	OutOfRangeCount  int64                       `json:"outOfRangeCount"`
+	OutOfRange       []*OutOfRangeValueResponse  `json:"outOfRange"`
+	UnparseableCount int64                       `json:"unparseableCount"`
+}
+
+// DeviceDataQualitySummaryResponse represents one device's line in the fleet summary
+type DeviceDataQualitySummaryResponse struct {
+	MacAddress       string  `json:"macAddress"`
+	DeviceName       string  `json:"deviceName"`
+	UserID           string  `json:"userId"`
+	ExpectedSamples  int64   `json:"expectedSamples"`
+	ActualSamples    int64   `json:"actualSamples"`
+	Completeness     float64 `json:"completeness"`
+	GapCount         int64   `json:"gapCount"`
+	MissingMs        int64   `json:"missingMs"`
+	DuplicateCount   int64   `json:"duplicateCount"`
+	OutOfRangeCount  int64   `json:"outOfRangeCount"`
+	UnparseableCount int64   `json:"unparseableCount"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// FleetDataQualityResponse represents data quality across all devices, worst first
+type FleetDataQualityResponse struct {
+	StartTime         int64                               `json:"startTime"`
+	EndTime           int64                               `json:"endTime"`
+	SamplePeriodMs    int64                               `json:"samplePeriodMs"`
+	DeviceCount       int                                 `json:"deviceCount"`
+	DevicesWithIssues int                                 `json:"devicesWithIssues"`
+	AvgCompleteness   float64                             `json:"avgCompleteness"`
+	TotalGaps         int64                               `json:"totalGaps"`
+	TotalDuplicates   int64                               `json:"totalDuplicates"`
+	TotalOutOfRange   int64                               `json:"totalOutOfRange"`
+	Devices           []*DeviceDataQualitySummaryResponse `json:"devices"`
+}
+
+// EntityMetricPointResponse represents combined device metrics for one time bucket
+type EntityMetricPointResponse struct {
+	BucketStart    int64   `json:"bucketStart"`
+	DeviceCount    int     `json:"deviceCount"`
+	SampleCount    int64   `json:"sampleCount"`
+	TotalAmperage  float64 `json:"totalAmperage"`
+	AvgTemperature float64 `json:"avgTemperature"`
+	AvgHumidity    float64 `json:"avgHumidity"`
+}
+
+// ChildEntityMetricsResponse represents the rolled-up metrics of one child entity's subtree
+type ChildEntityMetricsResponse struct {
+	EntityID    string                       `json:"entityId"`
+	Name        string                       `json:"name"`
+	DeviceCount int                          `json:"deviceCount"`
+	Series      []*EntityMetricPointResponse `json:"series"`
+}
+
+// EntityMetricsResponse represents sensor metrics rolled up across an entity subtree
+type EntityMetricsResponse struct {
+	EntityID    string                        `json:"entityId"`
+	Name        string                        `json:"name"`
+	StartTime   int64                         `json:"startTime"`
+	EndTime     int64                         `json:"endTime"`
+	IntervalMs  int64                         `json:"intervalMs"`
+	DeviceCount int                           `json:"deviceCount"`
+	Series      []*EntityMetricPointResponse  `json:"series"`
+	Children    []*ChildEntityMetricsResponse `json:"children"`
+}
+
+// ExportJobResponse represents the status of an asynchronous export job
+type ExportJobResponse struct {
+	ID          string     `json:"id"`
+	DeviceID    string     `json:"deviceId"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	StartTime   int64      `json:"startTime"`
+	EndTime     int64      `json:"endTime"`
+	RowCount    int64      `json:"rowCount"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"downloadUrl,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// EnergyBucketResponse represents the energy consumed within a time bucket
+type EnergyBucketResponse struct {
+	Start    int64   `json:"start"`
+	End      int64   `json:"end"`
+	KWh      float64 `json:"kwh"`
+	Coverage float64 `json:"coverage"`
+}
+
+// EnergyResponse represents a device's energy consumption over a range
+type EnergyResponse struct {
+	DeviceID string                  `json:"deviceId"`
+	Bucket   string                  `json:"bucket"`
+	TotalKWh float64                 `json:"totalKwh"`
+	Buckets  []*EnergyBucketResponse `json:"buckets"`
+}
+
+// CostBucketResponse represents the energy and cost of a billing period
+type CostBucketResponse struct {
+	Start int64   `json:"start"`
+	End   int64   `json:"end"`
+	KWh   float64 `json:"kwh"`
+	Cost  float64 `json:"cost"`
+}
+
+// EnergyCostResponse represents a device's energy cost over a range
+type EnergyCostResponse struct {
+	DeviceID  string                `json:"deviceId"`
+	Period    string                `json:"period"`
+	Currency  string                `json:"currency"`
+	TotalKWh  float64               `json:"totalKwh"`
+	TotalCost float64               `json:"totalCost"`
+	Buckets   []*CostBucketResponse `json:"buckets"`
+}
+
 // CategoryResponse represents category data in API responses
 type CategoryResponse struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Type string `json:"type"`
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	DetailsSchema map[string]any `json:"detailsSchema,omitempty"`
+	SchemaVersion int            `json:"schemaVersion"`
+	// EntityCount is the number of entities using the category, included when listing all categories
+	EntityCount *int `json:"entityCount,omitempty"`
+}
+
+// CategoryMergeResponse reports the result of merging or deleting a category
+type CategoryMergeResponse struct {
+	SourceCategoryID   string `json:"sourceCategoryId"`
+	TargetCategoryID   string `json:"targetCategoryId,omitempty"`
+	EntitiesMoved      int64  `json:"entitiesMoved"`
+	NonConformingCount int    `json:"nonConformingCount"`
+}
+
+// CategoryDuplicateGroupResponse lists categories of one type whose names differ only by case or whitespace
+type CategoryDuplicateGroupResponse struct {
+	Type           string              `json:"type"`
+	NormalizedName string              `json:"normalizedName"`
+	Categories     []*CategoryResponse `json:"categories"`
+}
+
+// CategorySchemaUpdateResponse summarises a schema change and the re-check of existing entities
+type CategorySchemaUpdateResponse struct {
+	CategoryID         string `json:"categoryId"`
+	SchemaVersion      int    `json:"schemaVersion"`
+	EntitiesChecked    int    `json:"entitiesChecked"`
+	NonConformingCount int    `json:"nonConformingCount"`
+}
+
+// CategoryTypeResponse represents a category type in API responses
+type CategoryTypeResponse struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsSystem    bool      `json:"isSystem"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// CategoryTypeRuleResponse represents the parenting rule of a category type
+type CategoryTypeRuleResponse struct {
+	CategoryType      string    `json:"categoryType"`
+	AllowedAsRoot     bool      `json:"allowedAsRoot"`
+	AllowedChildTypes []string  `json:"allowedChildTypes"`
+	MaxDepth          *int      `json:"maxDepth,omitempty"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// RuleViolationResponse describes one way an entity placement breaks the parenting rules
+type RuleViolationResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// EntityRuleViolationResponse represents an existing entity that breaks the parenting rules
+type EntityRuleViolationResponse struct {
+	EntityID     string                  `json:"entityId"`
+	Name         string                  `json:"name"`
+	CategoryType string                  `json:"categoryType"`
+	ParentID     string                  `json:"parentId,omitempty"`
+	Depth        int                     `json:"depth"`
+	Violations   []RuleViolationResponse `json:"violations"`
+}
+
+// EntityRuleAuditResponse reports the result of checking every entity against the parenting rules
+type EntityRuleAuditResponse struct {
+	EntitiesChecked int                            `json:"entitiesChecked"`
+	Violations      []*EntityRuleViolationResponse `json:"violations"`
+}
+
+// NonConformingEntityResponse represents an entity whose details do not match its category schema
+type NonConformingEntityResponse struct {
+	EntityID string            `json:"entityId"`
+	Name     string            `json:"name"`
+	Errors   []ValidationError `json:"errors"`
 }
 
 // PaginatedResponse wraps list responses with pagination metadata
@@ -78,27 +334,34 @@ type PaginatedResponse struct {
 	Page       int   `json:"page"`
 	PageSize   int   `json:"pageSize"`
 	TotalPages int   `json:"totalPages"`
+	// NextCursor fetches the following page, when there is one
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
-// EntityResponse represents an entity in API responses
+// EntityResponse represents an entity in API responses. Tags are only included in search results.
 type EntityResponse struct {
-	ID           string         `json:"id"`
-	Name         string         `json:"name"`
-	UserID       string         `json:"userId,omitempty"`
-	CategoryID   string         `json:"categoryId"`
-	CategoryName string         `json:"categoryName,omitempty"`
-	CategoryType string         `json:"categoryType,omitempty"`
-	ParentID     string         `json:"parentId,omitempty"`
-	Details      map[string]any `json:"details,omitempty"`
-	Depth        int            `json:"depth"`
-	CreatedAt    time.Time      `json:"createdAt"`
-	UpdatedAt    time.Time      `json:"updatedAt"`
-}
-
-// EntityHierarchyResponse represents an entity with its children in API responses
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	UserID       string            `json:"userId,omitempty"`
+	CategoryID   string            `json:"categoryId"`
+	CategoryName string            `json:"categoryName,omitempty"`
+	CategoryType string            `json:"categoryType,omitempty"`
+	ParentID     string            `json:"parentId,omitempty"`
+	Details      map[string]any    `json:"details,omitempty"`
+	Depth        int               `json:"depth"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+}
+
+// EntityHierarchyResponse represents an entity with its children in API responses.
+// HasMore is set when the node has children beyond the requested depth; they can be
+// loaded by requesting the hierarchy of that node.
 type EntityHierarchyResponse struct {
 	EntityResponse
-	Children []EntityHierarchyResponse `json:"children,omitempty"`
+	ChildCount int                        `json:"childCount"`
+	HasMore    bool                       `json:"hasMore"`
+	Children   []*EntityHierarchyResponse `json:"children,omitempty"`
 }
 
 // EntityChildrenResponse represents a list of entity children
@@ -107,3 +370,228 @@ type EntityChildrenResponse struct {
 	Children []*EntityResponse `json:"children"`
 	Count    int               `json:"count"`
 }
+
+// UserEntityResponse represents an entity the user can reach and the role they hold on it.
+// GrantedEntityID is the entity the role was granted on: the entity itself or an ancestor.
+type UserEntityResponse struct {
+	EntityResponse
+	Role            string `json:"role"`
+	GrantedEntityID string `json:"grantedEntityId"`
+}
+
+// EntityMemberResponse represents a membership or pending invitation on an entity
+type EntityMemberResponse struct {
+	EntityID   string     `json:"entityId"`
+	EntityName string     `json:"entityName"`
+	UserID     string     `json:"userId"`
+	Email      string     `json:"email"`
+	FirstName  string     `json:"firstName,omitempty"`
+	LastName   string     `json:"lastName,omitempty"`
+	Role       string     `json:"role"`
+	Status     string     `json:"status"`
+	InvitedBy  string     `json:"invitedBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+}
+
+// ChildInvitationResponse represents an invitation for a user to become a parent's sub-account
+type ChildInvitationResponse struct {
+	ID              string    `json:"id"`
+	ParentID        string    `json:"parentId"`
+	ParentEmail     string    `json:"parentEmail"`
+	ParentFirstName string    `json:"parentFirstName,omitempty"`
+	ParentLastName  string    `json:"parentLastName,omitempty"`
+	Email           string    `json:"email"`
+	FirstName       string    `json:"firstName,omitempty"`
+	LastName        string    `json:"lastName,omitempty"`
+	Phone           string    `json:"phone,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// EntityHistoryResponse represents a recorded change to an entity. Before and After hold
+// the entity as it was before and after the change, as stored.
+type EntityHistoryResponse struct {
+	ID          int64          `json:"id"`
+	EntityID    string         `json:"entityId"`
+	Action      string         `json:"action"`
+	ActorUserID string         `json:"actorUserId,omitempty"`
+	Before      map[string]any `json:"before,omitempty"`
+	After       map[string]any `json:"after,omitempty"`
+	ChangedAt   time.Time      `json:"changedAt"`
+}
+
+// DeletedRecordResponse represents a soft-deleted user, entity or device and when it will be purged
+type DeletedRecordResponse struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deletedAt"`
+	PurgeAt   time.Time `json:"purgeAt"`
+}
+
+// TrashResponse lists the soft-deleted records that can still be restored
+type TrashResponse struct {
+	Users    []*DeletedRecordResponse `json:"users"`
+	Entities []*DeletedRecordResponse `json:"entities"`
+	Devices  []*DeletedRecordResponse `json:"devices"`
+}
+
+// PurgeResponse reports how many expired records of each kind were permanently removed
+type PurgeResponse struct {
+	Users    int64 `json:"users"`
+	Entities int64 `json:"entities"`
+	Devices  int64 `json:"devices"`
+}
+
+// EntityTreeImportResponse reports the result of importing an entity tree
+type EntityTreeImportResponse struct {
+	RootEntityID    string `json:"rootEntityId"`
+	EntitiesCreated int    `json:"entitiesCreated"`
+}
+
+// GeoEntityResponse represents an entity placed on the map. DistanceMeters is set on
+// results of a search around a point.
+type GeoEntityResponse struct {
+	EntityResponse
+	Latitude       float64  `json:"latitude"`
+	Longitude      float64  `json:"longitude"`
+	DistanceMeters *float64 `json:"distanceMeters,omitempty"`
+}
+
+// GeoJSONFeatureCollection is a GeoJSON (RFC 7946) feature collection
+type GeoJSONFeatureCollection struct {
+	Type     string            `json:"type"`
+	Features []*GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature is a GeoJSON feature locating a single entity
+type GeoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id"`
+	Geometry   GeoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// GeoJSONGeometry is a GeoJSON point. Coordinates are longitude first, then latitude.
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// BulkUntagResponse reports how many tags a bulk removal removed
+type BulkUntagResponse struct {
+	Removed int64 `json:"removed"`
+}
+
+// TagSuggestionResponse represents a tag key or value offered by autocomplete
+type TagSuggestionResponse struct {
+	Text  string `json:"text"`
+	Count int64  `json:"count"`
+}
+
+// ReferralNodeResponse represents a referred user and the users they referred in turn.
+// HasMore is set when they referred users beyond the requested depth.
+type ReferralNodeResponse struct {
+	UserID        string                  `json:"userId"`
+	Email         string                  `json:"email"`
+	FirstName     *string                 `json:"firstName"`
+	LastName      *string                 `json:"lastName"`
+	ReferredAt    time.Time               `json:"referredAt"`
+	Depth         int                     `json:"depth"`
+	ReferralCount int                     `json:"referralCount"`
+	HasMore       bool                    `json:"hasMore"`
+	Referrals     []*ReferralNodeResponse `json:"referrals,omitempty"`
+}
+
+// ReferralStatsResponse summarizes a user's referrals. Totals cover all time; buckets count
+// the signups and conversions (first device registered) within the requested window.
+type ReferralStatsResponse struct {
+	Direct         int64                     `json:"direct"`
+	Total          int64                     `json:"total"`
+	Converted      int64                     `json:"converted"`
+	ConversionRate float64                   `json:"conversionRate"`
+	Interval       string                    `json:"interval"`
+	Buckets        []*ReferralBucketResponse `json:"buckets"`
+}
+
+// ReferralBucketResponse counts referral signups and conversions within one time bucket
+type ReferralBucketResponse struct {
+	Start       time.Time `json:"start"`
+	Signups     int64     `json:"signups"`
+	Conversions int64     `json:"conversions"`
+}
+
+// AdminUserResponse represents a user as seen by admins. SignedUp is false for accounts
+// created by a parent whose owner has not signed up yet.
+type AdminUserResponse struct {
+	UserResponse
+	Role     string `json:"role"`
+	SignedUp bool   `json:"signedUp"`
+}
+
+// AdminUserDetailResponse represents a user with their devices, entities and child accounts
+type AdminUserDetailResponse struct {
+	AdminUserResponse
+	Devices  []*DeviceResponse `json:"devices"`
+	Entities []*EntityResponse `json:"entities"`
+	Children []*UserResponse   `json:"children"`
+}
+
+// ImpersonationLogResponse represents a request an admin made while impersonating a user
+type ImpersonationLogResponse struct {
+	ID          int64     `json:"id"`
+	AdminUserID string    `json:"adminUserId"`
+	UserID      string    `json:"userId"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Status      int       `json:"status"`
+	ClientIP    string    `json:"clientIp,omitempty"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+// IdentityCacheStatsResponse reports how well this instance's identity cache is serving the
+// auth path since it started. HitRate is Hits over all lookups, or 0 before the first lookup.
+type IdentityCacheStatsResponse struct {
+	Enabled      bool    `json:"enabled"`
+	Hits         int64   `json:"hits"`
+	Misses       int64   `json:"misses"`
+	SharedHits   int64   `json:"sharedHits"`
+	SharedErrors int64   `json:"sharedErrors"`
+	Evictions    int64   `json:"evictions"`
+	Entries      int     `json:"entries"`
+	HitRate      float64 `json:"hitRate"`
+}
+
+// AccountDeletionResponse represents a pending account deletion. The account and everything
+// it owns are erased at ScheduledAt unless the deletion is cancelled before then.
+type AccountDeletionResponse struct {
+	RequestedAt time.Time `json:"requestedAt"`
+	ScheduledAt time.Time `json:"scheduledAt"`
+}
+
+// DataExportManifest describes the contents of a personal data export archive
+type DataExportManifest struct {
+	UserID           string                     `json:"userId"`
+	GeneratedAt      time.Time                  `json:"generatedAt"`
+	SensorDataFrom   time.Time                  `json:"sensorDataFrom"`
+	SensorDataTo     time.Time                  `json:"sensorDataTo"`
+	MaxRowsPerDevice int                        `json:"maxRowsPerDevice"`
+	Files            []string                   `json:"files"`
+	SensorData       []*DataExportSensorSummary `json:"sensorData"`
+}
+
+// DataExportSensorSummary describes the sensor readings exported for one device. Truncated
+// is set when the device had more readings in the window than were exported.
+type DataExportSensorSummary struct {
+	DeviceID  string `json:"deviceId"`
+	File      string `json:"file"`
+	Rows      int    `json:"rows"`
+	Truncated bool   `json:"truncated"`
+}
+
+// ProvisionedUserResponse represents the user a Cognito identity was provisioned to.
+// Outcome is "created", "linked" or "updated".
+type ProvisionedUserResponse struct {
+	Outcome string        `json:"outcome"`
+	User    *UserResponse `json:"user"`
+}
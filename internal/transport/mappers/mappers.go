@@ -2,9 +2,16 @@ package mappers
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
+	"n1h41/zolaris-backend-app/internal/cache"
 	"n1h41/zolaris-backend-app/internal/domain"
+	"n1h41/zolaris-backend-app/internal/energy"
+	"n1h41/zolaris-backend-app/internal/hierarchy"
+	"n1h41/zolaris-backend-app/internal/quality"
+	"n1h41/zolaris-backend-app/internal/rollup"
+	"n1h41/zolaris-backend-app/internal/schema"
 	"n1h41/zolaris-backend-app/internal/transport/dto"
 )
 
@@ -41,13 +48,18 @@ func UserToResponse(user *domain.User) *dto.UserResponse {
 			Country: user.Address.Country,
 			Zip:     user.Address.Zip,
 		},
-		CreatedAt: user.CreatedAt,
+		DisabledAt: user.DisabledAt,
+		CreatedAt:  user.CreatedAt,
 	}
 
 	if user.ParentID != nil {
 		response.ParentID = *user.ParentID
 	}
 
+	if user.ReferredBy != nil {
+		response.ReferredBy = *user.ReferredBy
+	}
+
 	return response
 }
 
@@ -62,7 +74,6 @@ func UserRequestToEntity(req *dto.UserDetailsRequest, existingUser *domain.User)
 		user = domain.NewUser(req.Email, req.FirstName, req.LastName, req.Phone)
 	}
 
-	user.Email = req.Email
 	user.FirstName = &req.FirstName
 	user.LastName = &req.LastName
 	user.Phone = &req.Phone
@@ -82,6 +93,72 @@ func UserRequestToEntity(req *dto.UserDetailsRequest, existingUser *domain.User)
 	return user
 }
 
+// UserToProfileDocument converts a domain User to the editable profile document that
+// PATCH /user/details merges into. An address with nothing filled in is left out.
+func UserToProfileDocument(user *domain.User) *dto.UserProfileDocument {
+	document := &dto.UserProfileDocument{
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Phone:     user.Phone,
+	}
+
+	if user.Address != nil && *user.Address != (domain.Address{}) {
+		document.Address = &dto.AddressRequest{
+			Street1: user.Address.Street1,
+			Street2: user.Address.Street2,
+			City:    user.Address.City,
+			Region:  user.Address.Region,
+			Country: user.Address.Country,
+			Zip:     user.Address.Zip,
+		}
+	}
+
+	return document
+}
+
+// ApplyProfileDocument copies a profile document onto a domain User. The email is not
+// copied; it only changes when Cognito verifies a new one.
+func ApplyProfileDocument(document *dto.UserProfileDocument, user *domain.User) {
+	user.FirstName = document.FirstName
+	user.LastName = document.LastName
+	user.Phone = document.Phone
+	user.Address = AddressRequestToDomain(document.Address)
+	user.UpdatedAt = time.Now()
+}
+
+// AddressRequestToDomain converts an AddressRequest DTO to a domain Address
+func AddressRequestToDomain(address *dto.AddressRequest) *domain.Address {
+	if address == nil {
+		return nil
+	}
+
+	return &domain.Address{
+		Street1: address.Street1,
+		Street2: address.Street2,
+		City:    address.City,
+		Region:  address.Region,
+		Country: address.Country,
+		Zip:     address.Zip,
+	}
+}
+
+// AddressToResponse converts a domain Address to an AddressOutput DTO
+func AddressToResponse(address *domain.Address) *dto.AddressOutput {
+	if address == nil {
+		return nil
+	}
+
+	return &dto.AddressOutput{
+		Street1: address.Street1,
+		Street2: address.Street2,
+		City:    address.City,
+		Region:  address.Region,
+		Country: address.Country,
+		Zip:     address.Zip,
+	}
+}
+
 // DeviceToResponse converts a domain Device to a DeviceResponse DTO
 func DeviceToResponse(device *domain.Device) *dto.DeviceResponse {
 	if device == nil {
@@ -89,9 +166,12 @@ func DeviceToResponse(device *domain.Device) *dto.DeviceResponse {
 	}
 
 	response := &dto.DeviceResponse{
-		DeviceID:   device.MacAddress,
-		DeviceName: device.Name,
-		CreatedAt:  device.CreatedAt,
+		DeviceID:    device.MacAddress,
+		DeviceName:  device.Name,
+		Voltage:     device.Voltage,
+		PhaseCount:  device.PhaseCount,
+		PowerFactor: device.PowerFactor,
+		CreatedAt:   device.CreatedAt,
 	}
 
 	if device.Category != nil {
@@ -134,20 +214,360 @@ func SensorReadingToResponse(reading *domain.SensorReading) *dto.SensorDataRespo
 	}
 }
 
+// ExportJobToResponse converts a domain ExportJob to an ExportJobResponse DTO.
+// downloadURL is only included once the job has completed.
+func ExportJobToResponse(job *domain.ExportJob, downloadURL string) *dto.ExportJobResponse {
+	if job == nil {
+		return nil
+	}
+
+	response := &dto.ExportJobResponse{
+		ID:          job.ID,
+		DeviceID:    job.MacAddress,
+		Format:      job.Format,
+		Status:      string(job.Status),
+		StartTime:   job.StartTime.UnixMilli(),
+		EndTime:     job.EndTime.UnixMilli(),
+		RowCount:    job.RowCount,
+		CreatedAt:   job.CreatedAt,
+		CompletedAt: job.CompletedAt,
+	}
+
+	if job.Error != nil {
+		response.Error = *job.Error
+	}
+
+	if job.Status == domain.ExportJobCompleted {
+		response.DownloadURL = downloadURL
+	}
+
+	return response
+}
+
+// EnergyBucketsToResponse converts energy buckets to an EnergyResponse DTO
+func EnergyBucketsToResponse(deviceID string, bucket energy.BucketSize, buckets []energy.Bucket) *dto.EnergyResponse {
+	response := &dto.EnergyResponse{
+		DeviceID: deviceID,
+		Bucket:   string(bucket),
+		Buckets:  make([]*dto.EnergyBucketResponse, len(buckets)),
+	}
+
+	for i, b := range buckets {
+		response.TotalKWh += b.KWh
+		response.Buckets[i] = &dto.EnergyBucketResponse{
+			Start:    b.Start.UnixMilli(),
+			End:      b.End.UnixMilli(),
+			KWh:      b.KWh,
+			Coverage: b.Coverage(),
+		}
+	}
+
+	return response
+}
+
+// CostBucketsToResponse converts cost buckets to an EnergyCostResponse DTO
+func CostBucketsToResponse(deviceID string, period energy.BucketSize, currency string, buckets []energy.CostBucket) *dto.EnergyCostResponse {
+	response := &dto.EnergyCostResponse{
+		DeviceID: deviceID,
+		Period:   string(period),
+		Currency: currency,
+		Buckets:  make([]*dto.CostBucketResponse, len(buckets)),
+	}
+
+	for i, b := range buckets {
+		response.TotalKWh += b.KWh
+		response.TotalCost += b.Cost
+		response.Buckets[i] = &dto.CostBucketResponse{
+			Start: b.Start.UnixMilli(),
+			End:   b.End.UnixMilli(),
+			KWh:   b.KWh,
+			Cost:  b.Cost,
+		}
+	}
+
+	return response
+}
+
+// TariffRequestToTariff converts a TariffRequest DTO to an energy tariff
+func TariffRequestToTariff(req *dto.TariffRequest, defaultCurrency string, location *time.Location) energy.Tariff {
+	currency := req.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
+	if req.Type == "flat" {
+		return energy.FlatTariff{Rate: req.Rate, CurrencyCode: currency}
+	}
+
+	periods := make([]energy.TimeOfUsePeriod, len(req.Periods))
+	for i, p := range req.Periods {
+		periods[i] = energy.TimeOfUsePeriod{
+			Name:      p.Name,
+			StartHour: p.StartHour,
+			EndHour:   p.EndHour,
+			Rate:      p.Rate,
+		}
+	}
+
+	return energy.TimeOfUseTariff{
+		Periods:      periods,
+		DefaultRate:  req.Rate,
+		CurrencyCode: currency,
+		Location:     location,
+	}
+}
+
+// SensorReadingInputsToDomain converts ingest request readings to domain sensor readings
+func SensorReadingInputsToDomain(macID string, inputs []dto.SensorReadingInput) []*domain.SensorReading {
+	readings := make([]*domain.SensorReading, len(inputs))
+	for i, input := range inputs {
+		readings[i] = &domain.SensorReading{
+			DeviceID:    macID,
+			Timestamp:   time.UnixMilli(input.Timestamp),
+			Amperage:    input.Amperage,
+			Temperature: input.Temperature,
+			Humidity:    input.Humidity,
+		}
+	}
+	return readings
+}
+
+// SensorAggregateToResponse converts a domain SensorAggregate to a SensorAggregateResponse DTO
+func SensorAggregateToResponse(aggregate *domain.SensorAggregate) *dto.SensorAggregateResponse {
+	if aggregate == nil {
+		return nil
+	}
+
+	return &dto.SensorAggregateResponse{
+		BucketStart:    aggregate.BucketStart.UnixMilli(),
+		Count:          aggregate.Count,
+		AvgAmperage:    aggregate.AvgAmperage,
+		MinAmperage:    aggregate.MinAmperage,
+		MaxAmperage:    aggregate.MaxAmperage,
+		AvgTemperature: aggregate.AvgTemperature,
+		AvgHumidity:    aggregate.AvgHumidity,
+	}
+}
+
+// DataQualityReportToResponse converts a quality Report to a DataQualityReportResponse DTO
+func DataQualityReportToResponse(report *quality.Report, gapThreshold time.Duration) *dto.DataQualityReportResponse {
+	if report == nil {
+		return nil
+	}
+
+	gaps := make([]*dto.DataGapResponse, len(report.Gaps))
+	for i, gap := range report.Gaps {
+		gaps[i] = &dto.DataGapResponse{
+			StartTime:  gap.Start.UnixMilli(),
+			EndTime:    gap.End.UnixMilli(),
+			DurationMs: gap.Duration.Milliseconds(),
+		}
+	}
+
+	duplicates := make([]*dto.DuplicateReadingResponse, len(report.Duplicates))
+	for i, duplicate := range report.Duplicates {
+		duplicates[i] = &dto.DuplicateReadingResponse{
+			Timestamp:         duplicate.Timestamp.UnixMilli(),
+			PreviousTimestamp: duplicate.PreviousTimestamp.UnixMilli(),
+		}
+	}
+
+	outOfRange := make([]*dto.OutOfRangeValueResponse, len(report.OutOfRange))
+	for i, value := range report.OutOfRange {
+		outOfRange[i] = &dto.OutOfRangeValueResponse{
+			Timestamp: value.Timestamp.UnixMilli(),
+			Field:     value.Field,
+			Value:     value.Value,
+			Min:       value.Bounds.Min,
+			Max:       value.Bounds.Max,
+		}
+	}
+
+	return &dto.DataQualityReportResponse{
+		MacAddress:       report.DeviceID,
+		StartTime:        report.Start.UnixMilli(),
+		EndTime:          report.End.UnixMilli(),
+		SamplePeriodMs:   report.SamplePeriod.Milliseconds(),
+		GapThresholdMs:   gapThreshold.Milliseconds(),
+		ExpectedSamples:  report.ExpectedSamples,
+		ActualSamples:    report.ActualSamples,
+		Completeness:     report.Completeness,
+		GapCount:         report.GapCount,
+		MissingMs:        report.MissingDuration.Milliseconds(),
+		Gaps:             gaps,
+		DuplicateCount:   report.DuplicateCount,
+		Duplicates:       duplicates,
+		OutOfRangeCount:  report.OutOfRangeCount,
+		OutOfRange:       outOfRange,
+		UnparseableCount: report.UnparseableCount,
+	}
+}
+
+// DataQualitySummaryToResponse converts a device and its quality Report to a fleet summary line
+func DataQualitySummaryToResponse(device *domain.Device, report *quality.Report) *dto.DeviceDataQualitySummaryResponse {
+	summary := &dto.DeviceDataQualitySummaryResponse{
+		MacAddress: device.MacAddress,
+		DeviceName: device.Name,
+		UserID:     device.UserID,
+	}
+	if report == nil {
+		return summary
+	}
+
+	summary.ExpectedSamples = report.ExpectedSamples
+	summary.ActualSamples = report.ActualSamples
+	summary.Completeness = report.Completeness
+	summary.GapCount = report.GapCount
+	summary.MissingMs = report.MissingDuration.Milliseconds()
+	summary.DuplicateCount = report.DuplicateCount
+	summary.OutOfRangeCount = report.OutOfRangeCount
+	summary.UnparseableCount = report.UnparseableCount
+	return summary
+}
+
+// RollupPointsToResponse converts rollup points to EntityMetricPointResponse DTOs
+func RollupPointsToResponse(points []rollup.Point) []*dto.EntityMetricPointResponse {
+	responses := make([]*dto.EntityMetricPointResponse, len(points))
+	for i, point := range points {
+		responses[i] = &dto.EntityMetricPointResponse{
+			BucketStart:    point.BucketStart.UnixMilli(),
+			DeviceCount:    point.DeviceCount,
+			SampleCount:    point.SampleCount,
+			TotalAmperage:  point.TotalAmperage,
+			AvgTemperature: point.AvgTemperature,
+			AvgHumidity:    point.AvgHumidity,
+		}
+	}
+	return responses
+}
+
 // CategoryToResponse converts a domain Category to a CategoryResponse DTO
 func CategoryToResponse(category *domain.Category) *dto.CategoryResponse {
 	if category == nil {
 		return nil
 	}
 
+	var detailsSchema map[string]any
+	if len(category.DetailsSchema) > 0 {
+		_ = json.Unmarshal(category.DetailsSchema, &detailsSchema)
+	}
+
 	return &dto.CategoryResponse{
-		ID:   category.ID,
-		Name: category.Name,
-		Type: category.Type,
+		ID:            category.ID,
+		Name:          category.Name,
+		Type:          category.Type,
+		DetailsSchema: detailsSchema,
+		SchemaVersion: category.SchemaVersion,
+		EntityCount:   category.EntityCount,
+	}
+}
+
+// CategoryTypeToResponse converts a domain CategoryTypeDefinition to a response DTO
+func CategoryTypeToResponse(categoryType *domain.CategoryTypeDefinition) *dto.CategoryTypeResponse {
+	if categoryType == nil {
+		return nil
+	}
+
+	return &dto.CategoryTypeResponse{
+		Name:        categoryType.Name,
+		Description: categoryType.Description,
+		IsSystem:    categoryType.IsSystem,
+		CreatedAt:   categoryType.CreatedAt,
+		UpdatedAt:   categoryType.UpdatedAt,
+	}
+}
+
+// CategoryTypeRuleToResponse converts a domain CategoryTypeRule to a response DTO
+func CategoryTypeRuleToResponse(rule *domain.CategoryTypeRule) *dto.CategoryTypeRuleResponse {
+	if rule == nil {
+		return nil
+	}
+
+	return &dto.CategoryTypeRuleResponse{
+		CategoryType:      rule.CategoryType,
+		AllowedAsRoot:     rule.AllowedAsRoot,
+		AllowedChildTypes: rule.AllowedChildTypes,
+		MaxDepth:          rule.MaxDepth,
+		UpdatedAt:         rule.UpdatedAt,
+	}
+}
+
+// EntityRuleViolationToResponse converts an entity placement and its rule violations to a response DTO
+func EntityRuleViolationToResponse(placement *domain.EntityPlacement, violations []hierarchy.Violation) *dto.EntityRuleViolationResponse {
+	response := &dto.EntityRuleViolationResponse{
+		EntityID:     placement.EntityID,
+		Name:         placement.Name,
+		CategoryType: placement.CategoryType,
+		Depth:        placement.Depth,
+		Violations:   make([]dto.RuleViolationResponse, len(violations)),
+	}
+	if placement.ParentID != nil {
+		response.ParentID = *placement.ParentID
+	}
+	for i, violation := range violations {
+		response.Violations[i] = dto.RuleViolationResponse{
+			Code:    violation.Code,
+			Message: violation.Message,
+		}
+	}
+	return response
+}
+
+// SchemaFieldErrorsToValidationErrors converts details schema failures to API validation errors
+func SchemaFieldErrorsToValidationErrors(fieldErrors []schema.FieldError) []dto.ValidationError {
+	validationErrors := make([]dto.ValidationError, len(fieldErrors))
+	for i, fieldError := range fieldErrors {
+		validationErrors[i] = dto.ValidationError{
+			Field:   fieldError.Field,
+			Message: fieldError.Message,
+		}
+	}
+	return validationErrors
+}
+
+// EntityConformanceToResponse converts a non-conforming entity record to a response DTO
+func EntityConformanceToResponse(conformance *domain.EntityConformance) *dto.NonConformingEntityResponse {
+	if conformance == nil {
+		return nil
+	}
+
+	var fieldErrors []schema.FieldError
+	if len(conformance.Errors) > 0 {
+		_ = json.Unmarshal(conformance.Errors, &fieldErrors)
+	}
+
+	return &dto.NonConformingEntityResponse{
+		EntityID: conformance.EntityID,
+		Name:     conformance.Name,
+		Errors:   SchemaFieldErrorsToValidationErrors(fieldErrors),
 	}
 }
 
 // Batch conversion helpers
+func CategoryTypesToResponses(categoryTypes []*domain.CategoryTypeDefinition) []*dto.CategoryTypeResponse {
+	responses := make([]*dto.CategoryTypeResponse, len(categoryTypes))
+	for i, categoryType := range categoryTypes {
+		responses[i] = CategoryTypeToResponse(categoryType)
+	}
+	return responses
+}
+
+func CategoryTypeRulesToResponses(rules []*domain.CategoryTypeRule) []*dto.CategoryTypeRuleResponse {
+	responses := make([]*dto.CategoryTypeRuleResponse, len(rules))
+	for i, rule := range rules {
+		responses[i] = CategoryTypeRuleToResponse(rule)
+	}
+	return responses
+}
+
+func EntityConformancesToResponses(conformances []*domain.EntityConformance) []*dto.NonConformingEntityResponse {
+	responses := make([]*dto.NonConformingEntityResponse, len(conformances))
+	for i, conformance := range conformances {
+		responses[i] = EntityConformanceToResponse(conformance)
+	}
+	return responses
+}
+
 func UsersToResponses(users []*domain.User) []*dto.UserResponse {
 	responses := make([]*dto.UserResponse, len(users))
 	for i, user := range users {
@@ -172,6 +592,14 @@ func SensorReadingsToResponses(readings []*domain.SensorReading) []*dto.SensorDa
 	return responses
 }
 
+func SensorAggregatesToResponses(aggregates []*domain.SensorAggregate) []*dto.SensorAggregateResponse {
+	responses := make([]*dto.SensorAggregateResponse, len(aggregates))
+	for i, aggregate := range aggregates {
+		responses[i] = SensorAggregateToResponse(aggregate)
+	}
+	return responses
+}
+
 func CategoriesToResponses(categories []*domain.Category) []*dto.CategoryResponse {
 	responses := make([]*dto.CategoryResponse, len(categories))
 	for i, category := range categories {
@@ -180,6 +608,19 @@ func CategoriesToResponses(categories []*domain.Category) []*dto.CategoryRespons
 	return responses
 }
 
+// CategoryDuplicateGroupsToResponses converts duplicate category groups to response DTOs
+func CategoryDuplicateGroupsToResponses(groups []*domain.CategoryDuplicateGroup) []*dto.CategoryDuplicateGroupResponse {
+	responses := make([]*dto.CategoryDuplicateGroupResponse, len(groups))
+	for i, group := range groups {
+		responses[i] = &dto.CategoryDuplicateGroupResponse{
+			Type:           group.Type,
+			NormalizedName: group.NormalizedName,
+			Categories:     CategoriesToResponses(group.Categories),
+		}
+	}
+	return responses
+}
+
 // EntityToResponse converts a domain Entity to an EntityResponse DTO
 func EntityToResponse(entity *domain.Entity) *dto.EntityResponse {
 	if entity == nil {
@@ -223,56 +664,413 @@ func EntitiesToResponses(entities []*domain.Entity) []*dto.EntityResponse {
 	return responses
 }
 
-// HierarchyMapToResponse converts a map-based entity hierarchy to EntityHierarchyResponse
-func HierarchyMapToResponse(entityMap map[string]any) *dto.EntityHierarchyResponse {
-	if entityMap == nil {
+// EntityNodesToResponses converts entity nodes to EntityResponse DTOs including their category
+func EntityNodesToResponses(nodes []*domain.EntityNode) []*dto.EntityResponse {
+	responses := make([]*dto.EntityResponse, len(nodes))
+	for i, node := range nodes {
+		responses[i] = EntityToResponse(&node.Entity)
+		responses[i].CategoryName = node.CategoryName
+		responses[i].CategoryType = node.CategoryType
+	}
+	return responses
+}
+
+// EntityAccessesToResponses converts reachable entities to UserEntityResponse DTOs
+func EntityAccessesToResponses(entities []*domain.EntityAccess) []*dto.UserEntityResponse {
+	responses := make([]*dto.UserEntityResponse, len(entities))
+	for i, access := range entities {
+		entity := EntityToResponse(&access.Entity)
+		entity.CategoryName = access.CategoryName
+		entity.CategoryType = access.CategoryType
+
+		responses[i] = &dto.UserEntityResponse{
+			EntityResponse:  *entity,
+			Role:            string(access.Role),
+			GrantedEntityID: access.GrantedEntityID,
+		}
+	}
+	return responses
+}
+
+// EntityMemberToResponse converts a domain EntityMember to an EntityMemberResponse DTO
+func EntityMemberToResponse(member *domain.EntityMember) *dto.EntityMemberResponse {
+	if member == nil {
 		return nil
 	}
 
-	response := &dto.EntityHierarchyResponse{
-		EntityResponse: dto.EntityResponse{
-			ID:        entityMap["id"].(string),
-			Name:      entityMap["name"].(string),
-			Depth:     entityMap["depth"].(int),
-			CreatedAt: entityMap["created_at"].(time.Time),
-		},
+	response := &dto.EntityMemberResponse{
+		EntityID:   member.EntityID,
+		EntityName: member.EntityName,
+		UserID:     member.UserID,
+		Email:      member.Email,
+		Role:       string(member.Role),
+		Status:     string(member.Status),
+		CreatedAt:  member.CreatedAt,
+		AcceptedAt: member.AcceptedAt,
 	}
 
-	// Handle optional fields
-	if userID, ok := entityMap["user_id"].(string); ok {
-		response.UserID = userID
+	if member.FirstName != nil {
+		response.FirstName = *member.FirstName
+	}
+	if member.LastName != nil {
+		response.LastName = *member.LastName
+	}
+	if member.InvitedBy != nil {
+		response.InvitedBy = *member.InvitedBy
 	}
 
-	if parentID, ok := entityMap["parent_id"].(string); ok {
-		response.ParentID = parentID
+	return response
+}
+
+// EntityMembersToResponses converts a slice of domain EntityMembers to response DTOs
+func EntityMembersToResponses(members []*domain.EntityMember) []*dto.EntityMemberResponse {
+	responses := make([]*dto.EntityMemberResponse, len(members))
+	for i, member := range members {
+		responses[i] = EntityMemberToResponse(member)
 	}
+	return responses
+}
 
-	if categoryID, ok := entityMap["category_id"].(string); ok {
-		response.CategoryID = categoryID
+func ChildInvitationToResponse(invitation *domain.ChildInvitation) *dto.ChildInvitationResponse {
+	if invitation == nil {
+		return nil
+	}
+
+	response := &dto.ChildInvitationResponse{
+		ID:          invitation.ID,
+		ParentID:    invitation.ParentID,
+		ParentEmail: invitation.ParentEmail,
+		Email:       invitation.Email,
+		CreatedAt:   invitation.CreatedAt,
+	}
+
+	if invitation.ParentFirstName != nil {
+		response.ParentFirstName = *invitation.ParentFirstName
+	}
+	if invitation.ParentLastName != nil {
+		response.ParentLastName = *invitation.ParentLastName
+	}
+	if invitation.FirstName != nil {
+		response.FirstName = *invitation.FirstName
+	}
+	if invitation.LastName != nil {
+		response.LastName = *invitation.LastName
+	}
+	if invitation.Phone != nil {
+		response.Phone = *invitation.Phone
 	}
 
-	if categoryName, ok := entityMap["category_name"].(string); ok {
-		response.CategoryName = categoryName
+	return response
+}
+
+func ChildInvitationsToResponses(invitations []*domain.ChildInvitation) []*dto.ChildInvitationResponse {
+	responses := make([]*dto.ChildInvitationResponse, len(invitations))
+	for i, invitation := range invitations {
+		responses[i] = ChildInvitationToResponse(invitation)
 	}
+	return responses
+}
 
-	if categoryType, ok := entityMap["category_type"].(string); ok {
-		response.CategoryType = categoryType
+// EntityHistoryToResponses converts recorded entity changes to EntityHistoryResponse DTOs
+func EntityHistoryToResponses(entries []*domain.EntityHistoryEntry) []*dto.EntityHistoryResponse {
+	responses := make([]*dto.EntityHistoryResponse, len(entries))
+	for i, entry := range entries {
+		response := &dto.EntityHistoryResponse{
+			ID:        entry.ID,
+			EntityID:  entry.EntityID,
+			Action:    entry.Action,
+			ChangedAt: entry.ChangedAt,
+		}
+		if entry.ActorUserID != nil {
+			response.ActorUserID = *entry.ActorUserID
+		}
+		if len(entry.Before) > 0 {
+			json.Unmarshal(entry.Before, &response.Before)
+		}
+		if len(entry.After) > 0 {
+			json.Unmarshal(entry.After, &response.After)
+		}
+		responses[i] = response
+	}
+	return responses
+}
+
+// DeletedRecordsToResponses converts soft-deleted records to DeletedRecordResponse DTOs,
+// each purged once retention has passed since its deletion
+func DeletedRecordsToResponses(records []*domain.DeletedRecord, retention time.Duration) []*dto.DeletedRecordResponse {
+	responses := make([]*dto.DeletedRecordResponse, len(records))
+	for i, record := range records {
+		responses[i] = &dto.DeletedRecordResponse{
+			Kind:      string(record.Kind),
+			ID:        record.ID,
+			Name:      record.Name,
+			DeletedAt: record.DeletedAt,
+			PurgeAt:   record.DeletedAt.Add(retention),
+		}
+	}
+	return responses
+}
+
+// EntityNodesToHierarchy builds a typed tree from hierarchy nodes ordered by depth,
+// returning nil if the root is not among them
+func EntityNodesToHierarchy(rootEntityID string, nodes []*domain.EntityNode) *dto.EntityHierarchyResponse {
+	byID := make(map[string]*dto.EntityHierarchyResponse, len(nodes))
+	var root *dto.EntityHierarchyResponse
+
+	for _, node := range nodes {
+		entity := EntityToResponse(&node.Entity)
+		entity.CategoryName = node.CategoryName
+		entity.CategoryType = node.CategoryType
+
+		response := &dto.EntityHierarchyResponse{
+			EntityResponse: *entity,
+			ChildCount:     node.ChildCount,
+		}
+		byID[node.ID] = response
+
+		if node.ID == rootEntityID {
+			root = response
+			continue
+		}
+
+		// Parents always precede their children because nodes are ordered by depth
+		if node.ParentID != nil {
+			if parent, ok := byID[*node.ParentID]; ok {
+				parent.Children = append(parent.Children, response)
+			}
+		}
 	}
 
-	if details, ok := entityMap["details"].(map[string]any); ok {
-		response.Details = details
+	for _, response := range byID {
+		response.HasMore = response.ChildCount > len(response.Children)
 	}
 
-	// Process children recursively
-	if children, ok := entityMap["children"].([]map[string]any); ok && len(children) > 0 {
-		response.Children = make([]dto.EntityHierarchyResponse, len(children))
-		for i, child := range children {
-			childResponse := HierarchyMapToResponse(child)
-			if childResponse != nil {
-				response.Children[i] = *childResponse
+	return root
+}
+
+// EntityNodesToTree builds an entity tree in the import/export format from hierarchy nodes
+// ordered by depth, returning nil if the root is not among them. Categories are referenced
+// by name and type so the tree can be imported into another environment.
+func EntityNodesToTree(rootEntityID string, nodes []*domain.EntityNode) *dto.EntityTreeNode {
+	byID := make(map[string]*dto.EntityTreeNode, len(nodes))
+	var root *dto.EntityTreeNode
+
+	for _, node := range nodes {
+		tree := &dto.EntityTreeNode{
+			Name:         node.Name,
+			Category:     node.CategoryName,
+			CategoryType: node.CategoryType,
+		}
+		if len(node.Details) > 0 {
+			json.Unmarshal(node.Details, &tree.Details)
+		}
+		if len(tree.Details) == 0 {
+			tree.Details = nil
+		}
+		byID[node.ID] = tree
+
+		if node.ID == rootEntityID {
+			root = tree
+			continue
+		}
+
+		// Parents always precede their children because nodes are ordered by depth
+		if node.ParentID != nil {
+			if parent, ok := byID[*node.ParentID]; ok {
+				parent.Children = append(parent.Children, tree)
 			}
 		}
 	}
 
+	return root
+}
+
+// GeoEntitiesToResponses converts located entities to GeoEntityResponse DTOs
+func GeoEntitiesToResponses(entities []*domain.GeoEntity) []*dto.GeoEntityResponse {
+	responses := make([]*dto.GeoEntityResponse, len(entities))
+	for i, entity := range entities {
+		response := EntityToResponse(&entity.Entity)
+		response.CategoryName = entity.CategoryName
+		response.CategoryType = entity.CategoryType
+
+		responses[i] = &dto.GeoEntityResponse{
+			EntityResponse: *response,
+			Latitude:       entity.Latitude,
+			Longitude:      entity.Longitude,
+			DistanceMeters: entity.DistanceMeters,
+		}
+	}
+	return responses
+}
+
+// GeoEntitiesToFeatureCollection converts located entities to a GeoJSON feature collection of
+// points. Properties carry enough of each entity for a map to label and group it.
+func GeoEntitiesToFeatureCollection(entities []*domain.GeoEntity) *dto.GeoJSONFeatureCollection {
+	features := make([]*dto.GeoJSONFeature, len(entities))
+	for i, entity := range entities {
+		properties := map[string]any{
+			"id":           entity.ID,
+			"name":         entity.Name,
+			"categoryName": entity.CategoryName,
+			"categoryType": entity.CategoryType,
+			"depth":        entity.Depth,
+		}
+		if entity.ParentID != nil {
+			properties["parentId"] = *entity.ParentID
+		}
+
+		features[i] = &dto.GeoJSONFeature{
+			Type: "Feature",
+			ID:   entity.ID,
+			Geometry: dto.GeoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{entity.Longitude, entity.Latitude},
+			},
+			Properties: properties,
+		}
+	}
+
+	return &dto.GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+}
+
+// TagSuggestionsToResponses converts tag suggestions to TagSuggestionResponse DTOs
+func TagSuggestionsToResponses(suggestions []*domain.TagSuggestion) []*dto.TagSuggestionResponse {
+	responses := make([]*dto.TagSuggestionResponse, len(suggestions))
+	for i, suggestion := range suggestions {
+		responses[i] = &dto.TagSuggestionResponse{
+			Text:  suggestion.Text,
+			Count: suggestion.Count,
+		}
+	}
+	return responses
+}
+
+// ReferralNodesToTree nests referral tree nodes, ordered by depth, under their referrers and
+// returns the direct referrals. Nodes at maxDepth with referrals of their own are marked HasMore.
+func ReferralNodesToTree(nodes []*domain.ReferralNode, maxDepth int) []*dto.ReferralNodeResponse {
+	roots := make([]*dto.ReferralNodeResponse, 0)
+	byID := make(map[string]*dto.ReferralNodeResponse, len(nodes))
+	for _, node := range nodes {
+		response := &dto.ReferralNodeResponse{
+			UserID:        node.UserID,
+			Email:         node.Email,
+			FirstName:     node.FirstName,
+			LastName:      node.LastName,
+			ReferredAt:    node.ReferredAt,
+			Depth:         node.Depth,
+			ReferralCount: node.ReferralCount,
+			HasMore:       node.Depth >= maxDepth && node.ReferralCount > 0,
+		}
+		byID[node.UserID] = response
+
+		if referrer, ok := byID[node.ReferredBy]; ok {
+			referrer.Referrals = append(referrer.Referrals, response)
+		} else {
+			roots = append(roots, response)
+		}
+	}
+	return roots
+}
+
+// ReferralStatsToResponse converts referral statistics to a ReferralStatsResponse DTO
+func ReferralStatsToResponse(stats *domain.ReferralStats, interval string) *dto.ReferralStatsResponse {
+	response := &dto.ReferralStatsResponse{
+		Direct:    stats.Direct,
+		Total:     stats.Total,
+		Converted: stats.Converted,
+		Interval:  interval,
+		Buckets:   make([]*dto.ReferralBucketResponse, len(stats.Buckets)),
+	}
+	if stats.Total > 0 {
+		response.ConversionRate = float64(stats.Converted) / float64(stats.Total)
+	}
+	for i, bucket := range stats.Buckets {
+		response.Buckets[i] = &dto.ReferralBucketResponse{
+			Start:       bucket.Start,
+			Signups:     bucket.Signups,
+			Conversions: bucket.Conversions,
+		}
+	}
 	return response
 }
+
+// UserAccountToAdminResponse converts a user account to an AdminUserResponse DTO
+func UserAccountToAdminResponse(account *domain.UserAccount) *dto.AdminUserResponse {
+	return &dto.AdminUserResponse{
+		UserResponse: *UserToResponse(&account.User),
+		Role:         string(account.Role),
+		SignedUp:     account.SignedUp,
+	}
+}
+
+func UserAccountsToAdminResponses(accounts []*domain.UserAccount) []*dto.AdminUserResponse {
+	responses := make([]*dto.AdminUserResponse, len(accounts))
+	for i, account := range accounts {
+		responses[i] = UserAccountToAdminResponse(account)
+	}
+	return responses
+}
+
+func CacheStatsToIdentityCacheResponse(stats cache.Stats) *dto.IdentityCacheStatsResponse {
+	response := &dto.IdentityCacheStatsResponse{
+		Enabled:      true,
+		Hits:         stats.Hits,
+		Misses:       stats.Misses,
+		SharedHits:   stats.SharedHits,
+		SharedErrors: stats.SharedErrors,
+		Evictions:    stats.Evictions,
+		Entries:      stats.Entries,
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		response.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return response
+}
+
+func ImpersonationRecordsToResponses(records []*domain.ImpersonationRecord) []*dto.ImpersonationLogResponse {
+	responses := make([]*dto.ImpersonationLogResponse, len(records))
+	for i, record := range records {
+		responses[i] = &dto.ImpersonationLogResponse{
+			ID:          record.ID,
+			AdminUserID: record.AdminUserID,
+			UserID:      record.UserID,
+			Method:      record.Method,
+			Path:        record.Path,
+			Status:      record.Status,
+			ClientIP:    record.ClientIP,
+			RequestedAt: record.RequestedAt,
+		}
+	}
+	return responses
+}
+
+// AccountDeletionToResponse converts a pending account deletion to an AccountDeletionResponse DTO
+func AccountDeletionToResponse(deletion *domain.AccountDeletion) *dto.AccountDeletionResponse {
+	return &dto.AccountDeletionResponse{
+		RequestedAt: deletion.RequestedAt,
+		ScheduledAt: deletion.ScheduledAt,
+	}
+}
+
+// CognitoAttributesToProfile converts Cognito user attributes, from a trigger event or an ID
+// token, to a CognitoProfile. Empty optional attributes are treated as not sent.
+func CognitoAttributesToProfile(attributes map[string]string) *domain.CognitoProfile {
+	optional := func(name string) *string {
+		if value := strings.TrimSpace(attributes[name]); value != "" {
+			return &value
+		}
+		return nil
+	}
+
+	return &domain.CognitoProfile{
+		CognitoID:     attributes["sub"],
+		Email:         strings.TrimSpace(attributes["email"]),
+		EmailVerified: attributes["email_verified"] == "true",
+		FirstName:     optional("given_name"),
+		LastName:      optional("family_name"),
+		Phone:         optional("phone_number"),
+	}
+}
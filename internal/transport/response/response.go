@@ -78,9 +78,17 @@ func ValidationErrors(c *gin.Context, errors []dto.ValidationError) {
 
 // Paginated sends a paginated response
 func Paginated(c *gin.Context, items any, total int64, page, pageSize int) {
-	totalPages := int(total) / pageSize
-	if int(total)%pageSize > 0 {
-		totalPages++
+	PaginatedWithCursor(c, items, total, page, pageSize, "")
+}
+
+// PaginatedWithCursor sends a paginated response that also carries the cursor of the next page
+func PaginatedWithCursor(c *gin.Context, items any, total int64, page, pageSize int, nextCursor string) {
+	totalPages := 0
+	if pageSize > 0 {
+		totalPages = int(total) / pageSize
+		if int(total)%pageSize > 0 {
+			totalPages++
+		}
 	}
 
 	c.JSON(http.StatusOK, dto.Response{
@@ -91,6 +99,7 @@ func Paginated(c *gin.Context, items any, total int64, page, pageSize int) {
 			Page:       page,
 			PageSize:   pageSize,
 			TotalPages: totalPages,
+			NextCursor: nextCursor,
 		},
 	})
 }
@@ -85,6 +85,10 @@ func formatValidationError(err validator.FieldError) string {
 		return "must be a valid email address"
 	case "oneof":
 		return fmt.Sprintf("must be one of: %s", err.Param())
+	case "e164":
+		return "must be a phone number in E.164 format, e.g. +14155550123"
+	case "iso3166_1_alpha2":
+		return "must be an ISO 3166-1 alpha-2 country code, e.g. IN"
 	}
 	return fmt.Sprintf("failed validation for '%s'", err.Tag())
 }
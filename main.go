@@ -18,11 +18,15 @@ import (
 	"n1h41/zolaris-backend-app/api/handlers"
 	"n1h41/zolaris-backend-app/docs"
 	"n1h41/zolaris-backend-app/internal/aws"
+	"n1h41/zolaris-backend-app/internal/cache"
+	"n1h41/zolaris-backend-app/internal/cognito"
 	"n1h41/zolaris-backend-app/internal/config"
 	"n1h41/zolaris-backend-app/internal/db"
+	"n1h41/zolaris-backend-app/internal/domain"
 	"n1h41/zolaris-backend-app/internal/middleware"
 	"n1h41/zolaris-backend-app/internal/repositories"
 	"n1h41/zolaris-backend-app/internal/services"
+	"n1h41/zolaris-backend-app/internal/storage"
 )
 
 func main() {
@@ -59,32 +63,108 @@ func main() {
 		log.Fatalf("Failed to initialize database clients: %v", err)
 	}
 
+	// Initialize telemetry storage
+	var telemetryStore repositories.TelemetryStore
+	switch cfg.Database.TelemetryBackend {
+	case repositories.PostgresTelemetryBackend:
+		pgTelemetry := repositories.NewPostgresTelemetryStore(database.GetPostgresPool()).WithTimescale(cfg.Database.TelemetryTimescale)
+		if err := pgTelemetry.EnsureHypertable(context.Background()); err != nil {
+			log.Fatalf("Failed to initialize telemetry storage: %v", err)
+		}
+		telemetryStore = pgTelemetry
+	case repositories.DynamoTelemetryBackend:
+		telemetryStore = repositories.NewDynamoTelemetryStore(database.GetDynamoClient(), database.GetMachineDataTableName())
+	default:
+		log.Fatalf("Unsupported telemetry backend: %s", cfg.Database.TelemetryBackend)
+	}
+	log.Printf("Using %s telemetry backend", cfg.Database.TelemetryBackend)
+
 	// Initialize repositories
-	deviceRepo := repositories.NewDeviceRepository(database.GetPostgresPool(), database.GetDynamoClient())
+	deviceRepo := repositories.NewDeviceRepository(database.GetPostgresPool(), telemetryStore)
 	policyRepo := repositories.NewPolicyRepository(awsClients.GetIoTClient())
 	categoryRepo := repositories.NewCategoryRepository(database.GetPostgresPool())
 	userRepo := repositories.NewUserRepository(database.GetPostgresPool())
+	// Every authenticated request resolves the caller's Cognito ID, so cache it in front of the database
+	var identityCache *repositories.CachedUserRepository
+	if cfg.IdentityCache.Size > 0 {
+		identityCache = repositories.NewCachedUserRepository(userRepo, cache.NewTiered[domain.UserIdentity](cfg.IdentityCache.Size, cfg.IdentityCache.TTL, nil, "identity:"))
+		userRepo = identityCache
+	}
 	entityRepo := repositories.NewEntityRepository(database.GetPostgresPool())
+	exportJobRepo := repositories.NewExportJobRepository(database.GetPostgresPool())
+	entityMemberRepo := repositories.NewEntityMemberRepository(database.GetPostgresPool())
+	tagRepo := repositories.NewTagRepository(database.GetPostgresPool())
+
+	// Radius queries use PostGIS where the location migration could install it
+	if err := entityRepo.DetectGeography(context.Background()); err != nil {
+		log.Fatalf("Failed to inspect entity location support: %v", err)
+	}
+	if entityRepo.UsesGeography() {
+		log.Println("Using PostGIS for entity radius queries")
+	} else {
+		log.Println("PostGIS not available, using plain coordinates for entity radius queries")
+	}
 
-	deviceRepo.WithMachineTable(database.GetMachineDataTableName())
+	// Initialize storage for generated files
+	exportStorage, err := storage.NewLocalStorage(cfg.Export.StorageDir)
+	if err != nil {
+		log.Fatalf("Failed to initialize export storage: %v", err)
+	}
 
 	// Initialize services
-	deviceService := services.NewDeviceService(deviceRepo)
+	deviceService := services.NewDeviceService(deviceRepo).WithEnergyConfig(cfg.Energy).WithTagRepository(tagRepo)
 	policyService := services.NewPolicyService(policyRepo, cfg.AWS.IoTPolicy)
-	categoryService := services.NewCategoryService(categoryRepo)
-	userService := services.NewUserService(userRepo)
-	entityService := services.NewEntityService(entityRepo)
+	categoryService := services.NewCategoryService(categoryRepo).WithEntityRepository(entityRepo)
+	userService := services.NewUserService(userRepo).WithEntityRepository(entityRepo).WithDeviceRepository(deviceRepo)
+	if cfg.Cognito.UserPoolID != "" && cfg.Cognito.JITProvisioning {
+		userService.WithTokenVerifier(cognito.NewTokenVerifier(cfg.AWS.Region, cfg.Cognito.UserPoolID, cfg.Cognito.ClientIDs))
+	}
+	childAccountService := services.NewChildAccountService(userRepo, entityRepo, deviceRepo)
+	adminUserService := services.NewAdminUserService(userRepo, entityRepo, deviceRepo).WithIdentityCache(identityCache)
+	accountService := services.NewAccountService(userRepo, entityRepo, deviceRepo, exportStorage, cfg.Account)
+	entityService := services.NewEntityService(entityRepo).WithCategoryRepository(categoryRepo).WithTagRepository(tagRepo)
+	entityMetricsService := services.NewEntityMetricsService(entityRepo, deviceRepo)
+	entityMemberService := services.NewEntityMemberService(entityRepo, entityMemberRepo, userRepo)
+	entityGeoService := services.NewEntityGeoService(entityRepo)
+	tagService := services.NewTagService(tagRepo, entityRepo, deviceRepo)
+	dataQualityService := services.NewDataQualityService(deviceRepo, cfg.Quality)
+	exportJobCtx, stopExportJobs := context.WithCancel(context.Background())
+	exportService := services.NewExportService(deviceRepo, exportJobRepo, exportStorage, cfg.Export, cfg.Server.ExternalURL).WithJobContext(exportJobCtx)
+	trashService := services.NewTrashService(userRepo, entityRepo, deviceRepo, cfg.Retention)
 
 	// Initialize handlers
 	entityHandler := handlers.NewEntityHandler(entityService)
+	entityMetricsHandler := handlers.NewEntityMetricsHandler(entityMetricsService)
+	entityMemberHandler := handlers.NewEntityMemberHandler(entityMemberService)
+	entityGeoHandler := handlers.NewEntityGeoHandler(entityGeoService)
+	tagHandler := handlers.NewTagHandler(tagService)
 	userHandler := handlers.NewUserHandler(userService)
+	childAccountHandler := handlers.NewChildAccountHandler(childAccountService)
+	adminUserHandler := handlers.NewAdminUserHandler(adminUserService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	cognitoWebhookHandler := handlers.NewCognitoWebhookHandler(userService, cfg.Cognito.WebhookSecret, cfg.Cognito.UserPoolID)
 	addDeviceHandler := handlers.NewAddDeviceHandler(deviceService)
 	attachIotPolicyHandler := handlers.NewAttachIotPolicyHandler(policyService)
 	getDeviceSensorDataHandler := handlers.NewGetDeviceSensorDataHandler(deviceService)
 	listUserDevicesHandler := handlers.NewListUserDevicesHandler(deviceService)
+	updateElectricalParamsHandler := handlers.NewUpdateDeviceElectricalParamsHandler(deviceService)
+	deleteDeviceHandler := handlers.NewDeleteDeviceHandler(deviceService)
+	ingestSensorDataHandler := handlers.NewIngestSensorDataHandler(deviceService)
+	aggregateSensorDataHandler := handlers.NewAggregateSensorDataHandler(deviceService)
+	energyHandler := handlers.NewEnergyHandler(deviceService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	dataQualityHandler := handlers.NewDataQualityHandler(dataQualityService)
+	trashHandler := handlers.NewTrashHandler(trashService)
 	addCategoryHandler := handlers.NewAddCategoryHandler(categoryService)
 	getCategoriesByTypeHandler := handlers.NewGetCategoriesByTypeHandler(categoryService)
 	listAllCategoriesHandler := handlers.NewListAllCategoriesHandler(categoryService)
+	categorySchemaHandler := handlers.NewCategorySchemaHandler(categoryService)
+	categoryRuleHandler := handlers.NewCategoryRuleHandler(categoryService)
+	categoryTypeHandler := handlers.NewCategoryTypeHandler(categoryService)
+	updateCategoryHandler := handlers.NewUpdateCategoryHandler(categoryService)
+	deleteCategoryHandler := handlers.NewDeleteCategoryHandler(categoryService)
+	mergeCategoryHandler := handlers.NewMergeCategoryHandler(categoryService)
+	listDuplicateCategoriesHandler := handlers.NewListDuplicateCategoriesHandler(categoryService)
 
 	// Create router with global middleware
 	r := gin.New()
@@ -115,9 +195,9 @@ func main() {
 			}
 			return false
 		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "X-Cognito-ID"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Cognito-ID", middleware.ImpersonateUserHeader},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "X-Impersonated-User"},
 		AllowCredentials: true,
 		MaxAge:           1 * time.Hour,
 	}))
@@ -138,6 +218,9 @@ func main() {
 		c.String(http.StatusOK, "OK")
 	})
 
+	// Cognito trigger events are authenticated by their signature instead of a user
+	r.POST("/webhooks/cognito", cognitoWebhookHandler.HandleEvent)
+
 	// Group private routes (require authentication)
 	private := r.Group("/")
 	private.Use(middleware.GinAuthMiddleware(userService))
@@ -145,17 +228,113 @@ func main() {
 		// Device endpoints
 		private.POST("/device/add", addDeviceHandler.HandleGin)
 		private.GET("/user/devices", listUserDevicesHandler.HandleGin)
+		private.DELETE("/device/:mac", deleteDeviceHandler.HandleGin)
+		private.PUT("/device/:mac/electrical-params", updateElectricalParamsHandler.HandleGin)
+		private.POST("/device/:mac/energy", energyHandler.HandleGetDeviceEnergy)
+		private.POST("/device/:mac/energy/cost", energyHandler.HandleGetDeviceEnergyCost)
+		private.POST("/device/:mac/sensor-data", ingestSensorDataHandler.HandleGin)
+		private.POST("/device/:mac/sensor-data/aggregate", aggregateSensorDataHandler.HandleGin)
+		private.GET("/device/:mac/sensor-data/export", exportHandler.HandleExportSensorData)
+		private.GET("/device/:mac/data-quality", dataQualityHandler.HandleGetDeviceDataQuality)
+		private.GET("/export-jobs/:job_id", exportHandler.HandleGetExportJob)
+		private.GET("/export-jobs/:job_id/download", exportHandler.HandleDownloadExport)
 
 		// User endpoints
 		private.GET("/user/check-parent-id", userHandler.HandleCheckHasParentID)
+		private.GET("/user/onboarding-status", userHandler.HandleGetOnboardingStatus)
 		private.POST("/user/details", userHandler.HandleUpdateUserDetails)
 		private.GET("/user/details", userHandler.HandleGetUserDetails)
+		private.PATCH("/user/details", userHandler.HandlePatchUserDetails)
+		private.GET("/user/address", userHandler.HandleGetAddress)
+		private.PUT("/user/address", userHandler.HandleReplaceAddress)
+		private.PATCH("/user/address", userHandler.HandlePatchAddress)
+		private.DELETE("/user/address", userHandler.HandleDeleteAddress)
 		private.GET("/user/has-entity", entityHandler.HandleCheckEntityPresence)
+		private.GET("/user/data-export", accountHandler.HandleDataExport)
+		private.DELETE("/user", accountHandler.HandleDeleteAccount)
+		private.GET("/user/deletion", accountHandler.HandleGetDeletion)
+		private.POST("/user/deletion/cancel", accountHandler.HandleCancelDeletion)
 		private.GET("/users/referrals", userHandler.HandleListReferredUsers)
+		private.GET("/users/referrals/stats", userHandler.HandleGetReferralStats)
+		private.GET("/users/children", childAccountHandler.HandleListChildren)
+		private.POST("/users/children", childAccountHandler.HandleCreateChild)
+		private.POST("/users/children/:user_id/disable", childAccountHandler.HandleDisableChild)
+		private.POST("/users/children/:user_id/enable", childAccountHandler.HandleEnableChild)
+		private.GET("/users/children/:user_id/devices", childAccountHandler.HandleListChildDevices)
+		private.GET("/users/children/:user_id/entities", childAccountHandler.HandleListChildEntities)
+		private.GET("/users/child-invitations", childAccountHandler.HandleListChildInvitations)
+		private.DELETE("/users/child-invitations/:invitation_id", childAccountHandler.HandleCancelChildInvitation)
+		private.GET("/user/parent-invitations", childAccountHandler.HandleListParentInvitations)
+		private.POST("/user/parent-invitations/:invitation_id/accept", childAccountHandler.HandleAcceptParentInvitation)
+		private.DELETE("/user/parent-invitations/:invitation_id", childAccountHandler.HandleDeclineParentInvitation)
+		private.GET("/user/entities", entityMemberHandler.HandleListUserEntities)
+		private.GET("/user/entity-invitations", entityMemberHandler.HandleListInvitations)
 
 		// Entity endpoints (authenticated)
+		private.GET("/entity/search", entityHandler.HandleSearchEntities)
+		private.GET("/entity/nearby", entityGeoHandler.HandleFindNearby)
+		private.GET("/entity/within", entityGeoHandler.HandleFindInBounds)
 		private.POST("/entity/root", entityHandler.HandleCreateRootEntity)
 		private.POST("/entity/sub", entityHandler.HandleCreateSubEntity)
+		private.PUT("/entity/:entity_id", entityHandler.HandleUpdateEntity)
+		private.DELETE("/entity/:entity_id", entityHandler.HandleDeleteEntity)
+		private.POST("/entity/:entity_id/move", entityHandler.HandleMoveEntity)
+		private.POST("/entity/:entity_id/metrics", entityMetricsHandler.HandleGetEntityMetrics)
+		private.GET("/entity/:entity_id/history", entityHandler.HandleGetEntityHistory)
+		private.GET("/entity/:entity_id/hierarchy/as-of", entityHandler.HandleGetEntityHierarchyAsOf)
+		private.POST("/entity/:entity_id/import", entityHandler.HandleImportEntityTree)
+		private.GET("/entity/:entity_id/export", entityHandler.HandleExportEntityTree)
+		private.PUT("/entity/:entity_id/location", entityGeoHandler.HandleSetLocation)
+		private.DELETE("/entity/:entity_id/location", entityGeoHandler.HandleClearLocation)
+		private.GET("/entity/:entity_id/geojson", entityGeoHandler.HandleGetGeoJSON)
+
+		// Tag endpoints
+		private.POST("/tags", tagHandler.HandleApplyTags)
+		private.POST("/tags/remove", tagHandler.HandleRemoveTags)
+		private.GET("/tags/suggest", tagHandler.HandleSuggestTags)
+
+		// Entity membership endpoints
+		private.GET("/entity/:entity_id/members", entityMemberHandler.HandleListMembers)
+		private.POST("/entity/:entity_id/members", entityMemberHandler.HandleInviteMember)
+		private.POST("/entity/:entity_id/members/accept", entityMemberHandler.HandleAcceptInvitation)
+		private.DELETE("/entity/:entity_id/members/:user_id", entityMemberHandler.HandleRemoveMember)
+	}
+
+	// Group admin routes (require authentication and the admin role)
+	admin := r.Group("/admin")
+	admin.Use(middleware.GinAuthMiddleware(userService), middleware.GinAdminMiddleware(userService))
+	{
+		admin.GET("/data-quality", dataQualityHandler.HandleGetFleetDataQuality)
+		admin.PUT("/category/:category_id/schema", categorySchemaHandler.HandleUpdateSchema)
+		admin.GET("/category/:category_id/nonconforming-entities", categorySchemaHandler.HandleListNonConformingEntities)
+		admin.POST("/category/:category_id/merge", mergeCategoryHandler.HandleGin)
+		admin.GET("/category/duplicates", listDuplicateCategoriesHandler.HandleGin)
+		admin.PUT("/category-type-rules/:type", categoryRuleHandler.HandleUpdateTypeRule)
+		admin.POST("/category-types", categoryTypeHandler.HandleAddCategoryType)
+		admin.PUT("/category-types/:name", categoryTypeHandler.HandleUpdateCategoryType)
+		admin.DELETE("/category-types/:name", categoryTypeHandler.HandleDeleteCategoryType)
+		admin.GET("/entity-rules/audit", entityHandler.HandleAuditTreeRules)
+		admin.GET("/trash", trashHandler.HandleListTrash)
+		admin.POST("/trash/purge", trashHandler.HandlePurgeTrash)
+		admin.GET("/users", adminUserHandler.HandleSearchUsers)
+		admin.GET("/users/:user_id", adminUserHandler.HandleGetUser)
+		admin.POST("/users/:user_id/disable", adminUserHandler.HandleDisableUser)
+		admin.POST("/users/:user_id/enable", adminUserHandler.HandleEnableUser)
+		admin.PUT("/users/:user_id/role", adminUserHandler.HandleUpdateUserRole)
+		admin.GET("/impersonations", adminUserHandler.HandleListImpersonations)
+		admin.GET("/identity-cache/stats", adminUserHandler.HandleGetIdentityCacheStats)
+		admin.DELETE("/users/:user_id", trashHandler.HandleDeleteUser)
+		admin.POST("/users/:user_id/restore", trashHandler.HandleRestoreUser)
+		admin.POST("/entities/:entity_id/restore", trashHandler.HandleRestoreEntity)
+		admin.POST("/devices/:mac/restore", trashHandler.HandleRestoreDevice)
+	}
+
+	// Category management routes (require authentication and the admin role)
+	categoryAdmin := r.Group("/category")
+	categoryAdmin.Use(middleware.GinAuthMiddleware(userService), middleware.GinAdminMiddleware(userService))
+	{
+		categoryAdmin.PUT("/:category_id", updateCategoryHandler.HandleGin)
+		categoryAdmin.DELETE("/:category_id", deleteCategoryHandler.HandleGin)
 	}
 
 	// Public routes (no authentication required)
@@ -164,6 +343,8 @@ func main() {
 	r.POST("/category/add", addCategoryHandler.HandleGin)
 	r.GET("/category/type/:type", getCategoriesByTypeHandler.HandleGin)
 	r.GET("/category/all", listAllCategoriesHandler.HandleGin)
+	r.GET("/category/type-rules", categoryRuleHandler.HandleListTypeRules)
+	r.GET("/category/types", categoryTypeHandler.HandleListCategoryTypes)
 
 	// Entity endpoints (public)
 	r.GET("/entity/:entity_id/children", entityHandler.HandleGetEntityChildren)
@@ -176,6 +357,17 @@ func main() {
 		Handler: r,
 	}
 
+	// Export jobs left unfinished by a previous process will never complete
+	if err := exportService.FailStaleJobs(context.Background()); err != nil {
+		log.Printf("Error failing stale export jobs: %v", err)
+	}
+
+	// Purge soft-deleted records once their retention period has passed, and erase
+	// accounts once their deletion grace period has
+	purgeCtx, stopPurger := context.WithCancel(context.Background())
+	go trashService.RunPurger(purgeCtx)
+	go accountService.RunDeletionJob(purgeCtx)
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Server listening on port %d", port)
@@ -190,11 +382,18 @@ func main() {
 	<-quit
 
 	log.Println("Server shutting down...")
+	stopPurger()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	shutdownErr := server.Shutdown(ctx)
+
+	// Export jobs in flight are cancelled and recorded as failed
+	stopExportJobs()
+	exportService.WaitForJobs()
+
+	if shutdownErr != nil {
+		log.Fatalf("Server forced to shutdown: %v", shutdownErr)
 	}
 
 	log.Println("Server exited properly")